@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 追加操作会覆盖原尾部元数据，覆盖前先把原尾部备份到旁路文件。
+// 备份格式：[附加数据结束位置(8字节)] + [原文件大小(8字节)] + [原尾部元数据]
+// 追加中途中断时，下次追加会先用备份把文件恢复到追加前的状态。
+const APPEND_BACKUP_SUFFIX = ".append-backup"
+
+// 追加操作备份文件路径
+func appendBackupPath(mergedPath string) string {
+	return mergedPath + APPEND_BACKUP_SUFFIX
+}
+
+// 备份原尾部元数据并同步到磁盘
+func writeAppendBackup(mergedFile *os.File, backupPath string, dataEnd, fileSize int64) error {
+	trailer := make([]byte, fileSize-dataEnd)
+	if err := readTrailerAt(mergedFile, trailer, dataEnd, nil); err != nil {
+		return newError("append.read_old_metadata_failed", err)
+	}
+
+	backup, err := os.Create(backupPath)
+	if err != nil {
+		return newError("append.create_backup_failed", err)
+	}
+	defer backup.Close()
+
+	header := binary.LittleEndian.AppendUint64(nil, uint64(dataEnd))
+	header = binary.LittleEndian.AppendUint64(header, uint64(fileSize))
+	if _, err := backup.Write(append(header, trailer...)); err != nil {
+		return newError("append.write_backup_failed", err)
+	}
+	if err := backup.Sync(); err != nil {
+		return newError("append.sync_backup_failed", err)
+	}
+
+	return nil
+}
+
+// 用备份恢复中断的追加操作
+func restoreAppendBackup(mergedPath, backupPath string) error {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return newError("append.read_backup_failed", err)
+	}
+	if len(data) < SIZE_LENGTH*2 {
+		return newError("append.backup_incomplete")
+	}
+
+	dataEnd := int64(binary.LittleEndian.Uint64(data[:SIZE_LENGTH]))
+	fileSize := int64(binary.LittleEndian.Uint64(data[SIZE_LENGTH : SIZE_LENGTH*2]))
+	trailer := data[SIZE_LENGTH*2:]
+	if dataEnd+int64(len(trailer)) != fileSize {
+		return newError("append.backup_mismatch")
+	}
+
+	mergedFile, err := os.OpenFile(mergedPath, os.O_WRONLY, 0)
+	if err != nil {
+		return newError("error.open_merged_failed", err)
+	}
+	defer mergedFile.Close()
+
+	if _, err := mergedFile.WriteAt(trailer, dataEnd); err != nil {
+		return newError("append.restore_metadata_failed", err)
+	}
+	if err := mergedFile.Truncate(fileSize); err != nil {
+		return newError("append.restore_size_failed", err)
+	}
+	if err := mergedFile.Sync(); err != nil {
+		return newError("append.sync_merged_failed", err)
+	}
+
+	return os.Remove(backupPath)
+}
+
+// 向已合并的文件追加附加文件，只改写文件尾部
+func appendFiles(mergedPath string, attachPaths []string, opts AppendOptions) error {
+	colorBlue.Println(msg("append.start"))
+	ctx := operationContext()
+
+	if len(attachPaths) == 0 {
+		return newError("error.no_attachments")
+	}
+
+	// 上次追加中断时先恢复
+	backupPath := appendBackupPath(mergedPath)
+	if _, err := os.Stat(backupPath); err == nil {
+		colorYellow.Printf(msg("append.recovering"), backupPath)
+		if err := restoreAppendBackup(mergedPath, backupPath); err != nil {
+			return newError("append.recover_failed", err)
+		}
+		colorGreen.Println(msg("append.recovered"))
+	}
+
+	mergedInfo, err := validateFile(mergedPath)
+	if err != nil {
+		return newError("error.merged_invalid", err)
+	}
+
+	mergedFile, err := os.OpenFile(mergedPath, os.O_RDWR, 0)
+	if err != nil {
+		return newError("error.open_merged_failed", err)
+	}
+	defer mergedFile.Close()
+
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
+	}
+	defer printDebugInfo(debugInfo)
+
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, opts.StealthKey, debugInfo)
+	if err != nil {
+		return err
+	}
+	// ZIP结构记录了唯一条目的位置和注释长度，追加后不再是有效的ZIP文件
+	if trailer.ZipHeader > 0 {
+		return exitErrorf(EXIT_USAGE, "zip.append_unsupported")
+	}
+	// free box 头记录了覆盖到文件末尾的大小，追加后不再是有效的 MP4
+	if trailer.BoxHeader > 0 {
+		return exitErrorf(EXIT_USAGE, "mp4box.append_unsupported")
+	}
+	// Segment 大小和 Void 元素覆盖到文件末尾，追加后不再是有效的 MKV
+	if trailer.MKV != nil {
+		return exitErrorf(EXIT_USAGE, "mkvattach.append_unsupported")
+	}
+	if err := checkRewriteAuth(trailer, opts.AuthKey, debugInfo); err != nil {
+		return err
+	}
+	// 改写后的尾部元数据不带纠错校验块
+	if trailer.FEC > 0 {
+		logWarnf("fec.dropped")
+	}
+
+	// 新附加文件不能与已有附加文件重名
+	usedNames := make(map[string]bool)
+	for _, entry := range trailer.Attachments {
+		usedNames[entry.Name] = true
+	}
+	attachInfos, newEntries, err := prepareAttachments(attachPaths, "", usedNames, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(messageOutput, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+	fmt.Fprintf(messageOutput, msg("append.existing_count"), len(trailer.Attachments))
+	for i, attachInfo := range attachInfos {
+		fmt.Fprintf(messageOutput, "%s: %s → %s (%s)\n", attachLabel(newEntries[i]), attachInfo.Name, newEntries[i].Name, formatFileSize(attachInfo.Size))
+	}
+
+	// 加密文件沿用原有加密参数，需要原密码
+	var aead cipher.AEAD
+	if trailer.Encrypted {
+		aead, err = openEncryptedAttachments(mergedFile, trailer, opts.Password, opts.KeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 重新计算整个附加数据区的校验值（只读取已有附加数据，不读取视频）
+	fmt.Fprintln(messageOutput)
+	colorCyan.Println(msg("append.reading_existing"))
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	existing := io.NewSectionReader(mergedFile, attachStartOf(trailer), int64(trailer.AttachSize))
+	if _, err := copyWithProgress(ctx, nil, existing, int64(trailer.AttachSize), newProgress(msg("append.progress_existing")), attachHash, attachCRC); err != nil {
+		return newError("append.read_existing_failed", err)
+	}
+	if trailer.AttachSHA256 != "" && trailer.AttachSHA256 != hex.EncodeToString(attachHash.Sum(nil)) {
+		return newError("append.existing_sha_mismatch")
+	}
+
+	// 覆盖原尾部前先备份
+	dataEnd := attachStartOf(trailer) + int64(trailer.AttachSize)
+	if err := writeAppendBackup(mergedFile, backupPath, dataEnd, mergedInfo.Size); err != nil {
+		return err
+	}
+
+	// 追加失败时立即用备份恢复原文件
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if err := restoreAppendBackup(mergedPath, backupPath); err != nil {
+			colorRed.Printf(msg("append.rollback_failed"), err, backupPath)
+			return
+		}
+		colorYellow.Println(msg("append.rolled_back"))
+	}()
+
+	// 从原尾部位置开始写入新附加文件
+	if _, err := mergedFile.Seek(dataEnd, io.SeekStart); err != nil {
+		return newError("append.seek_end_failed", err)
+	}
+	writer := attachmentWriter{
+		ctx:       ctx,
+		dst:       io.MultiWriter(mergedFile, attachHash, attachCRC),
+		aead:      aead,
+		encParams: trailer.Encryption,
+		compress:  trailer.Compressed,
+	}
+	offset := dataEnd
+	for i, attachInfo := range attachInfos {
+		index := len(trailer.Attachments) + i
+		newEntries[i].Offset = offset
+		colorCyan.Printf("\n%s (%d/%d): %s\n", attachCopyLabel(attachInfo), i+1, len(attachInfos), newEntries[i].Name)
+		if err := writer.write(attachInfo, &newEntries[i], index); err != nil {
+			return err
+		}
+		offset += int64(newEntries[i].Size)
+	}
+
+	// 写入描述全部附加文件的新尾部
+	colorCyan.Println(msg("common.writing_metadata"))
+	var videoSHA256 []byte
+	if trailer.VideoSHA256 != "" {
+		videoSHA256, _ = hex.DecodeString(trailer.VideoSHA256)
+	}
+	metadata, err := buildTrailer(&trailerSpec{
+		VideoSize:    int64(trailer.VideoSize),
+		Padding:      int64(trailer.Padding),
+		VideoName:    trailer.VideoName,
+		VideoSHA256:  videoSHA256,
+		AttachSHA256: attachHash.Sum(nil),
+		AttachCRC32:  attachCRC.Sum32(),
+		Comment:      trailer.Comment,
+		ToolVersion:  trailer.ToolVersion,
+		CreatedAt:    trailer.CreatedAt,
+		Attachments:  append(trailer.Attachments, newEntries...),
+		Encryption:   trailer.Encryption,
+		Compressed:   trailer.Compressed,
+		AuthKey:      opts.AuthKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	stealth := ""
+	if trailer.Stealth {
+		stealth = opts.StealthKey
+	}
+	if _, err := writeTrailer(mergedFile, metadata, stealth); err != nil {
+		return err
+	}
+
+	// 截断多余数据并落盘后才删除备份
+	newSize, err := mergedFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return newError("append.position_failed", err)
+	}
+	if err := mergedFile.Truncate(newSize); err != nil {
+		return newError("append.truncate_failed", err)
+	}
+	if err := mergedFile.Sync(); err != nil {
+		return newError("append.sync_merged_failed", err)
+	}
+	committed = true
+	if err := os.Remove(backupPath); err != nil {
+		colorYellow.Printf(msg("append.remove_backup_failed"), err)
+	}
+
+	absPath, err := filepath.Abs(mergedPath)
+	if err != nil {
+		absPath = mergedPath
+	}
+
+	colorGreen.Print(msg("append.done"))
+	fmt.Fprint(messageOutput, msg("append.stats"))
+	fmt.Fprintf(messageOutput, msg("append.stats_added"), len(newEntries), formatFileSize(offset-dataEnd))
+	fmt.Fprintf(messageOutput, msg("append.stats_total"), len(trailer.Attachments)+len(newEntries))
+	fmt.Fprintf(messageOutput, msg("common.total_size_change"), formatFileSize(mergedInfo.Size), formatFileSize(newSize))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(mergedPath)
+
+	return nil
+}
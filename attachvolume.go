@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 附加文件分卷：拆分时把附加文件按固定大小写成 name.001、name.002…，
+// 与合并分卷不同，没有分卷头，每个分卷就是原始数据的一段，可直接用 cat 或 join 命令拼接还原：
+//
+//	cat backup.tar.001 backup.tar.002 > backup.tar
+type attachVolume struct {
+	path string
+	size int64
+}
+
+// attachVolumeWriter 把附加文件数据依次写入编号分卷，每个分卷写满后单独同步到磁盘再创建下一个
+type attachVolumeWriter struct {
+	basePath   string
+	volumeSize int64
+	parts      []attachVolume
+	current    *os.File
+}
+
+// 创建第一个分卷（附加文件为空时也生成一个空分卷）
+func createAttachVolumes(basePath string, volumeSize int64) (*attachVolumeWriter, error) {
+	writer := &attachVolumeWriter{basePath: basePath, volumeSize: volumeSize}
+	return writer, writer.nextPart()
+}
+
+func (w *attachVolumeWriter) nextPart() error {
+	if w.current != nil {
+		if err := syncAndClose(w.current); err != nil {
+			return err
+		}
+		w.current = nil
+	}
+
+	path := volumePartPath(w.basePath, len(w.parts))
+	file, err := os.Create(path)
+	if err != nil {
+		return newError("volume.create_failed", path, err)
+	}
+	trackPartialOutput(path)
+	w.current = file
+	w.parts = append(w.parts, attachVolume{path: path})
+	return nil
+}
+
+func (w *attachVolumeWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		part := &w.parts[len(w.parts)-1]
+		if part.size == w.volumeSize {
+			if err := w.nextPart(); err != nil {
+				return written, err
+			}
+			continue
+		}
+		n, err := w.current.Write(p[:min(int64(len(p)), w.volumeSize-part.size)])
+		part.size += int64(n)
+		written += n
+		if err != nil {
+			return written, newError("volume.write_failed", part.path, err)
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// 同步并关闭最后一个分卷
+func (w *attachVolumeWriter) finish() error {
+	if err := syncAndClose(w.current); err != nil {
+		return err
+	}
+	w.current = nil
+	for _, part := range w.parts {
+		finishPartialOutput(part.path)
+	}
+	return nil
+}
+
+// 出错时关闭当前分卷并删除已写入的分卷
+func (w *attachVolumeWriter) abort() {
+	if w.current != nil {
+		w.current.Close()
+		w.current = nil
+	}
+	for _, part := range w.parts {
+		os.Remove(part.path)
+	}
+}
+
+// 大小为 size 的附加文件按 volumeSize 分卷后的各分卷路径（至少一个）
+func attachVolumePaths(basePath string, size, volumeSize int64) []string {
+	count := max(1, (size+volumeSize-1)/volumeSize)
+	paths := make([]string, count)
+	for i := range paths {
+		paths[i] = volumePartPath(basePath, i)
+	}
+	return paths
+}
+
+// 各附加文件的实际输出：写成分卷的附加文件展开为各分卷路径
+func attachOutputFiles(entries []AttachmentEntry, paths []string, volumeSize int64) []string {
+	files := make([]string, 0, len(paths))
+	for i, path := range paths {
+		if volumeSize > 0 && !entries[i].IsDir {
+			files = append(files, attachVolumePaths(path, int64(entries[i].OriginalSize), volumeSize)...)
+		} else {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// 把单个附加文件写成编号分卷，完成后显示分卷清单（各分卷文件名和字节数）
+func extractAttachmentVolumes(ctx context.Context, progress progressSource, reader io.Reader, entry AttachmentEntry, outputPath string, volumeSize int64) error {
+	volumes, err := createAttachVolumes(outputPath, volumeSize)
+	if err != nil {
+		return newError("split.create_attach_failed", err)
+	}
+	copied, err := copyWithProgress(ctx, volumes, reader, int64(entry.OriginalSize), progress.forCopy(msg("progress.attachment")))
+	logDebugf("devlog.copied", outputPath, copied, entry.OriginalSize)
+	if err == nil {
+		err = volumes.finish()
+	}
+	if err != nil {
+		volumes.abort()
+		return newError("split.extract_attach_failed", err)
+	}
+
+	items := make([]string, len(volumes.parts))
+	for i, part := range volumes.parts {
+		items[i] = fmt.Sprintf("%s %d", filepath.Base(part.path), part.size)
+	}
+	fmt.Fprintf(messageOutput, msg("attach_volume.manifest"), len(items), strings.Join(items, ", "))
+	return nil
+}
+
+// 依次计算多个文件拼接后的 SHA-256
+func hashFiles(ctx context.Context, progress progressSource, paths []string, label string) (string, error) {
+	files := make([]io.Reader, 0, len(paths))
+	var total int64
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", exitErrorf(EXIT_IO, "verify_output.open_failed", path, err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return "", exitErrorf(EXIT_IO, "verify_output.open_failed", path, err)
+		}
+		files = append(files, file)
+		total += info.Size()
+	}
+	sum, err := hashReader(ctx, progress, io.MultiReader(files...), total, label)
+	if err != nil {
+		return "", exitErrorf(EXIT_IO, "verify_output.read_failed", paths[0], err)
+	}
+	return sum, nil
+}
+
+// 找出与 path 同组的全部分卷：path 可以是任一分卷（name.002）或去掉编号的文件名，
+// 从 .001 开始依次查找，直到编号中断
+func findAttachVolumes(path string) (string, []string, error) {
+	basePath := path
+	if volumeSuffix.MatchString(path) {
+		basePath = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	var parts []string
+	for i := 0; ; i++ {
+		part := volumePartPath(basePath, i)
+		if info, err := os.Stat(part); err != nil || !info.Mode().IsRegular() {
+			break
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", nil, exitErrorf(EXIT_USAGE, "join.no_parts", volumePartPath(basePath, 0))
+	}
+	return basePath, parts, nil
+}
+
+// 把编号分卷按顺序拼接成一个文件，输出默认为去掉编号的文件名
+func joinVolumes(ctx context.Context, path, outputPath string) error {
+	colorBlue.Println(msg("join.start"))
+
+	basePath, parts, err := findAttachVolumes(path)
+	if err != nil {
+		return err
+	}
+	if outputPath == "" {
+		outputPath = basePath
+	}
+	if err := checkOutputsNotInputs([]string{outputPath}, parts); err != nil {
+		return err
+	}
+
+	var total int64
+	sizes := make([]int64, len(parts))
+	for i, part := range parts {
+		info, err := os.Stat(part)
+		if err != nil {
+			return exitErrorf(EXIT_IO, "join.open_failed", part, err)
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+		fmt.Fprintf(messageOutput, msg("volume.layout"), i+1, filepath.Base(part), formatFileSize(sizes[i]))
+	}
+	// 除最后一个外各分卷大小应相同，不同时可能缺了中间的分卷或混入了其他文件
+	for i := 1; i < len(parts)-1; i++ {
+		if sizes[i] != sizes[0] {
+			logWarnf("join.size_mismatch", filepath.Base(parts[i]), sizes[i], sizes[0])
+		}
+	}
+
+	if _, err := os.Stat(outputPath); err == nil {
+		colorYellow.Printf(msg("merge.output_exists"), outputPath)
+		if err := confirmOverwrite(outputPath, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+	if err := checkFreeSpace([]planOutput{{Label: msg("join.output"), Path: outputPath, Size: total}}, false); err != nil {
+		return err
+	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return exitErrorf(EXIT_IO, "error.create_output_failed", err)
+	}
+	defer outputFile.Close()
+	trackPartialOutput(outputPath)
+
+	for i, part := range parts {
+		file, err := os.Open(part)
+		if err != nil {
+			return exitErrorf(EXIT_IO, "join.open_failed", part, err)
+		}
+		logInfof(colorCyan, "join.copying", i+1, len(parts), filepath.Base(part))
+		_, err = copyWithProgress(ctx, outputFile, file, sizes[i], newProgress(msg("progress.join")))
+		file.Close()
+		if err != nil {
+			return exitErrorf(copyExitCode(err), "join.copy_failed", part, err)
+		}
+	}
+	if err := syncAndClose(outputFile); err != nil {
+		return withExitCode(EXIT_IO, err)
+	}
+	finishPartialOutput(outputPath)
+
+	absPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		absPath = outputPath
+	}
+	colorGreen.Print(msg("join.done"))
+	fmt.Fprintf(messageOutput, msg("join.stats"), len(parts), formatFileSize(total))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(outputPath)
+	return nil
+}
+
+// 拼接分卷命令
+var joinCmd = &cobra.Command{
+	Use:   "join <part> [output]",
+	Short: helpText("help.join.short"),
+	Long:  helpText("help.join.long"),
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var outputPath string
+		if len(args) > 1 {
+			outputPath = args[1]
+		}
+		return joinVolumes(operationContext(), args[0], outputPath)
+	},
+}
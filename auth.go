@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cancundeyingzi/Video-File-Merge-Split-Tool/pkg/mergefmt"
+)
+
+// 尾部元数据认证：合并时指定 --auth-key 在扩展块末尾写入 HMAC-SHA256（格式见 mergefmt.ComputeAuth），
+// 认证值覆盖全部元数据字段和视频、附加数据的 SHA-256。拆分和校验时指定同一密钥，
+// 即可发现附加文件被改动或尾部元数据被整体替换；不加密，任何人仍可读取数据
+
+// 校验尾部元数据的认证值。未指定密钥时不校验（文件带认证值时提示一句）；
+// 指定了密钥而认证值缺失或不符时报错，ignore 为 true 时只警告并继续（取证恢复）
+func checkTrailerAuth(trailer *TrailerInfo, authKey string, ignore bool, debugInfo *DebugInfo) error {
+	if authKey == "" {
+		if trailer.Authenticated {
+			fmt.Fprint(messageOutput, msg("auth.not_checked"))
+		}
+		return nil
+	}
+
+	err := mergefmt.VerifyAuth(trailer.metadata, []byte(authKey))
+	if err == nil {
+		fmt.Fprint(messageOutput, msg("auth.ok"))
+		return nil
+	}
+	id := "auth.mismatch"
+	if errors.Is(err, mergefmt.ErrAuthMissing) {
+		id = "auth.missing"
+	}
+	return authFailure(id, ignore, debugInfo)
+}
+
+// 认证失败：默认中止，--ignore-auth 时只警告
+func authFailure(id string, ignore bool, debugInfo *DebugInfo) error {
+	debugInfo.ValidationError = msg(id)
+	if ignore {
+		logWarnf("auth.ignored", msg(id))
+		return nil
+	}
+	return exitErrorf(EXIT_INVALID_FORMAT, "auth.failed", msg(id))
+}
+
+// append/update 重写尾部元数据前检查认证：原文件带认证值时必须提供密钥并先通过校验，
+// 新尾部用同一密钥重新计算，不会悄悄去掉认证
+func checkRewriteAuth(trailer *TrailerInfo, authKey string, debugInfo *DebugInfo) error {
+	if !trailer.Authenticated {
+		return nil
+	}
+	if authKey == "" {
+		return exitErrorf(EXIT_USAGE, "auth.rewrite_needs_key")
+	}
+	return checkTrailerAuth(trailer, authKey, false, debugInfo)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 批量处理中单个文件的结果
+type batchItem struct {
+	Input  string
+	Output string
+	// 跳过原因（非空表示未处理）
+	Skipped string
+	Err     error
+}
+
+// 批量合并输出文件名：<附加文件名>_in_<视频文件名>
+func batchMergeOutputName(attachName, videoName string, used map[string]bool) string {
+	attachBase := strings.TrimSuffix(attachName, filepath.Ext(attachName))
+	return uniqueAttachName(fmt.Sprintf("%s_in_%s", attachBase, videoName), used)
+}
+
+// 用同一个视频分别隐藏目录中的每个文件，单个失败不影响其余文件
+func mergeBatch(videoPath, attachDir, outputDir string, opts MergeOptions) error {
+	colorBlue.Println(msg("batch.merge_start"))
+
+	videoInfo, err := validateFile(videoPath)
+	if err != nil {
+		return newError("error.video_invalid", err)
+	}
+
+	dirEntries, err := os.ReadDir(attachDir)
+	if err != nil {
+		return newError("batch.read_attach_dir_failed", err)
+	}
+
+	var attachPaths []string
+	for _, entry := range dirEntries {
+		if !entry.Type().IsRegular() {
+			colorYellow.Printf(msg("common.skip_irregular"), entry.Name())
+			continue
+		}
+		attachPaths = append(attachPaths, filepath.Join(attachDir, entry.Name()))
+	}
+	if len(attachPaths) == 0 {
+		return newError("batch.no_files", attachDir)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return newError("error.create_output_dir_failed", err)
+	}
+
+	fmt.Fprintf(messageOutput, msg("common.video_file_line"), videoInfo.Name, formatFileSize(videoInfo.Size))
+	fmt.Fprintf(messageOutput, msg("batch.attach_count"), len(attachPaths))
+	fmt.Fprintf(messageOutput, msg("batch.output_dir"), outputDir)
+
+	usedNames := make(map[string]bool)
+	items := make([]batchItem, len(attachPaths))
+	for i, attachPath := range attachPaths {
+		outputName := batchMergeOutputName(filepath.Base(attachPath), videoInfo.Name, usedNames)
+		items[i] = batchItem{Input: attachPath, Output: filepath.Join(outputDir, outputName)}
+
+		colorMagenta.Printf("\n━━━ [%d/%d] %s ━━━\n", i+1, len(attachPaths), filepath.Base(attachPath))
+		items[i].setResult(mergeFiles(operationContext(), videoPath, []string{attachPath}, items[i].Output, opts))
+		if items[i].Err != nil {
+			colorRed.Printf(msg("batch.merge_failed"), items[i].Err)
+		}
+	}
+
+	return printBatchSummary(msg("batch.op_merge"), items)
+}
+
+// 记录单个文件的处理结果；输出已存在且用户选择不覆盖时记为跳过，不计入失败
+func (item *batchItem) setResult(err error) {
+	var exists *ErrOutputExists
+	if errors.As(err, &exists) && errors.Is(err, ErrCancelled) {
+		item.Skipped = msgf("batch.output_kept", exists.Path)
+		colorYellow.Println(msg("batch.skip_output_exists"))
+		return
+	}
+	item.Err = err
+}
+
+// 输出批量处理汇总，有失败时返回错误
+func printBatchSummary(operation string, items []batchItem) error {
+	var failed, skipped int
+	for _, item := range items {
+		if item.Err != nil {
+			failed++
+		} else if item.Skipped != "" {
+			skipped++
+		}
+	}
+
+	colorBlue.Printf(msg("batch.summary"), operation, len(items)-failed-skipped, skipped, failed)
+	for _, item := range items {
+		if item.Err == nil && item.Skipped == "" {
+			colorGreen.Printf("   ✅ %s → %s\n", filepath.Base(item.Input), item.Output)
+		}
+	}
+	for _, item := range items {
+		if item.Err == nil && item.Skipped != "" {
+			colorYellow.Printf("   ⏭️ %s: %s\n", filepath.Base(item.Input), item.Skipped)
+		}
+	}
+	for _, item := range items {
+		if item.Err != nil {
+			colorRed.Printf("   ❌ %s: %v\n", filepath.Base(item.Input), item.Err)
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+	// 所有失败的退出码相同时（如都缺少密码）沿用该退出码，否则为其他错误
+	code := -1
+	for _, item := range items {
+		if item.Err == nil {
+			continue
+		}
+		if itemCode := exitCodeOf(item.Err); code == -1 {
+			code = itemCode
+		} else if itemCode != code {
+			code = EXIT_FAILURE
+			break
+		}
+	}
+	return withExitCode(code, newError("batch.failed", operation, failed))
+}
+
+// 是否包含通配符（http(s) 地址中的 ? 是查询参数，不作通配符）
+func hasGlobMeta(path string) bool {
+	return !isRemotePath(path) && strings.ContainsAny(path, "*?[")
+}
+
+// 展开通配符并去重（Windows 命令行不会展开通配符）
+func expandInputPaths(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if hasGlobMeta(pattern) {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, newError("batch.bad_pattern", pattern, err)
+			}
+			if len(matches) == 0 {
+				colorYellow.Printf(msg("batch.no_match"), pattern)
+			}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// 输出路径已存在或已被占用时加序号
+func uniqueOutputPath(path string, reserved map[string]bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 2; ; i++ {
+		if _, err := os.Stat(candidate); err != nil && !reserved[candidate] {
+			break
+		}
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	reserved[candidate] = true
+	return candidate
+}
+
+// 依次拆分多个合并文件，跳过普通文件，同名输出自动加序号
+func splitBatch(patterns []string, outputDir string, opts SplitOptions) error {
+	if opts.VideoOut != "" || len(opts.AttachOut) > 0 {
+		return newError("batch.split_no_out_paths")
+	}
+	if jsonOutput {
+		return newError("batch.split_no_json")
+	}
+
+	inputs, err := expandInputPaths(patterns)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return newError("batch.split_nothing")
+	}
+
+	if outputDir == "" {
+		colorBlue.Printf(msg("batch.split_start_default"), len(inputs))
+	} else {
+		colorBlue.Printf(msg("batch.split_start"), len(inputs), outputDir)
+	}
+
+	opts.AutoRename = true
+	items := make([]batchItem, len(inputs))
+	for i, input := range inputs {
+		items[i].Input = input
+		colorMagenta.Printf("\n━━━ [%d/%d] %s ━━━\n", i+1, len(inputs), filepath.Base(input))
+
+		switch code, _ := detectMergedFile(input, opts.StealthKey, false); code {
+		case DETECT_EXIT_PLAIN:
+			items[i].Skipped = msg("batch.not_merged")
+			colorYellow.Println(msg("batch.skip_not_merged"))
+			continue
+		case DETECT_EXIT_UNREADABLE:
+			items[i].Err = newError("batch.unreadable")
+			colorRed.Println(msg("batch.unreadable_line"))
+			continue
+		}
+
+		itemOpts := opts
+		itemOpts.Result = &OperationResult{}
+		// 未指定输出目录时每个文件拆分到各自的 extracted_<文件名>
+		itemDir := outputDir
+		if itemDir == "" {
+			itemDir = defaultSplitOutputDir(input)
+		}
+		items[i].setResult(splitFiles(operationContext(), input, itemDir, itemOpts))
+		if items[i].Err != nil {
+			colorRed.Printf(msg("batch.split_failed"), items[i].Err)
+			continue
+		}
+		if items[i].Skipped != "" {
+			continue
+		}
+		names := make([]string, len(itemOpts.Result.Outputs))
+		for j, output := range itemOpts.Result.Outputs {
+			names[j] = filepath.Base(output)
+		}
+		items[i].Output = strings.Join(names, ", ")
+	}
+
+	return printBatchSummary(msg("batch.op_split"), items)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 文件浏览器：无法拖入文件时（如 tmux、SSH 中），在路径提示处输入 b 逐级浏览目录选择文件
+const (
+	// 每页显示的条目数
+	BROWSER_PAGE_SIZE = 30
+)
+
+var (
+	// 上次浏览到的目录，下次打开浏览器时从这里开始
+	browserDir string
+	// 是否显示隐藏文件（以 . 开头）
+	browserShowHidden = false
+)
+
+// browserEntry 浏览器中的一个条目，符号链接按指向的目标区分目录和文件
+type browserEntry struct {
+	Name  string
+	IsDir bool
+	Size  int64
+}
+
+// 是否为打开浏览器的输入
+func isBrowseCommand(input string) bool {
+	switch strings.ToLower(input) {
+	case "b", "browse", "浏览":
+		return true
+	}
+	return false
+}
+
+// 浏览目录选择一个文件；返回 false 表示取消
+func browseFiles() (string, bool) {
+	dir := browserDir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	page := 0
+	for {
+		entries, err := readBrowserEntries(dir)
+		if err != nil {
+			colorRed.Printf(msg("browser.read_failed"), dir, err)
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return "", false
+			}
+			dir, page = parent, 0
+			continue
+		}
+		browserDir = dir
+
+		pages := (len(entries) + BROWSER_PAGE_SIZE - 1) / BROWSER_PAGE_SIZE
+		if pages == 0 {
+			pages = 1
+		}
+		page = min(max(page, 0), pages-1)
+		printBrowserPage(dir, entries, page, pages)
+
+		input := readUserInput(msg("browser.prompt"))
+		switch strings.ToLower(input) {
+		case "":
+			continue
+		case "q", "quit", "exit":
+			return "", false
+		case "n":
+			if page+1 >= pages {
+				colorYellow.Println(msg("browser.last_page"))
+			}
+			page++
+			continue
+		case "p":
+			if page == 0 {
+				colorYellow.Println(msg("browser.first_page"))
+			}
+			page--
+			continue
+		case "h":
+			browserShowHidden = !browserShowHidden
+			page = 0
+			continue
+		case "0", "..":
+			dir, page = filepath.Dir(dir), 0
+			continue
+		}
+
+		// 序号在所有条目中连续编号，也可输入名称或路径（相对当前目录）
+		var target string
+		if index, err := strconv.Atoi(input); err == nil {
+			if index < 1 || index > len(entries) {
+				colorYellow.Printf(msg("browser.bad_index"), input, len(entries))
+				continue
+			}
+			target = filepath.Join(dir, entries[index-1].Name)
+		} else {
+			target = parseDroppedPath(input)
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+		}
+
+		info, err := os.Stat(target)
+		if err != nil {
+			colorYellow.Printf(msg("browser.not_found"), input)
+			continue
+		}
+		if info.IsDir() {
+			dir, page = target, 0
+			continue
+		}
+		return target, true
+	}
+}
+
+// 读取目录条目：目录在前，各自按名称排序（不区分大小写），按设置隐藏以 . 开头的条目
+func readBrowserEntries(dir string) ([]browserEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]browserEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if strings.HasPrefix(name, ".") && !browserShowHidden {
+			continue
+		}
+		entry := browserEntry{Name: name, IsDir: dirEntry.IsDir()}
+		// 符号链接跟随到目标，无法访问的条目按文件显示
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			entry.IsDir = info.IsDir()
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+	return entries, nil
+}
+
+// 显示一页条目，序号在所有页中连续
+func printBrowserPage(dir string, entries []browserEntry, page, pages int) {
+	fmt.Fprintln(messageOutput)
+	colorMagenta.Printf(msg("browser.title"), dir, len(entries), page+1, pages)
+	fmt.Fprintln(messageOutput, msg("browser.parent"))
+	if len(entries) == 0 {
+		colorYellow.Println(msg("browser.empty"))
+	}
+	end := min((page+1)*BROWSER_PAGE_SIZE, len(entries))
+	for i := page * BROWSER_PAGE_SIZE; i < end; i++ {
+		entry := entries[i]
+		if entry.IsDir {
+			colorCyan.Printf("   %3d. 📁 %s%c\n", i+1, entry.Name, filepath.Separator)
+		} else {
+			fmt.Fprintf(messageOutput, "   %3d. 📄 %s (%s)\n", i+1, entry.Name, formatFileSize(entry.Size))
+		}
+	}
+	hidden := msg("browser.hidden_off")
+	if browserShowHidden {
+		hidden = msg("browser.hidden_on")
+	}
+	fmt.Fprintf(messageOutput, msg("browser.help"), hidden)
+}
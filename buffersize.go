@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 读写缓冲区大小下限 (4KB)
+	MIN_BUFFER_SIZE = 4 * 1024
+	// 读写缓冲区大小上限 (256MB)
+	MAX_BUFFER_SIZE = 256 * 1024 * 1024
+)
+
+var (
+	// --buffer-size 指定的缓冲区大小
+	bufferSizeFlag = ""
+	// 实际使用的读写缓冲区大小
+	bufferSize int64 = BUFFER_SIZE
+)
+
+// 解析带单位的字节数，如 512K、4M、1G、4MiB，不带单位时为字节
+func parseByteSize(value string) (int64, bool) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "IB"), "B")
+	multiplier := int64(1)
+	if text != "" {
+		if index := strings.IndexByte("KMG", text[len(text)-1]); index >= 0 {
+			multiplier = int64(1) << (10 * (index + 1))
+			text = text[:len(text)-1]
+		}
+	}
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	// 乘以单位后超出 int64 时拒绝；取值范围由调用方检查
+	if number > math.MaxInt64/multiplier {
+		return 0, false
+	}
+	return number * multiplier, true
+}
+
+// 按 --buffer-size 设置读写缓冲区大小，未指定时保持默认 1MB
+func configureBufferSize() error {
+	if bufferSizeFlag == "" {
+		bufferSize = BUFFER_SIZE
+		return nil
+	}
+	size, ok := parseByteSize(bufferSizeFlag)
+	if !ok {
+		return exitErrorf(EXIT_USAGE, "buffer.invalid", bufferSizeFlag)
+	}
+	if size < MIN_BUFFER_SIZE || size > MAX_BUFFER_SIZE {
+		return exitErrorf(EXIT_USAGE, "buffer.out_of_range", bufferSizeFlag, formatFileSize(MIN_BUFFER_SIZE), formatFileSize(MAX_BUFFER_SIZE))
+	}
+	bufferSize = size
+	return nil
+}
+
+// tailReader 一次读入文件末尾一个缓冲区的数据，解析元数据时的多次小读取直接从内存返回
+type tailReader struct {
+	file   io.ReaderAt
+	offset int64
+	tail   []byte
+}
+
+func newTailReader(file io.ReaderAt, fileSize int64) *tailReader {
+	// 远程文件打开时已缓存末尾，不再按缓冲区大小额外请求
+	if _, ok := file.(*remoteFile); ok {
+		return &tailReader{file: file}
+	}
+	size := bufferSize
+	if size > fileSize {
+		size = fileSize
+	}
+	reader := &tailReader{file: file, offset: fileSize - size}
+	tail := make([]byte, size)
+	// 读取失败时不缓存，之后按原方式逐次读取
+	if n, err := file.ReadAt(tail, reader.offset); err == nil || (err == io.EOF && int64(n) == size) {
+		reader.tail = tail
+	}
+	return reader
+}
+
+func (r *tailReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.tail != nil && off >= r.offset && off+int64(len(p)) <= r.offset+int64(len(r.tail)) {
+		return copy(p, r.tail[off-r.offset:]), nil
+	}
+	return r.file.ReadAt(p, off)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"4096", 4096, true},
+		{"512K", 512 * 1024, true},
+		{"4m", 4 * 1024 * 1024, true},
+		{" 1G ", 1 << 30, true},
+		{"4MiB", 4 * 1024 * 1024, true},
+		{"64MB", 64 * 1024 * 1024, true},
+		// 超出缓冲区上限的值照常乘以单位，范围由调用方检查
+		{"300000000K", 300000000 * 1024, true},
+		{"8589934591G", 8589934591 << 30, true},
+		{strconv.FormatInt(math.MaxInt64, 10), math.MaxInt64, true},
+		// 乘以单位后溢出
+		{"8589934592G", 0, false},
+		{"9007199254740992M", 0, false},
+		{"", 0, false},
+		{"0", 0, false},
+		{"-1M", 0, false},
+		{"1.5G", 0, false},
+		{"G", 0, false},
+		{"12T", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseByteSize(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConfigureBufferSizeRange(t *testing.T) {
+	defer func(flag string) { bufferSizeFlag = flag; configureBufferSize() }(bufferSizeFlag)
+
+	for _, value := range []string{"300000000K", "8589934592G", "1K"} {
+		bufferSizeFlag = value
+		if err := configureBufferSize(); exitCodeOf(err) != EXIT_USAGE {
+			t.Errorf("--buffer-size %s: error = %v, want a usage error", value, err)
+		}
+	}
+	bufferSizeFlag = "8M"
+	if err := configureBufferSize(); err != nil || bufferSize != 8*1024*1024 {
+		t.Errorf("--buffer-size 8M: bufferSize = %d, error = %v", bufferSize, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// 载体类型：附加数据写在载体文件之后，视频和图片末尾的多余数据都会被播放器、看图软件忽略
+const (
+	CARRIER_NONE = iota
+	CARRIER_IMAGE
+	CARRIER_VIDEO
+)
+
+// 无法从开头字节识别载体类型时拆分出的载体扩展名
+const CARRIER_UNKNOWN_EXT = ".bin"
+
+// 嗅探载体类型读取的字节数（MPEG-TS 需要看到第二个包的同步字节）
+const CARRIER_SNIFF_LENGTH = 189
+
+var (
+	videoCarrierExts = []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".webm", ".m4v", ".flv", ".ts"}
+	imageCarrierExts = []string{".png", ".jpg", ".jpeg", ".gif"}
+)
+
+// 按扩展名判断载体类型
+func carrierKindOf(filePath string) int {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, videoExt := range videoCarrierExts {
+		if ext == videoExt {
+			return CARRIER_VIDEO
+		}
+	}
+	for _, imageExt := range imageCarrierExts {
+		if ext == imageExt {
+			return CARRIER_IMAGE
+		}
+	}
+	return CARRIER_NONE
+}
+
+// 按扩展名判断是否可作为载体（视频或图片）
+func isCarrierFile(filePath string) bool {
+	return carrierKindOf(filePath) != CARRIER_NONE
+}
+
+// 按开头字节嗅探载体的真实扩展名，无法识别时返回空
+func sniffCarrierExt(r io.ReaderAt) string {
+	header := make([]byte, CARRIER_SNIFF_LENGTH)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png"
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return ".jpg"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return ".gif"
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		// QuickTime 的主品牌为 "qt  "，其余 ISO 媒体按 MP4 处理
+		if string(header[8:12]) == "qt  " {
+			return ".mov"
+		}
+		return ".mp4"
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(header, []byte("webm")) {
+			return ".webm"
+		}
+		return ".mkv"
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return ".avi"
+	case bytes.HasPrefix(header, []byte("FLV")):
+		return ".flv"
+	case bytes.HasPrefix(header, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}):
+		return ".wmv"
+	case len(header) > 188 && header[0] == 0x47 && header[188] == 0x47:
+		return ".ts"
+	}
+	return ""
+}
+
+// 常见的图片处理（压缩优化、社交平台和图床重新编码）会丢弃图片结束标记之后的数据，
+// 图片载体合并时提示
+func warnStrippedCarrier(carrierPath string) {
+	if carrierKindOf(carrierPath) == CARRIER_IMAGE {
+		logWarnf("carrier.image_stripped", filepath.Base(carrierPath))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 补全时优先提示的载体（视频、图片）扩展名
+var completionCarrierExts = []string{"mp4", "mkv", "avi", "mov", "wmv", "webm", "flv", "png", "jpg", "jpeg", "gif"}
+
+// 补全脚本生成命令
+var completionCmd = &cobra.Command{
+	Use:                   "completion [bash|zsh|fish|powershell]",
+	Short:                 helpText("help.completion.short"),
+	Long:                  helpText("help.completion.long"),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := cmd.Root()
+		switch args[0] {
+		case "bash":
+			return root.GenBashCompletionV2(resultOutput, true)
+		case "zsh":
+			return root.GenZshCompletion(resultOutput)
+		case "fish":
+			return root.GenFishCompletion(resultOutput, true)
+		case "powershell":
+			return root.GenPowerShellCompletionWithDesc(resultOutput)
+		}
+		return newError("completion.unsupported_shell", args[0])
+	},
+}
+
+// 是否为载体扩展名
+func hasCarrierExt(name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	for _, carrierExt := range completionCarrierExts {
+		if ext == carrierExt {
+			return true
+		}
+	}
+	return false
+}
+
+// 列出可能是合并文件的候选：视频扩展名且不小于最小合并文件大小，目录照常列出以便继续深入
+func completeContainerFiles(toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir, prefix := filepath.Split(toComplete)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var candidates []string
+	hasDir := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// 未明确输入点号时不提示隐藏文件
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			candidates = append(candidates, dir+name+string(filepath.Separator))
+			hasDir = true
+			continue
+		}
+		if !entry.Type().IsRegular() || !hasCarrierExt(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() < MIN_V3_FILE_SIZE {
+			continue
+		}
+		candidates = append(candidates, dir+name)
+	}
+
+	directive := cobra.ShellCompDirectiveNoFileComp
+	if hasDir {
+		// 目录补全后不加空格，方便继续输入
+		directive |= cobra.ShellCompDirectiveNoSpace
+	}
+	return candidates, directive
+}
+
+// split 只补全可能是合并文件的路径
+func splitArgsCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeContainerFiles(toComplete)
+}
+
+// merge 第一个参数优先补全视频、图片等载体文件，其余参数为任意文件
+func mergeArgsCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completionCarrierExts, cobra.ShellCompDirectiveFilterFileExt
+	}
+	return nil, cobra.ShellCompDirectiveDefault
+}
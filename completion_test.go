@@ -0,0 +1,16 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 补全候选写到标准输出，shell 补全脚本从标准输出读取
+func TestCompletionWritesStdout(t *testing.T) {
+	for _, args := range [][]string{{"__complete", "sp"}, {"--quiet", "__complete", "sp"}} {
+		code, stdout, stderr := runMain(t, args...)
+		if code != EXIT_OK || !strings.HasPrefix(stdout, "split\t") {
+			t.Errorf("%v: exit %d, stdout %q, stderr %q", args, code, stdout, stderr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// 附加文件压缩：每个附加文件单独压缩为一个 gzip 流，先压缩后加密。
+// 扩展块记录压缩算法和每个附加文件的原始大小，拆分时据此解压和显示进度。
+const (
+	// 压缩算法：gzip
+	COMPRESS_GZIP = 1
+)
+
+// 编码压缩记录：[算法(1字节)] + [数量(4字节)] + 每个 [原始大小(8字节)]
+func encodeCompression(algorithm uint8, entries []AttachmentEntry) []byte {
+	buf := []byte{algorithm}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entries)))
+	for _, entry := range entries {
+		buf = binary.LittleEndian.AppendUint64(buf, entry.OriginalSize)
+	}
+	return buf
+}
+
+// 解析压缩记录，返回算法和原始大小列表
+func decodeCompression(value []byte) (uint8, []uint64, error) {
+	if len(value) < 1+UINT32_LENGTH {
+		return 0, nil, newError("compress.record_incomplete")
+	}
+
+	algorithm := value[0]
+	if algorithm != COMPRESS_GZIP {
+		return 0, nil, newError("compress.unsupported", algorithm)
+	}
+
+	count := binary.LittleEndian.Uint32(value[1 : 1+UINT32_LENGTH])
+	if uint64(len(value)) != uint64(1+UINT32_LENGTH)+uint64(count)*SIZE_LENGTH {
+		return 0, nil, newError("compress.record_length", len(value))
+	}
+
+	sizes := make([]uint64, count)
+	pos := 1 + UINT32_LENGTH
+	for i := range sizes {
+		sizes[i] = binary.LittleEndian.Uint64(value[pos : pos+SIZE_LENGTH])
+		pos += SIZE_LENGTH
+	}
+
+	return algorithm, sizes, nil
+}
+
+// 压缩算法名称
+func compressionName(algorithm uint8) string {
+	switch algorithm {
+	case COMPRESS_GZIP:
+		return "gzip"
+	default:
+		return msgf("compress.unknown", algorithm)
+	}
+}
+
+// 创建压缩写入器，Close 时写出压缩流结尾
+func newCompressWriter(dst io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(dst, gzip.BestCompression)
+}
+
+// 创建解压读取器
+func newDecompressReader(src io.Reader) (io.Reader, error) {
+	reader, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, newError("compress.decompress_failed", err)
+	}
+	return reader, nil
+}
+
+// 压缩率（压缩后占原始大小的百分比）
+func compressionRatio(original, stored int64) float64 {
+	if original == 0 {
+		return 100
+	}
+	return float64(stored) * 100 / float64(original)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 配置文件：启动时读取常用选项的默认值，命令行明确指定的选项优先。
+// 格式为 YAML 的简单子集：每行一个 key: value，# 开头为注释，值可加引号
+const (
+	CONFIG_DIR_NAME  = "video-merger"
+	CONFIG_FILE_NAME = "config.yaml"
+	// 指定配置文件路径的环境变量（--config 优先）
+	CONFIG_ENV = "VIDEO_MERGER_CONFIG"
+
+	CONFIG_SOURCE_DEFAULT = "default"
+	CONFIG_SOURCE_FILE    = "config"
+	CONFIG_SOURCE_FLAG    = "flag"
+)
+
+// 配置文件支持的键，顺序即 config 命令的显示顺序
+var configKeys = []string{"output_dir", "buffer_size", "color", "lang", "overwrite", "dev"}
+
+// 各个键对应的命令行选项，选项被明确指定时忽略配置文件中的值
+var configKeyFlags = map[string]string{
+	"buffer_size": "buffer-size",
+	"color":       "no-color",
+	"lang":        "lang",
+	"overwrite":   "force",
+	"dev":         "dev",
+}
+
+var (
+	configPathFlag string
+	// 实际读取的配置文件（不存在时为空）
+	loadedConfigPath string
+	// 各个键生效值的来源
+	configSources = map[string]string{}
+
+	// 拆分的默认输出目录、交互式合并的默认输出文件放在此目录下（空为当前目录）
+	defaultOutputDir string
+)
+
+// 配置文件路径：--config、环境变量，否则为用户配置目录下的 video-merger/config.yaml。
+// explicit 为 true 时文件必须存在
+func configFilePath() (path string, explicit bool) {
+	if configPathFlag != "" {
+		return configPathFlag, true
+	}
+	if path := os.Getenv(CONFIG_ENV); path != "" {
+		return path, true
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, CONFIG_DIR_NAME, CONFIG_FILE_NAME), false
+}
+
+// configEntry 配置文件中的一项，记下行号用于报错
+type configEntry struct {
+	Value string
+	Line  int
+}
+
+// 读取并应用配置文件；默认位置没有配置文件时不做任何事
+func loadConfig(cmd *cobra.Command) error {
+	for _, key := range configKeys {
+		configSources[key] = CONFIG_SOURCE_DEFAULT
+		if flag, ok := configKeyFlags[key]; ok && cmd.Flags().Changed(flag) {
+			configSources[key] = CONFIG_SOURCE_FLAG
+		}
+	}
+	path, explicit := configFilePath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return exitErrorf(EXIT_USAGE, "config.read_failed", path, err)
+	}
+	entries, err := parseConfig(data)
+	if err != nil {
+		return exitErrorf(EXIT_USAGE, "config.invalid", path, err)
+	}
+	loadedConfigPath = path
+	return applyConfig(path, entries)
+}
+
+// 解析配置文件，只检查格式；错误中带有行号
+func parseConfig(data []byte) (map[string]configEntry, error) {
+	entries := make(map[string]configEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if line != strings.TrimLeft(line, " \t") {
+			return nil, newError("config.line_nested", lineNumber)
+		}
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, newError("config.line_no_colon", lineNumber, line)
+		}
+		if !isConfigKey(key) {
+			return nil, newError("config.line_unknown_key", lineNumber, key, strings.Join(configKeys, ", "))
+		}
+		if previous, ok := entries[key]; ok {
+			return nil, newError("config.line_duplicate", lineNumber, key, previous.Line)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, newError("config.line_bad_quote", lineNumber, err)
+		}
+		entries[key] = configEntry{Value: value, Line: lineNumber}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func isConfigKey(key string) bool {
+	for _, known := range configKeys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+// 去掉值两边的引号和未加引号的值后面的注释
+func parseConfigValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := strings.LastIndex(value, `"`)
+		if end == 0 || !isConfigComment(value[end+1:]) {
+			return "", errors.New(value)
+		}
+		return strconv.Unquote(value[:end+1])
+	case strings.HasPrefix(value, `'`):
+		end := strings.LastIndex(value, `'`)
+		if end == 0 || !isConfigComment(value[end+1:]) {
+			return "", errors.New(value)
+		}
+		// YAML 单引号字符串中的单引号写作两个单引号
+		return strings.ReplaceAll(value[1:end], "''", "'"), nil
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = value[:index]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// 引号之后只能是空白或注释
+func isConfigComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// 检查各项的值，把未被命令行选项覆盖的项写入对应的设置
+func applyConfig(path string, entries map[string]configEntry) error {
+	for _, key := range configKeys {
+		entry, ok := entries[key]
+		if !ok {
+			continue
+		}
+		badValue := func(expected string) error {
+			return exitErrorf(EXIT_USAGE, "config.bad_value", path, entry.Line, key, entry.Value, expected)
+		}
+
+		// 先检查值，命令行选项覆盖时配置文件中的错误同样报告
+		var enabled bool
+		switch key {
+		case "output_dir":
+			if entry.Value == "" {
+				return badValue(msg("config.expect_dir"))
+			}
+		case "buffer_size":
+			size, ok := parseByteSize(entry.Value)
+			if !ok || size < MIN_BUFFER_SIZE || size > MAX_BUFFER_SIZE {
+				return badValue(msgf("config.expect_size", formatByteSize(MIN_BUFFER_SIZE), formatByteSize(MAX_BUFFER_SIZE)))
+			}
+		case "color":
+			if entry.Value != "auto" && entry.Value != "never" {
+				return badValue("auto, never")
+			}
+		case "lang":
+			if _, ok := parseLanguage(entry.Value); !ok {
+				return badValue("zh, en")
+			}
+		case "overwrite":
+			if entry.Value != "ask" && entry.Value != "force" {
+				return badValue("ask, force")
+			}
+		case "dev":
+			value, ok := parseConfigBool(entry.Value)
+			if !ok {
+				return badValue("true, false")
+			}
+			enabled = value
+		}
+
+		if configSources[key] == CONFIG_SOURCE_FLAG {
+			continue
+		}
+		configSources[key] = CONFIG_SOURCE_FILE
+		switch key {
+		case "output_dir":
+			defaultOutputDir = expandHomeDir(entry.Value)
+		case "buffer_size":
+			bufferSizeFlag = entry.Value
+		case "color":
+			noColor = entry.Value == "never"
+		case "lang":
+			langFlag = entry.Value
+		case "overwrite":
+			forceOverwrite = entry.Value == "force"
+		case "dev":
+			devMode = enabled
+		}
+	}
+	return nil
+}
+
+func parseConfigBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// 展开开头的 ~（配置文件不经过 shell）
+func expandHomeDir(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// 默认输出放在配置的输出目录下
+func withDefaultOutputDir(name string) string {
+	if defaultOutputDir == "" {
+		return name
+	}
+	return filepath.Join(defaultOutputDir, name)
+}
+
+// 显示生效的配置和每项的来源
+func printEffectiveConfig() {
+	path, _ := configFilePath()
+	if loadedConfigPath != "" {
+		fmt.Fprintf(resultOutput, msg("config.loaded_from"), loadedConfigPath)
+	} else {
+		fmt.Fprintf(resultOutput, msg("config.not_found"), path)
+	}
+
+	outputDir := defaultOutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	colorPolicy, overwrite := "auto", "ask"
+	if noColor {
+		colorPolicy = "never"
+	}
+	if forceOverwrite {
+		overwrite = "force"
+	}
+	values := map[string]string{
+		"output_dir":  outputDir,
+		"buffer_size": formatByteSize(bufferSize),
+		"color":       colorPolicy,
+		"lang":        currentLang,
+		"overwrite":   overwrite,
+		"dev":         strconv.FormatBool(devMode),
+	}
+	for _, key := range configKeys {
+		fmt.Fprintf(resultOutput, "%s: %s  # %s\n", key, strconv.Quote(values[key]), msg("config.source_"+configSources[key]))
+	}
+}
+
+// 以 K、M、G 为单位的大小，与 --buffer-size 的写法相同
+func formatByteSize(size int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// 写入示例配置文件，各项都已注释，取消注释后生效
+func writeStarterConfig() error {
+	path, _ := configFilePath()
+	if path == "" {
+		return exitErrorf(EXIT_IO, "config.no_dir")
+	}
+	if _, err := os.Stat(path); err == nil {
+		colorYellow.Printf(msg("common.file_exists"), path)
+		if err := confirmOverwrite(path, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return exitErrorf(EXIT_IO, "config.write_failed", path, err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(msg("config.template_header"))
+	for _, line := range []struct{ comment, example string }{
+		{"config.template_output_dir", "output_dir: ~/Videos/extracted"},
+		{"config.template_buffer_size", "buffer_size: 8M"},
+		{"config.template_color", "color: auto"},
+		{"config.template_lang", "lang: zh"},
+		{"config.template_overwrite", "overwrite: ask"},
+		{"config.template_dev", "dev: false"},
+	} {
+		fmt.Fprintf(&builder, "\n# %s\n# %s\n", msg(line.comment), line.example)
+	}
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		return exitErrorf(EXIT_IO, "config.write_failed", path, err)
+	}
+	colorGreen.Printf(msg("config.written"), path)
+	printResultPath(path)
+	return nil
+}
+
+// 配置命令
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: helpText("help.config.short"),
+	Long:  helpText("help.config.long"),
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printEffectiveConfig()
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: helpText("help.config_init.short"),
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return writeStarterConfig()
+	},
+}
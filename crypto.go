@@ -0,0 +1,359 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// 附加文件加密：AES-256-GCM 分块加密，支持超大文件流式处理。
+// 每块独立认证，nonce = 基础nonce 异或 [附加文件序号(4字节) + 块序号(4字节)]，
+// 附加数据(AAD)包含序号和末块标记，防止块被重排、截断或在附加文件间交换。
+const (
+	// 密钥派生方式：PBKDF2-HMAC-SHA256
+	KDF_PBKDF2_SHA256 = 1
+	// 密钥派生方式：密钥文件（32字节直接作为密钥，其他长度经 HKDF-SHA256 派生），不记录任何密钥内容
+	KDF_KEY_FILE = 2
+	// 密钥文件 HKDF 的 info 参数
+	KEY_FILE_HKDF_INFO = "video-merge-tool key-file v1"
+	// 密钥文件最大长度 (1MB)
+	MAX_KEY_FILE_SIZE = 1024 * 1024
+	// PBKDF2 迭代次数
+	PBKDF2_ITERATIONS = 600000
+	// 盐长度
+	SALT_LENGTH = 16
+	// GCM nonce 长度
+	NONCE_LENGTH = 12
+	// AES-256 密钥长度
+	KEY_LENGTH = 32
+	// 明文分块大小 (64KB)
+	ENCRYPTION_CHUNK_SIZE = 64 * 1024
+	// 每块认证标签长度
+	GCM_TAG_LENGTH = 16
+	// 加密参数记录长度：[KDF(1)] + [迭代次数(4)] + [盐(16)] + [nonce(12)] + [分块大小(4)]
+	ENCRYPTION_PARAMS_LENGTH = 1 + UINT32_LENGTH + SALT_LENGTH + NONCE_LENGTH + UINT32_LENGTH
+)
+
+// 密码错误或数据被篡改
+var errDecryptFailed = newError("crypto.wrong_password")
+
+// EncryptionParams 加密参数（写入扩展块）
+type EncryptionParams struct {
+	KDF        uint8
+	Iterations uint32
+	Salt       []byte
+	Nonce      []byte
+	ChunkSize  uint32
+}
+
+// 生成新的随机加密参数
+func newEncryptionParams() (*EncryptionParams, error) {
+	params := &EncryptionParams{
+		KDF:        KDF_PBKDF2_SHA256,
+		Iterations: PBKDF2_ITERATIONS,
+		Salt:       make([]byte, SALT_LENGTH),
+		Nonce:      make([]byte, NONCE_LENGTH),
+		ChunkSize:  ENCRYPTION_CHUNK_SIZE,
+	}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, newError("crypto.salt_failed", err)
+	}
+	if _, err := rand.Read(params.Nonce); err != nil {
+		return nil, newError("crypto.nonce_failed", err)
+	}
+	return params, nil
+}
+
+// 生成使用密钥文件的加密参数：没有迭代次数，盐用于 HKDF
+func newKeyFileEncryptionParams() (*EncryptionParams, error) {
+	params, err := newEncryptionParams()
+	if err != nil {
+		return nil, err
+	}
+	params.KDF = KDF_KEY_FILE
+	params.Iterations = 0
+	return params, nil
+}
+
+// 编码加密参数
+func encodeEncryptionParams(params *EncryptionParams) []byte {
+	buf := []byte{params.KDF}
+	buf = binary.LittleEndian.AppendUint32(buf, params.Iterations)
+	buf = append(buf, params.Salt...)
+	buf = append(buf, params.Nonce...)
+	buf = binary.LittleEndian.AppendUint32(buf, params.ChunkSize)
+	return buf
+}
+
+// 解析加密参数
+func decodeEncryptionParams(value []byte) (*EncryptionParams, error) {
+	if len(value) != ENCRYPTION_PARAMS_LENGTH {
+		return nil, newError("crypto.params_length", len(value))
+	}
+
+	pos := 1
+	params := &EncryptionParams{KDF: value[0]}
+	params.Iterations = binary.LittleEndian.Uint32(value[pos : pos+UINT32_LENGTH])
+	pos += UINT32_LENGTH
+	params.Salt = value[pos : pos+SALT_LENGTH]
+	pos += SALT_LENGTH
+	params.Nonce = value[pos : pos+NONCE_LENGTH]
+	pos += NONCE_LENGTH
+	params.ChunkSize = binary.LittleEndian.Uint32(value[pos : pos+UINT32_LENGTH])
+
+	if params.KDF != KDF_PBKDF2_SHA256 && params.KDF != KDF_KEY_FILE {
+		return nil, newError("crypto.unsupported_kdf", params.KDF)
+	}
+	if (params.Iterations == 0) != (params.KDF == KDF_KEY_FILE) || params.ChunkSize == 0 || params.ChunkSize > 64*1024*1024 {
+		return nil, newError("crypto.params_invalid", params.Iterations, params.ChunkSize)
+	}
+
+	return params, nil
+}
+
+// 为新写入的数据生成加密参数并创建 AES-256-GCM：指定了密钥文件时使用密钥文件，否则使用密码
+func newEncryption(password, keyFile string) (*EncryptionParams, cipher.AEAD, error) {
+	if keyFile != "" {
+		material, err := readKeyFile(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		params, err := newKeyFileEncryptionParams()
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err := newKeyFileAEAD(material, params)
+		return params, aead, err
+	}
+
+	params, err := newEncryptionParams()
+	if err != nil {
+		return nil, nil, err
+	}
+	logInfof(colorCyan, "merge.deriving_key")
+	aead, err := newPasswordAEAD(password, params)
+	return params, aead, err
+}
+
+// 由密码派生密钥并创建 AES-256-GCM
+func newPasswordAEAD(password string, params *EncryptionParams) (cipher.AEAD, error) {
+	if params.KDF == KDF_KEY_FILE {
+		return nil, exitErrorf(EXIT_USAGE, "crypto.needs_key_file")
+	}
+	if password == "" {
+		return nil, newError("crypto.empty_password")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, params.Salt, int(params.Iterations), KEY_LENGTH)
+	if err != nil {
+		return nil, newError("crypto.kdf_failed", err)
+	}
+	return newKeyAEAD(key)
+}
+
+// 读取密钥文件内容；错误信息只含路径，不含密钥内容
+func readKeyFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, exitErrorf(EXIT_IO, "crypto.key_file_failed", path, err)
+	}
+	defer file.Close()
+
+	material, err := io.ReadAll(io.LimitReader(file, MAX_KEY_FILE_SIZE+1))
+	if err != nil {
+		return nil, exitErrorf(EXIT_IO, "crypto.key_file_failed", path, err)
+	}
+	if len(material) == 0 || len(material) > MAX_KEY_FILE_SIZE {
+		return nil, exitErrorf(EXIT_USAGE, "crypto.key_file_size", path, formatFileSize(MAX_KEY_FILE_SIZE))
+	}
+	return material, nil
+}
+
+// 由密钥文件内容创建 AES-256-GCM：恰好32字节时直接作为密钥，否则以盐经 HKDF-SHA256 派生
+func newKeyFileAEAD(material []byte, params *EncryptionParams) (cipher.AEAD, error) {
+	if params.KDF != KDF_KEY_FILE {
+		return nil, exitErrorf(EXIT_USAGE, "crypto.needs_password")
+	}
+
+	key := material
+	if len(material) != KEY_LENGTH {
+		var err error
+		key, err = hkdf.Key(sha256.New, material, params.Salt, KEY_FILE_HKDF_INFO, KEY_LENGTH)
+		if err != nil {
+			return nil, newError("crypto.kdf_failed", err)
+		}
+	}
+	return newKeyAEAD(key)
+}
+
+// 由 32 字节密钥创建 AES-256-GCM
+func newKeyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, newError("crypto.init_failed", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// 计算分块的 nonce 和附加数据
+func chunkNonceAndAAD(baseNonce []byte, attachIndex, chunkIndex uint32, final bool) ([]byte, []byte) {
+	nonce := make([]byte, NONCE_LENGTH)
+	copy(nonce, baseNonce)
+
+	counter := make([]byte, SIZE_LENGTH)
+	binary.BigEndian.PutUint32(counter[0:4], attachIndex)
+	binary.BigEndian.PutUint32(counter[4:8], chunkIndex)
+	for i := 0; i < SIZE_LENGTH; i++ {
+		nonce[NONCE_LENGTH-SIZE_LENGTH+i] ^= counter[i]
+	}
+
+	aad := append(counter, 0)
+	if final {
+		aad[SIZE_LENGTH] = 1
+	}
+	return nonce, aad
+}
+
+// 由明文大小推算加密后大小
+func sealedSizeOf(plainSize uint64, chunkSize uint32) uint64 {
+	chunks := (plainSize + uint64(chunkSize) - 1) / uint64(chunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	return plainSize + chunks*GCM_TAG_LENGTH
+}
+
+// 由加密后大小推算明文大小
+func plainSizeOf(storedSize uint64, chunkSize uint32) uint64 {
+	sealedChunk := uint64(chunkSize) + GCM_TAG_LENGTH
+	chunks := (storedSize + sealedChunk - 1) / sealedChunk
+	if chunks == 0 {
+		chunks = 1
+	}
+	if storedSize < chunks*GCM_TAG_LENGTH {
+		return 0
+	}
+	return storedSize - chunks*GCM_TAG_LENGTH
+}
+
+// 分块加密写入器，Close 时写出末块
+type encryptWriter struct {
+	dst         io.Writer
+	aead        cipher.AEAD
+	baseNonce   []byte
+	attachIndex uint32
+	chunkIndex  uint32
+	buf         []byte
+	chunkSize   int
+}
+
+func newEncryptWriter(dst io.Writer, aead cipher.AEAD, params *EncryptionParams, attachIndex int) *encryptWriter {
+	return &encryptWriter{
+		dst:         dst,
+		aead:        aead,
+		baseNonce:   params.Nonce,
+		attachIndex: uint32(attachIndex),
+		buf:         make([]byte, 0, params.ChunkSize),
+		chunkSize:   int(params.ChunkSize),
+	}
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区已满且还有后续数据时，当前块不是末块
+		if len(w.buf) == w.chunkSize {
+			if err := w.sealChunk(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// 写出末块（明文为空时也会写出一个仅含认证标签的末块）
+func (w *encryptWriter) Close() error {
+	return w.sealChunk(true)
+}
+
+func (w *encryptWriter) sealChunk(final bool) error {
+	nonce, aad := chunkNonceAndAAD(w.baseNonce, w.attachIndex, w.chunkIndex, final)
+	sealed := w.aead.Seal(nil, nonce, w.buf, aad)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.chunkIndex++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// 分块解密读取器，读取已知长度的密文
+type decryptReader struct {
+	src         io.Reader
+	aead        cipher.AEAD
+	baseNonce   []byte
+	attachIndex uint32
+	chunkIndex  uint32
+	remaining   uint64
+	sealedChunk int
+	sealed      []byte
+	plain       []byte
+}
+
+func newDecryptReader(src io.Reader, storedSize uint64, aead cipher.AEAD, params *EncryptionParams, attachIndex int) *decryptReader {
+	return &decryptReader{
+		src:         src,
+		aead:        aead,
+		baseNonce:   params.Nonce,
+		attachIndex: uint32(attachIndex),
+		remaining:   storedSize,
+		sealedChunk: int(params.ChunkSize) + GCM_TAG_LENGTH,
+		sealed:      make([]byte, int(params.ChunkSize)+GCM_TAG_LENGTH),
+	}
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+
+		size := r.sealedChunk
+		if uint64(size) > r.remaining {
+			size = int(r.remaining)
+		}
+		final := uint64(size) == r.remaining
+		if size < GCM_TAG_LENGTH {
+			return 0, errDecryptFailed
+		}
+
+		if _, err := io.ReadFull(r.src, r.sealed[:size]); err != nil {
+			return 0, newError("crypto.read_failed", err)
+		}
+
+		nonce, aad := chunkNonceAndAAD(r.baseNonce, r.attachIndex, r.chunkIndex, final)
+		plain, err := r.aead.Open(r.sealed[:0], nonce, r.sealed[:size], aad)
+		if err != nil {
+			return 0, errDecryptFailed
+		}
+
+		r.plain = plain
+		r.remaining -= uint64(size)
+		r.chunkIndex++
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
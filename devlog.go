@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// 开发日志时间格式
+	DEV_LOG_TIME_FORMAT = "2006-01-02 15:04:05.000"
+	// 命令行中隐去的密码、密钥等参数值
+	DEV_LOG_REDACTED = "xxxxx"
+)
+
+// 值为密码、密钥或密钥文件的参数，写入开发日志时隐去
+var devLogSecretFlags = map[string]bool{
+	"password":    true,
+	"auth-key":    true,
+	"key-file":    true,
+	"stealth-key": true,
+}
+
+var (
+	// --dev-log 指定的日志文件
+	devLogPath = ""
+	// 已打开的开发日志，未启用时为 nil；所有级别的日志都会写入
+	devLog *os.File
+)
+
+// 打开开发日志（追加写入），并记录工具版本和完整命令行
+func openDevLog() error {
+	if devLogPath == "" {
+		return nil
+	}
+	// 日志中有文件路径和调试信息，只允许本人读取
+	file, err := os.OpenFile(devLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return exitErrorf(EXIT_IO, "devlog.open_failed", err)
+	}
+	devLog = file
+
+	args := redactArgs(os.Args)
+	for i, arg := range args {
+		args[i] = quoteArg(arg)
+	}
+	workDir, _ := os.Getwd()
+	fmt.Fprintf(devLog, "\n===== %s =====\n", time.Now().Format(DEV_LOG_TIME_FORMAT))
+	logDebugf("devlog.version", versionSummary())
+	logDebugf("devlog.command", strings.Join(args, " "))
+	logDebugf("devlog.work_dir", workDir)
+	return nil
+}
+
+// 隐去命令行中密码、密钥参数的值（--password x 和 --password=x 两种写法）和远程地址中的密码
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	secretNext := false
+	flagsDone := false
+	for i, arg := range args {
+		switch {
+		case secretNext:
+			arg = DEV_LOG_REDACTED
+			secretNext = false
+		case flagsDone || !strings.HasPrefix(arg, "--"):
+		case arg == "--":
+			flagsDone = true
+		default:
+			name, _, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+			if devLogSecretFlags[name] {
+				if hasValue {
+					arg = "--" + name + "=" + DEV_LOG_REDACTED
+				} else {
+					secretNext = true
+				}
+			}
+		}
+		if name, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(arg, "--") && isRemotePath(value) {
+			arg = name + "=" + redactURL(value)
+		} else if isRemotePath(arg) {
+			arg = redactURL(arg)
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+// 命令行参数含空白或引号时加引号，便于原样复现
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// traceReader 记录元数据解析时每次读取的偏移和长度
+type traceReader struct {
+	reader io.ReaderAt
+}
+
+// 需要调试日志（开发模式或 --dev-log）时包装读取器
+func traceReads(reader io.ReaderAt) io.ReaderAt {
+	if devLog == nil && minLogLevel() > LOG_DEBUG {
+		return reader
+	}
+	return &traceReader{reader: reader}
+}
+
+func (r *traceReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.reader.ReadAt(p, off)
+	if err != nil {
+		logDebugf("devlog.read_failed", off, len(p), err)
+	} else {
+		logDebugf("devlog.read", off, len(p))
+	}
+	return n, err
+}
+
+// debugOutput 调试信息输出位置：终端上按原样着色，写入日志文件时不着色
+type debugOutput struct {
+	file io.Writer
+}
+
+func (d debugOutput) printf(format string, args ...interface{}) {
+	if d.file != nil {
+		fmt.Fprintf(d.file, format, args...)
+		return
+	}
+	fmt.Fprintf(messageOutput, format, args...)
+}
+
+func (d debugOutput) colorPrintf(c *color.Color, format string, args ...interface{}) {
+	if d.file != nil {
+		fmt.Fprintf(d.file, format, args...)
+		return
+	}
+	c.Printf(format, args...)
+}
+
+// 输出一行，c 为 nil 时不着色
+func (d debugOutput) println(c *color.Color, text string) {
+	switch {
+	case d.file != nil:
+		fmt.Fprintln(d.file, text)
+	case c != nil:
+		c.Println(text)
+	default:
+		fmt.Fprintln(messageOutput, text)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 计数写入器，记录实际写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 统计目录内容，返回条目数量和打包后的预估大小
+func scanAttachDir(root string) (int, int64, error) {
+	var entries int
+	// tar结束标记为两个512字节的空块
+	estimate := int64(1024)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		entries++
+		// 每个条目一个512字节头部，内容按512字节对齐
+		estimate += 512 + (info.Size()+511)/512*512
+		return nil
+	})
+	if err != nil {
+		return 0, 0, newError("dir.read_failed", err)
+	}
+
+	return entries, estimate, nil
+}
+
+// 将目录打包为tar流，条目路径相对于目录本身
+func writeDirArchive(dst io.Writer, root string) error {
+	tw := tar.NewWriter(dst)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		// 只打包普通文件和目录，跳过符号链接等特殊文件
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			colorYellow.Printf(msg("dir.skip_irregular"), path)
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		// 不记录本机用户信息
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return newError("dir.pack_failed", err)
+	}
+
+	return tw.Close()
+}
+
+// 打包目录并写入输出
+func copyDirArchive(ctx context.Context, progress progressSource, dst io.Writer, attachInfo *FileInfo) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDirArchive(pw, attachInfo.Path))
+	}()
+
+	// 归档大小只是估算值，长度不符不算错误
+	if _, err := copyWithProgress(ctx, dst, pr, attachInfo.Size, progress.forCopy(msg("progress.attach_dir"))); err != nil && !errors.Is(err, errCopySizeMismatch) {
+		pr.CloseWithError(err)
+		return newError("dir.copy_failed", err)
+	}
+
+	return nil
+}
+
+// 将tar流解包到目标目录，拒绝越界路径；同名文件按覆盖策略先确认
+func extractDirArchive(src io.Reader, destDir string) (int, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return 0, newError("dir.mkdir_failed", err)
+	}
+
+	tr := tar.NewReader(src)
+	var files int
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, newError("dir.read_archive_failed", err)
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return files, newError("dir.illegal_path", header.Name)
+		}
+		target := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, newError("dir.create_dir_failed", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return files, newError("dir.create_dir_failed", err)
+			}
+			perm := header.FileInfo().Mode().Perm()
+			if perm == 0 {
+				perm = 0644
+			}
+			// 同名文件与普通输出一样先确认（--force 时直接覆盖）；先删除再创建，
+			// 避免已有的符号链接把数据写到目录之外
+			if _, err := os.Lstat(target); err == nil {
+				colorYellow.Printf(msg("common.file_exists"), target)
+				if err := confirmOverwrite(target, msg("prompt.overwrite")); err != nil {
+					return files, err
+				}
+				if err := os.Remove(target); err != nil {
+					return files, newError("dir.create_file_failed", err)
+				}
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, perm)
+			if err != nil {
+				return files, newError("dir.create_file_failed", err)
+			}
+			trackPartialOutput(target)
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return files, newError("dir.write_file_failed", err)
+			}
+			if err := file.Close(); err != nil {
+				return files, newError("dir.write_file_failed", err)
+			}
+			finishPartialOutput(target)
+			// 恢复归档内文件的修改时间
+			if !header.ModTime.IsZero() {
+				os.Chtimes(target, header.ModTime, header.ModTime)
+			}
+			files++
+		default:
+			colorYellow.Printf(msg("dir.skip_entry"), header.Name)
+		}
+	}
+
+	return files, nil
+}
+
+// 从合并文件中提取目录附加文件并解包
+func extractDirAttachment(ctx context.Context, progress progressSource, reader io.Reader, entry AttachmentEntry, destDir string) (int, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := copyWithProgress(ctx, pw, reader, int64(entry.OriginalSize), progress.forCopy(msg("progress.attach_dir")))
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	files, err := extractDirArchive(pr, destDir)
+	if err == nil {
+		// 读完归档末尾的填充数据，保证校验值覆盖完整数据区
+		_, err = io.Copy(io.Discard, pr)
+	}
+	if err != nil {
+		pr.CloseWithError(err)
+		<-done
+		return files, newError("dir.extract_failed", err)
+	}
+
+	if err := <-done; err != nil {
+		return files, newError("dir.extract_failed", err)
+	}
+
+	return files, nil
+}
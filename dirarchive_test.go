@@ -0,0 +1,64 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 生成只含一个普通文件的tar归档
+func tarWithFile(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractDirArchiveExistingFile(t *testing.T) {
+	defer func(force bool) { forceOverwrite = force }(forceOverwrite)
+	archive := tarWithFile(t, "a.txt", "new")
+
+	// 未确认（非终端、未指定 --yes）时不覆盖
+	forceOverwrite = false
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.txt")
+	os.WriteFile(existing, []byte("old"), 0644)
+	_, err := extractDirArchive(bytes.NewReader(archive), dir)
+	var exists *ErrOutputExists
+	if !errors.As(err, &exists) || exists.Path != existing {
+		t.Errorf("error = %v, want ErrOutputExists for %s", err, existing)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "old" {
+		t.Errorf("existing file = %q, want it unchanged", data)
+	}
+
+	// --force 时覆盖；已有的符号链接被替换，不写入链接指向的文件
+	forceOverwrite = true
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	os.WriteFile(outside, []byte("outside"), 0644)
+	dir = t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "a.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if files, err := extractDirArchive(bytes.NewReader(archive), dir); err != nil || files != 1 {
+		t.Fatalf("extractDirArchive = %d, %v", files, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "new" {
+		t.Errorf("extracted file = %q, want %q", data, "new")
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "outside" {
+		t.Errorf("symlink target was overwritten: %q", data)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 完成后目标磁盘的剩余空间低于此值时以红色提示
+const LOW_SPACE_THRESHOLD = 1024 * 1024 * 1024
+
+// 检查各输出所在文件系统的剩余空间，不足时拒绝开始；skip 为 true（--skip-space-check）时只警告
+func checkFreeSpace(outputs []planOutput, skip bool) error {
+	dirs, needByDir, sizeKnown := spaceNeededByDir(outputs)
+	for _, dir := range dirs {
+		available, err := availableSpace(dir)
+		if err != nil {
+			colorYellow.Printf(msg("space.unknown"), dir, err)
+			continue
+		}
+		fmt.Fprintf(messageOutput, msg("space.line"), dir, formatFileSize(needByDir[dir]), formatFileSize(available), remainingSpaceText(available, needByDir[dir]))
+		if needByDir[dir] <= available {
+			continue
+		}
+		if !skip {
+			return exitErrorf(EXIT_IO, "space.insufficient", dir, formatFileSize(needByDir[dir]), formatFileSize(available))
+		}
+		colorYellow.Printf(msg("space.insufficient_skipped"), dir)
+	}
+	if !sizeKnown {
+		colorYellow.Println(msg("space.partial"))
+	}
+	return nil
+}
+
+// 按所在目录（最近的已存在上级目录）汇总各输出需要的空间。
+// 已存在且将被覆盖的文件所占空间计为可用，大小未知（-1）的输出不计入，此时 sizeKnown 为 false
+func spaceNeededByDir(outputs []planOutput) (dirs []string, needByDir map[string]int64, sizeKnown bool) {
+	needByDir = make(map[string]int64)
+	sizeKnown = true
+	for _, output := range outputs {
+		need := output.Size
+		if need < 0 {
+			sizeKnown = false
+			continue
+		}
+		if info, err := os.Stat(output.Path); err == nil && !info.IsDir() {
+			need -= info.Size()
+			if need < 0 {
+				need = 0
+			}
+		}
+
+		dir := existingParentDir(output.Path)
+		if _, ok := needByDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		needByDir[dir] += need
+	}
+	return dirs, needByDir, sizeKnown
+}
+
+// 完成后的剩余空间；不足时显示缺少的大小，低于 LOW_SPACE_THRESHOLD 时为红色
+func remainingSpaceText(available, need int64) string {
+	remaining := available - need
+	if remaining < 0 {
+		return colorRed.Sprintf(msg("space.short_by"), formatFileSize(-remaining))
+	}
+	if remaining < LOW_SPACE_THRESHOLD {
+		return colorRed.Sprint(formatFileSize(remaining))
+	}
+	return formatFileSize(remaining)
+}
+
+// 交互模式操作摘要中的预计输出大小、目标磁盘当前的剩余空间和完成后的剩余空间；
+// 只显示，不足时由实际操作开始前的检查拒绝
+func printSpaceProjection(sizeID string, outputs []planOutput) {
+	if len(outputs) == 0 {
+		return
+	}
+	var total int64
+	for _, output := range outputs {
+		if output.Size > 0 {
+			total += output.Size
+		}
+	}
+	fmt.Fprintf(messageOutput, msg(sizeID), formatFileSize(total))
+
+	dirs, needByDir, _ := spaceNeededByDir(outputs)
+	for _, dir := range dirs {
+		available, err := availableSpace(dir)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(messageOutput, msg("interactive.summary_space"), dir, formatFileSize(available), remainingSpaceText(available, needByDir[dir]))
+	}
+}
+
+// 预计的合并输出，无法估算（如输入无效）时返回 nil
+func projectMergeOutputs(videoPath, attachPath, outputPath string) []planOutput {
+	videoInfo, err := validateFile(videoPath)
+	if err != nil {
+		return nil
+	}
+	attachInfos, attachEntries, err := prepareAttachments([]string{attachPath}, "", make(map[string]bool), true)
+	if err != nil {
+		return nil
+	}
+	size, err := estimateMergedSize(videoInfo, attachInfos, attachEntries, MergeOptions{})
+	if err != nil {
+		return nil
+	}
+	return []planOutput{{Label: msg("plan.merged_file"), Path: outputPath, Size: size}}
+}
+
+// 预计的拆分输出：视频和各附加文件的原始大小，无法读取元数据时返回 nil
+func projectSplitOutputs(mergedPath, outputDir string) []planOutput {
+	file, size, err := openDetectInput(mergedPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	if size < 0 {
+		return nil
+	}
+	debugInfo := &DebugInfo{FileSize: size, CalculatedPos: make(map[string]int64)}
+	trailer, err := loadTrailer(file, size, stealthKey, debugInfo)
+	if err != nil {
+		return nil
+	}
+
+	videoName := trailer.VideoName
+	if videoName == "" {
+		videoName = filepath.Base(mergedPath)
+	}
+	outputs := []planOutput{{Label: msg("preview.type_video"), Path: filepath.Join(outputDir, videoName), Size: int64(trailer.VideoSize)}}
+	for _, entry := range trailer.Attachments {
+		outputs = append(outputs, planOutput{Label: msg("plan.attachment"), Path: filepath.Join(outputDir, filepath.Base(entry.Name)), Size: int64(entry.OriginalSize)})
+	}
+	return outputs
+}
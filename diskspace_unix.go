@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// 目录所在文件系统的可用空间（字节）
+func availableSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
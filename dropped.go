@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// 一行中拖入多个文件时拆分出各个路径（macOS 终端可一次拖入多个文件，路径以空格分隔）。
+// 整行本身是存在的路径，或拆分后有任一项不存在时，仍按单个路径处理
+func parseDroppedPaths(input string) []string {
+	single := parseDroppedPath(input)
+	if _, err := os.Stat(single); err == nil {
+		return []string{single}
+	}
+
+	tokens := splitDroppedTokens(input)
+	if len(tokens) < 2 {
+		return []string{single}
+	}
+	paths := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		path := parseDroppedPath(token)
+		if _, err := os.Stat(path); err != nil {
+			return []string{single}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// 按引号外、未转义的空白拆分输入，各项保留原有引号和转义，交给 parseDroppedPath 处理；
+// 单独的 & 是 PowerShell 的调用运算符，不算路径
+func splitDroppedTokens(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	flush := func() {
+		if token := current.String(); token != "" && token != "&" {
+			tokens = append(tokens, token)
+		}
+		current.Reset()
+	}
+
+	for _, r := range input {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\\' && runtime.GOOS != "windows":
+			// Windows 路径中的反斜杠是分隔符，不是转义
+			escaped = true
+		case r == '"' || r == '\'':
+			quote = r
+		case unicode.IsSpace(r):
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return tokens
+}
+
+// 一次拖入两个文件时分配载体和附加文件：按扩展名视频优先于图片、图片优先于其他文件，
+// 两者类型相同时询问哪个是载体
+func assignDroppedPair(paths []string) (videoPath, attachPath string) {
+	first, second := carrierKindOf(paths[0]), carrierKindOf(paths[1])
+	switch {
+	case first > second:
+		return paths[0], paths[1]
+	case second > first:
+		return paths[1], paths[0]
+	}
+
+	colorYellow.Println(msg("interactive.pair_ambiguous"))
+	for i, path := range paths {
+		fmt.Fprintf(messageOutput, "  %d. %s\n", i+1, filepath.Base(path))
+	}
+	for {
+		switch readUserInput(msg("interactive.pair_which_video")) {
+		case "1":
+			return paths[0], paths[1]
+		case "2":
+			return paths[1], paths[0]
+		}
+		colorYellow.Println(msg("interactive.pair_invalid"))
+	}
+}
+
+// 拖放启动：把文件拖到程序图标上时，文件路径是根命令唯一的参数
+var droppedLaunch = false
+
+// 按智能模式处理拖放到程序上的文件：合并文件直接拆分，否则以它开始交互式合并
+func runDroppedFile(filePath string) error {
+	droppedLaunch = true
+	colorMagenta.Println(msg("interactive.smart_title"))
+	filePath = parseDroppedPath(filePath)
+	fmt.Fprintf(messageOutput, msg("interactive.parsed_path_pin"), filePath)
+	_, err := handleSmartFile(filePath)
+	return err
+}
+
+// 拖放启动时退出前等待回车，Windows 控制台窗口关闭前能看到结果和错误
+func pauseBeforeExit() {
+	if droppedLaunch {
+		readUserInput(msg("dropped.press_enter"))
+	}
+}
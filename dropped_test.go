@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseDroppedPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell escapes are not used on Windows")
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain", "/home/me/video.mp4", "/home/me/video.mp4"},
+		// macOS 终端（Finder 拖入）：反斜杠转义，末尾带一个空格
+		{"finder space", `/Users/me/My\ Movie.mp4 `, "/Users/me/My Movie.mp4"},
+		{"finder specials", `/Users/me/Clip\ \(1\)\ \&\ more.mp4`, "/Users/me/Clip (1) & more.mp4"},
+		{"finder unicode", `/Users/me/我的\ 视频.mp4`, "/Users/me/我的 视频.mp4"},
+		// iTerm2：与终端相同的反斜杠转义，引号和 $ 也被转义
+		{"iterm2 quote", `/Users/me/Bob\'s\ \$5\ clip.mp4`, "/Users/me/Bob's $5 clip.mp4"},
+		{"iterm2 backslash", `/Users/me/a\\b.mp4`, `/Users/me/a\b.mp4`},
+		// GNOME Terminal：整个路径用单引号包住，引号内不转义
+		{"gnome quoted", `'/home/me/My Movie (1).mp4' `, "/home/me/My Movie (1).mp4"},
+		{"gnome single quote", `'/home/me/it'\''s.mp4'`, "/home/me/it's.mp4"},
+		{"gnome backslash", `'/home/me/a\ b.mp4'`, `/home/me/a\ b.mp4`},
+		{"double quoted", `"/home/me/My Movie.mp4"`, "/home/me/My Movie.mp4"},
+		{"crlf", "/home/me/video.mp4\r\n", "/home/me/video.mp4"},
+		{"trailing backslash", `/home/me/odd\`, `/home/me/odd\`},
+		// PowerShell 的 Windows 路径中反斜杠是分隔符
+		{"powershell", `& 'C:\Videos\My Movie.mp4'`, `C:\Videos\My Movie.mp4`},
+	}
+	for _, tt := range tests {
+		if got := parseDroppedPath(tt.input); got != tt.want {
+			t.Errorf("%s: parseDroppedPath(%q) = %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDroppedPaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell escapes are not used on Windows")
+	}
+	video := writeTempFile(t, "My Movie (1).mp4", []byte("video"))
+	attach := writeTempFile(t, "notes.txt", []byte("notes"))
+	escape := strings.NewReplacer(" ", `\ `, "(", `\(`, ")", `\)`)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"single escaped", escape.Replace(video) + " ", []string{video}},
+		{"two escaped", escape.Replace(video) + " " + escape.Replace(attach) + " ", []string{video, attach}},
+		{"two quoted", "'" + video + "' '" + attach + "'", []string{video, attach}},
+		// 拆分后有不存在的项时按单个路径处理
+		{"missing item", escape.Replace(video) + " " + filepath.Join(filepath.Dir(attach), "missing.txt"), nil},
+	}
+	for _, tt := range tests {
+		got := parseDroppedPaths(tt.input)
+		if tt.want == nil {
+			if len(got) != 1 {
+				t.Errorf("%s: got %q, want a single path", tt.name, got)
+			}
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 资源管理器拖入的 Windows 路径保持原生形式（与运行平台无关）
+func TestNormalizeWindowsPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"drive letter", `C:\Users\me\My Movie.mp4`, `C:\Users\me\My Movie.mp4`},
+		{"forward slashes", `C:/Users/me/video.mp4`, `C:\Users\me\video.mp4`},
+		{"repeated separators", `C:\Users\\me\\\video.mp4`, `C:\Users\me\video.mp4`},
+		{"mapped drive", `Z:\share\video.mp4`, `Z:\share\video.mp4`},
+		{"unc", `\\nas\share\video.mp4`, `\\nas\share\video.mp4`},
+		{"unc forward slashes", `//nas/share/video.mp4`, `\\nas\share\video.mp4`},
+		{"unc repeated separators", `\\nas\share\\dir\video.mp4`, `\\nas\share\dir\video.mp4`},
+		{"long path", `\\?\C:\very\long\path\video.mp4`, `\\?\C:\very\long\path\video.mp4`},
+		{"long unc path", `\\?\UNC\nas\share\video.mp4`, `\\?\UNC\nas\share\video.mp4`},
+	}
+	for _, tt := range tests {
+		if got := normalizeWindowsPath(tt.input); got != tt.want {
+			t.Errorf("%s: normalizeWindowsPath(%q) = %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDroppedPathWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Windows path handling only applies on Windows")
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"C:\Users\me\My Movie.mp4"`, `C:\Users\me\My Movie.mp4`},
+		{`& 'Z:\share\it''s.mp4'`, `Z:\share\it's.mp4`},
+		{`"\\nas\share\video.mp4"`, `\\nas\share\video.mp4`},
+		{`\\?\C:\very\long\path.mp4`, `\\?\C:\very\long\path.mp4`},
+	}
+	for _, tt := range tests {
+		if got := parseDroppedPath(tt.input); got != tt.want {
+			t.Errorf("parseDroppedPath(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
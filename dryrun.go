@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// planOutput 试运行计划中的一个输出
+type planOutput struct {
+	Label string
+	Path  string
+	// 预计大小，-1 表示无法预知（如标准输入）
+	Size int64
+}
+
+// 最近的已存在上级目录，用于查询剩余空间
+func existingParentDir(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// 两个路径是否指向同一文件：都存在时跟随符号链接比较（Unix 上即设备号和 inode），
+// 否则比较解析符号链接后的绝对路径
+func samePath(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(infoA, infoB)
+	}
+	return resolvedPath(a) == resolvedPath(b)
+}
+
+// 清理后的绝对路径，已存在的上级目录中的符号链接会被解析
+func resolvedPath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	dir := existingParentDir(absPath)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		if rel, err := filepath.Rel(dir, absPath); err == nil {
+			return filepath.Join(resolved, rel)
+		}
+	}
+	return absPath
+}
+
+// 拒绝输出与任一输入为同一文件（如 merge a.mp4 b.txt a.mp4），否则创建输出时会截断输入
+func checkOutputsNotInputs(outputs []string, inputs []string) error {
+	for _, output := range outputs {
+		if output == "" {
+			continue
+		}
+		for _, input := range inputs {
+			if input != STDIN_PATH && samePath(input, output) {
+				return exitErrorf(EXIT_USAGE, "error.output_is_input", output, input)
+			}
+		}
+	}
+	return nil
+}
+
+// 打印试运行计划并检查实际操作能否执行：上级目录可用、
+// 已存在的输出能得到确认、剩余空间足够。不创建、不修改任何文件。
+// createDirs 为 true 时缺少的上级目录会在实际运行时自动创建
+func checkOutputPlan(outputs []planOutput, createDirs bool, skipSpaceCheck bool) error {
+	colorMagenta.Println(msg("dryrun.plan"))
+
+	for _, output := range outputs {
+		sizeText := msg("dryrun.size_unknown")
+		if output.Size >= 0 {
+			sizeText = formatFileSize(output.Size)
+		}
+		fmt.Fprintf(messageOutput, "   %s → %s (%s)\n", output.Label, output.Path, sizeText)
+
+		if !createDirs {
+			if info, err := os.Stat(filepath.Dir(output.Path)); err != nil || !info.IsDir() {
+				return exitErrorf(EXIT_IO, "dryrun.no_output_dir", filepath.Dir(output.Path))
+			}
+		}
+
+		if _, err := os.Stat(output.Path); err == nil {
+			if !forceOverwrite && !assumeYes && !term.IsTerminal(int(os.Stdin.Fd())) {
+				return exitErrorf(EXIT_USAGE, "dryrun.exists_no_tty", output.Path)
+			}
+			colorYellow.Printf(msg("dryrun.will_overwrite"), overwriteHint())
+		}
+	}
+
+	if err := checkFreeSpace(outputs, skipSpaceCheck); err != nil {
+		return err
+	}
+
+	colorGreen.Println(msg("dryrun.ok"))
+	return nil
+}
+
+// 已存在输出的处理方式说明
+func overwriteHint() string {
+	if forceOverwrite || assumeYes {
+		return msg("dryrun.hint_forced")
+	}
+	return msg("dryrun.hint_confirm")
+}
+
+// 估算合并后的文件大小（压缩按原大小估计，结果偏大）；附加数据来自标准输入时返回 -1
+func estimateMergedSize(videoInfo *FileInfo, attachInfos []*FileInfo, attachEntries []AttachmentEntry, opts MergeOptions) (int64, error) {
+	padding := alignPadding(videoInfo.Size, opts.Align)
+	size := videoInfo.Size + padding
+
+	var encParams *EncryptionParams
+	if opts.Password != "" || opts.KeyFile != "" {
+		var err error
+		if encParams, err = newEncryptionParams(); err != nil {
+			return 0, err
+		}
+	}
+
+	entries := make([]AttachmentEntry, len(attachEntries))
+	copy(entries, attachEntries)
+	for i, attachInfo := range attachInfos {
+		if attachInfo.IsStdin {
+			return -1, nil
+		}
+		stored := uint64(attachInfo.Size)
+		if encParams != nil {
+			stored = sealedSizeOf(stored, encParams.ChunkSize)
+		}
+		entries[i].Size = stored
+		entries[i].OriginalSize = uint64(attachInfo.Size)
+		size += int64(stored)
+	}
+	attachSize := size - videoInfo.Size - padding
+
+	// ZIP兼容模式在附加数据前后各有一段ZIP结构
+	var zipHeader, zipDirectory int64
+	if opts.ZipCompatible && len(entries) == 1 {
+		zipHeader, zipDirectory = zipStructureLengths(entries[0].Name)
+		size += zipHeader + zipDirectory
+	}
+
+	// MP4 box 嵌入模式在附加数据前有 free box 头
+	var boxHeader int64
+	if opts.EmbedMode == EMBED_MODE_MP4BOX {
+		boxHeader = MP4_LARGE_BOX_HEADER_LENGTH
+		size += boxHeader
+	}
+
+	// MKV 附件嵌入模式在附加数据前有 Attachments 元素头，之后有 Void 元素头；载体不可用时合并会改用末尾追加模式
+	var mkv *mkvLayout
+	if opts.EmbedMode == EMBED_MODE_MKV_ATTACHMENT && len(entries) == 1 {
+		if carrier, err := inspectMKVCarrier(videoInfo.Path); err == nil {
+			mkv = carrier.layout(entries[0].Name, entries[0].MimeType)
+			size += int64(mkv.Header + mkv.Void)
+		}
+	}
+
+	// 校验值长度固定，用占位值即可得到准确的元数据大小
+	createdAt := time.Now()
+	spec := &trailerSpec{
+		VideoSize:    videoInfo.Size,
+		Padding:      padding,
+		ZipHeader:    zipHeader,
+		ZipDirectory: zipDirectory,
+		BoxHeader:    boxHeader,
+		MKV:          mkv,
+		VideoName:    videoInfo.Name,
+		VideoSHA256:  make([]byte, sha256.Size),
+		AttachSHA256: make([]byte, sha256.Size),
+		Comment:      opts.Comment,
+		ToolVersion:  toolVersion,
+		CreatedAt:    &createdAt,
+		Attachments:  entries,
+		Encryption:   encParams,
+		Compressed:   opts.Compress,
+		AuthKey:      opts.AuthKey,
+	}
+	// 纠错校验块长度记录是定长的，占位值即可；校验块长度由附加数据开头和元数据长度决定
+	if opts.FEC {
+		spec.FEC = 1
+	}
+	metadata, err := buildTrailer(spec)
+	if err != nil {
+		return 0, err
+	}
+	size += int64(metadata.Len())
+	if opts.FEC {
+		fec, err := fecBlockLength(int(min(FEC_ATTACH_HEAD, attachSize)), metadata.Len())
+		if err != nil {
+			return 0, err
+		}
+		size += int64(fec)
+	}
+	if opts.StealthKey != "" {
+		size += GCM_TAG_LENGTH + STEALTH_FOOTER_LENGTH
+	}
+	return size, nil
+}
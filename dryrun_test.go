@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckOutputsNotInputs(t *testing.T) {
+	dir := t.TempDir()
+	video := writeTempFile(t, "v.mp4", []byte("video"))
+	link := filepath.Join(dir, "alias.mp4")
+	if err := os.Symlink(video, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	// 通过指向上级目录的符号链接引用尚不存在的同名输出
+	dirLink := filepath.Join(dir, "dir")
+	if err := os.Symlink(filepath.Dir(video), dirLink); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		same   bool
+	}{
+		{"same path", video, true},
+		{"unclean path", filepath.Join(filepath.Dir(video), ".", "v.mp4"), true},
+		{"symlink alias", link, true},
+		{"symlinked directory", filepath.Join(dirLink, "v.mp4"), true},
+		{"new file in symlinked directory", filepath.Join(dirLink, "new.mp4"), false},
+		{"other file", filepath.Join(dir, "out.mp4"), false},
+	}
+	for _, tt := range tests {
+		err := checkOutputsNotInputs([]string{tt.output}, []string{STDIN_PATH, video})
+		if got := err != nil; got != tt.same {
+			t.Errorf("%s: error = %v, want refused %v", tt.name, err, tt.same)
+		}
+		if err != nil && exitCodeOf(err) != EXIT_USAGE {
+			t.Errorf("%s: exit code %d, want %d", tt.name, exitCodeOf(err), EXIT_USAGE)
+		}
+	}
+}
+
+// 输出通过符号链接指向视频文件时拒绝合并，视频保持不变
+func TestMergeRefusesSymlinkedOutput(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 1000)
+	videoPath := writeTempFile(t, "v.mp4", video)
+	attachPath := writeTempFile(t, "a.txt", []byte("attachment"))
+	link := filepath.Join(t.TempDir(), "out.mp4")
+	if err := os.Symlink(videoPath, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+
+	code, _, stderr := runMain(t, "merge", "--force", videoPath, attachPath, link)
+	if code != EXIT_USAGE {
+		t.Errorf("exit code %d, want %d; stderr: %s", code, EXIT_USAGE, stderr)
+	}
+	if data, err := os.ReadFile(videoPath); err != nil || !bytes.Equal(data, video) {
+		t.Errorf("video was modified (%d bytes, %v)", len(data), err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// 重复嵌入检查：视频本身已是合并文件（--allow-nested）或指定了 --compare-with 时，
+// 比较附加文件与其中已嵌入的附加文件，大小相同时再流式计算 SHA-256 比较，不解出临时文件
+
+// embeddedMatch 与附加文件内容相同的已嵌入附加文件
+type embeddedMatch struct {
+	AttachName string
+	Container  string
+	Embedded   string
+}
+
+// 发现附加文件已嵌入时警告并询问是否继续，不继续时返回 ErrCancelled
+func checkAlreadyEmbedded(ctx context.Context, progress progressSource, videoPath string, nested *TrailerInfo, attachInfos []*FileInfo, compareWith string) error {
+	var containers []string
+	if nested != nil {
+		containers = append(containers, videoPath)
+	}
+	if compareWith != "" {
+		containers = append(containers, compareWith)
+	}
+
+	var matches []embeddedMatch
+	candidateHashes := make(map[int]string)
+	for _, container := range containers {
+		found, err := findEmbeddedCopies(ctx, progress, container, attachInfos, candidateHashes, container == compareWith)
+		if err != nil {
+			return err
+		}
+		matches = append(matches, found...)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	for _, match := range matches {
+		logWarnf("duplicate.found", match.AttachName, match.Container, match.Embedded)
+	}
+	return requireConfirmation(msg("duplicate.confirm"))
+}
+
+// 在一个合并文件中查找与附加文件内容相同的附加文件；candidateHashes 缓存各附加文件的 SHA-256。
+// required 为 true（--compare-with）时文件不是合并文件即报错
+func findEmbeddedCopies(ctx context.Context, progress progressSource, container string, attachInfos []*FileInfo, candidateHashes map[int]string, required bool) ([]embeddedMatch, error) {
+	file, err := os.Open(container)
+	if err != nil {
+		return nil, exitErrorf(EXIT_IO, "duplicate.open_failed", container, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, exitErrorf(EXIT_IO, "duplicate.open_failed", container, err)
+	}
+	debugInfo := &DebugInfo{FileSize: info.Size(), CalculatedPos: make(map[string]int64)}
+	trailer, err := loadTrailer(file, info.Size(), stealthKey, debugInfo)
+	if err != nil {
+		if required {
+			return nil, exitErrorf(EXIT_NOT_MERGED, "duplicate.not_merged", container, err)
+		}
+		return nil, nil
+	}
+	// 加密的附加文件需要密码才能得到原始数据，无法比较
+	if trailer.Encrypted {
+		logWarnf("duplicate.encrypted_skipped", container)
+		return nil, nil
+	}
+
+	logInfof(colorCyan, "duplicate.checking", container)
+	var matches []embeddedMatch
+	for i, attachInfo := range attachInfos {
+		if attachInfo.IsDir || attachInfo.IsStdin {
+			continue
+		}
+		for j, entry := range trailer.Attachments {
+			if entry.IsDir || int64(entry.OriginalSize) != attachInfo.Size {
+				continue
+			}
+			if _, ok := candidateHashes[i]; !ok {
+				sum, err := hashFile(ctx, progress, attachInfo.Path, msg("progress.compare_attach"))
+				if err != nil {
+					return nil, err
+				}
+				candidateHashes[i] = sum
+			}
+			reader, err := openAttachmentReader(file, trailer, j, io.Discard, nil)
+			if err != nil {
+				return nil, newError("split.read_attach_failed", entry.Name, err)
+			}
+			embedded, err := hashReader(ctx, progress, reader, int64(entry.OriginalSize), msg("progress.compare_embedded"))
+			if err != nil {
+				return nil, exitErrorf(EXIT_IO, "duplicate.read_failed", container, err)
+			}
+			if embedded == candidateHashes[i] {
+				matches = append(matches, embeddedMatch{AttachName: attachInfo.Name, Container: container, Embedded: entry.Name})
+			}
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import "errors"
+
+// 可供调用方用 errors.Is/errors.As 判断的错误；显示给用户的文字仍按当前语言生成
+
+var (
+	// 不是合并文件（魔术字节不匹配、文件过小）
+	ErrNotMergedFile = newError("trailer.not_merged")
+
+	// 用户取消操作
+	ErrCancelled = newError("error.cancelled")
+)
+
+// ErrStructureInvalid 按尾部记录推算的文件大小与实际大小不符
+type ErrStructureInvalid struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *ErrStructureInvalid) Error() string {
+	return msgf("trailer.structure_mismatch_fmt", e.Expected, e.Actual)
+}
+
+// ErrOutputExists 输出已存在且未确认覆盖，Err 为取消或需要确认的原因
+type ErrOutputExists struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrOutputExists) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ErrOutputExists) Unwrap() error {
+	return e.Err
+}
+
+// notMergedError 不是合并文件的具体原因（如文件过小），判断时视为 ErrNotMergedFile
+type notMergedError struct {
+	err error
+}
+
+func (e *notMergedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notMergedError) Is(target error) bool {
+	return target == ErrNotMergedFile
+}
+
+func (e *notMergedError) Unwrap() error {
+	return e.err
+}
+
+// 创建不是合并文件的错误，id 为消息目录中说明原因的消息ID
+func notMergedErrorf(id string, args ...interface{}) error {
+	return withExitCode(EXIT_NOT_MERGED, &notMergedError{err: newError(id, args...)})
+}
+
+// 没有对应消息ID的错误（如第三方库直接返回的错误）在 --json 中的错误代码
+const ERROR_CODE_UNKNOWN = "unknown"
+
+// 错误的稳定代码，取错误链中最内层（最具体原因）的消息ID，供 --json 调用方匹配；不随 --lang 变化
+func errorCode(err error) string {
+	var canceled *CanceledError
+	if errors.As(err, &canceled) {
+		return "interrupt.canceled"
+	}
+	var structure *ErrStructureInvalid
+	if errors.As(err, &structure) {
+		return "trailer.structure_mismatch"
+	}
+	code := ERROR_CODE_UNKNOWN
+	for ; err != nil; err = errors.Unwrap(err) {
+		if coded, ok := err.(*msgError); ok {
+			code = coded.id
+		}
+	}
+	return code
+}
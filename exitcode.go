@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+)
+
+// 进程退出码，便于脚本区分失败原因
+const (
+	EXIT_OK             = 0
+	EXIT_FAILURE        = 1   // 其他错误
+	EXIT_USAGE          = 2   // 参数或选项无效
+	EXIT_NOT_MERGED     = 3   // 不是合并文件（魔术字节不匹配、文件过小）
+	EXIT_INVALID_FORMAT = 4   // 结构验证或数据校验失败
+	EXIT_IO             = 5   // 读写文件失败
+	EXIT_CANCELLED      = 6   // 用户取消
+	EXIT_INTERRUPTED    = 130 // 被 Ctrl-C 或 SIGTERM 中断
+)
+
+// 参数解析完成、命令开始执行后置位，用于识别参数错误
+var commandStarted = false
+
+// exitError 带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// 为错误附加退出码；已有退出码（或为取消操作）时保持原样
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *exitError
+	if errors.As(err, &existing) || errors.Is(err, ErrCancelled) {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// 创建带退出码的错误，id 为消息目录中的消息ID
+func exitErrorf(code int, id string, args ...interface{}) error {
+	return &exitError{code: code, err: newError(id, args...)}
+}
+
+// 错误对应的退出码
+func exitCodeOf(err error) int {
+	if err == nil {
+		return EXIT_OK
+	}
+	if errors.Is(err, ErrCancelled) {
+		return EXIT_CANCELLED
+	}
+	var canceled *CanceledError
+	if errors.As(err, &canceled) {
+		return EXIT_INTERRUPTED
+	}
+	var coded *exitError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return EXIT_FAILURE
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 设置该环境变量时测试进程改为执行 main，参数取自 TEST_MAIN_ARGS（以 \n 分隔）
+const TEST_MAIN_ENV = "VIDEO_MERGER_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(TEST_MAIN_ENV) == "1" {
+		os.Args = append([]string{"video-merger-v3"}, strings.Split(os.Getenv("TEST_MAIN_ARGS"), "\n")...)
+		main()
+		os.Exit(EXIT_OK)
+	}
+	os.Exit(m.Run())
+}
+
+// 在子进程中运行命令，返回退出码、标准输出和标准错误输出
+func runMain(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(),
+		TEST_MAIN_ENV+"=1",
+		"TEST_MAIN_ARGS="+strings.Join(args, "\n"),
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+home,
+		CONFIG_ENV+"=",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("run %v: %v", args, err)
+	}
+	return cmd.ProcessState.ExitCode(), stdout.String(), stderr.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 1000)
+	merged := mergedBytes(t, video, []byte("attachment"), "a.txt")
+	corrupt := bytes.Clone(merged)
+	corrupt[0] ^= 0xff
+	plainPath := writeTempFile(t, "plain.mp4", video)
+	mergedPath := writeTempFile(t, "merged.mp4", merged)
+	corruptPath := writeTempFile(t, "corrupt.mp4", corrupt)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"ok", []string{"info", "--quiet", mergedPath}, EXIT_OK},
+		{"unknown flag", []string{"split", "--no-such-flag", mergedPath}, EXIT_USAGE},
+		{"missing argument", []string{"split"}, EXIT_USAGE},
+		{"not merged", []string{"split", "--quiet", plainPath, "-o", t.TempDir()}, EXIT_NOT_MERGED},
+		{"checksum mismatch", []string{"split", "--quiet", corruptPath, "-o", t.TempDir()}, EXIT_INVALID_FORMAT},
+		{"missing file", []string{"split", "--quiet", filepath.Join(t.TempDir(), "missing.mp4")}, EXIT_IO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runMain(t, tt.args...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.code, stderr)
+			}
+			if tt.code == EXIT_OK {
+				return
+			}
+			// 错误只输出一次本地化提示，不附带 cobra 的 Error: 和完整用法
+			if n := strings.Count(stderr, "❌"); n != 1 {
+				t.Errorf("error printed %d times:\n%s", n, stderr)
+			}
+			if strings.HasPrefix(stderr, "Error:") || strings.Contains(stderr, "\nError:") || strings.Contains(stderr, "Usage:") {
+				t.Errorf("cobra error or usage printed:\n%s", stderr)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{newError("error.merged_invalid_w", newError("error.not_found", "x")), "error.not_found"},
+		{notMergedErrorf("trailer.too_small_invalid"), "trailer.too_small_invalid"},
+		{withExitCode(EXIT_IO, fmt.Errorf("wrapped: %w", ErrCancelled)), "error.cancelled"},
+		{&ErrStructureInvalid{Expected: 1, Actual: 2}, "trailer.structure_mismatch"},
+		{&CanceledError{Phase: PHASE_VIDEO, Err: context.Canceled}, "interrupt.canceled"},
+		{errors.New("plain"), ERROR_CODE_UNKNOWN},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.code {
+			t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.code)
+		}
+	}
+}
+
+// --json 输出的 error_code 不随 --lang 变化
+func TestJSONErrorCode(t *testing.T) {
+	path := writeTempFile(t, "plain.mp4", []byte("tiny"))
+	var codes []string
+	for _, lang := range []string{"zh", "en"} {
+		code, stdout, _ := runMain(t, "split", "--json", "--lang", lang, path, "-o", t.TempDir())
+		var result OperationResult
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", lang, stdout, err)
+		}
+		if result.Success || result.ExitCode != code || code != EXIT_NOT_MERGED {
+			t.Errorf("%s: success=%v exit_code=%d, process exit %d", lang, result.Success, result.ExitCode, code)
+		}
+		codes = append(codes, result.ErrorCode)
+	}
+	if codes[0] != "trailer.too_small_invalid" || codes[1] != codes[0] {
+		t.Errorf("error codes = %q", codes)
+	}
+}
+
+// 没有终端时不提示输入密码，直接报参数错误，批量拆分同样如此
+func TestPasswordWithoutTerminal(t *testing.T) {
+	dir := t.TempDir()
+	videoPath := writeTempFile(t, "v.mp4", bytes.Repeat([]byte("video "), 1000))
+	attachPath := writeTempFile(t, "a.txt", []byte("attachment"))
+	mergedPath := filepath.Join(dir, "enc.mp4")
+	if code, _, stderr := runMain(t, "merge", "--quiet", "--password", "pw", videoPath, attachPath, mergedPath); code != EXIT_OK {
+		t.Fatalf("merge exit code = %d\n%s", code, stderr)
+	}
+
+	for _, args := range [][]string{
+		{"split", "--lang", "en", mergedPath, "-o", t.TempDir()},
+		{"split", "--lang", "en", filepath.Join(dir, "*.mp4"), "-o", t.TempDir()},
+	} {
+		code, _, stderr := runMain(t, args...)
+		if code != EXIT_USAGE {
+			t.Errorf("%v: exit code = %d, want %d\n%s", args, code, EXIT_USAGE, stderr)
+		}
+		if !strings.Contains(stderr, "--password or --key-file") || strings.Contains(stderr, "EOF") {
+			t.Errorf("%v: unclear error:\n%s", args, stderr)
+		}
+	}
+}
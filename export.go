@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// info 和 scan 的 --format 输出格式
+const (
+	FORMAT_TEXT = ""
+	FORMAT_JSON = "json"
+	FORMAT_YAML = "yaml"
+	FORMAT_CSV  = "csv"
+)
+
+// 解析 --format；--json 等同于 --format json，两者指定了不同格式时报错
+func resolveOutputFormat(format string, asJSON bool) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case FORMAT_TEXT, FORMAT_JSON, FORMAT_YAML, FORMAT_CSV:
+	case "yml":
+		format = FORMAT_YAML
+	default:
+		return "", exitErrorf(EXIT_USAGE, "format.invalid", format)
+	}
+	if asJSON {
+		if format != FORMAT_TEXT && format != FORMAT_JSON {
+			return "", exitErrorf(EXIT_USAGE, "format.json_conflict", format)
+		}
+		format = FORMAT_JSON
+	}
+	return format, nil
+}
+
+// exportField 导出的一个字段，名称取自 json 标签，YAML 和 CSV 与 JSON 使用相同的字段名
+type exportField struct {
+	Name      string
+	Value     interface{}
+	OmitEmpty bool
+}
+
+func exportFields(record interface{}) []exportField {
+	value := reflect.Indirect(reflect.ValueOf(record))
+	recordType := value.Type()
+	fields := make([]exportField, 0, recordType.NumField())
+	for i := 0; i < recordType.NumField(); i++ {
+		name, options, _ := strings.Cut(recordType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, exportField{Name: name, Value: value.Field(i).Interface(), OmitEmpty: options == "omitempty"})
+	}
+	return fields
+}
+
+// 写出元数据摘要；single 为 true 时（info）JSON 和 YAML 输出单个对象，否则输出列表。
+// CSV 总是先写表头，每个合并文件一行
+func writeScanResults(w io.Writer, format string, results []ScanResult, single bool) error {
+	if results == nil {
+		results = []ScanResult{}
+	}
+	switch format {
+	case FORMAT_JSON:
+		var output []byte
+		var err error
+		if single && len(results) == 1 {
+			output, err = json.MarshalIndent(results[0], "", "  ")
+		} else {
+			output, err = json.MarshalIndent(results, "", "  ")
+		}
+		if err != nil {
+			return newError("error.json_failed", err)
+		}
+		fmt.Fprintln(w, string(output))
+	case FORMAT_YAML:
+		if !single && len(results) == 0 {
+			fmt.Fprintln(w, "[]")
+		}
+		for _, result := range results {
+			writeYAMLRecord(w, &result, !single)
+		}
+	case FORMAT_CSV:
+		writer := csv.NewWriter(w)
+		var header []string
+		for _, field := range exportFields(&ScanResult{}) {
+			header = append(header, field.Name)
+		}
+		writer.Write(header)
+		for _, result := range results {
+			var row []string
+			for _, field := range exportFields(&result) {
+				row = append(row, fmt.Sprint(field.Value))
+			}
+			writer.Write(row)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return newError("format.write_failed", err)
+		}
+	}
+	return nil
+}
+
+// 写出一个 YAML 映射（inList 时作为列表项），省略 omitempty 的空值。
+// 字符串一律使用双引号，Go 的转义序列（\n、\t、\x、\u）在 YAML 双引号字符串中含义相同
+func writeYAMLRecord(w io.Writer, record interface{}, inList bool) {
+	prefix := ""
+	if inList {
+		prefix = "- "
+	}
+	for _, field := range exportFields(record) {
+		if field.OmitEmpty && reflect.ValueOf(field.Value).IsZero() {
+			continue
+		}
+		value := fmt.Sprint(field.Value)
+		if text, ok := field.Value.(string); ok {
+			value = strconv.Quote(text)
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", prefix, field.Name, value)
+		if inList {
+			prefix = "  "
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// 纠错校验块（--fec）：写在附加数据之后、尾部元数据之前，用 Reed–Solomon 校验分片保护
+// 尾部元数据和附加数据开头，文件末尾被截断或少量字节损坏时，拆分前先尝试修复。格式（小端）：
+//
+//	[魔术字节 "MFEC"(4)] [版本(1)] [分片大小(4)] [数据分片数(4)] [校验分片数(4)]
+//	[附加数据开头长度(4)] [尾部元数据长度(4)] [附加数据起始偏移(8)] [校验块起始偏移(8)]
+//	[各分片 CRC32C(分片数×4)] [以上内容的 CRC32C(4)] [校验分片(校验分片数×分片大小)]
+//
+// 数据分片由附加数据开头和尾部元数据分别按分片大小切分（末片补零）依次组成。
+// 损坏的分片由 CRC32C 确定，完好的分片（含校验分片）不少于数据分片数即可全部还原
+const (
+	FEC_MAGIC   = "MFEC"
+	FEC_VERSION = 1
+	// 校验块头中分片 CRC 之前的固定部分长度
+	FEC_FIXED_HEADER_LENGTH = 4 + 1 + UINT32_LENGTH*5 + SIZE_LENGTH*2
+	// 受保护的附加数据开头长度
+	FEC_ATTACH_HEAD = 512
+	// 最小分片大小，分片大小总是其倍数
+	FEC_MIN_SHARD_SIZE = 64
+	// 数据分片数目标值，分片大小据此确定
+	FEC_TARGET_DATA_SHARDS = 100
+	// 校验分片在可还原全部数据分片之外，再多容纳的损坏字节数
+	FEC_EXTRA_PARITY = 1024
+	// 尾部元数据无法读取时，从文件末尾向前查找校验块的范围
+	FEC_SEARCH_WINDOW = 2 * MAX_FEC_LENGTH
+)
+
+// fecHeader 纠错校验块头
+type fecHeader struct {
+	ShardSize     int
+	DataShards    int
+	ParityShards  int
+	HeadLength    int
+	TrailerLength int
+	AttachStart   int64
+	Offset        int64
+	// 各分片（数据分片在前）的 CRC32C
+	Checksums []uint32
+}
+
+// 按受保护的数据长度确定分片布局，校验分片数不少于数据分片数，尾部元数据整段丢失也能还原
+func newFECHeader(headLength, trailerLength int) *fecHeader {
+	shardSize := (headLength + trailerLength + FEC_TARGET_DATA_SHARDS - 1) / FEC_TARGET_DATA_SHARDS
+	shardSize = max(FEC_MIN_SHARD_SIZE, (shardSize+FEC_MIN_SHARD_SIZE-1)/FEC_MIN_SHARD_SIZE*FEC_MIN_SHARD_SIZE)
+	header := &fecHeader{ShardSize: shardSize, HeadLength: headLength, TrailerLength: trailerLength}
+	header.DataShards = header.headShards() + header.trailerShards()
+	header.ParityShards = min(RS_MAX_SHARDS-header.DataShards, header.DataShards+(FEC_EXTRA_PARITY+shardSize-1)/shardSize+1)
+	return header
+}
+
+func (h *fecHeader) headShards() int {
+	return (h.HeadLength + h.ShardSize - 1) / h.ShardSize
+}
+
+func (h *fecHeader) trailerShards() int {
+	return (h.TrailerLength + h.ShardSize - 1) / h.ShardSize
+}
+
+func (h *fecHeader) headerLength() int {
+	return FEC_FIXED_HEADER_LENGTH + (h.DataShards+h.ParityShards)*UINT32_LENGTH + UINT32_LENGTH
+}
+
+// 校验块总长度
+func (h *fecHeader) length() int64 {
+	return int64(h.headerLength()) + int64(h.ParityShards)*int64(h.ShardSize)
+}
+
+// 尾部元数据起始偏移
+func (h *fecHeader) trailerStart() int64 {
+	return h.Offset + h.length()
+}
+
+// 合并文件原本的大小
+func (h *fecHeader) fileSize() int64 {
+	return h.trailerStart() + int64(h.TrailerLength)
+}
+
+// 第 i 个数据分片在文件中的位置和有效长度（不含补零）
+func (h *fecHeader) dataShardRange(i int) (int64, int) {
+	if i < h.headShards() {
+		start := i * h.ShardSize
+		return h.AttachStart + int64(start), min(h.ShardSize, h.HeadLength-start)
+	}
+	start := (i - h.headShards()) * h.ShardSize
+	return h.trailerStart() + int64(start), min(h.ShardSize, h.TrailerLength-start)
+}
+
+func (h *fecHeader) encode() []byte {
+	buf := append([]byte(FEC_MAGIC), FEC_VERSION)
+	for _, value := range []int{h.ShardSize, h.DataShards, h.ParityShards, h.HeadLength, h.TrailerLength} {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(value))
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.AttachStart))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(h.Offset))
+	for _, checksum := range h.Checksums {
+		buf = binary.LittleEndian.AppendUint32(buf, checksum)
+	}
+	return binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, crc32cTable))
+}
+
+// 读取 offset 处的校验块头，魔术字节、头校验值或分片布局不符时返回 false
+func readFECHeader(r io.ReaderAt, offset int64) (*fecHeader, bool) {
+	fixed := make([]byte, FEC_FIXED_HEADER_LENGTH)
+	if _, err := r.ReadAt(fixed, offset); err != nil {
+		return nil, false
+	}
+	if string(fixed[:4]) != FEC_MAGIC || fixed[4] != FEC_VERSION {
+		return nil, false
+	}
+	field := func(i int) int {
+		return int(binary.LittleEndian.Uint32(fixed[5+i*UINT32_LENGTH:]))
+	}
+	h := &fecHeader{
+		ShardSize:     field(0),
+		DataShards:    field(1),
+		ParityShards:  field(2),
+		HeadLength:    field(3),
+		TrailerLength: field(4),
+		AttachStart:   int64(binary.LittleEndian.Uint64(fixed[5+UINT32_LENGTH*5:])),
+		Offset:        int64(binary.LittleEndian.Uint64(fixed[5+UINT32_LENGTH*5+SIZE_LENGTH:])),
+	}
+	if h.ShardSize < FEC_MIN_SHARD_SIZE || h.ShardSize > MAX_FEC_LENGTH || h.HeadLength > FEC_ATTACH_HEAD || h.TrailerLength == 0 ||
+		h.DataShards != h.headShards()+h.trailerShards() || h.ParityShards == 0 || h.DataShards+h.ParityShards > RS_MAX_SHARDS ||
+		h.Offset != offset || h.AttachStart < 0 || h.AttachStart+int64(h.HeadLength) > offset || h.length() > MAX_FEC_LENGTH {
+		return nil, false
+	}
+
+	buf := make([]byte, h.headerLength())
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, false
+	}
+	checksumStart := len(buf) - UINT32_LENGTH
+	if crc32.Checksum(buf[:checksumStart], crc32cTable) != binary.LittleEndian.Uint32(buf[checksumStart:]) {
+		return nil, false
+	}
+	h.Checksums = make([]uint32, h.DataShards+h.ParityShards)
+	for i := range h.Checksums {
+		h.Checksums[i] = binary.LittleEndian.Uint32(buf[FEC_FIXED_HEADER_LENGTH+i*UINT32_LENGTH:])
+	}
+	return h, true
+}
+
+// 纠错校验块长度，超出格式上限时返回错误
+func fecBlockLength(headLength, trailerLength int) (uint64, error) {
+	length := newFECHeader(headLength, trailerLength).length()
+	if length > MAX_FEC_LENGTH {
+		return 0, exitErrorf(EXIT_USAGE, "fec.too_large", trailerLength)
+	}
+	return uint64(length), nil
+}
+
+// 生成纠错校验块：head 为附加数据开头（最多 FEC_ATTACH_HEAD 字节），trailer 为写入的尾部元数据，
+// offset 为校验块写入位置（附加数据结束处）
+func buildFECBlock(head, trailer []byte, attachStart, offset int64) []byte {
+	h := newFECHeader(len(head), len(trailer))
+	h.AttachStart = attachStart
+	h.Offset = offset
+
+	data := make([][]byte, 0, h.DataShards)
+	for _, region := range [][]byte{head, trailer} {
+		for start := 0; start < len(region); start += h.ShardSize {
+			shard := make([]byte, h.ShardSize)
+			copy(shard, region[start:])
+			data = append(data, shard)
+		}
+	}
+	parity := newRSCode(h.DataShards, h.ParityShards).encode(data)
+	for _, shard := range append(data, parity...) {
+		h.Checksums = append(h.Checksums, crc32.Checksum(shard, crc32cTable))
+	}
+
+	block := h.encode()
+	for _, shard := range parity {
+		block = append(block, shard...)
+	}
+	return block
+}
+
+// 记下写入的附加数据开头，供生成纠错校验块
+type headCapture struct {
+	data  []byte
+	limit int
+}
+
+func (c *headCapture) Write(p []byte) (int, error) {
+	if n := min(len(p), c.limit-len(c.data)); n > 0 {
+		c.data = append(c.data, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// fecPatch 修复后替换文件内容的一段数据
+type fecPatch struct {
+	offset int64
+	data   []byte
+}
+
+// fecRepairedFile 按修复后的内容读取合并文件：修复过的分片以还原的数据代替，
+// 文件被截断时按原大小读取，截断部分由修复数据补回
+type fecRepairedFile struct {
+	mergedReader
+	size    int64
+	patches []fecPatch
+}
+
+func (f *fecRepairedFile) ReadAt(b []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	want := b[:min(int64(len(b)), f.size-off)]
+	n, err := f.mergedReader.ReadAt(want, off)
+	if n < len(want) {
+		if err != io.EOF {
+			return n, err
+		}
+		clear(want[n:])
+	}
+	for _, patch := range f.patches {
+		start := max(patch.offset, off)
+		end := min(patch.offset+int64(len(patch.data)), off+int64(len(want)))
+		if start < end {
+			copy(want[start-off:end-off], patch.data[start-patch.offset:end-patch.offset])
+		}
+	}
+	if len(want) < len(b) {
+		return len(want), io.EOF
+	}
+	return len(want), nil
+}
+
+// 尾部元数据无法读取时，从文件末尾向前查找纠错校验块
+func findFECHeader(r io.ReaderAt, fileSize int64) (*fecHeader, bool) {
+	windowStart := max(0, fileSize-FEC_SEARCH_WINDOW)
+	window := make([]byte, fileSize-windowStart)
+	n, err := r.ReadAt(window, windowStart)
+	if err != nil && err != io.EOF {
+		return nil, false
+	}
+	window = window[:n]
+	for end := len(window); ; {
+		index := bytes.LastIndex(window[:end], []byte(FEC_MAGIC))
+		if index < 0 {
+			return nil, false
+		}
+		if header, ok := readFECHeader(r, windowStart+int64(index)); ok {
+			return header, true
+		}
+		end = index
+	}
+}
+
+// 读取分片，超出文件末尾的部分为零，返回实际读到的长度
+func readFECShard(r io.ReaderAt, shard []byte, offset int64, length int) int {
+	n, _ := r.ReadAt(shard[:length], offset)
+	clear(shard[n:])
+	return n
+}
+
+// 用纠错校验块检查并修复合并文件末尾。trailer 为已读取的尾部元数据，为 nil 时（读取失败）从文件末尾查找校验块。
+// 没有校验块或没有损坏时返回 nil；损坏的分片多于校验分片时返回错误
+func repairWithFEC(file mergedReader, fileSize int64, trailer *TrailerInfo, debugInfo *DebugInfo) (*fecRepairedFile, error) {
+	var header *fecHeader
+	var ok bool
+	if trailer != nil {
+		header, ok = readFECHeader(file, attachStartOf(trailer)+int64(trailer.AttachSize))
+	} else {
+		header, ok = findFECHeader(file, fileSize)
+	}
+	if !ok {
+		if trailer != nil {
+			return nil, newError("fec.bad_header")
+		}
+		return nil, nil
+	}
+	debugInfo.FECAttempted = true
+	logDebugf("devlog.fec_found", header.Offset, header.DataShards, header.ParityShards, header.ShardSize)
+
+	// 逐个读取分片并校验 CRC，超出文件末尾（被截断）的分片同样视为损坏
+	total := header.DataShards + header.ParityShards
+	shards := make([][]byte, total)
+	good := make([]bool, total)
+	present := make([]int, header.DataShards)
+	bad, badData := 0, 0
+	for i := range shards {
+		shards[i] = make([]byte, header.ShardSize)
+		var offset int64
+		length := header.ShardSize
+		if i < header.DataShards {
+			offset, length = header.dataShardRange(i)
+		} else {
+			offset = header.Offset + int64(header.headerLength()) + int64(i-header.DataShards)*int64(header.ShardSize)
+		}
+		n := readFECShard(file, shards[i], offset, length)
+		if i < header.DataShards {
+			present[i] = n
+		}
+		good[i] = n == length && crc32.Checksum(shards[i], crc32cTable) == header.Checksums[i]
+		if !good[i] {
+			bad++
+			if i < header.DataShards {
+				badData++
+			}
+		}
+	}
+	debugInfo.FECBadShards = bad
+	// 只有校验分片损坏时受保护的数据完好，无需修复
+	if badData == 0 {
+		logDebugf("devlog.fec_clean", bad)
+		return nil, nil
+	}
+	if bad > header.ParityShards {
+		return nil, newError("fec.unrecoverable", bad, header.ParityShards)
+	}
+
+	original := make([][]byte, header.DataShards)
+	for i := range original {
+		original[i] = bytes.Clone(shards[i])
+	}
+	if !newRSCode(header.DataShards, header.ParityShards).reconstruct(shards, good) {
+		return nil, newError("fec.unrecoverable", bad, header.ParityShards)
+	}
+
+	// 只替换损坏的数据分片，记录修复的字节数（内容不同或已缺失）
+	repaired := &fecRepairedFile{mergedReader: file, size: header.fileSize()}
+	for i := 0; i < header.DataShards; i++ {
+		if good[i] {
+			continue
+		}
+		if crc32.Checksum(shards[i], crc32cTable) != header.Checksums[i] {
+			return nil, newError("fec.unrecoverable", bad, header.ParityShards)
+		}
+		offset, length := header.dataShardRange(i)
+		repaired.patches = append(repaired.patches, fecPatch{offset: offset, data: shards[i][:length]})
+		debugInfo.FECRepairedShards++
+		for j := 0; j < length; j++ {
+			if j >= present[i] || shards[i][j] != original[i][j] {
+				debugInfo.FECCorrectedBytes++
+			}
+		}
+	}
+	logDebugf("devlog.fec_repaired", bad, debugInfo.FECRepairedShards, debugInfo.FECCorrectedBytes)
+	return repaired, nil
+}
+
+// 拆分前用纠错校验块修复合并文件，返回按修复后内容读取的文件；没有校验块、没有损坏或无法修复时返回 nil
+func repairMergedInput(file mergedReader, fileSize int64, trailer *TrailerInfo, debugInfo *DebugInfo) *fecRepairedFile {
+	repaired, err := repairWithFEC(file, fileSize, trailer, debugInfo)
+	if err != nil {
+		debugInfo.FECAttempted = true
+		debugInfo.FECError = err.Error()
+		logWarnf("fec.repair_failed", err)
+		return nil
+	}
+	if repaired == nil {
+		return nil
+	}
+
+	colorYellow.Printf(msg("fec.repaired"), debugInfo.FECRepairedShards)
+	// 按修复后的内容重新解析，清除上次解析留下的错误
+	debugInfo.FileSize = repaired.size
+	debugInfo.ValidationError = ""
+	debugInfo.UnexpectedEOF = false
+	debugInfo.CalculatedPos = make(map[string]int64)
+	return repaired
+}
@@ -0,0 +1,24 @@
+package main
+
+import "path/filepath"
+
+// FAT32 单个文件大小上限 (4GB - 1)
+const FAT_FILE_SIZE_LIMIT = 4*1024*1024*1024 - 1
+
+// 输出所在文件系统有单文件大小上限（如 FAT32）且合并结果会超出时警告，
+// 并建议使用 --volume-size 分卷；已分卷且每卷不超过上限时不提示
+func checkFileSizeLimit(outputPath string, size, volumeSize int64) error {
+	dir := existingParentDir(outputPath)
+	fsName, limit := fileSizeLimit(dir)
+	if limit <= 0 {
+		return nil
+	}
+	if volumeSize > 0 {
+		size = volumeSize
+	}
+	if size <= limit {
+		return nil
+	}
+	colorYellow.Printf(msg("volume.fs_limit"), filepath.Clean(dir), fsName, formatFileSize(limit), formatFileSize(size))
+	return requireConfirmation(msg("volume.confirm_fs_limit"))
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+
+package main
+
+import "syscall"
+
+// 目录所在文件系统的名称和单文件大小上限，无上限或无法判断时 limit 为 0
+func fileSizeLimit(dir string) (string, int64) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return "", 0
+	}
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	// exFAT 为 "exfat"，没有 4GB 限制
+	if string(name) == "msdos" {
+		return "FAT", FAT_FILE_SIZE_LIMIT
+	}
+	return "", 0
+}
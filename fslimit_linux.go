@@ -0,0 +1,20 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// FAT (vfat/msdos) 的文件系统类型编号
+const MSDOS_SUPER_MAGIC = 0x4d44
+
+// 目录所在文件系统的名称和单文件大小上限，无上限或无法判断时 limit 为 0
+func fileSizeLimit(dir string) (string, int64) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return "", 0
+	}
+	if stat.Type == MSDOS_SUPER_MAGIC {
+		return "FAT", FAT_FILE_SIZE_LIMIT
+	}
+	return "", 0
+}
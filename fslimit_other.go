@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+// 其他系统无法判断文件系统类型，不做限制检查
+func fileSizeLimit(dir string) (string, int64) {
+	return "", 0
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetVolumeInformation = syscall.NewLazyDLL("kernel32.dll").NewProc("GetVolumeInformationW")
+
+// 目录所在磁盘的文件系统名称和单文件大小上限，无上限或无法判断时 limit 为 0
+func fileSizeLimit(dir string) (string, int64) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", 0
+	}
+	// GetVolumeInformationW 需要以分隔符结尾的卷根目录，如 C:\ 或 \\server\share\
+	root, err := syscall.UTF16PtrFromString(filepath.VolumeName(abs) + `\`)
+	if err != nil {
+		return "", 0
+	}
+	name := make([]uint16, syscall.MAX_PATH+1)
+	ret, _, _ := procGetVolumeInformation.Call(uintptr(unsafe.Pointer(root)), 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&name[0])), uintptr(len(name)))
+	if ret == 0 {
+		return "", 0
+	}
+	// exFAT 为 "exFAT"，没有 4GB 限制
+	fsName := syscall.UTF16ToString(name)
+	if strings.EqualFold(fsName, "FAT32") || strings.EqualFold(fsName, "FAT") {
+		return fsName, FAT_FILE_SIZE_LIMIT
+	}
+	return "", 0
+}
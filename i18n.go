@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// 中文（默认）
+	LANG_ZH = "zh"
+	// 英文
+	LANG_EN = "en"
+)
+
+var (
+	// --lang 指定的输出语言
+	langFlag = ""
+	// 当前输出语言
+	currentLang = LANG_ZH
+)
+
+// message 一条消息的中英文文本
+type message struct {
+	zh string
+	en string
+}
+
+// 按ID取当前语言的消息，缺少译文时回退到中文
+func msg(id string) string {
+	m, ok := messages[id]
+	if !ok {
+		return id
+	}
+	if currentLang == LANG_EN && m.en != "" {
+		return m.en
+	}
+	return m.zh
+}
+
+// 按ID取消息并格式化；与错误共用的消息可能含 %w，按 %v 输出
+func msgf(id string, args ...interface{}) string {
+	return fmt.Errorf(msg(id), args...).Error()
+}
+
+// msgError 以消息ID记录的错误，显示时才按当前语言生成文本；
+// 判断错误类型请用 errors.Is/errors.As，不要比较错误文本
+type msgError struct {
+	id      string
+	args    []interface{}
+	wrapped error
+}
+
+// 创建以消息ID记录的错误，格式中的 %w 与 fmt.Errorf 相同
+func newError(id string, args ...interface{}) error {
+	e := &msgError{id: id, args: args}
+	if len(args) > 0 {
+		e.wrapped = errors.Unwrap(fmt.Errorf(messages[id].zh, args...))
+	}
+	return e
+}
+
+func (e *msgError) Error() string {
+	if len(e.args) == 0 {
+		return msg(e.id)
+	}
+	return fmt.Errorf(msg(e.id), e.args...).Error()
+}
+
+func (e *msgError) Unwrap() error {
+	return e.wrapped
+}
+
+// 同一消息ID且不带参数的错误视为同一错误
+func (e *msgError) Is(target error) bool {
+	t, ok := target.(*msgError)
+	return ok && len(t.args) == 0 && t.id == e.id
+}
+
+// 解析 --lang 的值，如 en、en_US、zh-CN
+func parseLanguage(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case strings.HasPrefix(name, LANG_ZH):
+		return LANG_ZH, true
+	case strings.HasPrefix(name, LANG_EN):
+		return LANG_EN, true
+	}
+	return "", false
+}
+
+// 选择输出语言：--lang 优先，其次 LC_ALL、LC_MESSAGES、LANG 环境变量
+// （中文区域设置用中文，其他语言用英文），未设置或为 C/POSIX 时默认中文
+func configureLanguage() error {
+	if langFlag != "" {
+		lang, ok := parseLanguage(langFlag)
+		if !ok {
+			return exitErrorf(EXIT_USAGE, "i18n.bad_lang", langFlag)
+		}
+		currentLang = lang
+		return nil
+	}
+	currentLang = LANG_ZH
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := strings.ToLower(os.Getenv(key))
+		if value == "" {
+			continue
+		}
+		if base, _, _ := strings.Cut(value, "."); base != "c" && base != "posix" && !strings.HasPrefix(base, LANG_ZH) {
+			currentLang = LANG_EN
+		}
+		break
+	}
+	return nil
+}
+
+// 帮助文本中需要代入的参数，按消息ID；译文按显示时的语言生成
+var helpArgs = map[string]func() []interface{}{
+	"help.root.long":          func() []interface{} { return []interface{}{msg("help.exit_codes")} },
+	"flag.root.magic":         func() []interface{} { return []interface{}{MAGIC_LENGTH} },
+	"flag.repair.attach_name": func() []interface{} { return []interface{}{REPAIR_DEFAULT_ATTACH_NAME} },
+}
+
+// 按ID取命令帮助、选项说明的文本
+func helpText(id string) string {
+	if args, ok := helpArgs[id]; ok {
+		return fmt.Sprintf(msg(id), args()...)
+	}
+	return msg(id)
+}
+
+// 命令在帮助消息ID中的名称：去掉根命令名，空格和 - 记为 _，根命令为 root
+func helpKey(cmd *cobra.Command) string {
+	if !cmd.HasParent() {
+		return "root"
+	}
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(path)
+}
+
+// 按当前语言重新生成全部命令的说明和选项说明；
+// 命令定义在选择语言之前初始化，显示帮助和补全前调用
+func localizeHelp(cmd *cobra.Command) {
+	key := helpKey(cmd)
+	if _, ok := messages["help."+key+".short"]; ok {
+		cmd.Short = helpText("help." + key + ".short")
+	}
+	if _, ok := messages["help."+key+".long"]; ok {
+		cmd.Long = helpText("help." + key + ".long")
+	}
+	localize := func(flag *pflag.Flag) {
+		id := "flag." + key + "." + strings.ReplaceAll(flag.Name, "-", "_")
+		if _, ok := messages[id]; ok {
+			flag.Usage = helpText(id)
+		}
+	}
+	cmd.LocalFlags().VisitAll(localize)
+	for _, child := range cmd.Commands() {
+		localizeHelp(child)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// 每个命令的说明和每个选项的说明都应登记在消息目录中，且有英文译文
+func TestHelpMessagesComplete(t *testing.T) {
+	var check func(cmd *cobra.Command)
+	check = func(cmd *cobra.Command) {
+		key := helpKey(cmd)
+		if m, ok := messages["help."+key+".short"]; !ok || m.en == "" {
+			t.Errorf("missing help.%s.short", key)
+		}
+		cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+			if flag.Name == "help" || flag.Name == "version" {
+				return
+			}
+			id := "flag." + key + "." + strings.ReplaceAll(flag.Name, "-", "_")
+			if m, ok := messages[id]; !ok || m.en == "" {
+				t.Errorf("missing %s", id)
+			}
+		})
+		for _, child := range cmd.Commands() {
+			check(child)
+		}
+	}
+	check(rootCmd)
+}
+
+func TestHelpLanguage(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"split", "--help", "--lang", "en"}, []string{"Extract the original video file", "output directory (default extracted_<name>)", "custom magic bytes (must be 8 bytes)"}},
+		{[]string{"--help", "--lang", "en"}, []string{"Exit codes:", "Split a merged file"}},
+		{[]string{"repair", "--help", "--lang", "zh"}, []string{"尾部元数据损坏但视频和附加数据完好时", "记录的附加文件名（默认 " + REPAIR_DEFAULT_ATTACH_NAME + "）"}},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := runMain(t, tt.args...)
+		if code != EXIT_OK {
+			t.Fatalf("%v: exit code = %d\n%s", tt.args, code, stderr)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout+stderr, want) {
+				t.Errorf("%v: help missing %q", tt.args, want)
+			}
+		}
+	}
+}
+
+// 以字面量传给消息函数的 ID 都应登记在消息目录中，否则用户看到的是原始 ID
+func TestMessageIDsExist(t *testing.T) {
+	// 函数名 → 消息 ID 参数的位置
+	idArgs := map[string]int{
+		"msg": 0, "msgf": 0, "newError": 0, "helpText": 0, "notMergedErrorf": 0, "authFailure": 0, "confirmStart": 0,
+		"logDebugf": 0, "logWarnf": 0, "logErrorf": 0, "exitErrorf": 1, "logInfof": 1, "logf": 2,
+	}
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	checked := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Fun.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			index, ok := idArgs[fn.Name]
+			if !ok || index >= len(call.Args) {
+				return true
+			}
+			lit, ok := call.Args[index].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			id, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checked++
+			if _, ok := messages[id]; !ok {
+				t.Errorf("%s: %s(%q) is not in the message catalog", fset.Position(lit.Pos()), fn.Name, id)
+			}
+			return true
+		})
+	}
+	if checked == 0 {
+		t.Fatal("no message IDs found")
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// 显示光标（进度条绘制中断时可能残留隐藏状态）
+const SHOW_CURSOR = "\x1b[?25h"
+
+var (
+	// 当前操作的上下文，收到 SIGINT/SIGTERM 时取消，copyWithProgress 据此中止复制
+	operationCtx, cancelOperation = context.WithCancel(context.Background())
+	operationMu                   sync.Mutex
+	// 收到 SIGTERM，交互模式下也不再返回主菜单
+	terminating atomic.Bool
+
+	// 交互模式下输入提示被 Ctrl-C 打断，返回主菜单
+	errPromptInterrupted = newError("interrupt.prompt")
+
+	// 正在进行的复制数量
+	activeCopies atomic.Int32
+	// 交互模式正在执行菜单项（此时输入提示可被打断）
+	menuActionActive atomic.Bool
+	// 交互模式下输入提示被打断的通知
+	promptInterrupt = make(chan struct{}, 1)
+
+	// 尚未写完的输出文件，中断时删除
+	partialOutputs   = make(map[string]bool)
+	partialOutputsMu sync.Mutex
+
+	// 读取密码前的终端状态，中断时恢复回显
+	savedTerminalState atomic.Pointer[term.State]
+
+	// 交互模式会话中（输入提示改为可打断的读取）
+	interactiveSession = false
+	// 交互模式下等待中的标准输入读取
+	stdinLines   = make(chan string, 1)
+	stdinPending = false
+)
+
+// 安装 SIGINT/SIGTERM 处理
+func installInterruptHandler() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGTERM {
+				terminating.Store(true)
+			}
+			handleInterrupt()
+		}
+	}()
+}
+
+// 处理一次中断：复制中先取消复制，交互模式的菜单项中返回主菜单，其他情况清理后退出
+func handleInterrupt() {
+	operationMu.Lock()
+	ctx, cancel := operationCtx, cancelOperation
+	operationMu.Unlock()
+
+	// 已请求取消仍收到中断时立即退出
+	if ctx.Err() != nil {
+		exitInterrupted()
+	}
+	if activeCopies.Load() > 0 {
+		cancel()
+		return
+	}
+	if menuActionActive.Load() && !terminating.Load() {
+		select {
+		case promptInterrupt <- struct{}{}:
+		default:
+		}
+		return
+	}
+	exitInterrupted()
+}
+
+// 操作阶段，用于说明取消时进行到哪一步（消息ID为 "phase." + 阶段）
+const (
+	PHASE_PREPARE     = "prepare"
+	PHASE_VIDEO       = "video"
+	PHASE_ATTACHMENTS = "attachments"
+	PHASE_METADATA    = "metadata"
+	PHASE_VERIFY      = "verify"
+)
+
+// CanceledError 操作因上下文取消而中止。Phase 为当时的阶段，Written 为该阶段已复制的字节数；
+// errors.Is(err, context.Canceled) 等判断按 Err 进行
+type CanceledError struct {
+	Phase   string
+	Written int64
+	Err     error
+}
+
+func (e *CanceledError) Error() string {
+	return msgf("interrupt.canceled", msg("phase."+e.Phase), formatFileSize(e.Written))
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// 为尚未标明阶段的 CanceledError 标明阶段（并行提取时各任务分别标明）
+func inPhase(err error, phase string) error {
+	var canceled *CanceledError
+	if errors.As(err, &canceled) && canceled.Phase == "" {
+		canceled.Phase = phase
+	}
+	return err
+}
+
+// 上下文已取消时，把 err 换成记录了阶段的 CanceledError，并删除本次操作未写完的输出
+func canceledOperation(ctx context.Context, err error, phase string) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	cleanupPartialOutputs()
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		canceled = &CanceledError{Err: ctx.Err()}
+	}
+	if canceled.Phase == "" {
+		canceled.Phase = phase
+	}
+	return canceled
+}
+
+// 当前操作的上下文，命令行中收到 SIGINT/SIGTERM 时取消
+func operationContext() context.Context {
+	operationMu.Lock()
+	defer operationMu.Unlock()
+	return operationCtx
+}
+
+// 操作是否已被中断
+func operationInterrupted() bool {
+	return operationContext().Err() != nil
+}
+
+// 中断处理完毕，重新允许后续操作（交互模式）
+func resetOperation() {
+	operationMu.Lock()
+	operationCtx, cancelOperation = context.WithCancel(context.Background())
+	operationMu.Unlock()
+	select {
+	case <-promptInterrupt:
+	default:
+	}
+}
+
+// 登记正在写入的输出文件
+func trackPartialOutput(path string) {
+	partialOutputsMu.Lock()
+	defer partialOutputsMu.Unlock()
+	partialOutputs[path] = true
+}
+
+// 输出文件已写完，不再在中断时删除
+func finishPartialOutput(path string) {
+	partialOutputsMu.Lock()
+	defer partialOutputsMu.Unlock()
+	delete(partialOutputs, path)
+}
+
+// 删除所有未写完的输出文件
+func cleanupPartialOutputs() {
+	partialOutputsMu.Lock()
+	defer partialOutputsMu.Unlock()
+	for path := range partialOutputs {
+		if err := os.Remove(path); err == nil {
+			colorYellow.Fprintf(color.Error, msg("interrupt.removed"), path)
+		} else if !os.IsNotExist(err) {
+			colorRed.Fprintf(color.Error, msg("interrupt.remove_failed"), path, err)
+		}
+		delete(partialOutputs, path)
+	}
+}
+
+// 恢复终端：换行离开进度条、显示光标、恢复密码输入前的回显状态
+func restoreTerminal() {
+	if state := savedTerminalState.Load(); state != nil {
+		term.Restore(int(os.Stdin.Fd()), state)
+	}
+	fmt.Fprintln(color.Error)
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		fmt.Fprint(color.Error, SHOW_CURSOR)
+	}
+}
+
+// 清理未完成的输出并以中断退出码退出
+func exitInterrupted() {
+	restoreTerminal()
+	cleanupPartialOutputs()
+	colorYellow.Fprintln(color.Error, msg("interrupt.exiting"))
+	logDebugf("interrupt.exiting")
+	os.Exit(EXIT_INTERRUPTED)
+}
+
+// 交互模式下读取一行输入，可被 Ctrl-C 打断；被打断时未完成的读取留给下一次提示
+func readInterruptibleLine() string {
+	if !stdinPending {
+		stdinPending = true
+		go func() {
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			stdinLines <- line
+		}()
+	}
+	select {
+	case line := <-stdinLines:
+		stdinPending = false
+		return line
+	case <-promptInterrupt:
+		panic(errPromptInterrupted)
+	}
+}
+
+// 执行交互菜单项；其中的输入提示被 Ctrl-C 打断时返回 errPromptInterrupted
+func runMenuAction(action func() error) (err error) {
+	menuActionActive.Store(true)
+	defer func() {
+		menuActionActive.Store(false)
+		if r := recover(); r != nil {
+			if r != errPromptInterrupted {
+				panic(r)
+			}
+			err = errPromptInterrupted
+		}
+	}()
+	return action()
+}
+
+// 菜单项因中断结束时清理并返回主菜单
+func interruptedToMenu(err error) bool {
+	if err != errPromptInterrupted && !operationInterrupted() {
+		return false
+	}
+	if terminating.Load() {
+		exitInterrupted()
+	}
+	fmt.Fprintln(color.Error)
+	cleanupPartialOutputs()
+	resetOperation()
+	colorYellow.Println(msg("interrupt.back_to_menu"))
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// 上报的字节数达到 after 时取消操作，模拟复制途中收到 SIGINT
+type cancelingProgress struct {
+	cancel context.CancelFunc
+	after  int64
+	added  atomic.Int64
+}
+
+func (p *cancelingProgress) Start(int64) {}
+
+func (p *cancelingProgress) Add(n int64) {
+	if p.added.Add(n) >= p.after {
+		p.cancel()
+	}
+}
+
+func (p *cancelingProgress) Done() {}
+
+// 检查取消错误标明了阶段，并且输出目录中没有留下不完整的文件
+func checkCanceled(t *testing.T, err error, dir string) *CanceledError {
+	t.Helper()
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want CanceledError wrapping context.Canceled", err)
+	}
+	if canceled.Phase == "" {
+		t.Error("canceled error has no phase")
+	}
+	if exitCodeOf(err) != EXIT_INTERRUPTED {
+		t.Errorf("exit code %d, want %d", exitCodeOf(err), EXIT_INTERRUPTED)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("partial output %s was not removed", entry.Name())
+	}
+	return canceled
+}
+
+func TestMergeCanceledMidCopy(t *testing.T) {
+	videoPath := writeTempFile(t, "v.mp4", bytes.Repeat([]byte("video "), 2*BUFFER_SIZE))
+	attachPath := writeTempFile(t, "a.txt", []byte("attachment"))
+	outputDir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := &cancelingProgress{cancel: cancel, after: BUFFER_SIZE}
+	err := mergeFiles(ctx, videoPath, []string{attachPath}, filepath.Join(outputDir, "out.mp4"), MergeOptions{Progress: progress})
+	if canceled := checkCanceled(t, err, outputDir); canceled.Phase != PHASE_VIDEO || canceled.Written < BUFFER_SIZE {
+		t.Errorf("canceled in phase %q after %d bytes, want %q after at least %d", canceled.Phase, canceled.Written, PHASE_VIDEO, BUFFER_SIZE)
+	}
+}
+
+func TestSplitCanceledMidCopy(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 2*BUFFER_SIZE)
+	attach := bytes.Repeat([]byte("attachment "), BUFFER_SIZE)
+	mergedPath := writeTempFile(t, "merged.mp4", mergedBytes(t, video, attach, "a.txt"))
+
+	for _, sequential := range []bool{true, false} {
+		outputDir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := &cancelingProgress{cancel: cancel, after: BUFFER_SIZE}
+		err := splitFiles(ctx, mergedPath, outputDir, SplitOptions{Progress: progress, Sequential: sequential})
+		cancel()
+		canceled := checkCanceled(t, err, outputDir)
+		// 并行提取时先察觉取消的任务不一定已写入数据
+		if sequential && canceled.Written < BUFFER_SIZE {
+			t.Errorf("sequential: canceled after %d bytes, want at least %d", canceled.Written, BUFFER_SIZE)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+// 日志级别
+const (
+	LOG_DEBUG = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+// --log-format 可选值
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+var (
+	// --log-format 指定的日志格式
+	logFormatFlag = LOG_FORMAT_TEXT
+	// 每行输出一条JSON日志，不再输出带颜色的文字
+	logJSON = false
+	// JSON日志的输出位置（标准错误，不占用留给结果的标准输出）
+	logOutput io.Writer = os.Stderr
+)
+
+var logLevelNames = [...]string{"debug", "info", "warn", "error"}
+
+// 按 --log-format 设置日志格式；须在安静模式接管标准输出之前调用
+func configureLogging() error {
+	switch strings.ToLower(logFormatFlag) {
+	case LOG_FORMAT_TEXT:
+		logJSON = false
+	case LOG_FORMAT_JSON:
+		logJSON = true
+		logOutput = os.Stderr
+	default:
+		return exitErrorf(EXIT_USAGE, "log.bad_format", logFormatFlag)
+	}
+	return nil
+}
+
+// 当前输出的最低级别：开发模式下输出调试信息，安静模式下只输出警告和错误
+func minLogLevel() int {
+	switch {
+	case devMode:
+		return LOG_DEBUG
+	case quietMode:
+		return LOG_WARN
+	}
+	return LOG_INFO
+}
+
+// 调试信息：开发模式下显示，启用 --dev-log 时总是写入日志文件
+func logDebugf(id string, args ...interface{}) {
+	logf(LOG_DEBUG, colorMagenta, id, args...)
+}
+
+// 操作进度和结果，c 为文字格式下的颜色（nil 为不着色）
+func logInfof(c *color.Color, id string, args ...interface{}) {
+	logf(LOG_INFO, c, id, args...)
+}
+
+func logWarnf(id string, args ...interface{}) {
+	logf(LOG_WARN, colorYellow, id, args...)
+}
+
+func logErrorf(id string, args ...interface{}) {
+	logf(LOG_ERROR, colorRed, id, args...)
+}
+
+// 写入一条日志，id 为消息目录中的消息ID
+func logf(level int, c *color.Color, id string, args ...interface{}) {
+	text := msgf(id, args...)
+	if devLog != nil {
+		fmt.Fprintf(devLog, "[%s] %-5s %s\n", time.Now().Format(DEV_LOG_TIME_FORMAT), strings.ToUpper(logLevelNames[level]), strings.Trim(text, "\n"))
+	}
+	if level < minLogLevel() {
+		return
+	}
+	if logJSON {
+		writeJSONLog(level, id, text, args)
+		return
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	// 文字格式与原来的彩色输出相同（安静模式下已被屏蔽）
+	if c != nil {
+		c.Print(text)
+	} else {
+		fmt.Fprint(messageOutput, text)
+	}
+}
+
+// logEntry 一行JSON日志
+type logEntry struct {
+	Time  string        `json:"time"`
+	Level string        `json:"level"`
+	ID    string        `json:"id"`
+	Msg   string        `json:"msg"`
+	Args  []interface{} `json:"args,omitempty"`
+}
+
+// 去掉文字格式中的表情符号和多余空白，JSON日志只保留纯文字
+func plainText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.So, r) || r == '\uFE0F' || r == '\u200D' {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func writeJSONLog(level int, id, text string, args []interface{}) {
+	entry := logEntry{
+		Time:  time.Now().Format(time.RFC3339Nano),
+		Level: logLevelNames[level],
+		ID:    id,
+		Msg:   plainText(text),
+	}
+	// 错误等值按文字记录，便于日志系统检索
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case error:
+			arg = v.Error()
+		case fmt.Stringer:
+			arg = v.String()
+		}
+		entry.Args = append(entry.Args, arg)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(logOutput, string(line))
+}
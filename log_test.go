@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"\n📦 Merged file: a.mp4 (1 KB)\n", "Merged file: a.mp4 (1 KB)"},
+		{"   ⚠️  File has no SHA-256 checksums", "File has no SHA-256 checksums"},
+		{"\n✅ 校验通过!\n", "校验通过!"},
+		{"a → b", "a → b"},
+	}
+	for _, tt := range tests {
+		if got := plainText(tt.text); got != tt.want {
+			t.Errorf("plainText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// 检查标准错误的每一行都是一条JSON日志，消息为纯文字
+func checkJSONLog(t *testing.T, stderr string) []logEntry {
+	t.Helper()
+	var entries []logEntry
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var entry logEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("stderr line is not a JSON record: %q", line)
+		}
+		if entry.Msg != plainText(entry.Msg) {
+			t.Errorf("record %s has decorated msg %q", entry.ID, entry.Msg)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLogOnlyRecords(t *testing.T) {
+	path := writeTempFile(t, "merged.mp4", mergedBytes(t, []byte("video data"), []byte("attachment"), "a.txt"))
+	code, stdout, stderr := runMain(t, "--log-format", "json", "--lang", "en", "verify", path)
+	if code != EXIT_OK {
+		t.Fatalf("exit code = %d\n%s", code, stderr)
+	}
+	checkJSONLog(t, stderr)
+	if !strings.Contains(stdout, "Verification passed") {
+		t.Errorf("verify report missing from stdout:\n%s", stdout)
+	}
+
+	// 失败时错误同样是一条日志记录
+	code, _, stderr = runMain(t, "--log-format", "json", "verify", path+".missing")
+	if code == EXIT_OK {
+		t.Fatal("verify of a missing file succeeded")
+	}
+	entries := checkJSONLog(t, stderr)
+	if last := entries[len(entries)-1]; last.Level != "error" || last.ID != "main.error" {
+		t.Errorf("last record = %+v, want the main.error record", last)
+	}
+}
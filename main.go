@@ -2,37 +2,55 @@ package main
 
 import (
 	"bufio"
-	"encoding/binary"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 	"unicode/utf8"
 
+	"github.com/cancundeyingzi/Video-File-Merge-Split-Tool/pkg/mergefmt"
 	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const (
-	// v3格式魔术字节标记
-	MAGIC_BYTES = "MERGEDv3"
-	// 读写缓冲区大小 (1MB)
+	// v3格式默认魔术字节标记
+	MAGIC_BYTES = mergefmt.MAGIC_BYTES
+	// 读写缓冲区默认大小 (1MB)，可用 --buffer-size 调整
 	BUFFER_SIZE = 1024 * 1024
-	// 文件名最大长度
-	MAX_FILENAME_LENGTH = 255
+	// 元数据中文件名最大长度（字节）
+	MAX_FILENAME_LENGTH = mergefmt.MAX_FILENAME_LENGTH
+	// 常见文件系统单个文件名的最大长度（字节），提取时超出才缩短
+	FS_FILENAME_LENGTH = 255
 	// 魔术字节长度
-	MAGIC_LENGTH = 8 // "MERGEDv3"
+	MAGIC_LENGTH = mergefmt.MAGIC_LENGTH
 	// v3格式：文件大小字段长度（8字节）
-	SIZE_LENGTH = 8 // uint64
+	SIZE_LENGTH = mergefmt.SIZE_LENGTH
 	// 4字节长度字段（文件名长度）
-	UINT32_LENGTH = 4
+	UINT32_LENGTH = mergefmt.UINT32_LENGTH
 	// v3最小文件大小检查
 	MIN_V3_FILE_SIZE = 24 // 最小元数据大小
+	// 进度条最短刷新间隔
+	PROGRESS_UPDATE_INTERVAL = 100 * time.Millisecond
+	// 复制量每增加此字节数 (64MB) 也刷新一次进度条
+	PROGRESS_UPDATE_BYTES = 64 * 1024 * 1024
+	// 不显示进度时每次交给 io.CopyBuffer 的数据量，两块之间检查 Ctrl-C
+	COPY_CHUNK_SIZE = 64 * 1024 * 1024
 )
 
 var (
@@ -46,120 +64,600 @@ var (
 
 	// 开发模式标志
 	devMode = false
+
+	// 安静模式：不显示横幅、颜色和进度条，只输出错误和结果路径
+	quietMode = false
+	// 关闭彩色输出 (--no-color)
+	noColor = false
+	// 不显示进度条 (--no-progress)
+	noProgress = false
+	// 结果输出（输出路径、JSON、info 和 verify 报告），始终写入标准输出
+	resultOutput io.Writer = os.Stdout
+	// 普通输出（横幅、提示、摘要），命令执行时写到标准错误，安静模式下被屏蔽
+	messageOutput io.Writer = os.Stdout
+	// 原标准输出是否为终端
+	resultIsTerminal = true
+
+	// merge、split 以JSON输出结果
+	jsonOutput = false
+
+	// info 命令输出JSON
+	infoJSON = false
+	// info 命令的输出格式（json、yaml、csv）
+	infoFormat = ""
+
+	// detect 命令输出检测详情
+	detectVerbose = false
+
+	// 命令行合并、拆分选项
+	mergeOpts MergeOptions
+	batchOpts MergeOptions
+	// merge 分卷大小 (--volume-size)，如 4000M
+	mergeVolumeSize string
+	// merge 省略输出文件时默认名称输出的目录 (--output-dir)
+	mergeOutputDir string
+	// split 输出目录 (-o)
+	splitOutputDir string
+	splitOpts      SplitOptions
+	appendOpts     AppendOptions
+	updateOpts     UpdateOptions
+	restoreOpts    RestoreOptions
+	scanOpts       ScanOptions
+	askPassword    = false
+
+	// split 附加文件分卷大小 (--attach-volume-size)，如 4G
+	splitAttachVolumeSize string
+
+	// verify 命令的认证密钥 (--auth-key) 和 --ignore-auth
+	verifyAuthKey    string
+	verifyIgnoreAuth bool
+
+	// 隐蔽模式合并文件的密钥（全局选项）
+	stealthKey string
+
+	// 所有确认默认同意 (--yes)；已存在的输出直接覆盖 (--force)
+	assumeYes      = false
+	forceOverwrite = false
+
+	// 当前使用的魔术字节，可通过 --magic 自定义
+	magicBytes = MAGIC_BYTES
 )
 
 // FileInfo 文件信息结构体
 type FileInfo struct {
-	Name string
-	Size int64
-	Path string
+	Name    string
+	Size    int64
+	Path    string
+	IsDir   bool
+	IsStdin bool
+	ModTime time.Time
+	Mode    os.FileMode
+
+	// Path 为符号链接时解析后的目标路径，否则为空；Name 取自目标文件
+	Target string
+}
+
+// MergeOptions 合并选项
+type MergeOptions struct {
+	// 非空时使用 AES-256-GCM 加密附加文件
+	Password string
+	// 非空时改用此密钥文件加密附加文件（不能与 Password 同时指定）
+	KeyFile string
+	// 非空时以此密钥写入尾部元数据认证值 (HMAC-SHA256)
+	AuthKey string
+	// 写入前使用 gzip 压缩附加文件
+	Compress bool
+	// 备注（UTF-8，为空时不写入）
+	Comment string
+	// 隐蔽模式口令，非空时加密整个尾部元数据
+	StealthKey string
+	// 附加数据起始位置对齐单位（字节），0表示不对齐
+	Align int64
+	// 从标准输入读取的附加文件名（附加路径为 - 时必填）
+	AttachName string
+	// 只检查并显示操作计划，不写入任何文件
+	DryRun bool
+	// 允许视频本身已是合并文件（结果需拆分两次）
+	AllowNested bool
+	// 允许空附加文件，不再确认
+	AllowEmpty bool
+	// 剩余空间不足时仍然继续
+	SkipSpaceCheck bool
+	// 大于0时按此大小（含分卷头）写成编号分卷 输出.001、.002…
+	VolumeSize int64
+	// 断点有效时从上次中断处继续复制视频
+	Resume bool
+	// 非空时记录合并结果
+	Result *OperationResult
+	// 非 nil 时各步复制向它上报进度，不显示终端进度条（如图形界面转发进度）
+	Progress Progress
+	// 完成后检查输出中视频的容器结构，提示可能影响播放的布局
+	CheckPlayable bool
+	// 完成后重新读取输出，与写入时计算的校验值比较
+	Verify bool
+	// 非空时比较附加文件与此合并文件中已嵌入的附加文件，相同时警告
+	CompareWith string
+	// 附加文件写成ZIP条目，输出同时是有效的ZIP文件，可用任意解压工具取出
+	ZipCompatible bool
+	// 附加数据的嵌入方式：trailer（默认，追加在视频末尾）、mp4box（写在新增的顶层 free box 中）
+	// 或 mkv-attachment（写成 MKV Segment 内的附件）
+	EmbedMode string
+	// 在尾部元数据之前写入 Reed–Solomon 纠错校验块，保护尾部元数据和附加数据开头
+	FEC bool
+}
+
+// SplitOptions 拆分选项
+type SplitOptions struct {
+	// 加密文件的密码，为空时交互提示输入
+	Password string
+	// 使用密钥文件加密的文件的密钥文件路径
+	KeyFile string
+	// 非空时校验尾部元数据认证值，缺失或不符时中止
+	AuthKey string
+	// 认证失败时只警告并继续提取（取证恢复）
+	IgnoreAuth bool
+	// 仅快速校验附加数据CRC32，不提取文件
+	Quick bool
+	// 提取时跳过CRC32校验（用于有意截断的文件）
+	SkipCRC bool
+	// 隐蔽模式口令
+	StealthKey string
+	// 只提取视频文件
+	VideoOnly bool
+	// 只提取附加文件
+	AttachOnly bool
+	// 视频输出路径，为空时使用输出目录下的原始视频文件名
+	VideoOut string
+	// 各附加文件的输出路径（按顺序），为空时使用输出目录下的原文件名
+	AttachOut []string
+	// 非空时记录拆分结果
+	Result *OperationResult
+	// 输出已存在时自动加序号而不是询问（批量拆分）
+	AutoRename bool
+	// 只把附加文件写到标准输出，跳过视频
+	AttachToStdout bool
+	// 只检查并显示操作计划，不写入任何文件
+	DryRun bool
+	// 剩余空间不足时仍然继续
+	SkipSpaceCheck bool
+	// 按顺序提取视频和附加文件，不并行（机械硬盘上并发读写反而更慢）
+	Sequential bool
+	// 断点有效时从上次中断处继续提取视频
+	Resume bool
+	// 非 nil 时各步复制向它上报进度，不显示终端进度条（如图形界面转发进度）
+	Progress Progress
+	// 完成后重新读取提取出的文件并校验
+	Verify bool
+	// 按此大小把附加文件写成编号分卷 .001、.002…（原始数据片段），0 表示不分卷
+	AttachVolumeSize int64
+
+	// recover 命令找到的合并数据，非 nil 时代替打开合并文件
+	recovered *recoveredInput
+}
+
+// AppendOptions 追加选项
+type AppendOptions struct {
+	// 加密文件的密码，为空时交互提示输入
+	Password string
+	// 使用密钥文件加密的文件的密钥文件路径
+	KeyFile string
+	// 认证密钥：原文件带认证值时必须提供，新尾部用它重新计算
+	AuthKey string
+	// 隐蔽模式口令
+	StealthKey string
+}
+
+// UpdateOptions 替换附加文件选项
+type UpdateOptions struct {
+	// 加密文件的原密码，为空时交互提示输入
+	Password string
+	// 使用密钥文件加密的文件的密钥文件路径，替换后的数据也用它加密
+	KeyFile string
+	// 认证密钥：原文件带认证值时必须提供，新尾部用它重新计算
+	AuthKey string
+	// 隐蔽模式口令
+	StealthKey string
+}
+
+// RestoreOptions 还原选项
+type RestoreOptions struct {
+	// 输出副本路径，为空时直接截断原文件
+	Copy string
+	// 隐蔽模式口令
+	StealthKey string
 }
 
 // DebugInfo v3格式调试信息
 type DebugInfo struct {
 	FileSize        int64
 	MagicBytes      string
+	FormatVersion   int
 	AttachSize      uint64
 	VideoSize       uint64
+	Padding         uint64
 	FilenameLength  uint32
 	Filename        string
+	VideoName       string
+	Comment         string
+	ToolVersion     string
+	CreatedAt       string
 	CalculatedPos   map[string]int64
 	ValidationError string
+	Attachments     []AttachmentEntry
+	// 提取时清理后的附加文件名（与 Attachments 对应，未清理时为空）
+	SanitizedNames []string
+
+	// SHA-256 校验（期望值来自扩展块，实际值在提取时计算）
+	ExpectedVideoSHA256  string
+	ActualVideoSHA256    string
+	ExpectedAttachSHA256 string
+	ActualAttachSHA256   string
+
+	// CRC32 快速校验
+	ExpectedAttachCRC32 string
+	ActualAttachCRC32   string
+
+	// 隐蔽模式解析
+	StealthAttempted bool
+	StealthDetected  bool
+	StealthError     string
+
+	// 读取尾部元数据时遇到意外的文件结尾
+	UnexpectedEOF       bool
+	UnexpectedEOFOffset int64
+	UnexpectedEOFLength int
+
+	// 纠错校验块修复：损坏的分片数、修复的数据分片数和字节数
+	FECAttempted      bool
+	FECBadShards      int
+	FECRepairedShards int
+	FECCorrectedBytes int64
+	FECError          string
+}
+
+// 启用安静模式：屏蔽普通输出和颜色；同时启用开发模式时普通输出和调试信息改写到标准错误
+func enableQuietMode() {
+	color.NoColor = true
+	if devMode {
+		messageOutput = os.Stderr
+		color.Output = os.Stderr
+		return
+	}
+	messageOutput = io.Discard
+	color.Output = io.Discard
+}
+
+// 关闭彩色输出：--no-color、设置了 NO_COLOR 环境变量或普通输出所在的标准错误不是终端时
+func configureColor() {
+	_, envNoColor := os.LookupEnv("NO_COLOR")
+	if noColor || envNoColor || !term.IsTerminal(int(os.Stderr.Fd())) {
+		color.NoColor = true
+	}
+}
+
+// 输出结果路径，每行一个，供脚本读取；标准输出是终端时摘要里已有完整路径，只在安静模式下输出
+func printResultPath(path string) {
+	if jsonOutput || (resultIsTerminal && !quietMode) {
+		return
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	fmt.Fprintln(resultOutput, absPath)
+}
+
+// 提示信息输出位置（安静模式和JSON日志模式下写到标准错误，保证交互提示可见）
+func promptOutput() io.Writer {
+	if quietMode || logJSON {
+		return color.Error
+	}
+	return color.Output
 }
 
 // 打印横幅
 func printBanner() {
-	banner := `
- ╭─────────────────────────────────────────────────────────╮
- │                  🎬 视频文件合并拆分工具                    │
- │                   Video Merger & Splitter               │
- │                      Go Version v3.0                    │
- │                    支持超大文件 (8位长度)                   │
- ╰─────────────────────────────────────────────────────────╯
-`
+	if quietMode {
+		return
+	}
+	banner := msg("main.banner")
 	colorCyan.Print(banner)
 }
 
-// 开发模式调试信息
+// 打印开发模式调试信息；启用 --dev-log 时同时写入日志文件
 func printDebugInfo(info *DebugInfo) {
-	if !devMode {
-		return
+	if devMode {
+		writeDebugInfo(debugOutput{}, info)
 	}
+	if devLog != nil {
+		logDebugf("devlog.debug_info")
+		writeDebugInfo(debugOutput{file: devLog}, info)
+	}
+}
 
-	colorMagenta.Println("\n🔧 === 开发模式调试信息 ===")
-	fmt.Printf("📁 文件大小: %d bytes (%s)\n", info.FileSize, formatFileSize(info.FileSize))
+// 输出调试信息各项
+func writeDebugInfo(out debugOutput, info *DebugInfo) {
+	out.println(colorMagenta, msg("debug.header"))
+	out.printf(msg("debug.tool_version"), versionSummary())
+	out.printf(msg("debug.file_size"), info.FileSize, formatFileSize(info.FileSize))
 
 	if info.MagicBytes != "" {
-		fmt.Printf("🏷️  魔术字节: '%s' (长度: %d)\n", info.MagicBytes, len(info.MagicBytes))
+		out.printf(msg("debug.magic"), info.MagicBytes, len(info.MagicBytes))
+	}
+
+	if info.FormatVersion > 0 {
+		out.printf(msg("debug.format_version"), info.FormatVersion)
+	}
+
+	if info.StealthAttempted {
+		if info.StealthDetected {
+			out.println(nil, msg("debug.stealth_ok"))
+		} else {
+			out.colorPrintf(colorYellow, msg("debug.stealth_failed"), info.StealthError)
+		}
+	}
+
+	if info.FECAttempted {
+		switch {
+		case info.FECError != "":
+			out.colorPrintf(colorYellow, msg("debug.fec_failed"), info.FECError)
+		case info.FECRepairedShards > 0:
+			out.printf(msg("debug.fec_repaired"), info.FECBadShards, info.FECRepairedShards, info.FECCorrectedBytes)
+		default:
+			out.println(nil, msg("debug.fec_clean"))
+		}
 	}
 
 	if info.AttachSize > 0 {
-		fmt.Printf("📎 附加文件大小: %d bytes (%s)\n", info.AttachSize, formatFileSize(int64(info.AttachSize)))
+		out.printf(msg("debug.attach_size"), info.AttachSize, formatFileSize(int64(info.AttachSize)))
 	}
 
 	if info.VideoSize > 0 {
-		fmt.Printf("🎬 视频文件大小: %d bytes (%s)\n", info.VideoSize, formatFileSize(int64(info.VideoSize)))
+		out.printf(msg("debug.video_size"), info.VideoSize, formatFileSize(int64(info.VideoSize)))
+	}
+
+	if info.Padding > 0 {
+		out.printf(msg("debug.padding"), info.Padding, info.VideoSize+info.Padding)
 	}
 
 	if info.FilenameLength > 0 {
-		fmt.Printf("📝 文件名长度: %d\n", info.FilenameLength)
+		out.printf(msg("debug.name_length"), info.FilenameLength)
 	}
 
 	if info.Filename != "" {
-		fmt.Printf("📄 文件名: '%s'\n", info.Filename)
+		out.printf(msg("debug.filename"), info.Filename)
+	}
+
+	if info.VideoName != "" {
+		out.printf(msg("debug.video_name"), info.VideoName)
+	}
+
+	if info.Comment != "" {
+		out.printf(msg("debug.comment"), info.Comment)
+	}
+
+	if info.ToolVersion != "" {
+		out.printf(msg("debug.created_by"), info.ToolVersion)
+		out.printf(msg("debug.created_at"), info.CreatedAt)
+	}
+
+	if len(info.Attachments) > 0 {
+		out.printf(msg("debug.attach_list"), len(info.Attachments))
+		for i, entry := range info.Attachments {
+			out.printf(msg("debug.attach_entry"), i+1, entry.Name, entry.Offset, entry.Size, entry.IsDir)
+			out.printf(msg("debug.attach_attrs"), formatModTime(entry.ModTime), formatMode(entry.Mode))
+			if entry.OriginalSize != entry.Size {
+				out.printf(msg("debug.attach_original"), entry.OriginalSize)
+			}
+			if i < len(info.SanitizedNames) && info.SanitizedNames[i] != "" {
+				out.printf(msg("debug.attach_sanitized"), entry.Name, info.SanitizedNames[i])
+			}
+		}
 	}
 
 	if len(info.CalculatedPos) > 0 {
-		fmt.Println("📍 计算位置:")
+		out.println(nil, msg("debug.positions"))
 		for key, pos := range info.CalculatedPos {
-			fmt.Printf("   %s: %d\n", key, pos)
+			out.printf("   %s: %d\n", key, pos)
 		}
 	}
 
+	if info.ExpectedVideoSHA256 != "" || info.ActualVideoSHA256 != "" {
+		out.println(nil, msg("debug.video_sha"))
+		out.printf(msg("debug.expected"), info.ExpectedVideoSHA256)
+		out.printf(msg("debug.actual"), info.ActualVideoSHA256)
+	}
+
+	if info.ExpectedAttachSHA256 != "" || info.ActualAttachSHA256 != "" {
+		out.println(nil, msg("debug.attach_sha"))
+		out.printf(msg("debug.expected"), info.ExpectedAttachSHA256)
+		out.printf(msg("debug.actual"), info.ActualAttachSHA256)
+	}
+
+	if info.ExpectedAttachCRC32 != "" || info.ActualAttachCRC32 != "" {
+		out.println(nil, msg("debug.attach_crc"))
+		out.printf(msg("debug.expected"), info.ExpectedAttachCRC32)
+		out.printf(msg("debug.actual"), info.ActualAttachCRC32)
+	}
+
+	if info.UnexpectedEOF {
+		out.colorPrintf(colorRed, msg("debug.unexpected_eof"), info.UnexpectedEOFOffset, info.UnexpectedEOFLength)
+	}
+
 	if info.ValidationError != "" {
-		colorRed.Printf("❌ 验证错误: %s\n", info.ValidationError)
+		out.colorPrintf(colorRed, msg("debug.validation_error"), info.ValidationError)
 	}
 
-	colorMagenta.Println("🔧 === 调试信息结束 ===\n")
+	out.println(colorMagenta, msg("debug.footer"))
 }
 
 // 清理和解析拖拽的文件路径
 func parseDroppedPath(input string) string {
-	// 移除前后空白
+	// 移除前后空白（含 Windows 换行留下的 \r）
 	path := strings.TrimSpace(input)
 
+	// PowerShell 拖入时带调用运算符前缀：& 'C:\path with space.mp4'
+	powerShell := false
+	if rest, ok := strings.CutPrefix(path, "&"); ok {
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, `'`) || strings.HasPrefix(rest, `"`) {
+			path = rest
+			powerShell = true
+		}
+	}
+
 	// 移除可能的引号
+	quoted := false
 	if len(path) >= 2 {
 		if (strings.HasPrefix(path, `"`) && strings.HasSuffix(path, `"`)) ||
 			(strings.HasPrefix(path, `'`) && strings.HasSuffix(path, `'`)) {
+			quote := path[:1]
+			quoted = true
 			path = path[1 : len(path)-1]
+			switch {
+			case powerShell || runtime.GOOS == "windows":
+				// PowerShell 引号内的引号写作两个引号，如 'it''s.mp4'
+				path = strings.ReplaceAll(path, quote+quote, quote)
+			case quote == `'`:
+				// GNOME Terminal 用单引号包住路径，路径中的单引号写作 '\''
+				path = strings.ReplaceAll(path, `'\''`, `'`)
+			}
 		}
 	}
 
 	// Windows 路径处理
 	if runtime.GOOS == "windows" {
-		// 处理反斜杠
-		path = strings.ReplaceAll(path, `\`, `/`)
+		path = normalizeWindowsPath(path)
+	} else if !quoted {
+		// macOS 终端、iTerm2 拖入时用反斜杠转义空格和特殊字符，如 My\ Movie.mp4、\(1\)
+		path = unescapeShellPath(path)
 	}
 
 	return path
 }
 
+// 保持 Windows 路径的原生形式：\\?\ 长路径前缀下系统不再规范化路径，原样保留；
+// UNC 路径（\\nas\share\video.mp4）保留开头的双反斜杠；其余分隔符统一为反斜杠并合并重复的分隔符
+func normalizeWindowsPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	prefix := ""
+	if strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, `//`) {
+		prefix = `\\`
+		path = path[2:]
+	}
+	path = strings.ReplaceAll(path, `/`, `\`)
+	for strings.Contains(path, `\\`) {
+		path = strings.ReplaceAll(path, `\\`, `\`)
+	}
+	return prefix + path
+}
+
+// 去掉 shell 转义的反斜杠：\X 还原为 X，末尾单独的反斜杠保留
+func unescapeShellPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var builder strings.Builder
+	escaped := false
+	for _, r := range path {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		builder.WriteRune(r)
+		escaped = false
+	}
+	if escaped {
+		builder.WriteRune('\\')
+	}
+	return builder.String()
+}
+
 // 读取用户输入
 func readUserInput(prompt string) string {
-	colorBlue.Print(prompt)
+	colorBlue.Fprint(promptOutput(), prompt)
+	if interactiveSession {
+		return strings.TrimSpace(readInterruptibleLine())
+	}
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
 	return strings.TrimSpace(input)
 }
 
+// 读取密码（不回显）；标准输入不是终端时无法提示，立即报错而不是读到 EOF
+func readPassword(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", exitErrorf(EXIT_USAGE, "prompt.password_required")
+	}
+	colorBlue.Fprint(promptOutput(), prompt)
+	// 记下终端状态，读取中被中断时恢复回显
+	if state, err := term.GetState(int(os.Stdin.Fd())); err == nil {
+		savedTerminalState.Store(state)
+	}
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	savedTerminalState.Store(nil)
+	fmt.Fprintln(promptOutput())
+	if err != nil {
+		return "", newError("prompt.read_password_failed", err)
+	}
+	return string(password), nil
+}
+
+// 交互输入新密码（需输入两次确认）
+func askNewPassword() (string, error) {
+	password, err := readPassword(msg("prompt.new_password"))
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", newError("crypto.empty_password")
+	}
+	confirm, err := readPassword(msg("prompt.repeat_password"))
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", newError("prompt.password_mismatch")
+	}
+	return password, nil
+}
+
 // 确认操作
 func confirmAction(message string) bool {
+	if assumeYes {
+		colorBlue.Fprintf(promptOutput(), "%s (y/N): y (--yes)\n", message)
+		return true
+	}
 	response := readUserInput(fmt.Sprintf("%s (y/N): ", message))
 	response = strings.ToLower(response)
 	return response == "y" || response == "yes"
 }
 
+// 命令行模式确认：--yes 时直接同意，标准输入不是终端时立即报错而不是等待输入
+func requireConfirmation(message string) error {
+	if !assumeYes && !term.IsTerminal(int(os.Stdin.Fd())) {
+		return exitErrorf(EXIT_USAGE, "prompt.needs_confirmation", message)
+	}
+	if !confirmAction(message) {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// 确认覆盖已存在的输出 path，--force 时直接覆盖；未确认时返回 *ErrOutputExists
+func confirmOverwrite(path, message string) error {
+	if forceOverwrite {
+		colorYellow.Println(msg("prompt.force_hint"))
+		return nil
+	}
+	if err := requireConfirmation(message); err != nil {
+		return &ErrOutputExists{Path: path, Err: err}
+	}
+	return nil
+}
+
 // 显示文件信息预览
 func showFilePreview(filePath string) error {
 	info, err := validateFile(filePath)
@@ -167,110 +665,246 @@ func showFilePreview(filePath string) error {
 		return err
 	}
 
-	fmt.Printf("📁 文件: %s\n", info.Name)
-	fmt.Printf("📊 大小: %s\n", formatFileSize(info.Size))
-	fmt.Printf("📍 路径: %s\n", info.Path)
+	fmt.Fprintf(messageOutput, msg("preview.file"), info.Name)
+	fmt.Fprintf(messageOutput, msg("preview.size"), formatFileSize(info.Size))
+	fmt.Fprintf(messageOutput, msg("preview.path"), info.Path)
+	if info.Target != "" {
+		fmt.Fprintf(messageOutput, msg("preview.symlink"), info.Path, info.Target)
+	}
 
 	// 尝试检测文件类型
-	ext := strings.ToLower(filepath.Ext(info.Name))
 	var fileType string
-	switch ext {
-	case ".mp4", ".mkv", ".avi", ".mov", ".wmv", ".webm", ".flv":
-		fileType = "🎬 视频文件"
+	switch carrierKindOf(info.Name) {
+	case CARRIER_VIDEO:
+		fileType = msg("preview.type_video")
+	case CARRIER_IMAGE:
+		fileType = msg("preview.type_image")
 	default:
-		fileType = "📎 其他文件"
+		fileType = msg("preview.type_other")
 	}
-	fmt.Printf("🏷️ 类型: %s\n", fileType)
+	fmt.Fprintf(messageOutput, msg("preview.type"), fileType)
+	fmt.Fprintf(messageOutput, "🧬 MIME: %s\n", sniffMimeType(info.Path))
 
 	return nil
 }
 
-// 检测是否为v3合并文件
-func isMergedFile(filePath string) bool {
+// 检测是否为v3合并文件，不输出任何信息。返回的调试信息记录检测过程：FormatVersion 为末尾
+// 魔术字节对应的格式版本，StealthDetected 表示隐蔽模式文件，
+// ValidationError 为魔术字节相同但结构不符的原因。无法打开或读取文件时返回错误
+func isMergedFile(filePath, stealthKey string) (bool, *DebugInfo, error) {
+	debugInfo := &DebugInfo{CalculatedPos: make(map[string]int64)}
+
 	file, err := os.Open(filePath)
 	if err != nil {
-		return false
+		return false, debugInfo, err
 	}
 	defer file.Close()
 
 	info, err := file.Stat()
 	if err != nil {
-		return false
+		return false, debugInfo, err
 	}
+	debugInfo.FileSize = info.Size()
 
 	// 文件必须足够大：最小v3文件大小
 	if info.Size() < MIN_V3_FILE_SIZE {
-		colorBlue.Printf("ℹ️  文件太小，未检测到合并标记\n")
-		return false
+		return false, debugInfo, nil
 	}
 
 	// 读取文件末尾的魔术字节
 	magicBuffer := make([]byte, MAGIC_LENGTH)
-	if _, err := file.Seek(-int64(MAGIC_LENGTH), 2); err != nil {
-		colorBlue.Printf("ℹ️  无法读取文件末尾，未检测到合并标记\n")
-		return false
+	if err := readTrailerAt(file, magicBuffer, info.Size()-int64(MAGIC_LENGTH), debugInfo); err != nil {
+		return false, debugInfo, err
+	}
+	debugInfo.MagicBytes = string(magicBuffer)
+	debugInfo.FormatVersion = formatVersionOf(string(magicBuffer))
+
+	// 魔术字节不是v3时已可判定，不必读取整个尾部
+	if debugInfo.FormatVersion != 3 && stealthKey == "" {
+		return false, debugInfo, nil
 	}
+	// 之后的读取都落在文件末尾，一次读入内存再解析
+	tail := traceReads(newTailReader(file, info.Size()))
 
-	if _, err := file.Read(magicBuffer); err != nil {
-		colorBlue.Printf("ℹ️  读取失败，未检测到合并标记\n")
-		return false
+	// 隐蔽模式文件末尾没有魔术字节，需要密钥才能识别
+	if debugInfo.FormatVersion == 0 && stealthKey != "" {
+		debugInfo.StealthAttempted = true
+		if _, _, err := openStealthTrailer(tail, info.Size(), stealthKey); err != nil {
+			debugInfo.StealthError = err.Error()
+			return false, debugInfo, nil
+		}
+		debugInfo.StealthDetected = true
+		return true, debugInfo, nil
 	}
 
-	result := string(magicBuffer) == MAGIC_BYTES
+	// 末尾8字节可能恰好相同：再按固定位置校验大小字段、文件名长度和整体结构，只读取尾部
+	if debugInfo.FormatVersion == 3 {
+		if _, err := parseTrailer(tail, info.Size(), debugInfo); err != nil {
+			return false, debugInfo, nil
+		}
+	}
 
-	if result {
-		colorGreen.Printf("✅ 检测到格式合并文件\n")
-	} else {
-		colorBlue.Printf("ℹ️  普通文件，未检测到合并标记\n")
+	return debugInfo.FormatVersion == 3, debugInfo, nil
+}
+
+// 向用户说明 isMergedFile 的检测结果
+func reportMergedDetection(merged bool, debugInfo *DebugInfo, err error) {
+	switch {
+	case err != nil:
+		logInfof(colorBlue, "detect.read_failed")
+		logDebugf("detect.error_detail", err)
+	case debugInfo.FileSize < MIN_V3_FILE_SIZE:
+		logInfof(colorBlue, "detect.too_small")
+	case debugInfo.StealthDetected:
+		logInfof(colorGreen, "detect.stealth_found")
+	case debugInfo.FormatVersion == 3 && !merged:
+		logInfof(colorBlue, "detect.magic_only")
+		logDebugf("detect.error_detail", debugInfo.ValidationError)
+	case debugInfo.FormatVersion == 3:
+		logInfof(colorGreen, "detect.found")
+	default:
+		if debugInfo.StealthAttempted {
+			logDebugf("detect.stealth_failed", debugInfo.StealthError)
+		}
+		logInfof(colorBlue, "detect.plain")
+	}
+}
+
+// detect 命令退出码
+const (
+	DETECT_EXIT_MERGED     = 0
+	DETECT_EXIT_PLAIN      = 1
+	DETECT_EXIT_UNREADABLE = 2
+)
+
+// 完整校验文件结构判断是否为合并文件，返回退出码和单行结果
+func detectMergedFile(filePath, stealthKey string, verbose bool) (int, string) {
+	file, size, err := openDetectInput(filePath)
+	if err != nil {
+		if verbose {
+			logErrorf("detect.open_failed", err)
+		}
+		return DETECT_EXIT_UNREADABLE, "unreadable"
+	}
+	defer file.Close()
+	if size < 0 {
+		if verbose {
+			logErrorf("detect.not_regular", filePath)
+		}
+		return DETECT_EXIT_UNREADABLE, "unreadable"
+	}
+
+	debugInfo := &DebugInfo{
+		FileSize:      size,
+		CalculatedPos: make(map[string]int64),
+	}
+	trailer, err := loadTrailer(file, size, stealthKey, debugInfo)
+	if err != nil {
+		// 重新封装后尾部元数据已丢失，但本工具写入的 MKV 附件仍在（远程文件逐个读取元素头太慢，不查找）
+		if !isRemotePath(filePath) {
+			if found := findMKVAttachment(file, size); found != nil {
+				if verbose {
+					logInfof(colorGreen, "detect.mkv_remuxed", found.Name)
+				}
+				return DETECT_EXIT_MERGED, "merged"
+			}
+		}
+		if verbose {
+			logInfof(colorBlue, "detect.structure_failed", err)
+			printDebugInfo(debugInfo)
+		}
+		return DETECT_EXIT_PLAIN, "plain"
+	}
+
+	if verbose {
+		if trailer.Stealth {
+			logInfof(colorGreen, "detect.stealth_found")
+		} else {
+			logInfof(colorGreen, "detect.found")
+		}
+		logInfof(nil, "detect.summary", formatFileSize(int64(trailer.VideoSize)), len(trailer.Attachments), formatFileSize(int64(trailer.AttachSize)))
+		if trailer.BoxHeader > 0 {
+			logInfof(nil, "detect.mp4box")
+		}
+		if trailer.MKV != nil {
+			logInfof(nil, "detect.mkv")
+		}
+		printDebugInfo(debugInfo)
 	}
+	return DETECT_EXIT_MERGED, "merged"
+}
 
-	return result
+// 打开待检测的文件（http(s) 地址远程读取），不是普通文件时大小为 -1
+func openDetectInput(filePath string) (mergedReader, int64, error) {
+	if isRemotePath(filePath) {
+		remote, info, err := openRemoteFile(filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		return remote, info.Size, nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return file, -1, nil
+	}
+	return file, info.Size(), nil
 }
 
-// 智能操作建议
-func suggestOperation(filePath string) string {
+// 智能操作建议，merged 为 isMergedFile 的检测结果
+func suggestOperation(filePath string, merged bool) string {
 	// 首先检查是否为合并文件
-	if isMergedFile(filePath) {
+	if merged {
 		return "split"
 	}
 
-	// 如果不是合并文件，根据扩展名判断
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".mp4", ".mkv", ".avi", ".mov", ".wmv", ".webm":
-		return "merge"
-	default:
+	// 如果不是合并文件，根据扩展名判断：视频和图片作为载体，其他文件作为要隐藏的附加文件
+	if isCarrierFile(filePath) {
 		return "merge"
 	}
+	return "attach"
 }
 
 // 交互式合并操作
 func interactiveMerge() error {
-	colorMagenta.Println("\n🎬 === 文件合并模式 ===")
-	fmt.Println("请按顺序提供两个文件：视频文件和要隐藏的附加文件")
+	colorMagenta.Println(msg("interactive.merge_title"))
+	fmt.Fprintln(messageOutput, msg("interactive.merge_intro"))
 
 	// 获取视频文件
 	var videoPath string
 	for {
-		colorCyan.Println("\n📹 步骤 1: 请拖拽视频文件到此窗口，然后按回车:")
-		input := readUserInput("视频文件路径> ")
+		colorCyan.Println(msg("interactive.merge_step1"))
+		input := readPathInput(msg("interactive.video_prompt"))
 		if input == "" {
-			colorYellow.Println("⚠️ 路径不能为空，请重新拖拽文件")
+			colorYellow.Println(msg("interactive.empty_path"))
+			continue
+		}
+
+		// 一次拖入两个文件时直接分配视频和附加文件，跳到摘要确认
+		paths := parseDroppedPaths(input)
+		if len(paths) > 2 {
+			colorYellow.Printf(msg("interactive.too_many_paths"), len(paths))
 			continue
 		}
+		if len(paths) == 2 {
+			return interactiveMergePair(paths)
+		}
 
-		videoPath = parseDroppedPath(input)
-		fmt.Printf("\n解析路径: %s\n", videoPath)
+		videoPath = paths[0]
+		fmt.Fprintf(messageOutput, msg("interactive.parsed_path"), videoPath)
 
 		if err := showFilePreview(videoPath); err != nil {
-			colorRed.Printf("❌ 文件错误: %v\n", err)
-			if !confirmAction("是否重新选择文件？") {
-				return fmt.Errorf("用户取消操作")
+			colorRed.Printf(msg("interactive.file_error"), err)
+			if !confirmAction(msg("interactive.choose_again")) {
+				return ErrCancelled
 			}
 			continue
 		}
 
-		if confirmAction("确认使用此视频文件？") {
+		if confirmAction(msg("interactive.confirm_video")) {
 			break
 		}
 	}
@@ -278,124 +912,131 @@ func interactiveMerge() error {
 	// 获取附加文件
 	var attachPath string
 	for {
-		colorCyan.Println("\n📎 步骤 2: 请拖拽要隐藏的文件到此窗口，然后按回车:")
-		input := readUserInput("附加文件路径> ")
+		colorCyan.Println(msg("interactive.merge_step2"))
+		input := readPathInput(msg("interactive.attach_prompt"))
 		if input == "" {
-			colorYellow.Println("⚠️ 路径不能为空，请重新拖拽文件")
+			colorYellow.Println(msg("interactive.empty_path"))
 			continue
 		}
 
 		attachPath = parseDroppedPath(input)
-		fmt.Printf("\n解析路径: %s\n", attachPath)
+		fmt.Fprintf(messageOutput, msg("interactive.parsed_path"), attachPath)
 
 		if err := showFilePreview(attachPath); err != nil {
-			colorRed.Printf("❌ 文件错误: %v\n", err)
-			if !confirmAction("是否重新选择文件？") {
-				return fmt.Errorf("用户取消操作")
+			colorRed.Printf(msg("interactive.file_error"), err)
+			if !confirmAction(msg("interactive.choose_again")) {
+				return ErrCancelled
 			}
 			continue
 		}
 
-		if confirmAction("确认使用此附加文件？") {
+		if confirmAction(msg("interactive.confirm_attach")) {
 			break
 		}
 	}
 
 	// 生成输出文件名
 	videoInfo, _ := validateFile(videoPath)
-	defaultOutput := strings.TrimSuffix(videoInfo.Name, filepath.Ext(videoInfo.Name)) + "_merged_v3" + filepath.Ext(videoInfo.Name)
+	defaultOutput := withDefaultOutputDir(mergedOutputName(videoInfo.Name))
 
-	colorCyan.Printf("\n💾 步骤 3: 输出文件名 (默认: %s)\n", defaultOutput)
-	outputName := readUserInput("输出文件名 (直接回车使用默认): ")
+	colorCyan.Printf(msg("interactive.merge_step3"), defaultOutput)
+	outputName := readUserInput(msg("interactive.output_prompt"))
 	if outputName == "" {
 		outputName = defaultOutput
 	}
 
 	// 最终确认
-	fmt.Printf("\n📋 操作摘要:\n")
-	fmt.Printf("  🎬 视频文件: %s\n", filepath.Base(videoPath))
-	fmt.Printf("  📎 附加文件: %s\n", filepath.Base(attachPath))
-	fmt.Printf("  💾 输出文件: %s\n", outputName)
+	fmt.Fprint(messageOutput, msg("interactive.summary"))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_video"), filepath.Base(videoPath))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_attach"), filepath.Base(attachPath))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_output"), outputName)
+	printSpaceProjection("interactive.summary_size_merge", projectMergeOutputs(videoPath, attachPath, outputName))
 
-	if !confirmAction("确认开始格式合并？") {
-		return fmt.Errorf("用户取消操作")
+	if !confirmStart("interactive.confirm_merge") {
+		return ErrCancelled
 	}
 
-	return mergeFiles(videoPath, attachPath, outputName)
+	return runInteractiveMerge(videoPath, attachPath, outputName)
 }
 
 // 交互式拆分操作
 func interactiveSplit() error {
-	colorMagenta.Println("\n📦 === 文件拆分模式 ===")
-	fmt.Println("请提供一个格式合并后的文件进行拆分")
+	colorMagenta.Println(msg("interactive.split_title"))
+	fmt.Fprintln(messageOutput, msg("interactive.split_intro"))
 
 	// 获取合并文件
 	var mergedPath string
 	for {
-		colorCyan.Println("\n📥 请拖拽合并后的文件到此窗口，然后按回车:")
-		input := readUserInput("合并文件路径> ")
+		colorCyan.Println(msg("interactive.split_drag"))
+		input := readPathInput(msg("interactive.merged_prompt"))
 		if input == "" {
-			colorYellow.Println("⚠️ 路径不能为空，请重新拖拽文件")
+			colorYellow.Println(msg("interactive.empty_path"))
 			continue
 		}
 
 		mergedPath = parseDroppedPath(input)
-		fmt.Printf("\n解析路径: %s\n", mergedPath)
+		fmt.Fprintf(messageOutput, msg("interactive.parsed_path"), mergedPath)
 
 		if err := showFilePreview(mergedPath); err != nil {
-			colorRed.Printf("❌ 文件错误: %v\n", err)
-			if !confirmAction("是否重新选择文件？") {
-				return fmt.Errorf("用户取消操作")
+			colorRed.Printf(msg("interactive.file_error"), err)
+			if !confirmAction(msg("interactive.choose_again")) {
+				return ErrCancelled
 			}
 			continue
 		}
 
 		// 检测是否为v3合并文件
-		if !isMergedFile(mergedPath) {
-			colorYellow.Println("⚠️ 这个文件看起来不是格式合并文件")
-			if devMode || confirmAction("是否进入开发模式尝试解析？") {
+		merged, detection, err := isMergedFile(mergedPath, stealthKey)
+		reportMergedDetection(merged, detection, err)
+		if !merged {
+			colorYellow.Println(msg("interactive.not_merged"))
+			if devMode || confirmAction(msg("interactive.try_dev")) {
 				devMode = true
-				colorMagenta.Println("🔧 已启用开发模式，将显示详细调试信息")
+				colorMagenta.Println(msg("interactive.dev_enabled"))
 			}
-			if !confirmAction("继续尝试拆分？") {
+			if !confirmAction(msg("interactive.try_split")) {
 				continue
 			}
 		}
 
-		if confirmAction("确认使用此文件进行拆分？") {
+		if confirmAction(msg("interactive.confirm_split_file")) {
 			break
 		}
 	}
 
 	// 获取输出目录
-	defaultOutputDir := "extracted_v3"
-	colorCyan.Printf("\n📁 输出目录 (默认: %s)\n", defaultOutputDir)
-	outputDir := readUserInput("输出目录 (直接回车使用默认): ")
+	defaultOutputDir := defaultSplitOutputDir(mergedPath)
+	colorCyan.Printf(msg("interactive.output_dir"), defaultOutputDir)
+	outputDir := readUserInput(msg("interactive.output_dir_prompt"))
 	if outputDir == "" {
 		outputDir = defaultOutputDir
+		if err := confirmSplitOutputDir(outputDir); err != nil {
+			return err
+		}
 	}
 
 	// 最终确认
-	fmt.Printf("\n📋 操作摘要:\n")
-	fmt.Printf("  📦 合并文件: %s\n", filepath.Base(mergedPath))
-	fmt.Printf("  📁 输出目录: %s\n", outputDir)
-	fmt.Printf("  🔧 开发模式: %v\n", devMode)
+	fmt.Fprint(messageOutput, msg("interactive.summary"))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_merged"), filepath.Base(mergedPath))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_output_dir"), outputDir)
+	printSpaceProjection("interactive.summary_size_split", projectSplitOutputs(mergedPath, outputDir))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_dev"), devMode)
 
-	if !confirmAction("确认开始格式拆分？") {
-		return fmt.Errorf("用户取消操作")
+	if !confirmStart("interactive.confirm_split") {
+		return ErrCancelled
 	}
 
-	return splitFiles(mergedPath, outputDir)
+	return runInteractiveSplit(mergedPath, outputDir)
 }
 
 // 智能文件处理
 func smartFileHandler() error {
-	colorMagenta.Println("\n🎯 === 智能文件处理模式 ===")
-	fmt.Println("拖拽任意文件，程序将自动判断最适合的操作")
+	colorMagenta.Println(msg("interactive.smart_title"))
+	fmt.Fprintln(messageOutput, msg("interactive.smart_intro"))
 
 	for {
-		colorCyan.Println("\n📁 请拖拽文件到此窗口 (输入 'q' 退出, 'dev' 切换开发模式):")
-		input := readUserInput("文件路径> ")
+		colorCyan.Println(msg("interactive.smart_drag"))
+		input := readPathInput(msg("interactive.file_prompt"))
 
 		if input == "q" || input == "quit" || input == "exit" {
 			return nil
@@ -404,223 +1045,327 @@ func smartFileHandler() error {
 		if input == "dev" || input == "debug" {
 			devMode = !devMode
 			if devMode {
-				colorMagenta.Println("🔧 开发模式已启用，将显示详细调试信息")
+				colorMagenta.Println(msg("interactive.dev_on"))
 			} else {
-				colorBlue.Println("🔧 开发模式已禁用")
+				colorBlue.Println(msg("interactive.dev_off"))
 			}
 			continue
 		}
 
 		if input == "" {
-			colorYellow.Println("⚠️ 路径不能为空，请重新拖拽文件")
+			colorYellow.Println(msg("interactive.empty_path"))
 			continue
 		}
 
 		filePath := parseDroppedPath(input)
-		fmt.Printf("\n📍 解析路径: %s\n", filePath)
+		fmt.Fprintf(messageOutput, msg("interactive.parsed_path_pin"), filePath)
 
-		if err := showFilePreview(filePath); err != nil {
-			colorRed.Printf("❌ 文件错误: %v\n", err)
+		operation, err := handleSmartFile(filePath)
+		switch {
+		case operation == "":
+			colorRed.Printf(msg("interactive.file_error"), err)
 			continue
+		case err != nil:
+			if operation == "split" {
+				colorRed.Printf(msg("batch.split_failed"), err)
+			} else {
+				colorRed.Printf(msg("batch.merge_failed"), err)
+			}
+			if !confirmAction(msg("interactive.back_to_menu")) {
+				return err
+			}
+		case operation == "split":
+			if !confirmAction(msg("interactive.split_continue")) {
+				return nil
+			}
+		default:
+			if !confirmAction(msg("interactive.merge_continue")) {
+				return nil
+			}
 		}
+	}
+
+	return nil
+}
 
-		// 添加分隔线
-		fmt.Println()
+// 按检测结果处理一个文件：合并文件拆分到默认目录，否则以它开始交互式合并。
+// 返回执行的操作（"split" 或 "merge"），文件无法读取时为空
+func handleSmartFile(filePath string) (string, error) {
+	if err := showFilePreview(filePath); err != nil {
+		return "", err
+	}
 
-		// 智能建议操作
-		suggested := suggestOperation(filePath)
+	// 添加分隔线
+	fmt.Fprintln(messageOutput)
 
-		// 根据检测结果提供操作建议
-		fmt.Println() // 确保有空行分隔
+	// 智能建议操作
+	merged, detection, err := isMergedFile(filePath, stealthKey)
+	reportMergedDetection(merged, detection, err)
+	suggested := suggestOperation(filePath, merged)
 
-		if suggested == "split" {
-			colorGreen.Println("💡 建议操作：拆分文件（提取隐藏内容）")
-			outputDir := "extracted_v3_" + strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-			fmt.Println()
-			err := splitFiles(filePath, outputDir)
-			if err != nil {
-				colorRed.Printf("❌ 拆分失败: %v\n", err)
-				if !confirmAction("是否返回主菜单继续处理其他文件？") {
-					return err
-				}
-			} else {
-				if !confirmAction("拆分成功！是否继续处理其他文件？") {
-					return nil
-				}
-			}
-		} else {
-			colorGreen.Println("💡 建议操作：格式合并文件")
-			fmt.Println()
-			err := interactiveMergeWithVideo(filePath)
-			if err != nil {
-				colorRed.Printf("❌ 合并失败: %v\n", err)
-				if !confirmAction("是否返回主菜单继续处理其他文件？") {
-					return err
-				}
-			} else {
-				if !confirmAction("合并成功！是否继续处理其他文件？") {
-					return nil
-				}
-			}
+	// 根据检测结果提供操作建议
+	fmt.Fprintln(messageOutput) // 确保有空行分隔
+
+	if suggested == "split" {
+		colorGreen.Println(msg("interactive.suggest_split"))
+		outputDir := defaultSplitOutputDir(filePath)
+		fmt.Fprintln(messageOutput)
+		if err := confirmSplitOutputDir(outputDir); err != nil {
+			return "split", err
 		}
+		return "split", runInteractiveSplit(filePath, outputDir)
 	}
 
-	return nil
+	// 非视频文件默认作为附加文件，确认后再选择视频；否则仍作为视频
+	if suggested == "attach" {
+		colorGreen.Println(msg("interactive.suggest_attach"))
+		fmt.Fprintln(messageOutput)
+		if confirmAction(msg("interactive.confirm_as_attach")) {
+			return "merge", interactiveMergeWithAttachment(filePath)
+		}
+		return "merge", interactiveMergeWithVideo(filePath)
+	}
+	colorGreen.Println(msg("interactive.suggest_merge"))
+	fmt.Fprintln(messageOutput)
+	return "merge", interactiveMergeWithVideo(filePath)
 }
 
 // 预设视频文件的交互式合并
 func interactiveMergeWithVideo(videoPath string) error {
-	colorMagenta.Println("\n🎬 === 文件合并模式 (视频文件已选择) ===")
+	colorMagenta.Println(msg("interactive.merge_video_title"))
 
-	fmt.Printf("✅ 视频文件: %s\n", filepath.Base(videoPath))
+	fmt.Fprintf(messageOutput, msg("interactive.video_selected"), filepath.Base(videoPath))
 
 	// 获取附加文件
-	var attachPath string
+	attachPath, err := readDroppedFile(msg("interactive.attach_drag"), msg("interactive.attach_prompt"))
+	if err != nil {
+		return err
+	}
+
+	return finishInteractiveMerge(videoPath, attachPath)
+}
+
+// 预设附加文件的交互式合并（先选择要隐藏的文件，再选择视频）
+func interactiveMergeWithAttachment(attachPath string) error {
+	colorMagenta.Println(msg("interactive.merge_attach_title"))
+
+	fmt.Fprintf(messageOutput, msg("interactive.attach_selected"), filepath.Base(attachPath))
+
+	// 获取视频文件
+	videoPath, err := readDroppedFile(msg("interactive.video_drag"), msg("interactive.video_prompt"))
+	if err != nil {
+		return err
+	}
+
+	return finishInteractiveMerge(videoPath, attachPath)
+}
+
+// 一次拖入了视频和附加文件：分配角色、显示预览后直接进入摘要确认
+func interactiveMergePair(paths []string) error {
+	colorGreen.Printf(msg("interactive.pair_detected"), len(paths))
+	videoPath, attachPath := assignDroppedPair(paths)
+	for _, path := range []string{videoPath, attachPath} {
+		if err := showFilePreview(path); err != nil {
+			return err
+		}
+	}
+	return finishInteractiveMerge(videoPath, attachPath)
+}
+
+// 读取一个拖入的文件路径并显示预览，无效时询问是否重新选择
+func readDroppedFile(dragHint, prompt string) (string, error) {
 	for {
-		colorCyan.Println("\n📎 请拖拽要隐藏的文件到此窗口，然后按回车:")
-		input := readUserInput("附加文件路径> ")
+		colorCyan.Println(dragHint)
+		input := readPathInput(prompt)
 		if input == "" {
-			colorYellow.Println("⚠️ 路径不能为空，请重新拖拽文件")
+			colorYellow.Println(msg("interactive.empty_path"))
 			continue
 		}
 
-		attachPath = parseDroppedPath(input)
-		fmt.Printf("\n解析路径: %s\n", attachPath)
+		path := parseDroppedPath(input)
+		fmt.Fprintf(messageOutput, msg("interactive.parsed_path"), path)
 
-		if err := showFilePreview(attachPath); err != nil {
-			colorRed.Printf("❌ 文件错误: %v\n", err)
-			if !confirmAction("是否重新选择文件？") {
-				return fmt.Errorf("用户取消操作")
+		if err := showFilePreview(path); err != nil {
+			colorRed.Printf(msg("interactive.file_error"), err)
+			if !confirmAction(msg("interactive.choose_again")) {
+				return "", ErrCancelled
 			}
 			continue
 		}
-		break
+		return path, nil
 	}
+}
 
+// 视频和附加文件都已选择：询问输出文件名，显示各文件的角色并确认后合并
+func finishInteractiveMerge(videoPath, attachPath string) error {
 	// 生成输出文件名
-	videoInfo, _ := validateFile(videoPath)
-	defaultOutput := strings.TrimSuffix(videoInfo.Name, filepath.Ext(videoInfo.Name)) + "_merged_v3" + filepath.Ext(videoInfo.Name)
+	videoInfo, err := validateFile(videoPath)
+	if err != nil {
+		return newError("error.video_invalid_w", err)
+	}
+	defaultOutput := withDefaultOutputDir(mergedOutputName(videoInfo.Name))
 
-	colorCyan.Printf("\n💾 输出文件名 (默认: %s)\n", defaultOutput)
-	outputName := readUserInput("输出文件名 (直接回车使用默认): ")
+	colorCyan.Printf(msg("interactive.output_name"), defaultOutput)
+	outputName := readUserInput(msg("interactive.output_prompt"))
 	if outputName == "" {
 		outputName = defaultOutput
 	}
 
 	// 最终确认
-	fmt.Printf("\n📋 操作摘要:\n")
-	fmt.Printf("  🎬 视频文件: %s\n", filepath.Base(videoPath))
-	fmt.Printf("  📎 附加文件: %s\n", filepath.Base(attachPath))
-	fmt.Printf("  💾 输出文件: %s\n", outputName)
+	fmt.Fprint(messageOutput, msg("interactive.summary"))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_video"), filepath.Base(videoPath))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_attach"), filepath.Base(attachPath))
+	fmt.Fprintf(messageOutput, msg("interactive.summary_output"), outputName)
+	printSpaceProjection("interactive.summary_size_merge", projectMergeOutputs(videoPath, attachPath, outputName))
+
+	if !confirmStart("interactive.confirm_merge") {
+		return ErrCancelled
+	}
 
-	return mergeFiles(videoPath, attachPath, outputName)
+	return runInteractiveMerge(videoPath, attachPath, outputName)
+}
+
+// 菜单项被用户取消时不当作失败，直接返回主菜单
+func cancelledToMenu(err error) bool {
+	if !errors.Is(err, ErrCancelled) {
+		return false
+	}
+	colorYellow.Println(msg("menu.cancelled"))
+	return true
 }
 
 // 主交互界面
 func interactiveMode() error {
+	interactiveSession = true
 	for {
-		fmt.Println()
-		colorMagenta.Println("🎯 === 主菜单 ===")
-		fmt.Println("1. 📁 智能文件处理 (推荐)")
-		fmt.Println("2. 🎬 合并文件")
-		fmt.Println("3. 📦 拆分文件")
-		fmt.Println("4. 🔧 切换开发模式")
-		fmt.Println("5. ❓ 使用帮助")
-		fmt.Println("6. 🚪 退出程序")
-
-		fmt.Printf("当前模式: ")
+		fmt.Fprintln(messageOutput)
+		colorMagenta.Println(msg("menu.title"))
+		fmt.Fprintln(messageOutput, msg("menu.smart"))
+		fmt.Fprintln(messageOutput, msg("menu.merge"))
+		fmt.Fprintln(messageOutput, msg("menu.split"))
+		fmt.Fprintln(messageOutput, msg("menu.toggle_dev"))
+		fmt.Fprintln(messageOutput, msg("menu.help"))
+		fmt.Fprintln(messageOutput, msg("menu.queue"))
+		fmt.Fprintln(messageOutput, msg("menu.exit"))
+
+		fmt.Fprint(messageOutput, msg("menu.current_mode"))
 		if devMode {
-			colorMagenta.Printf("🔧 开发模式")
+			colorMagenta.Print(msg("menu.mode_dev"))
 		} else {
-			colorBlue.Printf("🎯 普通模式")
+			colorBlue.Print(msg("menu.mode_normal"))
 		}
-		fmt.Println()
+		fmt.Fprintln(messageOutput)
 
-		choice := readUserInput("\n请选择操作 (1-6): ")
+		choice := readUserInput(msg("menu.choose"))
 
 		switch choice {
 		case "1":
-			if err := smartFileHandler(); err != nil {
-				colorRed.Printf("❌ 操作失败: %v\n", err)
-				if !confirmAction("是否返回主菜单？") {
+			if err := runMenuAction(smartFileHandler); err != nil {
+				if interruptedToMenu(err) || cancelledToMenu(err) {
+					continue
+				}
+				colorRed.Printf(msg("menu.failed"), err)
+				if !confirmAction(msg("menu.back")) {
 					return err
 				}
 			}
 		case "2":
-			if err := interactiveMerge(); err != nil {
-				colorRed.Printf("❌ 合并失败: %v\n", err)
-				if !confirmAction("是否返回主菜单？") {
+			if err := runMenuAction(interactiveMerge); err != nil {
+				if interruptedToMenu(err) || cancelledToMenu(err) {
+					continue
+				}
+				colorRed.Printf(msg("batch.merge_failed"), err)
+				if !confirmAction(msg("menu.back")) {
 					return err
 				}
 			}
 		case "3":
-			if err := interactiveSplit(); err != nil {
-				colorRed.Printf("❌ 拆分失败: %v\n", err)
-				if !confirmAction("是否返回主菜单？") {
+			if err := runMenuAction(interactiveSplit); err != nil {
+				if interruptedToMenu(err) || cancelledToMenu(err) {
+					continue
+				}
+				colorRed.Printf(msg("batch.split_failed"), err)
+				if !confirmAction(msg("menu.back")) {
 					return err
 				}
 			}
 		case "4":
 			devMode = !devMode
 			if devMode {
-				colorMagenta.Println("🔧 开发模式已启用，将显示详细调试信息")
+				colorMagenta.Println(msg("interactive.dev_on"))
 			} else {
-				colorBlue.Println("🔧 开发模式已禁用")
+				colorBlue.Println(msg("interactive.dev_off"))
 			}
 		case "5":
 			showInteractiveHelp()
-		case "6", "q", "quit", "exit":
-			colorGreen.Println("\n👋 感谢使用！")
+		case "6":
+			queueMenu()
+		case "7", "q", "quit", "exit":
+			colorGreen.Println(msg("menu.bye"))
 			return nil
 		default:
-			colorYellow.Printf("⚠️ 无效选择: %s\n", choice)
+			colorYellow.Printf(msg("menu.invalid"), choice)
 		}
 	}
 }
 
 // 显示交互式帮助
 func showInteractiveHelp() {
-	fmt.Println()
-	colorCyan.Println("📖 === 版本使用帮助 ===")
-	fmt.Println()
-
-	colorBlue.Println("🎯 智能文件处理:")
-	fmt.Println("  • 直接拖拽任意文件到窗口")
-	fmt.Println("  • 程序自动判断最适合的操作")
-	fmt.Println("  • 合并文件→拆分，视频文件→合并")
-	fmt.Println()
-
-	colorBlue.Println("🎬 文件合并:")
-	fmt.Println("  • 将任意文件隐藏到视频文件中")
-	fmt.Println("  • 支持超大文件 (8字节大小字段)")
-	fmt.Println("  • 生成格式，不兼容v1/v2")
-	fmt.Println()
-
-	colorBlue.Println("📦 文件拆分:")
-	fmt.Println("  • 仅支持格式合并文件")
-	fmt.Println("  • 超快固定位置读取")
-	fmt.Println("  • 自动验证文件完整性")
-	fmt.Println()
-
-	colorBlue.Println("🔧 开发模式:")
-	fmt.Println("  • 显示详细的格式解析信息")
-	fmt.Println("  • 即使解析失败也显示调试数据")
-	fmt.Println("  • 帮助诊断文件格式问题")
-	fmt.Println()
-
-	colorBlue.Println("💡 格式优势:")
-	fmt.Println("  • 支持18EB超大文件")
-	fmt.Println("  • 固定位置读取，极速解析")
-	fmt.Println("  • 更严格的数据验证")
-	fmt.Println("  • 简化的处理逻辑")
-
-	readUserInput("\n按回车返回主菜单...")
+	fmt.Fprintln(messageOutput)
+	colorCyan.Println(msg("help.title"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.smart"))
+	fmt.Fprintln(messageOutput, msg("help.smart_1"))
+	fmt.Fprintln(messageOutput, msg("help.smart_2"))
+	fmt.Fprintln(messageOutput, msg("help.smart_3"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.merge"))
+	fmt.Fprintln(messageOutput, msg("help.merge_1"))
+	fmt.Fprintln(messageOutput, msg("help.merge_2"))
+	fmt.Fprintln(messageOutput, msg("help.merge_3"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.split"))
+	fmt.Fprintln(messageOutput, msg("help.split_1"))
+	fmt.Fprintln(messageOutput, msg("help.split_2"))
+	fmt.Fprintln(messageOutput, msg("help.split_3"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.dev"))
+	fmt.Fprintln(messageOutput, msg("help.dev_1"))
+	fmt.Fprintln(messageOutput, msg("help.dev_2"))
+	fmt.Fprintln(messageOutput, msg("help.dev_3"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.queue"))
+	fmt.Fprintln(messageOutput, msg("help.queue_1"))
+	fmt.Fprintln(messageOutput, msg("help.queue_2"))
+	fmt.Fprintln(messageOutput, msg("help.queue_3"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.paths"))
+	fmt.Fprintln(messageOutput, msg("help.paths_1"))
+	fmt.Fprintln(messageOutput, msg("help.paths_2"))
+	fmt.Fprintln(messageOutput, msg("help.paths_3"))
+	fmt.Fprintln(messageOutput, msg("help.paths_4"))
+	fmt.Fprintln(messageOutput)
+
+	colorBlue.Println(msg("help.format"))
+	fmt.Fprintln(messageOutput, msg("help.format_1"))
+	fmt.Fprintln(messageOutput, msg("help.format_2"))
+	fmt.Fprintln(messageOutput, msg("help.format_3"))
+	fmt.Fprintln(messageOutput, msg("help.format_4"))
+	fmt.Fprintln(messageOutput, msg("help.format_5"))
+
+	readUserInput(msg("help.back"))
 }
 
 // 验证并清理文件名
 func validateAndCleanFilename(filename string) (string, error) {
 	if filename == "" {
-		return "", fmt.Errorf("文件名不能为空")
+		return "", newError("filename.empty")
 	}
 
 	// 移除路径分隔符，只保留文件名部分
@@ -634,63 +1379,143 @@ func validateAndCleanFilename(filename string) (string, error) {
 	cleaned = strings.TrimLeft(cleaned, ".")
 
 	// 限制长度
-	if len(cleaned) > MAX_FILENAME_LENGTH {
-		ext := filepath.Ext(cleaned)
-		nameWithoutExt := strings.TrimSuffix(cleaned, ext)
-		maxNameLength := MAX_FILENAME_LENGTH - len(ext)
-		if maxNameLength > 0 {
-			cleaned = nameWithoutExt[:maxNameLength] + ext
-		} else {
-			cleaned = cleaned[:MAX_FILENAME_LENGTH]
-		}
-	}
+	cleaned = truncateFilename(cleaned, MAX_FILENAME_LENGTH)
 
 	// 确保UTF-8编码有效
 	if !utf8.ValidString(cleaned) {
-		return "", fmt.Errorf("文件名包含无效的UTF-8字符")
+		return "", newError("filename.invalid_utf8")
 	}
 
 	if cleaned == "" {
-		return "", fmt.Errorf("处理后的文件名为空")
+		return "", newError("filename.empty_after_clean")
 	}
 
 	return cleaned, nil
 }
 
+// 由合并文件中存储的附加文件名生成输出目录下的路径。存储名来自文件本身、不可信，
+// 先按合并时的规则清理，再确认结果仍在输出目录内，防止 ../ 等路径穿越。返回路径和清理后的文件名
+func attachmentOutputPath(outputDir, storedName string) (string, string, error) {
+	name, err := validateAndCleanFilename(storedName)
+	if err != nil {
+		return "", "", exitErrorf(EXIT_INVALID_FORMAT, "split.bad_stored_name", storedName, err)
+	}
+	path := filepath.Join(outputDir, name)
+	if rel, err := filepath.Rel(outputDir, path); err != nil || !filepath.IsLocal(rel) {
+		return "", "", exitErrorf(EXIT_INVALID_FORMAT, "split.name_escapes", storedName, outputDir)
+	}
+	return path, name, nil
+}
+
+// 将文件名截断到指定字节数，尽量保留扩展名
+func truncateFilename(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+	ext := filepath.Ext(name)
+	nameWithoutExt := strings.TrimSuffix(name, ext)
+	maxNameLength := maxBytes - len(ext)
+	if maxNameLength > 0 {
+		return truncateUTF8(nameWithoutExt, maxNameLength) + ext
+	}
+	// 扩展名本身超长时整体截断
+	return truncateUTF8(name, maxBytes)
+}
+
+// 按字节长度截断字符串，只在完整字符边界处截断
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
 // 验证文件
 func validateFile(filePath string) (*FileInfo, error) {
+	return checkInputFile(filePath, false)
+}
+
+// 验证输入文件存在、可读且不是目录；allowEmpty 为 false 时拒绝空文件
+func checkInputFile(filePath string, allowEmpty bool) (*FileInfo, error) {
+	// 符号链接按目标文件报告和命名，目标不存在时单独说明
+	var target string
+	if linkInfo, err := os.Lstat(filePath); err == nil && linkInfo.Mode()&os.ModeSymlink != 0 {
+		resolved, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			dest, _ := os.Readlink(filePath)
+			return nil, exitErrorf(EXIT_IO, "error.symlink_broken", filePath, dest)
+		}
+		target = resolved
+	}
+
 	info, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("文件不存在: %s", filePath)
+			return nil, exitErrorf(EXIT_IO, "error.not_found", filePath)
 		}
-		return nil, fmt.Errorf("无法访问文件: %v", err)
+		return nil, exitErrorf(EXIT_IO, "error.stat_failed", err)
 	}
 
 	if info.IsDir() {
-		return nil, fmt.Errorf("不能处理目录: %s", filePath)
+		return nil, exitErrorf(EXIT_USAGE, "error.is_directory", filePath)
 	}
 
-	if info.Size() == 0 {
-		return nil, fmt.Errorf("不能处理空文件: %s", filePath)
+	if info.Size() == 0 && !allowEmpty {
+		return nil, exitErrorf(EXIT_USAGE, "error.empty_file", filePath)
 	}
 
 	// 检查文件是否可读
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("无法打开文件进行读取: %v", err)
+		return nil, exitErrorf(EXIT_IO, "error.open_read_failed", err)
 	}
 	file.Close()
 
+	name := info.Name()
+	if target != "" {
+		name = filepath.Base(target)
+	}
 	return &FileInfo{
-		Name: info.Name(),
-		Size: info.Size(),
-		Path: filePath,
+		Name:    name,
+		Size:    info.Size(),
+		Path:    filePath,
+		Target:  target,
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
 	}, nil
 }
 
-// 格式化文件大小
-func formatFileSize(bytes int64) string {
+// 验证附加路径，允许普通文件（可以为空）或目录（目录将打包为归档）
+func validateAttachPath(attachPath string) (*FileInfo, error) {
+	info, err := os.Stat(attachPath)
+	if err != nil || !info.IsDir() {
+		return checkInputFile(attachPath, true)
+	}
+
+	entries, estimate, err := scanAttachDir(attachPath)
+	if err != nil {
+		return nil, err
+	}
+	if entries == 0 {
+		colorYellow.Printf(msg("merge.empty_dir"), attachPath)
+	}
+
+	return &FileInfo{
+		Name:    info.Name(),
+		Size:    estimate,
+		Path:    attachPath,
+		IsDir:   true,
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}, nil
+}
+
+// 格式化文件大小
+func formatFileSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
@@ -703,467 +1528,2087 @@ func formatFileSize(bytes int64) string {
 	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// 流式复制数据，带进度条
-func copyWithProgress(dst io.Writer, src io.Reader, size int64, desc string) error {
-	bar := progressbar.NewOptions64(size,
-		progressbar.OptionSetDescription(desc),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "█",
-			SaucerHead:    "█",
-			SaucerPadding: "░",
+// 格式化修改时间，未记录时显示 unknown
+func formatModTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Format("2006-01-02 15:04:05 -0700")
+}
+
+// 格式化权限位，未记录时显示 unknown
+func formatMode(mode uint32) string {
+	if mode == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%04o", mode)
+}
+
+// 复制的字节数与预期不符（源数据被截断或在复制过程中变化）
+var errCopySizeMismatch = newError("copy.size_mismatch")
+
+// 创建写到标准错误的进度条，visible 为 false 时不显示
+func newProgressBar(size int64, desc string, visible bool) *progressbar.ProgressBar {
+	theme := progressbar.Theme{
+		Saucer:        "█",
+		SaucerHead:    "█",
+		SaucerPadding: "░",
+		BarStart:      "[",
+		BarEnd:        "]",
+	}
+	// 不使用颜色时多半是日志或不支持VT的控制台，改用纯ASCII
+	if color.NoColor {
+		theme = progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
 			BarStart:      "[",
 			BarEnd:        "]",
-		}),
+		}
+	}
+	return progressbar.NewOptions64(size,
+		progressbar.OptionSetDescription(desc),
+		progressbar.OptionSetTheme(theme),
 		progressbar.OptionShowBytes(true),
 		progressbar.OptionSetWidth(50),
 		progressbar.OptionShowCount(),
+		// 自带的剩余时间按全程平均速度估算，改为在描述中显示平滑后的速度和剩余时间
+		progressbar.OptionSetPredictTime(false),
+		progressbar.OptionSetVisibility(visible),
+		progressbar.OptionSetWriter(os.Stderr),
 	)
+}
 
-	buffer := make([]byte, BUFFER_SIZE)
-	var copied int64
+// 流式复制数据并向 progress 上报进度，返回复制的字节数；size 不为 -1 时复制量必须与之相等。
+// hashes 在复制的同一遍中计算，不额外读取数据；只计算校验值时 dst 为 nil
+func copyWithProgress(ctx context.Context, dst io.Writer, src io.Reader, size int64, progress Progress, hashes ...hash.Hash) (copied int64, err error) {
+	// 在读取端计算校验值，目标保持原样，仍可使用其 ReadFrom 快速路径。
+	// 数据先送入校验值再写入目标：目标中的断点记录保存的是已包含本次数据的校验状态
+	if len(hashes) > 0 {
+		writers := make([]io.Writer, len(hashes))
+		for i, h := range hashes {
+			writers[i] = h
+		}
+		src = io.TeeReader(src, io.MultiWriter(writers...))
+	}
+	// 只计算校验值时读取的数据直接丢弃；io.Discard 的 ReadFrom 按 8KB 读取，隐藏它以按缓冲区大小读取
+	if dst == nil || dst == io.Discard {
+		dst = writeOnly{io.Discard}
+	}
+	// 并行提取时另一个任务失败后中止读取
+	if task, ok := progress.(sharedTask); ok {
+		src = task.shared.wrap(src)
+	}
+	_, silent := progress.(noopProgress)
 
-	for {
-		n, err := src.Read(buffer)
-		if n > 0 {
-			if _, writeErr := dst.Write(buffer[:n]); writeErr != nil {
-				return fmt.Errorf("写入失败: %v", writeErr)
+	activeCopies.Add(1)
+	defer activeCopies.Add(-1)
+	// 取消时返回已复制的字节数，阶段由调用的操作补充
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = &CanceledError{Written: copied, Err: ctx.Err()}
+		}
+	}()
+	// --limit-rate 时按限速读取（所有复制共用一个限速器）
+	if copyLimiter != nil {
+		src = &limitedReader{src: src, ctx: ctx, limiter: copyLimiter}
+	}
+
+	buffer := make([]byte, bufferSize)
+	if !silent {
+		// 计数读取器上报进度；写入端隐藏 ReadFrom，保证按 --buffer-size 读写
+		progress.Start(size)
+		reader := &progressReader{src: src, ctx: ctx, progress: progress}
+		_, err := io.CopyBuffer(writeOnly{dst}, reader, buffer)
+		copied = reader.copied
+		if err != nil {
+			return copied, err
+		}
+		if size < 0 || copied == size {
+			progress.Done()
+		}
+	} else {
+		// 不显示进度时交给 io.CopyBuffer，目标支持 ReadFrom 时（文件到文件）
+		// 走 copy_file_range/sendfile 等系统快速路径；分块复制以便在块之间响应 Ctrl-C。
+		// 两条路径都原样返回读写错误，由调用方说明是哪一步失败
+		for {
+			if ctx.Err() != nil {
+				return copied, ctx.Err()
+			}
+			n, err := io.CopyBuffer(dst, io.LimitReader(src, COPY_CHUNK_SIZE), buffer)
+			copied += n
+			if err != nil {
+				return copied, err
+			}
+			if n < COPY_CHUNK_SIZE {
+				break
 			}
-			copied += int64(n)
-			bar.Set64(copied)
 		}
-		if err == io.EOF {
-			break
+	}
+	if size >= 0 && copied != size {
+		return copied, newError("copy.size_mismatch_detail", errCopySizeMismatch, copied, size)
+	}
+	return copied, nil
+}
+
+// 复制失败对应的退出码：数据长度不符视为数据损坏，其他为读写错误
+func copyExitCode(err error) int {
+	if errors.Is(err, errCopySizeMismatch) {
+		return EXIT_INVALID_FORMAT
+	}
+	return EXIT_IO
+}
+
+// 验证附加路径并生成附加文件条目，usedNames 用于避免与已有附加文件重名
+// stdinName 为从标准输入读取的附加文件名（附加路径为 - 时使用）
+func prepareAttachments(attachPaths []string, stdinName string, usedNames map[string]bool, allowEmpty bool) ([]*FileInfo, []AttachmentEntry, error) {
+	attachInfos := make([]*FileInfo, 0, len(attachPaths))
+	attachEntries := make([]AttachmentEntry, 0, len(attachPaths))
+	stdinUsed := false
+	for _, attachPath := range attachPaths {
+		var attachInfo *FileInfo
+		var err error
+		if attachPath == STDIN_PATH {
+			if stdinUsed {
+				return nil, nil, exitErrorf(EXIT_USAGE, "merge.stdin_once")
+			}
+			stdinUsed = true
+			attachInfo, err = stdinAttachInfo(stdinName)
+		} else {
+			attachInfo, err = validateAttachPath(attachPath)
+		}
+		if err != nil {
+			return nil, nil, newError("error.attach_invalid", err)
+		}
+		// 空附加文件（如只靠文件名表达含义的标记文件）需确认或 --allow-empty
+		if attachInfo.Size == 0 && !attachInfo.IsDir && !attachInfo.IsStdin && !allowEmpty {
+			if err := requireConfirmation(msgf("merge.confirm_empty", attachPath)); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		cleanedAttachName, err := validateAndCleanFilename(attachInfo.Name)
+		if err != nil {
+			return nil, nil, exitErrorf(EXIT_USAGE, "error.filename_failed", err)
+		}
+
+		attachInfos = append(attachInfos, attachInfo)
+		modTime := attachInfo.ModTime
+		entry := AttachmentEntry{
+			Name:     uniqueAttachName(cleanedAttachName, usedNames),
+			IsDir:    attachInfo.IsDir,
+			ModTime:  &modTime,
+			MimeType: MIME_TAR,
+		}
+		if !attachInfo.IsDir && !attachInfo.IsStdin {
+			entry.MimeType = sniffMimeType(attachInfo.Path)
+		}
+		// 权限位仅在Unix系统上有意义
+		if runtime.GOOS != "windows" {
+			entry.Mode = uint32(attachInfo.Mode.Perm())
+		}
+		attachEntries = append(attachEntries, entry)
+	}
+	if stdinName != "" && !stdinUsed {
+		return nil, nil, exitErrorf(EXIT_USAGE, "merge.attach_name_without_stdin")
+	}
+
+	return attachInfos, attachEntries, nil
+}
+
+// 附加文件复制时的提示文字
+func attachCopyLabel(attachInfo *FileInfo) string {
+	if attachInfo.IsDir {
+		return msg("merge.packing_dir")
+	}
+	return msg("merge.copying_attach")
+}
+
+// 附加文件写入器：原始数据 → 压缩 → 加密 → 输出
+type attachmentWriter struct {
+	ctx       context.Context
+	progress  progressSource
+	dst       io.Writer
+	aead      cipher.AEAD
+	encParams *EncryptionParams
+	compress  bool
+}
+
+// 写入一个附加文件，完成后填写条目的存储大小和原始大小
+func (w *attachmentWriter) write(attachInfo *FileInfo, entry *AttachmentEntry, index int) error {
+	// 实际写入大小（目录打包、压缩、加密后）在写入完成后才能确定
+	counter := &countingWriter{w: w.dst}
+	var attachDst io.Writer = counter
+	var encWriter *encryptWriter
+	if w.aead != nil {
+		encWriter = newEncryptWriter(counter, w.aead, w.encParams, index)
+		attachDst = encWriter
+	}
+	var compWriter io.WriteCloser
+	if w.compress {
+		var err error
+		compWriter, err = newCompressWriter(attachDst)
+		if err != nil {
+			return newError("merge.compress_init_failed", err)
+		}
+		attachDst = compWriter
+	}
+	plainCounter := &countingWriter{w: attachDst}
+
+	if attachInfo.IsDir {
+		if err := copyDirArchive(w.ctx, w.progress, plainCounter, attachInfo); err != nil {
+			return err
+		}
+	} else if attachInfo.IsStdin {
+		// 标准输入无法预先读取，边写边识别MIME类型
+		mimeType, err := copyStdinAttachment(w.ctx, w.progress, plainCounter)
+		if err != nil {
+			return err
+		}
+		entry.MimeType = mimeType
+	} else {
+		if err := copyAttachFile(w.ctx, w.progress, plainCounter, attachInfo); err != nil {
+			return err
+		}
+	}
+
+	if compWriter != nil {
+		if err := compWriter.Close(); err != nil {
+			return newError("merge.write_compressed_failed", err)
+		}
+	}
+	if encWriter != nil {
+		if err := encWriter.Close(); err != nil {
+			return newError("merge.write_encrypted_failed", err)
+		}
+	}
+
+	entry.Size = uint64(counter.n)
+	entry.OriginalSize = uint64(plainCounter.n)
+	return nil
+}
+
+// progressReader 统计读取的字节数并上报进度，收到 Ctrl-C 时在两次读取之间中止
+type progressReader struct {
+	src      io.Reader
+	ctx      context.Context
+	progress Progress
+	copied   int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, r.ctx.Err()
+	}
+	n, err := r.src.Read(p)
+	r.copied += int64(n)
+	if n > 0 {
+		r.progress.Add(int64(n))
+	}
+	return n, err
+}
+
+// writeOnly 只暴露 Write，不让 io.CopyBuffer 改用 ReadFrom 而绕过缓冲区
+type writeOnly struct {
+	dst io.Writer
+}
+
+func (w writeOnly) Write(p []byte) (int, error) {
+	return w.dst.Write(p)
+}
+
+// 复制单个附加文件到输出
+func copyAttachFile(ctx context.Context, progress progressSource, dst io.Writer, attachInfo *FileInfo) error {
+	attachFile, err := os.Open(attachInfo.Path)
+	if err != nil {
+		return newError("merge.open_attach_failed", err)
+	}
+	defer attachFile.Close()
+
+	if _, err := copyWithProgress(ctx, dst, attachFile, attachInfo.Size, progress.forCopy(msg("progress.attachment"))); err != nil {
+		return newError("merge.copy_attach_failed", err)
+	}
+
+	return nil
+}
+
+// 为重名的附加文件生成唯一名称（追加 _2、_3 等后缀）
+func uniqueAttachName(name string, used map[string]bool) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// 合并输出的默认文件名：<视频名>_merged_v3.<扩展名>
+func mergedOutputName(videoName string) string {
+	ext := filepath.Ext(videoName)
+	return strings.TrimSuffix(videoName, ext) + "_merged_v3" + ext
+}
+
+// merge 省略输出文件时的默认输出路径：位于 outputDir（--output-dir）、
+// 配置的默认输出目录或视频所在目录
+func defaultMergeOutputPath(videoPath, outputDir string) string {
+	name := mergedOutputName(filepath.Base(videoPath))
+	switch {
+	case outputDir != "":
+		return filepath.Join(outputDir, name)
+	case defaultOutputDir != "":
+		return filepath.Join(defaultOutputDir, name)
+	}
+	return filepath.Join(filepath.Dir(videoPath), name)
+}
+
+// 格式合并文件
+// ctx 被取消时在两次读写之间中止，删除未写完的输出并返回 *CanceledError
+func mergeFiles(ctx context.Context, videoPath string, attachPaths []string, outputPath string, opts MergeOptions) (err error) {
+	logInfof(colorBlue, "merge.start")
+	// 本次操作各步复制的进度来源，随调用传递
+	progress := progressSource{progress: opts.Progress}
+	phase := PHASE_PREPARE
+	defer func() { err = canceledOperation(ctx, err, phase) }()
+
+	if len(attachPaths) == 0 {
+		return exitErrorf(EXIT_USAGE, "error.no_attachments")
+	}
+
+	// 验证备注
+	if len(opts.Comment) > MAX_COMMENT_LENGTH {
+		return exitErrorf(EXIT_USAGE, "merge.comment_too_long", len(opts.Comment), MAX_COMMENT_LENGTH)
+	}
+	if !utf8.ValidString(opts.Comment) {
+		return exitErrorf(EXIT_USAGE, "merge.comment_invalid_utf8")
+	}
+
+	// 验证对齐单位
+	if opts.Align < 0 || opts.Align > MAX_ALIGNMENT {
+		return exitErrorf(EXIT_USAGE, "merge.bad_align", opts.Align, MAX_ALIGNMENT)
+	}
+
+	// 验证嵌入方式
+	if opts.EmbedMode, err = parseEmbedMode(opts.EmbedMode); err != nil {
+		return err
+	}
+
+	if opts.Password != "" && opts.KeyFile != "" {
+		return exitErrorf(EXIT_USAGE, "crypto.password_key_file_conflict")
+	}
+	// 纠错校验块以明文魔术字节开头，会暴露隐蔽模式的合并文件
+	if opts.FEC && opts.StealthKey != "" {
+		return exitErrorf(EXIT_USAGE, "fec.conflict", "--stealth-key")
+	}
+
+	// 验证输入文件
+	videoInfo, err := validateFile(videoPath)
+	if err != nil {
+		return newError("error.video_invalid_w", err)
+	}
+	if err := checkOutputsNotInputs([]string{outputPath}, append([]string{videoPath}, attachPaths...)); err != nil {
+		return err
+	}
+
+	// 视频本身已是合并文件时，外层尾部会遮住内层，默认拒绝
+	nested := nestedTrailerOf(videoPath)
+	if nested != nil {
+		if !opts.AllowNested {
+			return exitErrorf(EXIT_USAGE, "merge.nested_refused", videoInfo.Name, len(nested.Attachments))
+		}
+		logWarnf("merge.nested_allowed", videoInfo.Name, len(nested.Attachments))
+	}
+	warnStrippedCarrier(videoPath)
+
+	// 验证附加文件并清理文件名
+	attachInfos, attachEntries, err := prepareAttachments(attachPaths, opts.AttachName, make(map[string]bool), opts.AllowEmpty)
+	if err != nil {
+		return err
+	}
+	if opts.ZipCompatible {
+		if err := checkZipCompatible(opts, videoInfo, attachInfos, attachEntries); err != nil {
+			return err
+		}
+	}
+	// MP4 box 嵌入只适用于 MP4/MOV 载体，其他载体改用末尾追加模式
+	if opts.EmbedMode == EMBED_MODE_MP4BOX {
+		if err := checkMP4BoxMode(opts, attachInfos); err != nil {
+			return err
+		}
+		if err := checkMP4Carrier(videoPath); err != nil {
+			logWarnf("mp4box.fallback", videoInfo.Name, err)
+			opts.EmbedMode = EMBED_MODE_TRAILER
+		}
+	}
+	// MKV 附件嵌入只适用于可追加附件的 MKV 载体，其他载体改用末尾追加模式
+	var carrier *mkvCarrier
+	if opts.EmbedMode == EMBED_MODE_MKV_ATTACHMENT {
+		if err := checkMKVAttachmentMode(opts, attachInfos); err != nil {
+			return err
+		}
+		if carrier, err = inspectMKVCarrier(videoPath); err != nil {
+			logWarnf("mkvattach.fallback", videoInfo.Name, err)
+			opts.EmbedMode = EMBED_MODE_TRAILER
+		}
+	}
+	if nested != nil || opts.CompareWith != "" {
+		if err := checkAlreadyEmbedded(ctx, progress, videoPath, nested, attachInfos, opts.CompareWith); err != nil {
+			return err
+		}
+	}
+
+	// 显示文件信息
+	fmt.Fprintf(messageOutput, msg("common.video_file_line"), videoInfo.Name, formatFileSize(videoInfo.Size))
+	for i, attachInfo := range attachInfos {
+		if attachInfo.IsDir {
+			fmt.Fprintf(messageOutput, msg("merge.dir_line"), attachInfo.Name, attachEntries[i].Name, formatFileSize(attachInfo.Size))
+		} else if attachInfo.IsStdin {
+			fmt.Fprintf(messageOutput, msg("merge.stdin_line"), attachEntries[i].Name)
+		} else {
+			fmt.Fprintf(messageOutput, msg("merge.attach_line"), attachInfo.Name, attachEntries[i].Name, formatFileSize(attachInfo.Size), attachEntries[i].MimeType)
+		}
+	}
+
+	size, err := estimateMergedSize(videoInfo, attachInfos, attachEntries, opts)
+	if err != nil {
+		return err
+	}
+	// MKV 附件嵌入：Segment 大小字段改写为覆盖到输出末尾，原字段放不下时改用末尾追加模式
+	var mkv *mkvLayout
+	var segmentSize []byte
+	if carrier != nil {
+		if segmentSize, err = carrier.patch(size); err != nil {
+			logWarnf("mkvattach.fallback", videoInfo.Name, err)
+			opts.EmbedMode = EMBED_MODE_TRAILER
+			if size, err = estimateMergedSize(videoInfo, attachInfos, attachEntries, opts); err != nil {
+				return err
+			}
+		} else {
+			mkv = carrier.layout(attachEntries[0].Name, attachEntries[0].MimeType)
+		}
+	}
+	outputs := []planOutput{{Label: msg("plan.merged_file"), Path: outputPath, Size: size}}
+	if opts.DryRun {
+		return checkOutputPlan(outputs, false, opts.SkipSpaceCheck)
+	}
+
+	// --resume：断点有效时在已写入的视频数据之后继续，无效时从头开始
+	var resume *resumeCheckpoint
+	if opts.Resume {
+		if opts.VolumeSize > 0 {
+			return exitErrorf(EXIT_USAGE, "resume.no_volumes")
+		}
+		resume = loadResumeCheckpoint(outputPath, videoPath, videoInfo.Size)
+	}
+
+	// 检查输出文件是否存在（分卷时检查第一个分卷）
+	existingPath := outputPath
+	if opts.VolumeSize > 0 {
+		existingPath = volumePartPath(outputPath, 0)
+	}
+	if _, err := os.Stat(existingPath); err == nil && resume == nil {
+		colorYellow.Printf(msg("merge.output_exists"), existingPath)
+		if err := confirmOverwrite(existingPath, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+
+	// 开始写入前确认剩余空间足够，且不超出文件系统的单文件大小上限
+	if err := checkFreeSpace(outputs, opts.SkipSpaceCheck); err != nil {
+		return err
+	}
+	if err := checkFileSizeLimit(outputPath, size, opts.VolumeSize); err != nil {
+		return err
+	}
+
+	// 准备加密
+	var encParams *EncryptionParams
+	var aead cipher.AEAD
+	if opts.Password != "" || opts.KeyFile != "" {
+		encParams, aead, err = newEncryption(opts.Password, opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		logInfof(nil, "merge.will_encrypt")
+	}
+	if opts.Compress {
+		logInfof(nil, "merge.will_compress")
+	}
+
+	// ZIP兼容模式先读一遍附加文件计算ZIP条目的 CRC-32
+	var zip *zipEntry
+	if opts.ZipCompatible {
+		logInfof(colorCyan, "zip.computing_checksum")
+		checksum, err := zipChecksum(ctx, progress, attachInfos[0])
+		if err != nil {
+			return withExitCode(copyExitCode(err), err)
+		}
+		zip = &zipEntry{Name: attachEntries[0].Name, Size: attachInfos[0].Size, CRC32: checksum, ModTime: attachInfos[0].ModTime}
+	}
+
+	// 打开视频文件
+	videoFile, err := os.Open(videoPath)
+	if err != nil {
+		return exitErrorf(EXIT_IO, "merge.open_video_failed", err)
+	}
+	defer videoFile.Close()
+
+	// 创建输出文件（或第一个分卷）
+	var outputFile io.Writer
+	var file *os.File
+	var volumes *volumeWriter
+	if opts.VolumeSize > 0 {
+		volumes, err = createVolumes(outputPath, opts.VolumeSize)
+		if err != nil {
+			return withExitCode(EXIT_IO, err)
+		}
+		defer volumes.Close()
+		outputFile = volumes
+	} else {
+		if resume != nil {
+			file, err = openResumedOutput(outputPath, resume)
+		} else {
+			file, err = os.Create(outputPath)
+		}
+		if err != nil {
+			return exitErrorf(EXIT_IO, "merge.create_output_failed", err)
+		}
+		defer file.Close()
+		// 续传时断点之前的数据有效，中断时不删除
+		if resume == nil {
+			trackPartialOutput(outputPath)
+			removeResumeFile(outputPath)
+		}
+		outputFile = file
+		// 失败时截断到已写入的部分（成功时文件已关闭，不再生效）
+		if preallocateOutput(file, size) {
+			defer trimPreallocated(file)
+		}
+	}
+	// 合并零碎的小写入（加密分块、压缩输出、尾部元数据），缓冲区与 --buffer-size 同大；同步到磁盘前须 Flush
+	output := bufio.NewWriterSize(outputFile, int(bufferSize))
+	transferStart := time.Now()
+
+	fmt.Fprintln(messageOutput)
+
+	// 1. 复制视频文件（同时计算SHA-256）；与输出在同一支持 reflink 的文件系统上时直接克隆，只读取计算校验值
+	logInfof(colorCyan, "merge.copying_video")
+	phase = PHASE_VIDEO
+	var videoDst io.Writer = output
+	var videoCloned int64
+	if file != nil && resume == nil && mkv == nil {
+		if videoCloned = cloneVideoData(file, videoFile, videoInfo.Size); videoCloned > 0 {
+			videoDst = &skipWriter{w: output, skip: videoCloned}
+		}
+	}
+	// 续传时只读取断点之后的视频数据，校验值从记录的中间状态继续计算
+	videoHash := resumedVideoHash(resume)
+	var videoSrc io.Reader = videoFile
+	var resumedSize int64
+	if resume != nil {
+		resumedSize = resume.Offset
+		videoSrc = io.NewSectionReader(videoFile, resumedSize, videoInfo.Size-resumedSize)
+	}
+	// 克隆的视频不需要断点
+	if file != nil && videoCloned == 0 {
+		if checkpoints := startCheckpoints(resume, videoPath, videoInfo.Size, outputPath, file, output.Flush, videoHash); checkpoints != nil {
+			videoDst = io.MultiWriter(videoDst, checkpoints)
+		}
+	}
+	// MKV 附件嵌入：写入的 Segment 大小字段已改写，校验值仍按原始视频计算
+	if segmentSize != nil {
+		videoDst = &patchWriter{w: videoDst, offset: carrier.SizeOffset, data: segmentSize}
+	}
+	if _, err := copyWithProgress(ctx, videoDst, videoSrc, videoInfo.Size-resumedSize, progress.forCopy(msg("progress.video")), videoHash); err != nil {
+		return exitErrorf(copyExitCode(err), "merge.copy_video_failed", err)
+	}
+
+	// 对齐填充（全零，不计入校验值）
+	padding := alignPadding(videoInfo.Size, opts.Align)
+	if padding > 0 {
+		if _, err := output.Write(make([]byte, padding)); err != nil {
+			return exitErrorf(EXIT_IO, "merge.write_padding_failed", err)
+		}
+	}
+
+	// ZIP兼容模式：附加数据之前写入ZIP本地文件头
+	var zipHeader, zipDirectory int64
+	zipCRC := crc32.NewIEEE()
+	if zip != nil {
+		zip.Offset = videoInfo.Size + padding
+		if _, err := output.Write(zip.localHeader()); err != nil {
+			return exitErrorf(EXIT_IO, "zip.write_failed", err)
+		}
+		zipHeader, zipDirectory = zipStructureLengths(zip.Name)
+	}
+
+	// MP4 box 嵌入：附加数据之前写入 free box 头，box 覆盖附加数据和尾部元数据直到文件末尾
+	var boxHeader int64
+	if opts.EmbedMode == EMBED_MODE_MP4BOX {
+		boxHeader = MP4_LARGE_BOX_HEADER_LENGTH
+		if _, err := output.Write(mp4BoxHeader(size - videoInfo.Size - padding)); err != nil {
+			return exitErrorf(EXIT_IO, "mp4box.write_failed", err)
+		}
+	}
+	// MKV 附件嵌入：附加数据之前写入 Attachments/AttachedFile/FileData 元素头
+	if mkv != nil {
+		uid, err := newMKVFileUID()
+		if err != nil {
+			return err
+		}
+		if _, err := output.Write(mkvAttachmentHeader(attachEntries[0].Name, attachEntries[0].MimeType, uid, attachInfos[0].Size)); err != nil {
+			return exitErrorf(EXIT_IO, "mkvattach.write_failed", err)
+		}
+	}
+	attachStart := videoInfo.Size + padding + zipHeader + boxHeader + int64(mkv.HeaderLength())
+
+	// 2. 依次复制附加文件（同时计算整个附加数据区的SHA-256和CRC32）
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	// --fec：记下附加数据开头，由纠错校验块一并保护
+	attachHead := &headCapture{}
+	if opts.FEC {
+		attachHead.limit = FEC_ATTACH_HEAD
+	}
+	writer := attachmentWriter{
+		ctx:       ctx,
+		progress:  progress,
+		dst:       io.MultiWriter(output, attachHash, attachCRC, zipCRC, attachHead),
+		aead:      aead,
+		encParams: encParams,
+		compress:  opts.Compress,
+	}
+	phase = PHASE_ATTACHMENTS
+	var totalAttachSize, totalOriginalSize int64
+	for i, attachInfo := range attachInfos {
+		attachEntries[i].Offset = attachStart + totalAttachSize
+		colorCyan.Printf("\n%s (%d/%d): %s\n", attachCopyLabel(attachInfo), i+1, len(attachInfos), attachEntries[i].Name)
+		if err := writer.write(attachInfo, &attachEntries[i], i); err != nil {
+			return withExitCode(copyExitCode(err), err)
+		}
+		totalAttachSize += int64(attachEntries[i].Size)
+		totalOriginalSize += int64(attachEntries[i].OriginalSize)
+	}
+
+	// 计算 CRC-32 之后附加文件被修改时，ZIP条目将无法通过解压工具的校验
+	if zip != nil && zipCRC.Sum32() != zip.CRC32 {
+		return exitErrorf(EXIT_IO, "zip.attach_changed", attachInfos[0].Name)
+	}
+
+	// 3. 写入格式元数据
+	logInfof(colorCyan, "common.writing_metadata")
+	phase = PHASE_METADATA
+	createdAt := time.Now()
+
+	spec := &trailerSpec{
+		VideoSize:    videoInfo.Size,
+		Padding:      padding,
+		ZipHeader:    zipHeader,
+		ZipDirectory: zipDirectory,
+		BoxHeader:    boxHeader,
+		MKV:          mkv,
+		VideoName:    videoInfo.Name,
+		VideoSHA256:  videoHash.Sum(nil),
+		AttachSHA256: attachHash.Sum(nil),
+		AttachCRC32:  attachCRC.Sum32(),
+		Comment:      opts.Comment,
+		ToolVersion:  toolVersion,
+		CreatedAt:    &createdAt,
+		Attachments:  attachEntries,
+		Encryption:   encParams,
+		Compressed:   opts.Compress,
+		AuthKey:      opts.AuthKey,
+	}
+	// 纠错校验块长度取决于尾部元数据长度：长度记录是定长的，先按占位值生成得到元数据长度
+	if opts.FEC {
+		spec.FEC = 1
+	}
+	metadata, err := buildTrailer(spec)
+	if err != nil {
+		return err
+	}
+	var fecBlock []byte
+	if opts.FEC {
+		if spec.FEC, err = fecBlockLength(len(attachHead.data), metadata.Len()); err != nil {
+			return err
+		}
+		if metadata, err = buildTrailer(spec); err != nil {
+			return err
+		}
+		fecBlock = buildFECBlock(attachHead.data, metadata.Bytes(), attachStart, attachStart+totalAttachSize)
+		if _, err := output.Write(fecBlock); err != nil {
+			return exitErrorf(EXIT_IO, "error.write_metadata_failed", err)
+		}
+	}
+
+	// box 头中的大小按合并前的附加文件大小计算，合并过程中附加文件被修改时不再覆盖到文件末尾
+	if boxHeader > 0 {
+		if written := boxHeader + totalAttachSize + int64(metadata.Len()); written != size-videoInfo.Size-padding {
+			return exitErrorf(EXIT_IO, "mp4box.size_changed", size-videoInfo.Size-padding, written)
 		}
+	}
+	// Segment 大小和元素头中的大小同样按合并前的附加文件大小计算
+	if mkv != nil {
+		if written := int64(mkv.Header+mkv.Void) + totalAttachSize + int64(metadata.Len()); written != size-videoInfo.Size {
+			return exitErrorf(EXIT_IO, "mkvattach.size_changed", size-videoInfo.Size, written)
+		}
+		if _, err := output.Write(ebmlElementHeader(EBML_VOID_ID, uint64(metadata.Len()))); err != nil {
+			return exitErrorf(EXIT_IO, "mkvattach.write_failed", err)
+		}
+	}
+
+	if opts.StealthKey != "" {
+		logInfof(colorCyan, "merge.sealing_metadata")
+	}
+	// ZIP兼容模式：中央目录和目录结束记录写在尾部元数据之前，尾部元数据作为ZIP注释
+	if zip != nil {
+		directory, err := zip.directory(metadata.Len())
+		if err != nil {
+			return err
+		}
+		if _, err := output.Write(directory); err != nil {
+			return exitErrorf(EXIT_IO, "zip.write_failed", err)
+		}
+	}
+	totalMetadataSize, err := writeTrailer(output, metadata, opts.StealthKey)
+	if err != nil {
+		return withExitCode(EXIT_IO, err)
+	}
+	if err := output.Flush(); err != nil {
+		return exitErrorf(EXIT_IO, "error.flush_output_failed", err)
+	}
+	// 确认数据（含尾部元数据）已写入磁盘后才报告完成
+	outputPaths := []string{outputPath}
+	var totalSize int64
+	if volumes != nil {
+		if err := volumes.finish(); err != nil {
+			return withExitCode(EXIT_IO, err)
+		}
+		outputPaths = volumes.paths()
+		totalSize = volumes.total
+	} else {
+		if err := trimPreallocated(file); err != nil {
+			return withExitCode(EXIT_IO, err)
+		}
+		if err := syncAndClose(file); err != nil {
+			return withExitCode(EXIT_IO, err)
+		}
+		finishPartialOutput(outputPath)
+		removeResumeFile(outputPath)
+		if outputInfo, err := os.Stat(outputPath); err == nil {
+			totalSize = outputInfo.Size()
+		}
+	}
+
+	transferTime := time.Since(transferStart)
+
+	// --verify：重新读取输出，与写入时计算的校验值比较
+	if opts.Verify {
+		phase = PHASE_VERIFY
+		if err := verifyMergedOutput(ctx, progress, existingPath, opts.StealthKey, hex.EncodeToString(videoHash.Sum(nil)), hex.EncodeToString(attachHash.Sum(nil))); err != nil {
+			return err
+		}
+	}
+
+	// 获取输出文件的绝对路径
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		absOutputPath = outputPath
+	}
+
+	colorGreen.Print(msg("merge.done"))
+	fmt.Fprint(messageOutput, msg("merge.stats"))
+	fmt.Fprintf(messageOutput, msg("merge.stats_video"), formatFileSize(videoInfo.Size))
+	if videoCloned > 0 {
+		fmt.Fprintf(messageOutput, msg("merge.stats_cloned"), formatFileSize(videoCloned))
+	}
+	if padding > 0 {
+		fmt.Fprintf(messageOutput, msg("merge.stats_padding"), padding, attachStart)
+	}
+	if zip != nil {
+		fmt.Fprintf(messageOutput, msg("merge.stats_zip"), zip.Name)
+	}
+	if boxHeader > 0 {
+		fmt.Fprintf(messageOutput, msg("merge.stats_mp4box"), videoInfo.Size+padding)
+	}
+	if mkv != nil {
+		fmt.Fprintf(messageOutput, msg("merge.stats_mkv"), videoInfo.Size, attachEntries[0].Name)
+	}
+	if len(attachEntries) > 1 {
+		fmt.Fprintf(messageOutput, msg("merge.stats_attach_multi"), formatFileSize(totalAttachSize), len(attachEntries))
+	} else {
+		fmt.Fprintf(messageOutput, msg("merge.stats_attach"), formatFileSize(totalAttachSize))
+	}
+	if opts.Comment != "" {
+		fmt.Fprintf(messageOutput, msg("merge.stats_comment"), opts.Comment)
+	}
+	if opts.Compress {
+		fmt.Fprintf(messageOutput, msg("merge.stats_compress"), formatFileSize(totalOriginalSize), formatFileSize(totalAttachSize), compressionRatio(totalOriginalSize, totalAttachSize))
+	}
+	if encParams != nil {
+		fmt.Fprint(messageOutput, msg("merge.stats_encrypt"))
+	}
+	fmt.Fprintf(messageOutput, msg("merge.stats_metadata"), formatFileSize(int64(totalMetadataSize)))
+	if opts.StealthKey != "" {
+		fmt.Fprint(messageOutput, msg("merge.stats_stealth"))
+	}
+	if fecBlock != nil {
+		fmt.Fprintf(messageOutput, msg("merge.stats_fec"), formatFileSize(int64(len(fecBlock))))
+	}
+	fmt.Fprintf(messageOutput, msg("merge.stats_total"), formatFileSize(totalSize))
+	if opts.Verify {
+		fmt.Fprint(messageOutput, msg("verify_output.stats_ok"))
+		if opts.Result != nil {
+			opts.Result.Verified = true
+		}
+	}
+	printTransferStats(totalSize-resumedSize, transferTime, opts.Result)
+	if nested != nil {
+		colorYellow.Print(msg("merge.stats_nested"))
+	}
+	if opts.CheckPlayable {
+		// MKV 附件嵌入后 Segment 覆盖整个输出
+		playableSize := videoInfo.Size
+		if mkv != nil {
+			playableSize = totalSize
+		}
+		checkPlayable(outputPath, playableSize)
+	}
+	if opts.Result != nil {
+		opts.Result.Outputs = absPaths(outputPaths...)
+		opts.Result.VideoSize = videoInfo.Size
+		opts.Result.AttachSize = totalAttachSize
+		opts.Result.MetadataSize = int64(totalMetadataSize)
+		opts.Result.TotalSize = totalSize
+	}
+	if volumes != nil {
+		fmt.Fprintf(messageOutput, msg("merge.volumes"), len(volumes.parts), formatFileSize(opts.VolumeSize))
+		printVolumeLayout(volumes.parts)
+		colorCyan.Printf(msg("common.full_path"), absOutputPath)
+		for _, path := range outputPaths {
+			printResultPath(path)
+		}
+		return nil
+	}
+	fmt.Fprintf(messageOutput, msg("merge.output_file"), filepath.Base(outputPath))
+	colorCyan.Printf(msg("common.full_path"), absOutputPath)
+	printResultPath(outputPath)
+
+	return nil
+}
+
+// 格式拆分文件
+// ctx 被取消时在两次读写之间中止，删除未写完的输出并返回 *CanceledError
+func splitFiles(ctx context.Context, mergedPath, outputDir string, opts SplitOptions) (err error) {
+	logInfof(colorBlue, "split.start")
+	// 本次操作各步复制的进度来源，随调用传递
+	progress := progressSource{progress: opts.Progress}
+	phase := PHASE_PREPARE
+	defer func() { err = canceledOperation(ctx, err, phase) }()
+
+	if opts.VideoOnly && opts.AttachOnly {
+		return exitErrorf(EXIT_USAGE, "split.video_only_attach_only")
+	}
+	if opts.VideoOut != "" && opts.AttachOnly {
+		return exitErrorf(EXIT_USAGE, "split.video_out_attach_only")
+	}
+	if len(opts.AttachOut) > 0 && opts.VideoOnly {
+		return exitErrorf(EXIT_USAGE, "split.attach_out_video_only")
+	}
+	if opts.AttachToStdout && (opts.VideoOnly || opts.Quick || opts.VideoOut != "" || len(opts.AttachOut) > 0 || opts.AttachVolumeSize > 0) {
+		return exitErrorf(EXIT_USAGE, "split.stdout_conflict")
+	}
+	if opts.DryRun && (opts.Quick || opts.AttachToStdout) {
+		return exitErrorf(EXIT_USAGE, "split.dry_run_conflict")
+	}
+
+	// 验证并打开合并文件（分卷时拼接全部分卷）；recover 命令直接提供找到的合并数据
+	var mergedFile mergedReader
+	var mergedInfo *FileInfo
+	if opts.recovered != nil {
+		mergedFile, mergedInfo = opts.recovered, opts.recovered.info
+	} else if mergedFile, mergedInfo, err = openMergedInput(mergedPath); err != nil {
+		return err
+	}
+	defer mergedFile.Close()
+
+	fmt.Fprintf(messageOutput, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+
+	// 创建调试信息
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
+	}
+
+	fmt.Fprintln(messageOutput)
+	logInfof(colorCyan, "split.parsing_metadata")
+
+	// 尝试读取格式数据，即使出错也要显示调试信息
+	defer printDebugInfo(debugInfo)
+
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, opts.StealthKey, debugInfo)
+	// 带纠错校验块时先检查尾部元数据和附加数据开头，有损坏时按修复后的内容继续；
+	// 尾部元数据无法读取时也从文件末尾查找校验块
+	if err != nil || trailer.FEC > 0 {
+		if repaired := repairMergedInput(mergedFile, mergedInfo.Size, trailer, debugInfo); repaired != nil {
+			repairedInfo := *mergedInfo
+			repairedInfo.Size = repaired.size
+			mergedFile, mergedInfo = repaired, &repairedInfo
+			trailer, err = loadTrailer(mergedFile, mergedInfo.Size, opts.StealthKey, debugInfo)
+		}
+	}
+	if err != nil {
+		// 重新封装后尾部元数据已丢失时，仍可取出本工具写入的 MKV 附件
+		if !opts.VideoOnly && !opts.Quick && !opts.AttachToStdout {
+			if found := findMKVAttachment(mergedFile, mergedInfo.Size); found != nil {
+				logWarnf("mkvattach.remuxed", found.Name)
+				// 尾部元数据已丢失，无法校验认证值
+				if opts.AuthKey != "" {
+					if err := authFailure("auth.missing", opts.IgnoreAuth, debugInfo); err != nil {
+						return err
+					}
+				}
+				phase = PHASE_ATTACHMENTS
+				return extractRemuxedAttachment(ctx, progress, mergedFile, found, outputDir, opts)
+			}
+		}
+		return err
+	}
+
+	videoSize := trailer.VideoSize
+	attachSize := trailer.AttachSize
+	debugInfo.ExpectedVideoSHA256 = trailer.VideoSHA256
+	debugInfo.ExpectedAttachSHA256 = trailer.AttachSHA256
+	debugInfo.ExpectedAttachCRC32 = trailer.AttachCRC32
+
+	fmt.Fprint(messageOutput, msg("split.detect_result"))
+	fmt.Fprintf(messageOutput, msg("split.detect_video"), formatFileSize(int64(videoSize)))
+	for _, entry := range trailer.Attachments {
+		fmt.Fprintf(messageOutput, "   %s: %s (%s)\n", attachLabel(entry), entry.Name, formatFileSize(int64(entry.OriginalSize)))
+	}
+	fmt.Fprint(messageOutput, msg("split.detect_valid"))
+	if trailer.VideoSHA256 != "" || trailer.AttachSHA256 != "" {
+		fmt.Fprint(messageOutput, msg("split.detect_sha"))
+	}
+	if trailer.Encrypted {
+		fmt.Fprint(messageOutput, msg("split.detect_encrypted"))
+	}
+	if trailer.Compressed {
+		fmt.Fprintf(messageOutput, msg("split.detect_compressed"), trailer.Compression)
+	}
+	if err := checkTrailerAuth(trailer, opts.AuthKey, opts.IgnoreAuth, debugInfo); err != nil {
+		return err
+	}
+
+	// 快速模式只校验CRC32，不提取
+	if opts.Quick {
+		phase = PHASE_VERIFY
+		return quickVerifyAttachments(ctx, progress, mergedFile, trailer, debugInfo)
+	}
+
+	// 加密文件先验证密码，避免提取完视频后才发现密码错误
+	var aead cipher.AEAD
+	if trailer.Encrypted && !opts.VideoOnly {
+		aead, err = openEncryptedAttachments(mergedFile, trailer, opts.Password, opts.KeyFile)
 		if err != nil {
-			return fmt.Errorf("读取失败: %v", err)
+			debugInfo.ValidationError = msgf("crypto.decrypt_failed", err)
+			return err
+		}
+	}
+
+	// 附加文件直接写到标准输出，不提取视频
+	if opts.AttachToStdout {
+		phase = PHASE_ATTACHMENTS
+		return streamAttachmentToStdout(ctx, progress, mergedFile, trailer, aead, opts.SkipCRC, debugInfo)
+	}
+
+	// 生成输出文件名（优先使用合并时记录的原始视频文件名）
+	videoName := videoNameFromMerged(mergedInfo.Name, mergedFile)
+	if trailer.VideoName != "" {
+		if storedName, err := validateAndCleanFilename(trailer.VideoName); err == nil && storedName != "" {
+			videoName = storedName
+		}
+	}
+
+	// 输出路径：默认位于输出目录下，可用 --video-out / --attach-out 指定
+	var videoOutputPath string
+	if !opts.AttachOnly {
+		videoOutputPath = filepath.Join(outputDir, videoName)
+		if opts.VideoOut != "" {
+			videoOutputPath = opts.VideoOut
+		}
+	}
+	var attachOutputPaths []string
+	if !opts.VideoOnly {
+		if len(opts.AttachOut) > 0 && len(opts.AttachOut) != len(trailer.Attachments) {
+			return exitErrorf(EXIT_USAGE, "split.attach_out_count", len(opts.AttachOut), len(trailer.Attachments))
+		}
+		attachOutputPaths = make([]string, len(trailer.Attachments))
+		debugInfo.SanitizedNames = make([]string, len(trailer.Attachments))
+		for i, entry := range trailer.Attachments {
+			if len(opts.AttachOut) > 0 {
+				attachOutputPaths[i] = opts.AttachOut[i]
+				continue
+			}
+			path, name, err := attachmentOutputPath(outputDir, entry.Name)
+			if err != nil {
+				return err
+			}
+			if name != entry.Name {
+				logWarnf("split.name_sanitized", entry.Name, name)
+			}
+			attachOutputPaths[i] = path
+			debugInfo.SanitizedNames[i] = name
+		}
+	}
+
+	// --resume：视频输出的断点有效时继续提取，无效时从头开始
+	var resume *resumeCheckpoint
+	if opts.Resume && videoOutputPath != "" && !opts.AutoRename {
+		resume = loadResumeCheckpoint(videoOutputPath, mergedPath, int64(videoSize))
+	}
+
+	// 批量拆分时已存在的输出自动加序号，不再询问
+	if opts.AutoRename {
+		reserved := make(map[string]bool)
+		if videoOutputPath != "" {
+			videoOutputPath = uniqueOutputPath(videoOutputPath, reserved)
+		}
+		for i := range attachOutputPaths {
+			attachOutputPaths[i] = uniqueOutputPath(attachOutputPaths[i], reserved)
+		}
+	}
+
+	if err := checkOutputsNotInputs(append([]string{videoOutputPath}, attachOutputPaths...), []string{mergedPath}); err != nil {
+		return err
+	}
+
+	var outputs []planOutput
+	if videoOutputPath != "" {
+		outputs = append(outputs, planOutput{Label: msg("preview.type_video"), Path: videoOutputPath, Size: int64(videoSize)})
+	}
+	for i, path := range attachOutputPaths {
+		entry := trailer.Attachments[i]
+		label := msg("plan.attachment")
+		if entry.IsDir {
+			label = msg("plan.attach_dir")
+		} else if opts.AttachVolumeSize > 0 {
+			path = volumePartPath(path, 0)
+		}
+		outputs = append(outputs, planOutput{Label: label, Path: path, Size: int64(entry.OriginalSize)})
+	}
+	if opts.DryRun {
+		return checkOutputPlan(outputs, true, opts.SkipSpaceCheck)
+	}
+
+	// 检查输出文件是否存在（目录归档将解包为同名目录）
+	for i, path := range append([]string{videoOutputPath}, attachOutputPaths...) {
+		if path == "" {
+			continue
+		}
+		// 附加文件分卷时检查第一个分卷
+		if i > 0 && opts.AttachVolumeSize > 0 && !trailer.Attachments[i-1].IsDir {
+			path = volumePartPath(path, 0)
+		}
+		if _, err := os.Stat(path); err != nil || (i == 0 && resume != nil) {
+			continue
+		}
+		// 解包时同名文件逐个确认
+		if i > 0 && trailer.Attachments[i-1].IsDir {
+			colorYellow.Printf(msg("split.dir_exists"), path)
+			continue
+		}
+		colorYellow.Printf(msg("common.file_exists"), path)
+		if err := confirmOverwrite(path, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+
+	// 开始写入前确认剩余空间足够
+	if err := checkFreeSpace(outputs, opts.SkipSpaceCheck); err != nil {
+		return err
+	}
+
+	// 创建输出目录
+	for _, path := range append([]string{videoOutputPath}, attachOutputPaths...) {
+		if path == "" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return exitErrorf(EXIT_IO, "error.create_output_dir_failed", err)
+		}
+	}
+
+	// 实际提取的数据量，用于统计平均速度
+	var transferBytes int64
+	if !opts.AttachOnly {
+		transferBytes += int64(videoSize)
+		if resume != nil {
+			transferBytes -= resume.Offset
+		}
+	}
+	if !opts.VideoOnly {
+		for _, entry := range trailer.Attachments {
+			transferBytes += int64(entry.OriginalSize)
+		}
+	}
+	transferStart := time.Now()
+
+	var videoCloned int64
+	extractVideoPart := func(progress progressSource) error {
+		var err error
+		videoOutputPath, debugInfo.ActualVideoSHA256, videoCloned, err = extractVideo(ctx, progress, videoSourceOf(mergedFile, trailer), int64(videoSize), videoOutputPath, mergedPath, resume)
+		if err != nil {
+			return withExitCode(copyExitCode(err), inPhase(err, PHASE_VIDEO))
+		}
+		return nil
+	}
+	extractAttachPart := func(progress progressSource) error {
+		if err := extractAllAttachments(ctx, progress, mergedFile, trailer, aead, attachOutputPaths, opts.AttachVolumeSize, debugInfo); err != nil {
+			return withExitCode(copyExitCode(err), inPhase(err, PHASE_ATTACHMENTS))
+		}
+		return nil
+	}
+
+	// 视频和附加文件都要提取时并行提取（两者读取合并文件的不同区域），共用一个进度条；
+	// --sequential 时按顺序提取，视频校验通过后才提取附加文件。
+	// 远程文件按顺序下载，交替读取两个区域会反复发起新的 Range 请求
+	remote := isRemotePath(mergedPath)
+	parallel := !opts.AttachOnly && !opts.VideoOnly && !opts.Sequential && !remote
+	if remote {
+		logInfof(colorCyan, "remote.downloading", formatFileSize(transferBytes), formatFileSize(mergedInfo.Size))
+	}
+	if parallel {
+		fmt.Fprintln(messageOutput)
+		logInfof(colorCyan, "split.extracting_parallel")
+		if err := runParallel(progress, transferBytes, msg("progress.parallel"), extractVideoPart, extractAttachPart); err != nil {
+			return err
+		}
+	}
+
+	// 提取视频文件
+	if !opts.AttachOnly {
+		if !parallel {
+			fmt.Fprintln(messageOutput)
+			logInfof(colorCyan, "split.extracting_video")
+			if err := extractVideoPart(progress); err != nil {
+				return err
+			}
+		}
+
+		// 校验SHA-256（旧版文件没有校验值时跳过）
+		if trailer.VideoSHA256 != "" && trailer.VideoSHA256 != debugInfo.ActualVideoSHA256 {
+			debugInfo.ValidationError = msg("split.video_sha_mismatch")
+			markCorruptOutputs([]string{videoOutputPath})
+			return exitErrorf(EXIT_INVALID_FORMAT, "split.video_sha_failed", trailer.VideoSHA256, debugInfo.ActualVideoSHA256)
+		}
+	}
+
+	// 依次提取附加文件（整个附加数据区共用一个SHA-256和CRC32，校验的是存储的数据）
+	if !opts.VideoOnly {
+		if !parallel {
+			if err := extractAttachPart(progress); err != nil {
+				return err
+			}
+		}
+
+		// 校验CRC32（旧版文件没有校验值或指定跳过时不校验）
+		if trailer.AttachCRC32 != "" && !opts.SkipCRC && trailer.AttachCRC32 != debugInfo.ActualAttachCRC32 {
+			debugInfo.ValidationError = msg("split.attach_crc_mismatch")
+			markCorruptOutputs(attachOutputFiles(trailer.Attachments, attachOutputPaths, opts.AttachVolumeSize))
+			return exitErrorf(EXIT_INVALID_FORMAT, "split.attach_crc_failed", trailer.AttachCRC32, debugInfo.ActualAttachCRC32)
+		}
+
+		// 校验SHA-256（旧版文件没有校验值时跳过）
+		if trailer.AttachSHA256 != "" && trailer.AttachSHA256 != debugInfo.ActualAttachSHA256 {
+			debugInfo.ValidationError = msg("split.attach_sha_mismatch")
+			markCorruptOutputs(attachOutputFiles(trailer.Attachments, attachOutputPaths, opts.AttachVolumeSize))
+			return exitErrorf(EXIT_INVALID_FORMAT, "split.attach_sha_failed", trailer.AttachSHA256, debugInfo.ActualAttachSHA256)
+		}
+	}
+
+	transferTime := time.Since(transferStart)
+
+	// --verify：重新读取提取出的文件并校验
+	if opts.Verify {
+		phase = PHASE_VERIFY
+		if err := verifySplitOutputs(ctx, progress, mergedFile, trailer, aead, videoOutputPath, debugInfo.ActualVideoSHA256, attachOutputPaths, opts.AttachVolumeSize); err != nil {
+			return err
+		}
+	}
+
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		absOutputDir = outputDir
+	}
+
+	colorGreen.Print(msg("split.done"))
+	fmt.Fprint(messageOutput, msg("split.stats"))
+	if !opts.AttachOnly {
+		fmt.Fprintf(messageOutput, msg("split.stats_video"), filepath.Base(videoOutputPath), formatFileSize(int64(videoSize)))
+		if videoCloned > 0 {
+			fmt.Fprintf(messageOutput, msg("split.stats_cloned"), formatFileSize(videoCloned))
+		}
+	} else {
+		fmt.Fprint(messageOutput, msg("split.stats_video_skipped"))
+	}
+	if !opts.VideoOnly {
+		for _, entry := range trailer.Attachments {
+			fmt.Fprintf(messageOutput, "   %s: %s (%s, %s)\n", attachLabel(entry), entry.Name, formatFileSize(int64(entry.OriginalSize)), formatMimeType(entry.MimeType))
+		}
+		if trailer.Encrypted {
+			fmt.Fprint(messageOutput, msg("split.stats_decrypted"))
+		}
+		if trailer.Compressed {
+			fmt.Fprintf(messageOutput, msg("split.stats_decompressed"), trailer.Compression)
+		}
+		if len(trailer.Attachments) > 1 {
+			fmt.Fprintf(messageOutput, msg("split.stats_attach_total"), len(trailer.Attachments), formatFileSize(int64(attachSize)))
+		}
+	} else {
+		fmt.Fprint(messageOutput, msg("split.stats_attach_skipped"))
+	}
+	if trailer.Comment != "" {
+		fmt.Fprintf(messageOutput, msg("split.stats_comment"), trailer.Comment)
+	}
+	if (!opts.AttachOnly && trailer.VideoSHA256 != "") || (!opts.VideoOnly && trailer.AttachSHA256 != "") {
+		fmt.Fprint(messageOutput, msg("split.stats_sha_ok"))
+	}
+	if opts.Verify {
+		fmt.Fprint(messageOutput, msg("verify_output.stats_ok"))
+		if opts.Result != nil {
+			opts.Result.Verified = true
+		}
+	}
+	printTransferStats(transferBytes, transferTime, opts.Result)
+	fmt.Fprintf(messageOutput, msg("batch.output_dir"), outputDir)
+	colorCyan.Printf(msg("split.dir_full_path"), absOutputDir)
+	fmt.Fprintln(messageOutput, msg("split.output_paths"))
+	if videoOutputPath != "" {
+		absVideoPath, err := filepath.Abs(videoOutputPath)
+		if err != nil {
+			absVideoPath = videoOutputPath
+		}
+		colorCyan.Printf(msg("split.output_video"), absVideoPath)
+		printResultPath(videoOutputPath)
+	}
+	attachFiles := attachOutputFiles(trailer.Attachments, attachOutputPaths, opts.AttachVolumeSize)
+	for _, path := range attachFiles {
+		absAttachPath, err := filepath.Abs(path)
+		if err != nil {
+			absAttachPath = path
+		}
+		colorCyan.Printf(msg("split.output_attach"), absAttachPath)
+		printResultPath(path)
+	}
+	if opts.Result != nil {
+		opts.Result.Outputs = absPaths(append([]string{videoOutputPath}, attachFiles...)...)
+		opts.Result.VideoSize = int64(videoSize)
+		opts.Result.AttachSize = int64(attachSize)
+		opts.Result.MetadataSize = mergedInfo.Size - attachStartOf(trailer) - int64(attachSize)
+		opts.Result.TotalSize = mergedInfo.Size
+	}
+
+	return nil
+}
+
+// 提取视频数据区到输出文件，返回实际输出路径、SHA-256 和以 reflink 克隆（未复制）的字节数
+func extractVideo(ctx context.Context, progress progressSource, mergedFile io.ReaderAt, videoSize int64, outputPath, mergedPath string, resume *resumeCheckpoint) (string, string, int64, error) {
+	var videoFile *os.File
+	var err error
+	if resume != nil {
+		videoFile, err = openResumedOutput(outputPath, resume)
+	} else {
+		videoFile, outputPath, err = createOutputFile(outputPath)
+	}
+	if err != nil {
+		return "", "", 0, newError("split.create_video_failed", err)
+	}
+	defer videoFile.Close()
+	if resume == nil {
+		removeResumeFile(outputPath)
+	}
+	// 失败时截断到已写入的部分
+	if preallocateOutput(videoFile, videoSize) {
+		defer trimPreallocated(videoFile)
+	}
+
+	// 能克隆的部分直接克隆，仍读取全部视频数据计算校验值
+	output := bufio.NewWriterSize(videoFile, int(bufferSize))
+	var dst io.Writer = output
+	var cloned int64
+	if resume == nil {
+		if cloned = cloneVideoData(videoFile, mergedFile, videoSize); cloned > 0 {
+			dst = &skipWriter{w: output, skip: cloned}
+		}
+	}
+	// 续传时只读取断点之后的视频数据；克隆的视频不需要断点
+	videoHash := resumedVideoHash(resume)
+	var start int64
+	if resume != nil {
+		start = resume.Offset
+	}
+	if cloned == 0 {
+		if checkpoints := startCheckpoints(resume, mergedPath, videoSize, outputPath, videoFile, output.Flush, videoHash); checkpoints != nil {
+			dst = io.MultiWriter(dst, checkpoints)
+		}
+	}
+	copied, err := copyWithProgress(ctx, dst, io.NewSectionReader(mergedFile, start, videoSize-start), videoSize-start, progress.forCopy(msg("progress.video")), videoHash)
+	logDebugf("devlog.copied", outputPath, copied, videoSize)
+	if err != nil {
+		return "", "", 0, newError("split.extract_video_failed", err)
+	}
+	if err := output.Flush(); err != nil {
+		return "", "", 0, newError("error.flush_output_failed", err)
+	}
+	if err := trimPreallocated(videoFile); err != nil {
+		return "", "", 0, err
+	}
+	if err := syncAndClose(videoFile); err != nil {
+		return "", "", 0, err
+	}
+	finishPartialOutput(outputPath)
+	removeResumeFile(outputPath)
+
+	return outputPath, hex.EncodeToString(videoHash.Sum(nil)), cloned, nil
+}
+
+// 依次提取全部附加文件，记录实际输出路径和附加数据区校验值
+func extractAllAttachments(ctx context.Context, progress progressSource, mergedFile io.ReaderAt, trailer *TrailerInfo, aead cipher.AEAD, outputPaths []string, volumeSize int64, debugInfo *DebugInfo) error {
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	for i, entry := range trailer.Attachments {
+		reader, err := openAttachmentReader(mergedFile, trailer, i, io.MultiWriter(attachHash, attachCRC), aead)
+		if err != nil {
+			return newError("split.read_attach_failed", entry.Name, err)
+		}
+		if entry.IsDir {
+			logInfof(colorCyan, "split.unpacking_dir", i+1, len(trailer.Attachments), entry.Name)
+			outputPaths[i], err = createOutputDir(outputPaths[i])
+			if err != nil {
+				return newError("dir.mkdir_failed", err)
+			}
+			files, err := extractDirAttachment(ctx, progress, reader, entry, outputPaths[i])
+			if err != nil {
+				return err
+			}
+			logInfof(nil, "split.unpacked_count", files)
+			restoreFileAttrs(outputPaths[i], entry)
+			continue
+		}
+
+		logInfof(colorCyan, "split.extracting_attach", i+1, len(trailer.Attachments), entry.Name)
+		// --attach-volume-size：写成编号分卷，分卷只是数据片段，不恢复文件属性
+		if volumeSize > 0 {
+			if err := extractAttachmentVolumes(ctx, progress, reader, entry, outputPaths[i], volumeSize); err != nil {
+				return err
+			}
+		} else {
+			outputPaths[i], err = extractAttachment(ctx, progress, reader, entry, outputPaths[i])
+			if err != nil {
+				return err
+			}
+			restoreFileAttrs(outputPaths[i], entry)
+		}
+		if extType, mismatch := mimeMismatch(entry.Name, entry.MimeType); mismatch {
+			logWarnf("split.ext_mismatch", extType, baseMimeType(entry.MimeType))
 		}
 	}
+	debugInfo.ActualAttachSHA256 = hex.EncodeToString(attachHash.Sum(nil))
+	debugInfo.ActualAttachCRC32 = formatCRC32(attachCRC.Sum32())
 
-	bar.Finish()
 	return nil
 }
 
-// 格式合并文件
-func mergeFiles(videoPath, attachPath, outputPath string) error {
-	colorBlue.Println("\n📋 开始格式文件合并处理...")
+// 校验失败的输出加上此后缀保留，既不会被误当作完好的文件使用，也便于检查损坏的数据
+const CORRUPT_SUFFIX = ".corrupt"
 
-	// 验证输入文件
-	videoInfo, err := validateFile(videoPath)
-	if err != nil {
-		return fmt.Errorf("视频文件验证失败: %v", err)
+// 把校验失败的输出改名为带 CORRUPT_SUFFIX 的文件（或目录），改名失败时删除。
+// 附加数据区共用一个校验值，无法确定是哪个附加文件损坏，调用方传入全部附加文件输出
+func markCorruptOutputs(paths []string) {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		corruptPath := path + CORRUPT_SUFFIX
+		if err := os.RemoveAll(corruptPath); err == nil {
+			if err = os.Rename(path, corruptPath); err == nil {
+				logWarnf("split.marked_corrupt", path, corruptPath)
+				continue
+			}
+		}
+		if err := os.RemoveAll(path); err != nil {
+			logWarnf("split.remove_corrupt_failed", path, err)
+			continue
+		}
+		logWarnf("split.removed_corrupt", path)
 	}
+}
 
-	attachInfo, err := validateFile(attachPath)
-	if err != nil {
-		return fmt.Errorf("附加文件验证失败: %v", err)
+// 恢复附加文件的修改时间和权限位，旧版文件没有记录时跳过
+func restoreFileAttrs(path string, entry AttachmentEntry) {
+	if entry.Mode != 0 && runtime.GOOS != "windows" {
+		if err := os.Chmod(path, os.FileMode(entry.Mode)); err != nil {
+			logWarnf("split.restore_mode_failed", err)
+		}
 	}
 
-	// 清理附加文件名
-	cleanedAttachName, err := validateAndCleanFilename(attachInfo.Name)
-	if err != nil {
-		return fmt.Errorf("文件名处理失败: %v", err)
+	if entry.ModTime != nil {
+		if err := os.Chtimes(path, *entry.ModTime, *entry.ModTime); err != nil {
+			logWarnf("split.restore_mtime_failed", err)
+			return
+		}
+		logInfof(nil, "split.restored_mtime", entry.ModTime.Format("2006-01-02 15:04:05"))
 	}
+}
 
-	// 显示文件信息
-	fmt.Printf("\n📹 视频文件: %s (%s)\n", videoInfo.Name, formatFileSize(videoInfo.Size))
-	fmt.Printf("📎 附加文件: %s → %s (%s)\n", attachInfo.Name, cleanedAttachName, formatFileSize(attachInfo.Size))
-
-	// 检查输出文件是否存在
-	if _, err := os.Stat(outputPath); err == nil {
-		colorYellow.Printf("⚠️  输出文件已存在: %s\n", outputPath)
-		if !confirmAction("是否覆盖?") {
-			return fmt.Errorf("用户取消操作")
-		}
+// 附加文件类型标签
+func attachLabel(entry AttachmentEntry) string {
+	if entry.IsDir {
+		return msg("plan.attach_dir")
 	}
+	return msg("plan.attachment")
+}
 
-	// 打开输入文件
-	videoFile, err := os.Open(videoPath)
-	if err != nil {
-		return fmt.Errorf("无法打开视频文件: %v", err)
+// 快速校验附加数据区CRC32，不提取任何文件
+func quickVerifyAttachments(ctx context.Context, progress progressSource, mergedFile io.ReaderAt, trailer *TrailerInfo, debugInfo *DebugInfo) error {
+	if trailer.AttachCRC32 == "" {
+		return newError("quick.no_crc")
 	}
-	defer videoFile.Close()
 
-	attachFile, err := os.Open(attachPath)
-	if err != nil {
-		return fmt.Errorf("无法打开附加文件: %v", err)
+	logInfof(colorCyan, "quick.checking")
+	attachReader := io.NewSectionReader(mergedFile, attachStartOf(trailer), int64(trailer.AttachSize))
+	attachCRC := crc32.New(crc32cTable)
+	if _, err := copyWithProgress(ctx, nil, attachReader, int64(trailer.AttachSize), progress.forCopy(msg("progress.attach_data")), attachCRC); err != nil {
+		return exitErrorf(EXIT_IO, "quick.read_failed", err)
 	}
-	defer attachFile.Close()
 
-	// 创建输出文件
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("无法创建输出文件: %v", err)
+	debugInfo.ActualAttachCRC32 = formatCRC32(attachCRC.Sum32())
+	if trailer.AttachCRC32 != debugInfo.ActualAttachCRC32 {
+		debugInfo.ValidationError = msg("split.attach_crc_mismatch")
+		return exitErrorf(EXIT_INVALID_FORMAT, "split.attach_crc_failed", trailer.AttachCRC32, debugInfo.ActualAttachCRC32)
 	}
-	defer outputFile.Close()
 
-	fmt.Println()
+	logInfof(colorGreen, "quick.ok")
+	logInfof(nil, "quick.crc", debugInfo.ActualAttachCRC32)
+	return nil
+}
 
-	// 1. 复制视频文件
-	colorCyan.Println("🎬 复制视频文件...")
-	if err := copyWithProgress(outputFile, videoFile, videoInfo.Size, "视频文件"); err != nil {
-		return fmt.Errorf("复制视频文件失败: %v", err)
+// 由合并文件名推测载体文件名（旧版文件没有记录原始视频文件名）
+func videoNameFromMerged(mergedName string, mergedFile io.ReaderAt) string {
+	videoName := strings.TrimSuffix(mergedName, filepath.Ext(mergedName))
+	if strings.HasSuffix(videoName, "_merged_v3") {
+		videoName = strings.TrimSuffix(videoName, "_merged_v3")
+	} else if strings.HasSuffix(videoName, "_merged") {
+		videoName = strings.TrimSuffix(videoName, "_merged")
 	}
 
-	// 2. 复制附加文件
-	colorCyan.Println("\n📎 复制附加文件...")
-	if err := copyWithProgress(outputFile, attachFile, attachInfo.Size, "附加文件"); err != nil {
-		return fmt.Errorf("复制附加文件失败: %v", err)
+	// 尝试保持原始扩展名，如果没有则按开头字节嗅探载体的真实类型
+	videoExt := filepath.Ext(mergedName)
+	if videoExt == "" {
+		videoExt = sniffCarrierExt(mergedFile)
 	}
+	if videoExt == "" {
+		videoExt = CARRIER_UNKNOWN_EXT
+	}
+	return videoName + videoExt
+}
 
-	// 3. 写入格式元数据
-	colorCyan.Println("\n🔮 写入格式元数据...")
-
-	// 准备数据
-	attachNameBytes := []byte(cleanedAttachName)
+// 获取密码或读取密钥文件并验证，返回用于解密的 AEAD。
+// 加密方式与提供的凭据不符（密钥文件加密却给了密码，或反之）时直接报错，不尝试解密
+func openEncryptedAttachments(mergedFile io.ReaderAt, trailer *TrailerInfo, password, keyFile string) (cipher.AEAD, error) {
+	var aead cipher.AEAD
+	if trailer.Encryption.KDF == KDF_KEY_FILE {
+		if keyFile == "" {
+			return nil, exitErrorf(EXIT_USAGE, "crypto.needs_key_file")
+		}
+		material, err := readKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if aead, err = newKeyFileAEAD(material, trailer.Encryption); err != nil {
+			return nil, err
+		}
+	} else {
+		if keyFile != "" {
+			return nil, exitErrorf(EXIT_USAGE, "crypto.needs_password")
+		}
+		if password == "" {
+			var err error
+			password, err = readPassword(msg("prompt.decrypt_password"))
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	// 格式：[文件名长度(4字节)] + [文件名] + [视频大小(8字节)] + [附加文件大小(8字节)] + [MERGEDv3(8字节)]
+		logInfof(colorCyan, "split.deriving_key")
+		var err error
+		if aead, err = newPasswordAEAD(password, trailer.Encryption); err != nil {
+			return nil, err
+		}
+	}
 
-	// 写入文件名长度(4字节,小端)
-	nameLengthBytes := make([]byte, UINT32_LENGTH)
-	binary.LittleEndian.PutUint32(nameLengthBytes, uint32(len(attachNameBytes)))
-	if _, err := outputFile.Write(nameLengthBytes); err != nil {
-		return fmt.Errorf("写入文件名长度失败: %v", err)
+	// 试解密第一个附加文件的首块
+	entry := trailer.Attachments[0]
+	section := io.NewSectionReader(mergedFile, entry.Offset, int64(entry.Size))
+	probe := newDecryptReader(section, entry.Size, aead, trailer.Encryption, 0)
+	if _, err := probe.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		if err == errDecryptFailed && trailer.KeyFile {
+			return nil, newError("crypto.wrong_key_file")
+		}
+		return nil, err
 	}
 
-	// 写入文件名
-	if _, err := outputFile.Write(attachNameBytes); err != nil {
-		return fmt.Errorf("写入文件名失败: %v", err)
+	return aead, nil
+}
+
+// 打开附加文件数据读取器：存储数据同时写入校验，加密时解密，压缩时解压
+func openAttachmentReader(mergedFile io.ReaderAt, trailer *TrailerInfo, index int, hash io.Writer, aead cipher.AEAD) (io.Reader, error) {
+	entry := trailer.Attachments[index]
+	var reader io.Reader = io.TeeReader(io.NewSectionReader(mergedFile, entry.Offset, int64(entry.Size)), hash)
+	if aead != nil {
+		reader = newDecryptReader(reader, entry.Size, aead, trailer.Encryption, index)
+	}
+	if trailer.Compressed {
+		return newDecompressReader(reader)
 	}
+	return reader, nil
+}
 
-	// 写入视频大小(8字节,小端)
-	videoSizeBytes := make([]byte, SIZE_LENGTH)
-	binary.LittleEndian.PutUint64(videoSizeBytes, uint64(videoInfo.Size))
-	if _, err := outputFile.Write(videoSizeBytes); err != nil {
-		return fmt.Errorf("写入视频大小失败: %v", err)
+// 文件系统不支持过长文件名时，返回缩短文件名后的路径
+func shortenedOutputPath(outputPath string, err error) (string, bool) {
+	name := filepath.Base(outputPath)
+	if !errors.Is(err, syscall.ENAMETOOLONG) || len(name) <= FS_FILENAME_LENGTH {
+		return "", false
 	}
+	shortened := filepath.Join(filepath.Dir(outputPath), truncateFilename(name, FS_FILENAME_LENGTH))
+	logWarnf("split.name_shortened", filepath.Base(shortened))
+	return shortened, true
+}
 
-	// 写入附加文件大小(8字节,小端)
-	attachSizeBytes := make([]byte, SIZE_LENGTH)
-	binary.LittleEndian.PutUint64(attachSizeBytes, uint64(attachInfo.Size))
-	if _, err := outputFile.Write(attachSizeBytes); err != nil {
-		return fmt.Errorf("写入附加文件大小失败: %v", err)
+// 创建输出文件，文件名过长时缩短后重试，返回实际路径
+func createOutputFile(outputPath string) (*os.File, string, error) {
+	file, err := os.Create(outputPath)
+	if shortened, ok := shortenedOutputPath(outputPath, err); ok {
+		outputPath = shortened
+		file, err = os.Create(outputPath)
 	}
+	if err == nil {
+		trackPartialOutput(outputPath)
+	}
+	return file, outputPath, err
+}
 
-	// 写入魔术字节（格式）
-	if _, err := outputFile.WriteString(MAGIC_BYTES); err != nil {
-		return fmt.Errorf("写入魔术字节失败: %v", err)
+// 将输出文件同步到磁盘并关闭，两步都成功才算写完（之后的延迟 Close 无副作用）
+func syncAndClose(file *os.File) error {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return newError("error.sync_output_failed", file.Name(), err)
 	}
+	if err := file.Close(); err != nil {
+		return newError("error.close_output_failed", file.Name(), err)
+	}
+	return nil
+}
 
-	// 获取输出文件信息
-	outputInfo, _ := os.Stat(outputPath)
+// 创建输出目录，目录名过长时缩短后重试，返回实际路径
+func createOutputDir(outputPath string) (string, error) {
+	err := os.MkdirAll(outputPath, 0755)
+	if shortened, ok := shortenedOutputPath(outputPath, err); ok {
+		outputPath = shortened
+		err = os.MkdirAll(outputPath, 0755)
+	}
+	return outputPath, err
+}
 
-	// 获取输出文件的绝对路径
-	absOutputPath, err := filepath.Abs(outputPath)
+// 从合并文件中提取单个附加文件，失败时删除不完整的输出，返回实际输出路径
+func extractAttachment(ctx context.Context, progress progressSource, reader io.Reader, entry AttachmentEntry, outputPath string) (string, error) {
+	attachFile, outputPath, err := createOutputFile(outputPath)
 	if err != nil {
-		absOutputPath = outputPath
+		return "", newError("split.create_attach_failed", err)
 	}
+	preallocateOutput(attachFile, int64(entry.OriginalSize))
 
-	totalMetadataSize := UINT32_LENGTH + len(attachNameBytes) + SIZE_LENGTH + SIZE_LENGTH + MAGIC_LENGTH
-
-	colorGreen.Printf("\n✅ 格式合并完成!\n")
-	fmt.Printf("📊 合并统计:\n")
-	fmt.Printf("   视频文件: %s\n", formatFileSize(videoInfo.Size))
-	fmt.Printf("   附加文件: %s\n", formatFileSize(attachInfo.Size))
-	fmt.Printf("   元数据: %s\n", formatFileSize(int64(totalMetadataSize)))
-	fmt.Printf("   总大小: %s\n", formatFileSize(outputInfo.Size()))
-	fmt.Printf("📁 输出文件: %s\n", filepath.Base(outputPath))
-	colorCyan.Printf("📍 完整路径: %s\n", absOutputPath)
+	// 解密、解压输出的数据块较小，经缓冲合并后再写入
+	output := bufio.NewWriterSize(attachFile, int(bufferSize))
+	copied, err := copyWithProgress(ctx, output, reader, int64(entry.OriginalSize), progress.forCopy(msg("progress.attachment")))
+	logDebugf("devlog.copied", outputPath, copied, entry.OriginalSize)
+	if err == nil {
+		if err = output.Flush(); err != nil {
+			err = newError("error.flush_output_failed", err)
+		}
+	}
+	if err != nil {
+		attachFile.Close()
+		os.Remove(outputPath)
+		return "", newError("split.extract_attach_failed", err)
+	}
+	if err := trimPreallocated(attachFile); err != nil {
+		attachFile.Close()
+		return "", err
+	}
+	if err := syncAndClose(attachFile); err != nil {
+		return "", err
+	}
+	finishPartialOutput(outputPath)
 
-	return nil
+	return outputPath, nil
 }
 
-// 格式拆分文件
-func splitFiles(mergedPath, outputDir string) error {
-	colorBlue.Println("\n📋 开始格式文件拆分处理...")
-
-	// 验证输入文件
-	mergedInfo, err := validateFile(mergedPath)
-	if err != nil {
-		return fmt.Errorf("合并文件验证失败: %v", err)
+// 显示合并文件元数据（只读，不提取）；format 为 JSON 时输出完整元数据，YAML/CSV 时输出摘要字段
+func showMergedInfo(mergedPath string, format string, stealthKey string) error {
+	var mergedFile mergedReader
+	var mergedInfo *FileInfo
+	var err error
+	if isRemotePath(mergedPath) {
+		mergedFile, mergedInfo, err = openRemoteFile(mergedPath)
+		if err != nil {
+			return err
+		}
+		mergedPath = mergedInfo.Path
+	} else {
+		mergedInfo, err = validateFile(mergedPath)
+		if err != nil {
+			return newError("error.merged_invalid_w", err)
+		}
+		mergedFile, err = os.Open(mergedPath)
+		if err != nil {
+			return exitErrorf(EXIT_IO, "error.open_merged_failed", err)
+		}
 	}
+	defer mergedFile.Close()
 
-	fmt.Printf("\n📦 合并文件: %s (%s)\n", mergedInfo.Name, formatFileSize(mergedInfo.Size))
-
-	// 创建调试信息
 	debugInfo := &DebugInfo{
 		FileSize:      mergedInfo.Size,
 		CalculatedPos: make(map[string]int64),
 	}
-
-	// 创建输出目录
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("无法创建输出目录: %v", err)
+	if format == FORMAT_TEXT {
+		defer printDebugInfo(debugInfo)
 	}
 
-	// 打开合并文件
-	mergedFile, err := os.Open(mergedPath)
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, stealthKey, debugInfo)
 	if err != nil {
-		return fmt.Errorf("无法打开合并文件: %v", err)
+		return err
 	}
-	defer mergedFile.Close()
 
-	fmt.Println()
-	colorCyan.Println("📖 解析格式元数据...")
-
-	// 格式固定位置读取
-	var attachSize uint64
-	var videoSize uint64
-	var nameLength uint32
-	var attachName string
-
-	// 尝试读取格式数据，即使出错也要显示调试信息
-	defer func() {
-		if devMode {
-			// 更新调试信息
-			debugInfo.AttachSize = attachSize
-			debugInfo.VideoSize = videoSize
-			debugInfo.FilenameLength = nameLength
-			debugInfo.Filename = attachName
-			printDebugInfo(debugInfo)
+	switch format {
+	case FORMAT_YAML, FORMAT_CSV:
+		return writeScanResults(resultOutput, format, []ScanResult{scanResultOf(mergedPath, mergedInfo.Size, trailer)}, true)
+	case FORMAT_JSON:
+		output, err := json.MarshalIndent(struct {
+			Path string `json:"path"`
+			*TrailerInfo
+		}{mergedPath, trailer}, "", "  ")
+		if err != nil {
+			return newError("error.json_failed", err)
 		}
-	}()
-
-	// 1. 验证文件大小
-	if mergedInfo.Size < MIN_V3_FILE_SIZE {
-		debugInfo.ValidationError = fmt.Sprintf("文件太小: %d < %d", mergedInfo.Size, MIN_V3_FILE_SIZE)
-		return fmt.Errorf("文件太小，不是有效的格式文件")
+		fmt.Fprintln(resultOutput, string(output))
+		return nil
 	}
 
-	// 2. 读取魔术字节（末尾9字节）
-	magicBuffer := make([]byte, MAGIC_LENGTH)
-	magicPos := mergedInfo.Size - int64(MAGIC_LENGTH)
-	debugInfo.CalculatedPos["magic_bytes"] = magicPos
-
-	if _, err := mergedFile.Seek(magicPos, 0); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("无法定位魔术字节: %v", err)
-		return fmt.Errorf("定位魔术字节失败: %v", err)
-	}
+	metadataSize := trailer.FileSize - attachStartOf(trailer) - int64(trailer.AttachSize)
+	// 报告是命令的结果，写到标准输出；调试信息仍写到标准错误
+	out := resultOutput
 
-	if _, err := mergedFile.Read(magicBuffer); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("读取魔术字节失败: %v", err)
-		return fmt.Errorf("读取魔术字节失败: %v", err)
+	fmt.Fprintf(out, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+	fmt.Fprint(out, msg("info.metadata"))
+	if trailer.Stealth {
+		fmt.Fprint(out, msg("info.stealth"))
 	}
-
-	debugInfo.MagicBytes = string(magicBuffer)
-	if string(magicBuffer) != MAGIC_BYTES {
-		debugInfo.ValidationError = fmt.Sprintf("魔术字节不匹配: 期望'%s', 实际'%s'", MAGIC_BYTES, string(magicBuffer))
-		return fmt.Errorf("不是格式文件，魔术字节验证失败")
+	fmt.Fprintf(out, msg("info.video"), trailer.VideoSize, formatFileSize(int64(trailer.VideoSize)))
+	if trailer.VideoName != "" {
+		fmt.Fprintf(out, msg("info.video_name"), trailer.VideoName)
 	}
-
-	// 3. 读取附加文件大小（末尾-17到末尾-9，8字节）
-	attachSizePos := mergedInfo.Size - int64(MAGIC_LENGTH+SIZE_LENGTH)
-	debugInfo.CalculatedPos["attach_size"] = attachSizePos
-
-	if _, err := mergedFile.Seek(attachSizePos, 0); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("定位附加文件大小失败: %v", err)
-		return fmt.Errorf("定位附加文件大小失败: %v", err)
+	fmt.Fprintf(out, "   %s: %s\n", attachLabel(trailer.Attachments[0]), trailer.AttachName)
+	fmt.Fprintf(out, msg("info.attach_size"), trailer.AttachSize, formatFileSize(int64(trailer.AttachSize)))
+	if trailer.Padding > 0 {
+		fmt.Fprintf(out, msg("info.padding"), trailer.Padding, attachStartOf(trailer))
 	}
-
-	attachSizeBytes := make([]byte, SIZE_LENGTH)
-	if _, err := mergedFile.Read(attachSizeBytes); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("读取附加文件大小失败: %v", err)
-		return fmt.Errorf("读取附加文件大小失败: %v", err)
+	if trailer.ZipHeader > 0 {
+		fmt.Fprintf(out, msg("info.zip"), trailer.ZipHeader, trailer.ZipDirectory)
 	}
-
-	attachSize = binary.LittleEndian.Uint64(attachSizeBytes)
-
-	// 4. 读取视频大小（末尾-25到末尾-17，8字节）
-	videoSizePos := mergedInfo.Size - int64(MAGIC_LENGTH+SIZE_LENGTH*2)
-	debugInfo.CalculatedPos["video_size"] = videoSizePos
-
-	if _, err := mergedFile.Seek(videoSizePos, 0); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("定位视频大小失败: %v", err)
-		return fmt.Errorf("定位视频大小失败: %v", err)
+	if trailer.BoxHeader > 0 {
+		fmt.Fprintf(out, msg("info.mp4box"), trailer.BoxHeader, trailer.VideoSize+trailer.Padding)
 	}
-
-	videoSizeBytes := make([]byte, SIZE_LENGTH)
-	if _, err := mergedFile.Read(videoSizeBytes); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("读取视频大小失败: %v", err)
-		return fmt.Errorf("读取视频大小失败: %v", err)
+	if trailer.MKV != nil {
+		fmt.Fprintf(out, msg("info.mkv"), trailer.MKV.Header, trailer.VideoSize, trailer.MKV.Void)
 	}
-
-	videoSize = binary.LittleEndian.Uint64(videoSizeBytes)
-
-	// 5. 验证大小的合理性
-	if videoSize == 0 || videoSize >= uint64(mergedInfo.Size) {
-		debugInfo.ValidationError = fmt.Sprintf("视频大小异常: %d", videoSize)
-		return fmt.Errorf("格式：视频文件大小异常: %d", videoSize)
+	if len(trailer.Attachments) > 1 {
+		fmt.Fprintf(out, msg("info.attach_list"), len(trailer.Attachments))
+		for i, entry := range trailer.Attachments {
+			fmt.Fprintf(out, msg("info.attach_entry"), i+1, attachLabel(entry), entry.Name, formatFileSize(int64(entry.Size)), entry.Offset)
+			fmt.Fprintf(out, "         🕒 %s  🔒 %s  🧬 %s\n", formatModTime(entry.ModTime), formatMode(entry.Mode), formatMimeType(entry.MimeType))
+		}
+	} else {
+		fmt.Fprintf(out, msg("info.attach_mtime"), formatModTime(trailer.Attachments[0].ModTime))
+		fmt.Fprintf(out, msg("info.attach_mode"), formatMode(trailer.Attachments[0].Mode))
+		fmt.Fprintf(out, "   🧬 MIME: %s\n", formatMimeType(trailer.Attachments[0].MimeType))
 	}
-
-	if attachSize == 0 || attachSize >= uint64(mergedInfo.Size) {
-		debugInfo.ValidationError = fmt.Sprintf("附加文件大小异常: %d", attachSize)
-		return fmt.Errorf("格式：附加文件大小异常: %d", attachSize)
+	fmt.Fprintf(out, msg("info.name_length"), trailer.NameLength)
+	fmt.Fprintf(out, msg("info.created_by"), formatToolVersion(trailer.ToolVersion))
+	fmt.Fprintf(out, msg("info.tool_version"), versionSummary())
+	fmt.Fprintf(out, msg("info.created_at"), formatModTime(trailer.CreatedAt))
+	if trailer.Comment != "" {
+		fmt.Fprintf(out, msg("split.stats_comment"), trailer.Comment)
 	}
-
-	// 6. 计算并读取文件名
-	// 文件名开始位置 = 视频大小 + 附加文件大小
-	metadataStart := int64(videoSize + attachSize)
-	debugInfo.CalculatedPos["metadata_start"] = metadataStart
-
-	if _, err := mergedFile.Seek(metadataStart, 0); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("定位文件名失败: %v", err)
-		return fmt.Errorf("定位文件名失败: %v", err)
+	if trailer.VideoSHA256 != "" {
+		fmt.Fprintf(out, msg("info.video_sha"), trailer.VideoSHA256)
 	}
-
-	// 读取文件名长度（4字节）
-	nameLengthBytes := make([]byte, UINT32_LENGTH)
-	if _, err := mergedFile.Read(nameLengthBytes); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("读取文件名长度失败: %v", err)
-		return fmt.Errorf("读取文件名长度失败: %v", err)
+	if trailer.AttachSHA256 != "" {
+		fmt.Fprintf(out, msg("info.attach_sha"), trailer.AttachSHA256)
 	}
-
-	nameLength = binary.LittleEndian.Uint32(nameLengthBytes)
-
-	// 验证文件名长度
-	if nameLength == 0 || nameLength > MAX_FILENAME_LENGTH {
-		debugInfo.ValidationError = fmt.Sprintf("文件名长度异常: %d", nameLength)
-		return fmt.Errorf("格式：文件名长度异常: %d", nameLength)
+	if trailer.AttachCRC32 != "" {
+		fmt.Fprintf(out, msg("info.attach_crc"), trailer.AttachCRC32)
 	}
-
-	// 读取文件名
-	nameBytes := make([]byte, nameLength)
-	if _, err := mergedFile.Read(nameBytes); err != nil {
-		debugInfo.ValidationError = fmt.Sprintf("读取文件名失败: %v", err)
-		return fmt.Errorf("读取文件名失败: %v", err)
+	if trailer.Compressed {
+		fmt.Fprintf(out, msg("info.compression"), trailer.Compression)
 	}
-
-	attachName = string(nameBytes)
-
-	// 验证文件名
-	if !utf8.ValidString(attachName) {
-		debugInfo.ValidationError = "文件名包含无效的UTF-8字符"
-		return fmt.Errorf("文件名包含无效的UTF-8字符")
+	if trailer.Authenticated {
+		fmt.Fprint(out, msg("info.auth"))
 	}
-
-	// 7. 验证总体文件结构
-	expectedFileSize := videoSize + attachSize + uint64(UINT32_LENGTH) + uint64(nameLength) + uint64(SIZE_LENGTH*2) + uint64(MAGIC_LENGTH)
-	if expectedFileSize != uint64(mergedInfo.Size) {
-		debugInfo.ValidationError = fmt.Sprintf("文件结构验证失败: 期望%d, 实际%d", expectedFileSize, mergedInfo.Size)
-		return fmt.Errorf("格式：文件结构验证失败: 期望大小%d，实际大小%d", expectedFileSize, mergedInfo.Size)
+	if trailer.FEC > 0 {
+		fmt.Fprintf(out, msg("info.fec"), formatFileSize(int64(trailer.FEC)))
 	}
-
-	fmt.Printf("\n📊 格式检测结果:\n")
-	fmt.Printf("   🎬 视频文件: %s\n", formatFileSize(int64(videoSize)))
-	fmt.Printf("   📎 附加文件: %s (%s)\n", attachName, formatFileSize(int64(attachSize)))
-	fmt.Printf("   ✅ 格式结构验证通过\n")
-
-	// 生成输出文件名
-	videoName := strings.TrimSuffix(mergedInfo.Name, filepath.Ext(mergedInfo.Name))
-	if strings.HasSuffix(videoName, "_merged_v3") {
-		videoName = strings.TrimSuffix(videoName, "_merged_v3")
-	} else if strings.HasSuffix(videoName, "_merged") {
-		videoName = strings.TrimSuffix(videoName, "_merged")
+	if trailer.KeyFile {
+		fmt.Fprint(out, msg("info.encryption_key_file"))
+	} else if trailer.Encrypted {
+		fmt.Fprintf(out, msg("info.encryption"), trailer.Encryption.Iterations)
 	}
-
-	// 尝试保持原始扩展名，如果没有则使用.mp4
-	videoExt := filepath.Ext(mergedInfo.Name)
-	if videoExt == "" {
-		videoExt = ".mp4"
+	if trailer.Compressed || trailer.Encrypted {
+		for _, entry := range trailer.Attachments {
+			fmt.Fprintf(out, msg("info.original_size"), entry.Name, formatFileSize(int64(entry.OriginalSize)))
+		}
 	}
-	videoName += videoExt
-
-	videoOutputPath := filepath.Join(outputDir, videoName)
-	attachOutputPath := filepath.Join(outputDir, attachName)
+	fmt.Fprintf(out, msg("info.metadata_ext"), formatFileSize(metadataSize))
 
-	// 检查输出文件是否存在
-	for _, path := range []string{videoOutputPath, attachOutputPath} {
-		if _, err := os.Stat(path); err == nil {
-			colorYellow.Printf("⚠️  文件已存在: %s\n", path)
-			if !confirmAction("是否覆盖?") {
-				return fmt.Errorf("用户取消操作")
-			}
+	fmt.Fprintln(out, msg("info.offsets"))
+	for _, key := range []string{"video_start", "attach_start", "metadata_start", "filename", "extension_start", "video_size", "attach_size", "magic_bytes"} {
+		if pos, ok := trailer.Offsets[key]; ok {
+			fmt.Fprintf(out, "   %-16s %d\n", key+":", pos)
 		}
 	}
 
-	fmt.Println()
+	return nil
+}
 
-	// 提取视频文件
-	colorCyan.Println("🎬 提取视频文件...")
-	if _, err := mergedFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("定位视频文件失败: %v", err)
-	}
+// 端到端校验合并文件结构及数据可读性
+func verifyMergedFile(mergedPath, stealthKey, authKey string, ignoreAuth bool) error {
+	ctx := operationContext()
+	logInfof(colorBlue, "verify.start")
 
-	videoFile, err := os.Create(videoOutputPath)
+	mergedInfo, err := validateFile(mergedPath)
 	if err != nil {
-		return fmt.Errorf("创建视频文件失败: %v", err)
-	}
-	defer videoFile.Close()
-
-	if err := copyWithProgress(videoFile, io.LimitReader(mergedFile, int64(videoSize)), int64(videoSize), "视频文件"); err != nil {
-		return fmt.Errorf("提取视频文件失败: %v", err)
+		return newError("error.merged_invalid_w", err)
 	}
 
-	// 提取附加文件
-	colorCyan.Println("\n📎 提取附加文件...")
-	if _, err := mergedFile.Seek(int64(videoSize), 0); err != nil {
-		return fmt.Errorf("定位附加文件失败: %v", err)
-	}
+	// 报告（校验的文件和结果）写到标准输出，各步骤进度和警告写到标准错误
+	out := resultOutput
+	fmt.Fprintf(out, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
 
-	attachFile, err := os.Create(attachOutputPath)
+	mergedFile, err := os.Open(mergedPath)
 	if err != nil {
-		return fmt.Errorf("创建附加文件失败: %v", err)
+		return exitErrorf(EXIT_IO, "error.open_merged_failed", err)
 	}
-	defer attachFile.Close()
+	defer mergedFile.Close()
 
-	if err := copyWithProgress(attachFile, io.LimitReader(mergedFile, int64(attachSize)), int64(attachSize), "附加文件"); err != nil {
-		return fmt.Errorf("提取附加文件失败: %v", err)
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
 	}
+	defer printDebugInfo(debugInfo)
 
-	// 获取输出文件的绝对路径
-	absVideoPath, err := filepath.Abs(videoOutputPath)
+	// 1. 结构校验（魔术字节、大小字段、文件名长度、总体结构）
+	fmt.Fprintln(messageOutput)
+	logInfof(colorCyan, "verify.checking_metadata")
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, stealthKey, debugInfo)
 	if err != nil {
-		absVideoPath = videoOutputPath
+		return newError("verify.structure_failed", err)
+	}
+	logInfof(colorGreen, "verify.structure_ok")
+	if err := checkTrailerAuth(trailer, authKey, ignoreAuth, debugInfo); err != nil {
+		return err
 	}
 
-	absAttachPath, err := filepath.Abs(attachOutputPath)
-	if err != nil {
-		absAttachPath = attachOutputPath
+	// 2. 完整读取视频数据区
+	logInfof(colorCyan, "verify.checking_video")
+	videoReader := io.NewSectionReader(videoSourceOf(mergedFile, trailer), 0, int64(trailer.VideoSize))
+	videoHash := sha256.New()
+	if _, err := copyWithProgress(ctx, nil, videoReader, int64(trailer.VideoSize), newProgress(msg("progress.video_data")), videoHash); err != nil {
+		return newError("verify.video_failed", err)
+	}
+	debugInfo.ExpectedVideoSHA256 = trailer.VideoSHA256
+	debugInfo.ActualVideoSHA256 = hex.EncodeToString(videoHash.Sum(nil))
+	if trailer.VideoSHA256 != "" && trailer.VideoSHA256 != debugInfo.ActualVideoSHA256 {
+		debugInfo.ValidationError = msg("verify.video_sha_mismatch")
+		return newError("verify.video_sha_failed", trailer.VideoSHA256, debugInfo.ActualVideoSHA256)
+	}
+
+	// 3. 完整读取附加文件数据区
+	logInfof(colorCyan, "verify.checking_attach")
+	attachReader := io.NewSectionReader(mergedFile, attachStartOf(trailer), int64(trailer.AttachSize))
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	if _, err := copyWithProgress(ctx, nil, attachReader, int64(trailer.AttachSize), newProgress(msg("progress.attach_data")), attachHash, attachCRC); err != nil {
+		return newError("verify.attach_failed", err)
+	}
+	debugInfo.ExpectedAttachCRC32 = trailer.AttachCRC32
+	debugInfo.ActualAttachCRC32 = formatCRC32(attachCRC.Sum32())
+	if trailer.AttachCRC32 != "" && trailer.AttachCRC32 != debugInfo.ActualAttachCRC32 {
+		debugInfo.ValidationError = msg("verify.attach_crc_mismatch")
+		return newError("verify.attach_crc_failed", trailer.AttachCRC32, debugInfo.ActualAttachCRC32)
+	}
+	debugInfo.ExpectedAttachSHA256 = trailer.AttachSHA256
+	debugInfo.ActualAttachSHA256 = hex.EncodeToString(attachHash.Sum(nil))
+	if trailer.AttachSHA256 != "" && trailer.AttachSHA256 != debugInfo.ActualAttachSHA256 {
+		debugInfo.ValidationError = msg("verify.attach_sha_mismatch")
+		return newError("verify.attach_sha_failed", trailer.AttachSHA256, debugInfo.ActualAttachSHA256)
+	}
+
+	fmt.Fprint(out, msg("verify.ok"))
+	fmt.Fprintf(out, msg("verify.video_readable"), formatFileSize(int64(trailer.VideoSize)))
+	if len(trailer.Attachments) > 1 {
+		fmt.Fprintf(out, msg("verify.attach_list"), len(trailer.Attachments), formatFileSize(int64(trailer.AttachSize)))
+		for i, entry := range trailer.Attachments {
+			fmt.Fprintf(out, msg("verify.attach_entry"), i+1, attachLabel(entry), entry.Name, formatFileSize(int64(entry.Size)))
+		}
+	} else {
+		fmt.Fprintf(out, msg("verify.attach_readable"), trailer.Attachments[0].Name, formatFileSize(int64(trailer.AttachSize)))
+	}
+	if trailer.VideoSHA256 != "" || trailer.AttachSHA256 != "" {
+		fmt.Fprint(out, msg("split.stats_sha_ok"))
+	} else {
+		logWarnf("verify.no_sha")
 	}
 
-	absOutputDir, err := filepath.Abs(outputDir)
-	if err != nil {
-		absOutputDir = outputDir
+	return nil
+}
+
+// 拆分命令参数：兼容旧的 split <merged_file> <output_dir> 用法
+func splitCommandArgs(args []string, outputFlag string) ([]string, string) {
+	if outputFlag != "" {
+		return args, outputFlag
 	}
+	if len(args) == 2 && !hasGlobMeta(args[1]) {
+		if info, err := os.Stat(args[1]); err != nil || info.IsDir() {
+			return args[:1], args[1]
+		}
+	}
+	return args, ""
+}
 
-	colorGreen.Printf("\n✅ 格式拆分完成!\n")
-	fmt.Printf("📊 拆分统计:\n")
-	fmt.Printf("   🎬 视频文件: %s (%s)\n", videoName, formatFileSize(int64(videoSize)))
-	fmt.Printf("   📎 附加文件: %s (%s)\n", attachName, formatFileSize(int64(attachSize)))
-	fmt.Printf("📁 输出目录: %s\n", outputDir)
-	colorCyan.Printf("📍 目录完整路径: %s\n", absOutputDir)
-	fmt.Println("\n📄 输出文件完整路径:")
-	colorCyan.Printf("   🎬 视频: %s\n", absVideoPath)
-	colorCyan.Printf("   📎 附加: %s\n", absAttachPath)
+// 未指定输出目录时的默认目录：extracted_<合并文件名（不含扩展名和分卷编号）>，配置了输出目录时放在其中
+func defaultSplitOutputDir(mergedPath string) string {
+	if isRemotePath(mergedPath) {
+		name := remoteBaseName(mergedPath)
+		return withDefaultOutputDir("extracted_" + strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	name := filepath.Base(volumeSuffix.ReplaceAllString(mergedPath, ""))
+	return withDefaultOutputDir("extracted_" + strings.TrimSuffix(name, filepath.Ext(name)))
+}
 
-	return nil
+// 默认输出目录已存在且不为空时确认是否继续写入（--force 时直接继续）
+func confirmSplitOutputDir(outputDir string) error {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	colorYellow.Printf(msg("split.output_dir_not_empty"), outputDir, len(entries))
+	return confirmOverwrite(outputDir, msg("split.confirm_output_dir"))
 }
 
 // 合并命令
 var mergeCmd = &cobra.Command{
-	Use:   "merge <video_file> <attach_file> <output_file>",
-	Short: "格式合并视频文件和附加文件",
-	Long: `将一个视频文件和一个任意文件合并成一个格式的新文件。
-格式支持超大文件（8字节大小字段），不兼容v1/v2格式。`,
-	Args: cobra.ExactArgs(3),
+	Use:   "merge <video_file> <attach_file>... [output_file]",
+	Short: helpText("help.merge.short"),
+	Long:  helpText("help.merge.long"),
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := mergeOpts
+		opts.StealthKey = stealthKey
+		// 只有视频和一个附加文件，或指定了 --output-dir 时，输出文件按视频名生成
+		attachPaths, outputPath := args[1:len(args)-1], args[len(args)-1]
+		if len(args) == 2 || mergeOutputDir != "" {
+			attachPaths, outputPath = args[1:], defaultMergeOutputPath(args[0], mergeOutputDir)
+			if mergeOutputDir != "" && !opts.DryRun {
+				if err := os.MkdirAll(mergeOutputDir, 0755); err != nil {
+					return newError("error.create_output_dir_failed", err)
+				}
+			}
+			logInfof(colorCyan, "merge.default_output", outputPath)
+		}
+		if mergeVolumeSize != "" {
+			volumeSize, err := parseVolumeSize(mergeVolumeSize)
+			if err != nil {
+				return err
+			}
+			opts.VolumeSize = volumeSize
+		}
+		if askPassword && hasStdinPath(attachPaths) {
+			return exitErrorf(EXIT_USAGE, "merge.stdin_ask_password")
+		}
+		if askPassword {
+			password, err := askNewPassword()
+			if err != nil {
+				return err
+			}
+			opts.Password = password
+		}
+		opts.Result = &OperationResult{Operation: "merge", Inputs: absPaths(append([]string{args[0]}, attachPaths...)...)}
+		return runWithResult(opts.Result, func() error {
+			return mergeFiles(operationContext(), args[0], attachPaths, outputPath, opts)
+		})
+	},
+}
+
+// 批量合并命令
+var mergeBatchCmd = &cobra.Command{
+	Use:   "merge-batch <video_file> <attach_dir> <output_dir>",
+	Short: helpText("help.merge_batch.short"),
+	Long:  helpText("help.merge_batch.long"),
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := batchOpts
+		opts.StealthKey = stealthKey
+		return mergeBatch(args[0], args[1], args[2], opts)
+	},
+}
+
+// 追加命令
+var appendCmd = &cobra.Command{
+	Use:   "append <merged_file> <attach_file>...",
+	Short: helpText("help.append.short"),
+	Long:  helpText("help.append.long"),
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := appendOpts
+		opts.StealthKey = stealthKey
+		return appendFiles(args[0], args[1:], opts)
+	},
+}
+
+// 替换命令
+var updateCmd = &cobra.Command{
+	Use:   "update <merged_file> <attach_file>...",
+	Short: helpText("help.update.short"),
+	Long:  helpText("help.update.long"),
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := updateOpts
+		opts.StealthKey = stealthKey
+		return updateFiles(args[0], args[1:], opts)
+	},
+}
+
+// 还原命令
+var restoreCmd = &cobra.Command{
+	Use:   "restore <merged_file>",
+	Short: helpText("help.restore.short"),
+	Long:  helpText("help.restore.long"),
+	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return mergeFiles(args[0], args[1], args[2])
+		opts := restoreOpts
+		opts.StealthKey = stealthKey
+		return restoreVideo(args[0], opts)
 	},
 }
 
 // 拆分命令
 var splitCmd = &cobra.Command{
-	Use:   "split <merged_file> [output_dir]",
-	Short: "拆分格式合并后的文件",
-	Long: `从格式合并后的文件中提取原始的视频文件和隐藏的附加文件。
-仅支持格式，使用固定位置快速解析。
-如果不指定输出目录，则在当前目录下创建extracted_目录。`,
-	Args: cobra.RangeArgs(1, 2),
+	Use:   "split <merged_file>... [-o output_dir]",
+	Short: helpText("help.split.short"),
+	Long:  helpText("help.split.long"),
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputs, outputDir := splitCommandArgs(args, splitOutputDir)
+		opts := splitOpts
+		opts.StealthKey = stealthKey
+		if splitAttachVolumeSize != "" {
+			volumeSize, err := parseVolumeSize(splitAttachVolumeSize)
+			if err != nil {
+				return err
+			}
+			opts.AttachVolumeSize = volumeSize
+		}
+		if opts.AttachToStdout && (jsonOutput || len(inputs) > 1 || hasGlobMeta(inputs[0])) {
+			return exitErrorf(EXIT_USAGE, "split.stdout_single")
+		}
+		if len(inputs) > 1 || hasGlobMeta(inputs[0]) {
+			return splitBatch(inputs, outputDir, opts)
+		}
+		// 未指定输出目录时默认为 extracted_<文件名>，已有内容时先确认
+		if outputDir == "" {
+			outputDir = defaultSplitOutputDir(inputs[0])
+			if !opts.DryRun && !opts.AttachToStdout {
+				if err := confirmSplitOutputDir(outputDir); err != nil {
+					return err
+				}
+			}
+		}
+		opts.Result = &OperationResult{Operation: "split", Inputs: absPaths(args[0])}
+		return runWithResult(opts.Result, func() error {
+			return splitFiles(operationContext(), inputs[0], outputDir, opts)
+		})
+	},
+}
+
+// 信息命令
+var infoCmd = &cobra.Command{
+	Use:   "info <merged_file>",
+	Short: helpText("help.info.short"),
+	Long:  helpText("help.info.long"),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := resolveOutputFormat(infoFormat, infoJSON)
+		if err != nil {
+			return err
+		}
+		return showMergedInfo(args[0], format, stealthKey)
+	},
+}
+
+// 校验命令
+var verifyCmd = &cobra.Command{
+	Use:   "verify <merged_file>",
+	Short: helpText("help.verify.short"),
+	Long:  helpText("help.verify.long"),
+	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		outputDir := "extracted_"
-		if len(args) > 1 {
-			outputDir = args[1]
+		return verifyMergedFile(args[0], stealthKey, verifyAuthKey, verifyIgnoreAuth)
+	},
+}
+
+// 检测命令
+var detectCmd = &cobra.Command{
+	Use:   "detect <file>",
+	Short: helpText("help.detect.short"),
+	Long:  helpText("help.detect.long"),
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		code, result := detectMergedFile(args[0], stealthKey, detectVerbose)
+		fmt.Fprintln(resultOutput, result)
+		os.Exit(code)
+	},
+}
+
+// 扫描命令
+var scanCmd = &cobra.Command{
+	Use:   "scan <dir>",
+	Short: helpText("help.scan.short"),
+	Long:  helpText("help.scan.long"),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := scanOpts
+		opts.StealthKey = stealthKey
+		format, err := resolveOutputFormat(opts.Format, jsonOutput)
+		if err != nil {
+			return err
 		}
-		return splitFiles(args[0], outputDir)
+		opts.Format = format
+		return scanMergedFiles(args[0], opts)
 	},
 }
 
@@ -1171,12 +3616,8 @@ var splitCmd = &cobra.Command{
 var interactiveCmd = &cobra.Command{
 	Use:     "interactive",
 	Aliases: []string{"i", "inter"},
-	Short:   "启动交互式模式",
-	Long: `启动交互式模式，支持拖拽文件到命令行窗口进行操作。
-
-
-
-推荐：初次使用或需要处理大文件的用户`,
+	Short:   helpText("help.interactive.short"),
+	Long:    helpText("help.interactive.long"),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return interactiveMode()
 	},
@@ -1184,30 +3625,20 @@ var interactiveCmd = &cobra.Command{
 
 // 根命令
 var rootCmd = &cobra.Command{
-	Use:   "video-merger-v3",
-	Short: "视频文件合并拆分工具",
-	Long: `🎬 视频文件合并拆分工具
-
-这是一个命令行工具，可以将任意文件隐藏在视频文件中，
-或者从格式合并后的文件中提取原始文件。
-
-格式特性:
-  ✅ 支持超大文件（8字节大小字段）
-  ✅ 固定位置读取，极速解析  
-  ✅ 智能文件名处理
-  ✅ 详细的进度显示
-  ✅ 完善的错误处理和调试
-  🎯 交互式模式，支持拖拽文件
-  🔧 开发模式，详细调试信息
-
-快速开始:
-  1. 交互模式: video-merger-v3 interactive
-  2. 直接合并: video-merger-v3 merge video.mp4 secret.txt output_v3.mp4
-  3. 直接拆分: video-merger-v3 split output_v3.mp4`,
+	Use:     "video-merger-v3",
+	Short:   helpText("help.root.short"),
+	Version: toolVersion,
+	Long:    helpText("help.root.long"),
+	Args:    cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// 把文件拖到程序图标上启动时路径是唯一的参数，按智能模式处理该文件
+		if len(args) == 1 {
+			return runDroppedFile(args[0])
+		}
+
 		// 如果没有参数，默认启动交互模式
-		colorYellow.Println("💡 未指定操作，启动交互式模式...")
-		colorYellow.Println("   提示：下次可以直接使用 'video-merger-v3 interactive'")
+		colorYellow.Println(msg("main.no_command"))
+		colorYellow.Println(msg("main.no_command_hint"))
 		time.Sleep(1 * time.Second)
 		return interactiveMode()
 	},
@@ -1216,15 +3647,165 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(mergeCmd)
 	rootCmd.AddCommand(splitCmd)
+	rootCmd.AddCommand(mergeBatchCmd)
+	rootCmd.AddCommand(appendCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(joinCmd)
+	rootCmd.AddCommand(recoverCmd)
+	rootCmd.AddCommand(repairCmd)
 	rootCmd.AddCommand(interactiveCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(detectCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	rootCmd.SetVersionTemplate(versionSummary() + "\n")
+
+	// 使用自定义的 completion 命令替代 cobra 默认命令
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	mergeCmd.ValidArgsFunction = mergeArgsCompletion
+	splitCmd.ValidArgsFunction = splitArgsCompletion
 
 	// 添加开发模式标志
-	rootCmd.PersistentFlags().BoolVarP(&devMode, "dev", "d", false, "启用开发模式，显示详细调试信息")
+	rootCmd.PersistentFlags().BoolVarP(&devMode, "dev", "d", false, helpText("flag.root.dev"))
+	rootCmd.PersistentFlags().BoolVarP(&quietMode, "quiet", "q", false, helpText("flag.root.quiet"))
+	rootCmd.PersistentFlags().StringVar(&configPathFlag, "config", "", helpText("flag.root.config"))
+	rootCmd.PersistentFlags().StringVar(&langFlag, "lang", "", helpText("flag.root.lang"))
+	rootCmd.PersistentFlags().StringVar(&devLogPath, "dev-log", "", helpText("flag.root.dev_log"))
+	rootCmd.PersistentFlags().StringVar(&bufferSizeFlag, "buffer-size", "", helpText("flag.root.buffer_size"))
+	rootCmd.PersistentFlags().StringVar(&limitRateFlag, "limit-rate", "", helpText("flag.root.limit_rate"))
+	rootCmd.PersistentFlags().StringVar(&logFormatFlag, "log-format", LOG_FORMAT_TEXT, helpText("flag.root.log_format"))
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, helpText("flag.root.no_progress"))
+	rootCmd.PersistentFlags().BoolVar(&noPreallocate, "no-preallocate", false, helpText("flag.root.no_preallocate"))
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, helpText("flag.root.no_color"))
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, helpText("flag.root.yes"))
+	rootCmd.PersistentFlags().BoolVarP(&forceOverwrite, "force", "f", false, helpText("flag.root.force"))
+	rootCmd.PersistentFlags().StringVar(&stealthKey, "stealth-key", "", helpText("flag.root.stealth_key"))
+	rootCmd.PersistentFlags().StringVar(&magicBytes, "magic", MAGIC_BYTES, helpText("flag.root.magic"))
+
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, helpText("flag.info.json"))
+	infoCmd.Flags().StringVar(&infoFormat, "format", "", helpText("flag.info.format"))
+	scanCmd.Flags().BoolVar(&jsonOutput, "json", false, helpText("flag.scan.json"))
+	scanCmd.Flags().StringVar(&scanOpts.Format, "format", "", helpText("flag.scan.format"))
+	scanCmd.Flags().StringSliceVar(&scanOpts.Extensions, "ext", nil, helpText("flag.scan.ext"))
+	scanCmd.Flags().Int64Var(&scanOpts.MinSize, "min-size", 0, helpText("flag.scan.min_size"))
+	detectCmd.Flags().BoolVarP(&detectVerbose, "verbose", "v", false, helpText("flag.detect.verbose"))
+	verifyCmd.Flags().StringVar(&verifyAuthKey, "auth-key", "", helpText("flag.verify.auth_key"))
+	verifyCmd.Flags().BoolVar(&verifyIgnoreAuth, "ignore-auth", false, helpText("flag.verify.ignore_auth"))
+
+	mergeCmd.Flags().BoolVar(&jsonOutput, "json", false, helpText("flag.merge.json"))
+	splitCmd.Flags().BoolVar(&jsonOutput, "json", false, helpText("flag.split.json"))
+	mergeCmd.Flags().StringVar(&mergeOpts.Password, "password", "", helpText("flag.merge.password"))
+	mergeCmd.Flags().BoolVar(&askPassword, "ask-password", false, helpText("flag.merge.ask_password"))
+	mergeCmd.Flags().StringVar(&mergeOpts.AuthKey, "auth-key", "", helpText("flag.merge.auth_key"))
+	mergeCmd.Flags().StringVar(&mergeOpts.KeyFile, "key-file", "", helpText("flag.merge.key_file"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.Compress, "compress", false, helpText("flag.merge.compress"))
+	mergeCmd.Flags().StringVar(&mergeOpts.Comment, "comment", "", helpText("flag.merge.comment"))
+	mergeCmd.Flags().Int64Var(&mergeOpts.Align, "align", 0, helpText("flag.merge.align"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.AllowNested, "allow-nested", false, helpText("flag.merge.allow_nested"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.ZipCompatible, "zip-compatible", false, helpText("flag.merge.zip_compatible"))
+	mergeCmd.Flags().StringVar(&mergeOpts.EmbedMode, "embed-mode", EMBED_MODE_TRAILER, helpText("flag.merge.embed_mode"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.Verify, "verify", false, helpText("flag.merge.verify"))
+	mergeCmd.Flags().StringVar(&mergeOpts.CompareWith, "compare-with", "", helpText("flag.merge.compare_with"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.CheckPlayable, "check-playable", false, helpText("flag.merge.check_playable"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.AllowEmpty, "allow-empty", false, helpText("flag.merge.allow_empty"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.SkipSpaceCheck, "skip-space-check", false, helpText("flag.merge.skip_space_check"))
+	mergeCmd.Flags().StringVar(&mergeVolumeSize, "volume-size", "", helpText("flag.merge.volume_size"))
+	mergeCmd.Flags().StringVar(&mergeOutputDir, "output-dir", "", helpText("flag.merge.output_dir"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.Resume, "resume", false, helpText("flag.merge.resume"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.DryRun, "dry-run", false, helpText("flag.merge.dry_run"))
+	mergeCmd.Flags().StringVar(&mergeOpts.AttachName, "attach-name", "", helpText("flag.merge.attach_name"))
+	mergeCmd.Flags().BoolVar(&mergeOpts.FEC, "fec", false, helpText("flag.merge.fec"))
+	splitCmd.Flags().StringVar(&splitOpts.Password, "password", "", helpText("flag.split.password"))
+	splitCmd.Flags().StringVar(&splitOpts.KeyFile, "key-file", "", helpText("flag.split.key_file"))
+	splitCmd.Flags().StringVar(&splitOpts.AuthKey, "auth-key", "", helpText("flag.split.auth_key"))
+	splitCmd.Flags().BoolVar(&splitOpts.IgnoreAuth, "ignore-auth", false, helpText("flag.split.ignore_auth"))
+	splitCmd.Flags().BoolVar(&splitOpts.Quick, "quick", false, helpText("flag.split.quick"))
+	splitCmd.Flags().BoolVar(&splitOpts.SkipCRC, "skip-crc", false, helpText("flag.split.skip_crc"))
+	splitCmd.Flags().BoolVar(&splitOpts.VideoOnly, "video-only", false, helpText("flag.split.video_only"))
+	splitCmd.Flags().BoolVar(&splitOpts.AttachOnly, "attach-only", false, helpText("flag.split.attach_only"))
+	splitCmd.Flags().StringVarP(&splitOutputDir, "output", "o", "", helpText("flag.split.output"))
+	splitCmd.Flags().StringVar(&splitOpts.VideoOut, "video-out", "", helpText("flag.split.video_out"))
+	splitCmd.Flags().BoolVar(&splitOpts.SkipSpaceCheck, "skip-space-check", false, helpText("flag.split.skip_space_check"))
+	splitCmd.Flags().BoolVar(&splitOpts.Verify, "verify", false, helpText("flag.split.verify"))
+	splitCmd.Flags().BoolVar(&splitOpts.Sequential, "sequential", false, helpText("flag.split.sequential"))
+	splitCmd.Flags().BoolVar(&splitOpts.Resume, "resume", false, helpText("flag.split.resume"))
+	splitCmd.Flags().BoolVar(&splitOpts.DryRun, "dry-run", false, helpText("flag.split.dry_run"))
+	splitCmd.Flags().BoolVar(&splitOpts.AttachToStdout, "attach-to-stdout", false, helpText("flag.split.attach_to_stdout"))
+	splitCmd.Flags().StringArrayVar(&splitOpts.AttachOut, "attach-out", nil, helpText("flag.split.attach_out"))
+	splitCmd.Flags().StringVar(&splitAttachVolumeSize, "attach-volume-size", "", helpText("flag.split.attach_volume_size"))
+	recoverCmd.Flags().StringVarP(&recoverOutputDir, "output", "o", "", helpText("flag.recover.output"))
+	recoverCmd.Flags().BoolVar(&recoverOpts.Full, "full", false, helpText("flag.recover.full"))
+	recoverCmd.Flags().StringVar(&recoverWindow, "window", "", helpText("flag.recover.window"))
+	recoverCmd.Flags().BoolVar(&recoverOpts.ListOnly, "list", false, helpText("flag.recover.list"))
+	recoverCmd.Flags().IntVar(&recoverOpts.Candidate, "candidate", 0, helpText("flag.recover.candidate"))
+	recoverCmd.Flags().StringVar(&recoverOpts.Split.Password, "password", "", helpText("flag.recover.password"))
+	recoverCmd.Flags().StringVar(&recoverOpts.Split.KeyFile, "key-file", "", helpText("flag.recover.key_file"))
+	repairCmd.Flags().StringVar(&repairVideoSize, "video-size", "", helpText("flag.repair.video_size"))
+	repairCmd.Flags().StringVar(&repairAttachSize, "attach-size", "", helpText("flag.repair.attach_size"))
+	repairCmd.Flags().StringVar(&repairOpts.AttachName, "attach-name", "", helpText("flag.repair.attach_name"))
+	repairCmd.Flags().BoolVar(&repairOpts.DryRun, "dry-run", false, helpText("flag.repair.dry_run"))
+	repairCmd.MarkFlagRequired("video-size")
+	mergeBatchCmd.Flags().StringVar(&batchOpts.Password, "password", "", helpText("flag.merge_batch.password"))
+	mergeBatchCmd.Flags().StringVar(&batchOpts.AuthKey, "auth-key", "", helpText("flag.merge_batch.auth_key"))
+	mergeBatchCmd.Flags().StringVar(&batchOpts.KeyFile, "key-file", "", helpText("flag.merge_batch.key_file"))
+	mergeBatchCmd.Flags().BoolVar(&batchOpts.Compress, "compress", false, helpText("flag.merge_batch.compress"))
+	mergeBatchCmd.Flags().StringVar(&batchOpts.Comment, "comment", "", helpText("flag.merge_batch.comment"))
+	mergeBatchCmd.Flags().BoolVar(&batchOpts.AllowNested, "allow-nested", false, helpText("flag.merge_batch.allow_nested"))
+	mergeBatchCmd.Flags().BoolVar(&batchOpts.AllowEmpty, "allow-empty", false, helpText("flag.merge_batch.allow_empty"))
+	mergeBatchCmd.Flags().BoolVar(&batchOpts.SkipSpaceCheck, "skip-space-check", false, helpText("flag.merge_batch.skip_space_check"))
+	mergeBatchCmd.Flags().Int64Var(&batchOpts.Align, "align", 0, helpText("flag.merge_batch.align"))
+	mergeBatchCmd.Flags().BoolVar(&batchOpts.FEC, "fec", false, helpText("flag.merge_batch.fec"))
+	appendCmd.Flags().StringVar(&appendOpts.Password, "password", "", helpText("flag.append.password"))
+	appendCmd.Flags().StringVar(&appendOpts.KeyFile, "key-file", "", helpText("flag.append.key_file"))
+	appendCmd.Flags().StringVar(&appendOpts.AuthKey, "auth-key", "", helpText("flag.append.auth_key"))
+	updateCmd.Flags().StringVar(&updateOpts.Password, "password", "", helpText("flag.update.password"))
+	updateCmd.Flags().StringVar(&updateOpts.KeyFile, "key-file", "", helpText("flag.update.key_file"))
+	updateCmd.Flags().StringVar(&updateOpts.AuthKey, "auth-key", "", helpText("flag.update.auth_key"))
+	restoreCmd.Flags().StringVar(&restoreOpts.Copy, "copy", "", helpText("flag.restore.copy"))
+
+	// 输出目录只补全目录
+	splitCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	})
 }
 
 func main() {
 	// 设置banner显示逻辑
-	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// 配置文件有误时仍可用 config init 重新写入
+		if err := loadConfig(cmd); err != nil && cmd != configInitCmd {
+			return err
+		}
+		if err := configureLanguage(); err != nil {
+			return err
+		}
+		localizeHelp(cmd.Root())
+		if err := configureBufferSize(); err != nil {
+			return err
+		}
+		if err := configureRateLimit(); err != nil {
+			return err
+		}
+		if err := configureLogging(); err != nil {
+			return err
+		}
+		if err := openDevLog(); err != nil {
+			return err
+		}
+		logDebugf("devlog.buffer_size", formatFileSize(bufferSize))
+		if quietMode {
+			enableQuietMode()
+		}
+		reserveStdoutForResults()
+		// cobra 输出的补全候选同样是结果，shell 补全脚本从标准输出读取
+		cmd.Root().SetOut(resultOutput)
+		configureColor()
+
 		// 只在交互模式或根命令时显示banner
 		if cmd.Name() == "interactive" || cmd.Name() == "video-merger-v3" {
 			printBanner()
@@ -1232,18 +3813,73 @@ func main() {
 
 		// 显示开发模式状态
 		if devMode {
-			colorMagenta.Println("🔧 开发模式已启用")
+			colorMagenta.Println(msg("main.dev_enabled"))
+			colorMagenta.Printf(msg("buffer.effective"), formatFileSize(bufferSize))
+		}
+
+		// 验证自定义魔术字节
+		if len(magicBytes) != MAGIC_LENGTH {
+			return exitErrorf(EXIT_USAGE, "main.bad_magic", MAGIC_LENGTH, len(magicBytes), magicBytes)
+		}
+		if magicBytes != MAGIC_BYTES {
+			colorYellow.Printf(msg("main.custom_magic"), magicBytes)
 		}
+		commandStarted = true
+		return nil
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		colorRed.Printf("\n❌ 错误: %v\n", err)
+	// --help 不经过 PersistentPreRunE，显示前按 --lang、配置文件和环境变量选择语言
+	defaultHelp := rootCmd.HelpFunc()
+	rootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		loadConfig(cmd)
+		if configureLanguage() != nil {
+			langFlag = ""
+			configureLanguage()
+		}
+		localizeHelp(cmd.Root())
+		defaultHelp(cmd, args)
+	})
+
+	// 错误由下面统一以本地化格式输出一次，参数错误只提示查看 --help
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+
+	installInterruptHandler()
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		// 复制被中断：清理未完成的输出后按中断退出
+		if operationInterrupted() {
+			exitInterrupted()
+		}
+		// 命令尚未开始执行时的错误来自参数、选项解析
+		if !commandStarted {
+			err = withExitCode(EXIT_USAGE, err)
+			// 参数解析失败时尚未选择语言；--lang 本身无效时按环境变量显示
+			if configureLanguage() != nil {
+				langFlag = ""
+				configureLanguage()
+			}
+		}
+		if logJSON {
+			logErrorf("main.error", err)
+			if !commandStarted {
+				logInfof(nil, "main.usage_hint", cmd.CommandPath())
+			}
+		} else {
+			colorRed.Fprintf(color.Error, msg("main.error"), err)
+			if !commandStarted {
+				colorYellow.Fprintln(color.Error, msgf("main.usage_hint", cmd.CommandPath()))
+			}
+		}
 
 		// 如果是交互模式的错误，提供重试选项
-		if strings.Contains(err.Error(), "用户取消") {
-			colorYellow.Println("💡 提示：可以随时重新运行程序")
+		if errors.Is(err, ErrCancelled) {
+			colorYellow.Println(msg("main.rerun_hint"))
 		}
 
-		os.Exit(1)
+		logDebugf("devlog.failed", exitCodeOf(err), err)
+		pauseBeforeExit()
+		os.Exit(exitCodeOf(err))
 	}
+	logDebugf("devlog.done")
+	pauseBeforeExit()
 }
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cancundeyingzi/Video-File-Merge-Split-Tool/pkg/mergefmt"
+	"github.com/fatih/color"
+)
+
+// 在临时目录写入文件并返回路径
+func writeTempFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 在内存中生成 v3 合并文件
+func mergedBytes(t *testing.T, video, attach []byte, attachName string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := mergefmt.Merge(bytes.NewReader(video), bytes.NewReader(attach), mergefmt.Metadata{AttachName: attachName}, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestIsMergedFile(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 100)
+	merged := mergedBytes(t, video, []byte("attachment"), "a.txt")
+	// 末尾恰好是魔术字节但结构不符
+	magicOnly := append(bytes.Clone(video), magicBytes...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		merged  bool
+		version int
+	}{
+		{"v3", merged, true, 3},
+		{"magic only", magicOnly, false, 3},
+		{"plain", video, false, 0},
+		{"too small", []byte("tiny"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, debugInfo, err := isMergedFile(writeTempFile(t, "f.mp4", tt.data), "")
+			if err != nil {
+				t.Fatalf("isMergedFile: %v", err)
+			}
+			if got != tt.merged || debugInfo.FormatVersion != tt.version {
+				t.Errorf("isMergedFile = %v, version %d; want %v, version %d", got, debugInfo.FormatVersion, tt.merged, tt.version)
+			}
+		})
+	}
+}
+
+func TestVerifyListsAttachments(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString("video data")
+	file.WriteString("onetwo!")
+	metadata, err := mergefmt.EncodeTrailer(&mergefmt.Trailer{
+		VideoSize:   10,
+		Attachments: []mergefmt.Attachment{{Name: "1.txt", Size: 3}, {Name: "2.txt", Size: 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(metadata)
+	path := writeTempFile(t, "multi.mp4", file.Bytes())
+
+	// 校验报告是结果，写到标准输出；各步骤的进度写到标准错误
+	code, stdout, stderr := runMain(t, "verify", "--lang", "en", path)
+	if code != EXIT_OK {
+		t.Fatalf("exit code = %d\n%s", code, stderr)
+	}
+	for _, want := range []string{"multi.mp4", "Verification passed", "Attachment list (2, 7 B total)", "1. 📎 Attachment: 1.txt (3 B)", "2. 📎 Attachment: 2.txt (4 B)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout missing %q:\n%s", want, stdout)
+		}
+	}
+	if !strings.Contains(stderr, "Checking video data") || strings.Contains(stderr, "Attachment list") {
+		t.Errorf("stderr should hold only progress:\n%s", stderr)
+	}
+}
+
+// info 的文字报告写到标准输出，重定向到文件时不会是空的
+func TestInfoReportOnStdout(t *testing.T) {
+	path := writeTempFile(t, "merged.mp4", mergedBytes(t, []byte("video data"), []byte("attachment"), "a.txt"))
+	code, stdout, stderr := runMain(t, "info", "--lang", "en", path)
+	if code != EXIT_OK {
+		t.Fatalf("exit code = %d\n%s", code, stderr)
+	}
+	for _, want := range []string{"merged.mp4", "a.txt", "video_start:"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"中文", 6, "中文"},
+		{"中文", 5, "中"},
+		{"中文", 4, "中"},
+		{"中文", 3, "中"},
+		{"中文", 2, ""},
+		{"a😀b", 4, "a"},
+		{"a😀b", 5, "a😀"},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateFilename(t *testing.T) {
+	// 84个汉字 = 252字节，加 ".txt" 正好 256 字节，超出 255 字节一个字节
+	han := strings.Repeat("文", 84)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"fits", strings.Repeat("文", 83) + ".txt", strings.Repeat("文", 83) + ".txt"},
+		{"exactly 255", strings.Repeat("a", 251) + ".txt", strings.Repeat("a", 251) + ".txt"},
+		{"one byte over", han + ".txt", strings.Repeat("文", 83) + ".txt"},
+		{"emoji", "ab" + strings.Repeat("😀", 63) + ".mp4", "ab" + strings.Repeat("😀", 62) + ".mp4"},
+		{"long extension", "a." + strings.Repeat("扩", 100), "a." + strings.Repeat("扩", 84)},
+		{"extension fills budget", "名字." + strings.Repeat("x", 254), "名字." + strings.Repeat("x", 248)},
+	}
+	for _, tt := range tests {
+		got := truncateFilename(tt.in, FS_FILENAME_LENGTH)
+		if got != tt.want {
+			t.Errorf("%s: got %q (%d bytes), want %q", tt.name, got, len(got), tt.want)
+		}
+		if len(got) > FS_FILENAME_LENGTH || !utf8.ValidString(got) {
+			t.Errorf("%s: result is %d bytes, valid UTF-8 %v", tt.name, len(got), utf8.ValidString(got))
+		}
+	}
+}
+
+// 超过元数据长度上限的多字节文件名截断后仍然有效，扩展名不变
+func TestValidateAndCleanFilenameLong(t *testing.T) {
+	for _, name := range []string{strings.Repeat("文", 400) + ".pdf", "a" + strings.Repeat("😀", 300) + ".mkv"} {
+		cleaned, err := validateAndCleanFilename(name)
+		if err != nil {
+			t.Errorf("validateAndCleanFilename(%d bytes): %v", len(name), err)
+			continue
+		}
+		if len(cleaned) > MAX_FILENAME_LENGTH || filepath.Ext(cleaned) != filepath.Ext(name) {
+			t.Errorf("cleaned name is %d bytes with extension %q", len(cleaned), filepath.Ext(cleaned))
+		}
+	}
+}
+
+// 只计数的进度实现，基准测试中代替终端进度条
+type countingProgress struct{ n int64 }
+
+func (p *countingProgress) Start(int64) {}
+func (p *countingProgress) Add(n int64) { p.n += n }
+func (p *countingProgress) Done()       {}
+
+// 基准测试数据大小的环境变量，如 VIDEO_MERGER_BENCH_SIZE=4G；-short 时忽略
+const BENCH_SIZE_ENV = "VIDEO_MERGER_BENCH_SIZE"
+
+// 基准测试数据大小：默认 64MB，环境变量可改为多GB以覆盖每 1GB 的断点记录和预分配
+func benchSize(b *testing.B) int64 {
+	b.Helper()
+	value := os.Getenv(BENCH_SIZE_ENV)
+	if value == "" || testing.Short() {
+		return 64 * 1024 * 1024
+	}
+	size, ok := parseByteSize(value)
+	if !ok {
+		b.Fatalf("invalid %s=%q", BENCH_SIZE_ENV, value)
+	}
+	return size
+}
+
+// 分块写入 size 字节的测试文件，多GB时不占用同样大小的内存
+func writeBenchFile(b *testing.B, name string, size int64) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), name)
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), BUFFER_SIZE/16)
+	for written := int64(0); written < size; written += int64(len(chunk)) {
+		if size-written < int64(len(chunk)) {
+			chunk = chunk[:size-written]
+		}
+		if _, err := file.Write(chunk); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// 文件到文件复制：不显示进度时目标的 ReadFrom（copy_file_range）可用，校验值在读取端计算
+func BenchmarkCopyWithProgress(b *testing.B) {
+	size := benchSize(b)
+	srcPath := writeBenchFile(b, "src.bin", size)
+	dstPath := filepath.Join(b.TempDir(), "dst.bin")
+
+	for _, bc := range []struct {
+		name     string
+		progress func() Progress
+		hash     bool
+	}{
+		{"silent", func() Progress { return noopProgress{} }, false},
+		{"silent_sha256", func() Progress { return noopProgress{} }, true},
+		{"progress", func() Progress { return &countingProgress{} }, false},
+		{"progress_sha256", func() Progress { return &countingProgress{} }, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				src, err := os.Open(srcPath)
+				if err != nil {
+					b.Fatal(err)
+				}
+				dst, err := os.Create(dstPath)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var hashes []hash.Hash
+				if bc.hash {
+					hashes = append(hashes, sha256.New())
+				}
+				if _, err := copyWithProgress(context.Background(), dst, src, size, bc.progress(), hashes...); err != nil {
+					b.Fatal(err)
+				}
+				src.Close()
+				dst.Close()
+			}
+		})
+	}
+}
+
+// 完整的合并流程：输出预先分配，--resume 时视频数据每 1GB 记录一次断点
+func BenchmarkMergeLargeVideo(b *testing.B) {
+	// 与 --quiet 相同，屏蔽普通输出和结果路径
+	defer func(quiet bool, messages, colored, results io.Writer) {
+		quietMode, messageOutput, color.Output, resultOutput = quiet, messages, colored, results
+	}(quietMode, messageOutput, color.Output, resultOutput)
+	quietMode, messageOutput, color.Output, resultOutput = true, io.Discard, io.Discard, io.Discard
+
+	size := benchSize(b)
+	videoPath := writeBenchFile(b, "video.mp4", size)
+	attachPath := writeBenchFile(b, "attach.bin", 1024*1024)
+	outputPath := filepath.Join(b.TempDir(), "out.mp4")
+
+	for _, resume := range []bool{false, true} {
+		name := "plain"
+		if resume {
+			name = "resume"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				os.Remove(outputPath)
+				opts := MergeOptions{Resume: resume, Progress: noopProgress{}}
+				if err := mergeFiles(context.Background(), videoPath, []string{attachPath}, outputPath, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// 逐字节返回数据的读取器
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+// 每次 ReadAt 最多返回一个字节且不报错，模拟网络文件系统的短读
+type oneByteReaderAt struct{ r io.ReaderAt }
+
+func (o oneByteReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.ReadAt(p[:1], off)
+}
+
+func TestParseTrailerShortReads(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 100)
+	merged := mergedBytes(t, video, []byte("attachment"), "附件.txt")
+	newDebugInfo := func() *DebugInfo {
+		return &DebugInfo{FileSize: int64(len(merged)), CalculatedPos: make(map[string]int64)}
+	}
+	want, err := parseTrailer(bytes.NewReader(merged), int64(len(merged)), newDebugInfo())
+	if err != nil {
+		t.Fatalf("parseTrailer: %v", err)
+	}
+	got, err := parseTrailer(oneByteReaderAt{bytes.NewReader(merged)}, int64(len(merged)), newDebugInfo())
+	if err != nil {
+		t.Fatalf("parseTrailer with one-byte reads: %v", err)
+	}
+	if got.AttachName != want.AttachName || got.VideoSize != want.VideoSize || got.AttachSize != want.AttachSize || got.VideoSHA256 != want.VideoSHA256 {
+		t.Errorf("one-byte reads: %q/%d/%d, want %q/%d/%d", got.AttachName, got.VideoSize, got.AttachSize, want.AttachName, want.VideoSize, want.AttachSize)
+	}
+}
+
+func TestReadTrailerAtEOF(t *testing.T) {
+	data := oneByteReaderAt{bytes.NewReader([]byte("0123456789"))}
+	buf := make([]byte, 4)
+	debugInfo := &DebugInfo{}
+	if err := readTrailerAt(data, buf, 3, debugInfo); err != nil || string(buf) != "3456" {
+		t.Errorf("readTrailerAt = %q, %v", buf, err)
+	}
+	if err := readTrailerAt(data, buf, 8, debugInfo); err != errTrailerEOF {
+		t.Errorf("readTrailerAt past the end: error = %v, want %v", err, errTrailerEOF)
+	}
+	if !debugInfo.UnexpectedEOF || debugInfo.UnexpectedEOFOffset != 8 || debugInfo.UnexpectedEOFLength != 4 {
+		t.Errorf("debug info = %v/%d/%d, want true/8/4", debugInfo.UnexpectedEOF, debugInfo.UnexpectedEOFOffset, debugInfo.UnexpectedEOFLength)
+	}
+}
+
+func TestCopyWithProgressHashes(t *testing.T) {
+	data := bytes.Repeat([]byte("copy data "), 10000)
+	want := sha256.Sum256(data)
+	for _, progress := range []Progress{noopProgress{}, &countingProgress{}} {
+		for _, dst := range []io.Writer{nil, io.Discard, &bytes.Buffer{}} {
+			h := sha256.New()
+			copied, err := copyWithProgress(context.Background(), dst, oneByteReader{bytes.NewReader(data)}, int64(len(data)), progress, h)
+			if err != nil || copied != int64(len(data)) {
+				t.Fatalf("copyWithProgress(%T, %T) = %d, %v", progress, dst, copied, err)
+			}
+			if !bytes.Equal(h.Sum(nil), want[:]) {
+				t.Errorf("copyWithProgress(%T, %T): wrong SHA-256", progress, dst)
+			}
+			if buf, ok := dst.(*bytes.Buffer); ok && !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("copyWithProgress(%T): destination differs from source", progress)
+			}
+		}
+	}
+}
+
+// 读写错误两条路径都原样返回，长度不符时为 errCopySizeMismatch
+func TestCopyWithProgressErrors(t *testing.T) {
+	readErr := errors.New("read boom")
+	for _, progress := range []Progress{noopProgress{}, &countingProgress{}} {
+		_, err := copyWithProgress(context.Background(), io.Discard, io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(readErr)), 10, progress)
+		if !errors.Is(err, readErr) {
+			t.Errorf("%T: read error = %v, want %v", progress, err, readErr)
+		}
+		_, err = copyWithProgress(context.Background(), errWriter{}, strings.NewReader("abc"), 3, progress)
+		if !errors.Is(err, errWriteBoom) {
+			t.Errorf("%T: write error = %v, want %v", progress, err, errWriteBoom)
+		}
+		_, err = copyWithProgress(context.Background(), io.Discard, strings.NewReader("abc"), 5, progress)
+		if !errors.Is(err, errCopySizeMismatch) || copyExitCode(err) != EXIT_INVALID_FORMAT {
+			t.Errorf("%T: short copy error = %v, want errCopySizeMismatch", progress, err)
+		}
+	}
+}
+
+var errWriteBoom = errors.New("write boom")
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errWriteBoom }
+
+// slowWriter 模拟网络共享等每次写入都有固定开销的目标，记录写入次数
+type slowWriter struct {
+	delay  time.Duration
+	writes int
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.writes++
+	time.Sleep(w.delay)
+	return len(p), nil
+}
+
+// 压缩附加文件的零碎写入：直接写入目标与经 --buffer-size 大小的 bufio.Writer 合并后写入，比较写入次数
+func BenchmarkMergeOutputWrites(b *testing.B) {
+	defer func(quiet bool) { quietMode = quiet }(quietMode)
+	quietMode = true
+
+	data := make([]byte, 16*1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	attachPath := filepath.Join(b.TempDir(), "attach.bin")
+	if err := os.WriteFile(attachPath, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	attachInfo, err := validateAttachPath(attachPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, buffered := range []bool{false, true} {
+		name := "unbuffered"
+		if buffered {
+			name = "buffered"
+		}
+		b.Run(name, func(b *testing.B) {
+			var writes int
+			for i := 0; i < b.N; i++ {
+				target := &slowWriter{delay: 200 * time.Microsecond}
+				var dst io.Writer = target
+				output := bufio.NewWriterSize(target, int(bufferSize))
+				if buffered {
+					dst = output
+				}
+				writer := attachmentWriter{ctx: context.Background(), dst: dst, compress: true}
+				if err := writer.write(attachInfo, &AttachmentEntry{}, 0); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := writeTrailer(dst, bytes.NewBuffer(make([]byte, 256)), ""); err != nil {
+					b.Fatal(err)
+				}
+				if err := output.Flush(); err != nil {
+					b.Fatal(err)
+				}
+				writes += target.writes
+			}
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		})
+	}
+}
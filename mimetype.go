@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// 内容嗅探读取的字节数（与 http.DetectContentType 一致）
+	MIME_SNIFF_LENGTH = 512
+	// 目录归档的类型
+	MIME_TAR = "application/x-tar"
+	// 无法识别时的类型
+	MIME_UNKNOWN = "application/octet-stream"
+)
+
+// 根据文件开头内容嗅探MIME类型
+func sniffMimeType(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return MIME_UNKNOWN
+	}
+	defer file.Close()
+
+	buffer := make([]byte, MIME_SNIFF_LENGTH)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return MIME_UNKNOWN
+	}
+
+	return http.DetectContentType(buffer[:n])
+}
+
+// 去掉MIME类型的参数部分（如 charset）
+func baseMimeType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	}
+	return mediaType
+}
+
+// 格式化MIME类型，未记录时显示 unknown
+func formatMimeType(mimeType string) string {
+	if mimeType == "" {
+		return "unknown"
+	}
+	return mimeType
+}
+
+// 检查扩展名与嗅探类型是否矛盾，返回扩展名对应的类型
+func mimeMismatch(name, mimeType string) (string, bool) {
+	sniffed := baseMimeType(mimeType)
+	// 纯文本和未知类型无法可靠区分，不提示
+	if sniffed == "" || sniffed == MIME_UNKNOWN || sniffed == "text/plain" {
+		return "", false
+	}
+
+	extType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if extType == "" {
+		return "", false
+	}
+
+	extType = baseMimeType(extType)
+	return extType, extType != sniffed
+}
+
+// 编码MIME类型列表：[数量(4字节)] + 每个 [长度(4字节)] + [类型]
+func encodeMimeTypes(entries []AttachmentEntry) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(entries)))
+	for _, entry := range entries {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entry.MimeType)))
+		buf = append(buf, entry.MimeType...)
+	}
+	return buf
+}
+
+// 解析MIME类型列表并应用到附加文件列表
+func applyMimeTypes(entries []AttachmentEntry, value []byte) error {
+	if len(value) < UINT32_LENGTH {
+		return newError("mime.incomplete")
+	}
+
+	count := binary.LittleEndian.Uint32(value[:UINT32_LENGTH])
+	if int(count) != len(entries) {
+		return newError("mime.count_mismatch", count, len(entries))
+	}
+
+	pos := UINT32_LENGTH
+	for i := range entries {
+		if len(value)-pos < UINT32_LENGTH {
+			return newError("mime.entry_incomplete", i+1)
+		}
+		length := binary.LittleEndian.Uint32(value[pos : pos+UINT32_LENGTH])
+		pos += UINT32_LENGTH
+		if uint64(length) > uint64(len(value)-pos) {
+			return newError("mime.entry_bad_length", i+1, length)
+		}
+		entries[i].MimeType = string(value[pos : pos+int(length)])
+		pos += int(length)
+	}
+
+	if pos != len(value) {
+		return newError("mime.trailing_data")
+	}
+
+	return nil
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MKV 附件嵌入模式：附加文件写成 Segment 内的 Attachments 元素（带文件名和 MIME 类型），
+// 尾部元数据包在其后的 Void 元素里，Segment 的大小字段改写为覆盖到文件末尾。
+// 附件是 Matroska 的标准结构，mkvextract 可以直接取出，经 mkvmerge 重新封装后也会保留。
+// 格式：[视频(Segment 大小已改写)] + [Attachments/AttachedFile/...FileData 元素头] + [附加文件] + [Void 元素头] + [尾部元数据]
+const (
+	EMBED_MODE_MKV_ATTACHMENT = "mkv-attachment"
+
+	// Matroska 元素ID
+	EBML_DOC_TYPE_ID        = 0x4282
+	EBML_VOID_ID            = 0xEC
+	MKV_ATTACHMENTS_ID      = 0x1941A469
+	MKV_ATTACHED_FILE_ID    = 0x61A7
+	MKV_FILE_DESCRIPTION_ID = 0x467E
+	MKV_FILE_NAME_ID        = 0x466E
+	MKV_FILE_MIME_TYPE_ID   = 0x4660
+	MKV_FILE_DATA_ID        = 0x465C
+	MKV_FILE_UID_ID         = 0x46AE
+
+	// 写入的元素大小字段固定8字节，元素头长度与数据大小无关
+	EBML_SIZE_FIELD_LENGTH = 8
+	// Void 元素头：1字节ID + 8字节大小
+	MKV_VOID_HEADER_LENGTH = 1 + EBML_SIZE_FIELD_LENGTH
+	// 读取的字符串元素（文件名、MIME 类型、描述）最大长度
+	MKV_MAX_STRING_LENGTH = 4096
+
+	// 附件描述，重新封装后尾部元数据丢失时据此找回本工具写入的附件
+	MKV_ATTACHMENT_DESCRIPTION = "video-merger-v3 attachment"
+	// 未识别类型时的 MIME 类型（Matroska 要求附件必须有 MIME 类型）
+	MKV_DEFAULT_MIME_TYPE = "application/octet-stream"
+)
+
+// EBML 元素头
+type ebmlElement struct {
+	ID         uint64
+	Start      int64
+	SizeOffset int64
+	SizeLength int
+	DataStart  int64
+	// 大小未知时为读取范围的末尾
+	DataEnd int64
+	Unknown bool
+}
+
+// 可写入附件的 MKV 载体：Segment 大小字段的位置和原始字节（大小未知时为 nil，不需要改写）
+type mkvCarrier struct {
+	SizeOffset int64
+	SizeLength int
+	DataStart  int64
+	Original   []byte
+}
+
+// 重新封装后找到的本工具写入的附件
+type mkvAttachment struct {
+	Name     string
+	MimeType string
+	Offset   int64
+	Size     int64
+}
+
+// 检查 MKV 附件嵌入模式的限制：只支持一个未加密、未压缩的普通附加文件
+func checkMKVAttachmentMode(opts MergeOptions, attachInfos []*FileInfo) error {
+	switch {
+	case opts.Password != "":
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--password")
+	case opts.KeyFile != "":
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--key-file")
+	case opts.Compress:
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--compress")
+	case opts.StealthKey != "":
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--stealth-key")
+	case opts.ZipCompatible:
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--zip-compatible")
+	case opts.Align > 1:
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--align")
+	case opts.FEC:
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--fec")
+	case opts.Resume:
+		return exitErrorf(EXIT_USAGE, "mkvattach.conflict", "--resume")
+	}
+	if len(attachInfos) != 1 || attachInfos[0].IsDir || attachInfos[0].IsStdin {
+		return exitErrorf(EXIT_USAGE, "mkvattach.single_file")
+	}
+	return nil
+}
+
+// 确认载体是可以追加附件的 MKV：Segment 恰好在文件末尾结束（或大小未知），且还没有 Attachments 元素
+func inspectMKVCarrier(videoPath string) (*mkvCarrier, error) {
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := info.Size()
+
+	docType, segment, err := readMKVSegment(file, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	if docType == "webm" {
+		return nil, newError("mkvattach.webm")
+	}
+	if !segment.Unknown && segment.DataEnd != fileSize {
+		return nil, newError("mkvattach.segment_not_at_end", segment.DataEnd, fileSize)
+	}
+	for position := segment.DataStart; position < segment.DataEnd; {
+		element, err := readEBMLElement(file, position, segment.DataEnd)
+		if err != nil {
+			return nil, err
+		}
+		if element.ID == MKV_ATTACHMENTS_ID {
+			return nil, newError("mkvattach.has_attachments")
+		}
+		// 大小未知的元素（如直播录制的 Cluster）延伸到 Segment 末尾，无法再向后遍历
+		if element.Unknown {
+			break
+		}
+		position = element.DataEnd
+	}
+
+	carrier := &mkvCarrier{SizeOffset: segment.SizeOffset, SizeLength: segment.SizeLength, DataStart: segment.DataStart}
+	if !segment.Unknown {
+		carrier.Original = make([]byte, segment.SizeLength)
+		if _, err := file.ReadAt(carrier.Original, segment.SizeOffset); err != nil {
+			return nil, err
+		}
+	}
+	return carrier, nil
+}
+
+// 合并后的布局；Segment 大小未知时不改写大小字段
+func (c *mkvCarrier) layout(name, mimeType string) *mkvLayout {
+	layout := &mkvLayout{
+		Header: uint64(len(mkvAttachmentHeader(name, mimeType, 0, 0))),
+		Void:   MKV_VOID_HEADER_LENGTH,
+	}
+	if c.Original != nil {
+		layout.SizeOffset = c.SizeOffset
+		layout.OriginalSize = c.Original
+	}
+	return layout
+}
+
+// 合并后 Segment 大小字段的新内容（覆盖到 totalSize），原字段长度放不下时返回错误
+func (c *mkvCarrier) patch(totalSize int64) ([]byte, error) {
+	if c.Original == nil {
+		return nil, nil
+	}
+	size, ok := encodeEBMLSize(uint64(totalSize-c.DataStart), c.SizeLength)
+	if !ok {
+		return nil, newError("mkvattach.size_field_short", c.SizeLength)
+	}
+	return size, nil
+}
+
+// 附件 MIME 类型，未识别时使用通用类型
+func mkvMimeType(mimeType string) string {
+	if mimeType == "" {
+		return MKV_DEFAULT_MIME_TYPE
+	}
+	return mimeType
+}
+
+// Attachments/AttachedFile 元素头、附件属性和 FileData 元素头，其后紧跟 dataSize 字节的附加文件
+func mkvAttachmentHeader(name, mimeType string, uid uint64, dataSize int64) []byte {
+	var fields []byte
+	fields = append(fields, ebmlElementBytes(MKV_FILE_DESCRIPTION_ID, []byte(MKV_ATTACHMENT_DESCRIPTION))...)
+	fields = append(fields, ebmlElementBytes(MKV_FILE_NAME_ID, []byte(name))...)
+	fields = append(fields, ebmlElementBytes(MKV_FILE_MIME_TYPE_ID, []byte(mkvMimeType(mimeType)))...)
+	fields = append(fields, ebmlElementBytes(MKV_FILE_UID_ID, binary.BigEndian.AppendUint64(nil, uid))...)
+	fields = append(fields, ebmlElementHeader(MKV_FILE_DATA_ID, uint64(dataSize))...)
+
+	attachedFile := ebmlElementHeader(MKV_ATTACHED_FILE_ID, uint64(len(fields))+uint64(dataSize))
+	header := ebmlElementHeader(MKV_ATTACHMENTS_ID, uint64(len(attachedFile)+len(fields))+uint64(dataSize))
+	header = append(header, attachedFile...)
+	return append(header, fields...)
+}
+
+// 随机生成附件 UID（Matroska 要求非0）
+func newMKVFileUID() (uint64, error) {
+	buf := make([]byte, 8)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return 0, err
+		}
+		if uid := binary.BigEndian.Uint64(buf); uid != 0 {
+			return uid, nil
+		}
+	}
+}
+
+// 元素ID按原样写入（ID 本身已含长度标记位，取最短的字节数）
+func ebmlID(id uint64) []byte {
+	length := 1
+	for id>>(8*length) != 0 {
+		length++
+	}
+	buf := make([]byte, length)
+	for i := range buf {
+		buf[i] = byte(id >> (8 * (length - 1 - i)))
+	}
+	return buf
+}
+
+// 元素头：ID + 固定8字节的大小字段
+func ebmlElementHeader(id, size uint64) []byte {
+	size8, _ := encodeEBMLSize(size, EBML_SIZE_FIELD_LENGTH)
+	return append(ebmlID(id), size8...)
+}
+
+// 完整元素：元素头 + 数据
+func ebmlElementBytes(id uint64, data []byte) []byte {
+	return append(ebmlElementHeader(id, uint64(len(data))), data...)
+}
+
+// 按指定长度编码 EBML 大小字段；数值位全为1表示大小未知，不能用于实际大小
+func encodeEBMLSize(size uint64, length int) ([]byte, bool) {
+	if length < 1 || length > EBML_MAX_VINT_LENGTH || size >= 1<<(7*length)-1 {
+		return nil, false
+	}
+	buf := binary.BigEndian.AppendUint64(nil, size)[8-length:]
+	buf[0] |= 0x80 >> (length - 1)
+	return buf, true
+}
+
+// 读取 position 处的元素头，大小超出 limit 时返回错误；大小未知的元素延伸到 limit
+func readEBMLElement(r io.ReaderAt, position, limit int64) (*ebmlElement, error) {
+	id, idLength, _, err := readEBMLVint(r, position, limit, true)
+	if err != nil {
+		return nil, err
+	}
+	if idLength == 0 {
+		return nil, newError("mkvattach.bad_element", position)
+	}
+	size, sizeLength, unknown, err := readEBMLVint(r, position+int64(idLength), limit, false)
+	if err != nil {
+		return nil, err
+	}
+	if sizeLength == 0 {
+		return nil, newError("mkvattach.bad_element", position)
+	}
+	element := &ebmlElement{
+		ID:         id,
+		Start:      position,
+		SizeOffset: position + int64(idLength),
+		SizeLength: sizeLength,
+		DataStart:  position + int64(idLength) + int64(sizeLength),
+		DataEnd:    limit,
+		Unknown:    unknown,
+	}
+	if !unknown {
+		if size > uint64(limit-element.DataStart) {
+			return nil, newError("mkvattach.bad_element", position)
+		}
+		element.DataEnd = element.DataStart + int64(size)
+	}
+	return element, nil
+}
+
+// 读取字符串元素的内容（去掉末尾的补零）
+func readEBMLString(r io.ReaderAt, element *ebmlElement) (string, error) {
+	length := element.DataEnd - element.DataStart
+	if length > MKV_MAX_STRING_LENGTH {
+		return "", newError("mkvattach.bad_element", element.Start)
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, element.DataStart); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(buf), "\x00"), nil
+}
+
+// 读取开头的 EBML 头（返回 DocType）和紧随其后的 Segment 元素头
+func readMKVSegment(r io.ReaderAt, fileSize int64) (string, *ebmlElement, error) {
+	header, err := readEBMLElement(r, 0, fileSize)
+	if err != nil || header.ID != EBML_HEADER_ID || header.Unknown {
+		return "", nil, newError("mkvattach.not_mkv")
+	}
+	var docType string
+	for position := header.DataStart; position < header.DataEnd; {
+		element, err := readEBMLElement(r, position, header.DataEnd)
+		if err != nil {
+			return "", nil, err
+		}
+		if element.ID == EBML_DOC_TYPE_ID {
+			if docType, err = readEBMLString(r, element); err != nil {
+				return "", nil, err
+			}
+		}
+		position = element.DataEnd
+	}
+
+	segment, err := readEBMLElement(r, header.DataEnd, fileSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if segment.ID != MKV_SEGMENT_ID {
+		return "", nil, newError("mkvattach.no_segment", header.DataEnd)
+	}
+	return docType, segment, nil
+}
+
+// 确认 start 处是延伸到 voidStart 的 Attachments 元素，其后的 Void 元素覆盖尾部元数据直到文件末尾，
+// 且改写过的 Segment 大小字段恰好覆盖到文件末尾
+func locateMKVAttachment(r io.ReaderAt, fileSize, start, voidStart int64, layout *mkvLayout) error {
+	attachments, err := readEBMLElement(r, start, fileSize)
+	if err != nil {
+		return err
+	}
+	if attachments.ID != MKV_ATTACHMENTS_ID || attachments.DataEnd != voidStart {
+		return newError("mkvattach.not_found", start)
+	}
+	void, err := readEBMLElement(r, voidStart, fileSize)
+	if err != nil {
+		return err
+	}
+	if void.ID != EBML_VOID_ID || void.DataStart-voidStart != int64(layout.Void) || void.DataEnd != fileSize {
+		return newError("mkvattach.not_found", start)
+	}
+
+	if len(layout.OriginalSize) == 0 {
+		return nil
+	}
+	segmentStart := layout.SizeOffset - int64(len(ebmlID(MKV_SEGMENT_ID)))
+	if segmentStart < 0 {
+		return newError("mkvattach.segment_mismatch")
+	}
+	segment, err := readEBMLElement(r, segmentStart, fileSize)
+	if err != nil {
+		return err
+	}
+	if segment.ID != MKV_SEGMENT_ID || segment.SizeLength != len(layout.OriginalSize) || segment.DataEnd != fileSize {
+		return newError("mkvattach.segment_mismatch")
+	}
+	return nil
+}
+
+// 在 Segment 的顶层元素中查找本工具写入的附件（按附件描述识别），没有时返回 nil。
+// 用于重新封装后尾部元数据已丢失的文件
+func findMKVAttachment(r io.ReaderAt, fileSize int64) *mkvAttachment {
+	_, segment, err := readMKVSegment(r, fileSize)
+	if err != nil {
+		return nil
+	}
+	for position := segment.DataStart; position < segment.DataEnd; {
+		element, err := readEBMLElement(r, position, segment.DataEnd)
+		if err != nil {
+			return nil
+		}
+		if element.ID == MKV_ATTACHMENTS_ID {
+			if found := findAttachedFile(r, element); found != nil {
+				return found
+			}
+		}
+		if element.Unknown {
+			return nil
+		}
+		position = element.DataEnd
+	}
+	return nil
+}
+
+// 在 Attachments 元素中查找描述为本工具标记的 AttachedFile
+func findAttachedFile(r io.ReaderAt, attachments *ebmlElement) *mkvAttachment {
+	for position := attachments.DataStart; position < attachments.DataEnd; {
+		attachedFile, err := readEBMLElement(r, position, attachments.DataEnd)
+		if err != nil || attachedFile.Unknown {
+			return nil
+		}
+		position = attachedFile.DataEnd
+		if attachedFile.ID != MKV_ATTACHED_FILE_ID {
+			continue
+		}
+
+		var found mkvAttachment
+		var description string
+		hasData := false
+		for field := attachedFile.DataStart; field < attachedFile.DataEnd; {
+			element, err := readEBMLElement(r, field, attachedFile.DataEnd)
+			if err != nil || element.Unknown {
+				return nil
+			}
+			switch element.ID {
+			case MKV_FILE_DESCRIPTION_ID:
+				description, err = readEBMLString(r, element)
+			case MKV_FILE_NAME_ID:
+				found.Name, err = readEBMLString(r, element)
+			case MKV_FILE_MIME_TYPE_ID:
+				found.MimeType, err = readEBMLString(r, element)
+			case MKV_FILE_DATA_ID:
+				found.Offset, found.Size, hasData = element.DataStart, element.DataEnd-element.DataStart, true
+			}
+			if err != nil {
+				return nil
+			}
+			field = element.DataEnd
+		}
+		if description == MKV_ATTACHMENT_DESCRIPTION && hasData && found.Name != "" {
+			return &found
+		}
+	}
+	return nil
+}
+
+// 从重新封装过的 MKV 中提取本工具写入的附件。尾部元数据已丢失，没有校验值可比对，也不提取视频
+func extractRemuxedAttachment(ctx context.Context, progress progressSource, r io.ReaderAt, found *mkvAttachment, outputDir string, opts SplitOptions) error {
+	var outputPath string
+	switch {
+	case len(opts.AttachOut) > 1:
+		return exitErrorf(EXIT_USAGE, "split.attach_out_count", len(opts.AttachOut), 1)
+	case len(opts.AttachOut) == 1:
+		outputPath = opts.AttachOut[0]
+	default:
+		path, name, err := attachmentOutputPath(outputDir, found.Name)
+		if err != nil {
+			return err
+		}
+		if name != found.Name {
+			logWarnf("split.name_sanitized", found.Name, name)
+		}
+		outputPath = path
+	}
+	if opts.AutoRename {
+		outputPath = uniqueOutputPath(outputPath, make(map[string]bool))
+	}
+
+	outputs := []planOutput{{Label: msg("plan.attachment"), Path: outputPath, Size: found.Size}}
+	if opts.DryRun {
+		return checkOutputPlan(outputs, true, opts.SkipSpaceCheck)
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		colorYellow.Printf(msg("common.file_exists"), outputPath)
+		if err := confirmOverwrite(outputPath, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+	if err := checkFreeSpace(outputs, opts.SkipSpaceCheck); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return exitErrorf(EXIT_IO, "error.create_output_dir_failed", err)
+	}
+
+	fmt.Fprintln(messageOutput)
+	logInfof(colorCyan, "split.extracting_attach", 1, 1, found.Name)
+	entry := AttachmentEntry{Name: found.Name, Size: uint64(found.Size), OriginalSize: uint64(found.Size), MimeType: found.MimeType}
+	outputPath, err := extractAttachment(ctx, progress, io.NewSectionReader(r, found.Offset, found.Size), entry, outputPath)
+	if err != nil {
+		return withExitCode(copyExitCode(err), err)
+	}
+
+	absPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		absPath = outputPath
+	}
+	colorGreen.Print(msg("split.done"))
+	fmt.Fprint(messageOutput, msg("split.stats"))
+	fmt.Fprintf(messageOutput, "   %s: %s (%s, %s)\n", attachLabel(entry), entry.Name, formatFileSize(found.Size), formatMimeType(entry.MimeType))
+	colorCyan.Printf(msg("split.output_attach"), absPath)
+	printResultPath(outputPath)
+	if opts.Result != nil {
+		opts.Result.Outputs = absPaths(outputPath)
+		opts.Result.AttachSize = found.Size
+	}
+	return nil
+}
+
+// 原地还原时把 Segment 大小字段改回合并前的内容
+func restoreMKVSegmentSize(path string, layout *mkvLayout) error {
+	if layout == nil || len(layout.OriginalSize) == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(layout.OriginalSize, layout.SizeOffset); err != nil {
+		file.Close()
+		return err
+	}
+	return syncAndClose(file)
+}
+
+// 复制视频时把 Segment 大小字段替换为新内容；传入的数据可能同时送给校验值，替换前先复制一份
+type patchWriter struct {
+	w        io.Writer
+	position int64
+	offset   int64
+	data     []byte
+}
+
+func (p *patchWriter) Write(b []byte) (int, error) {
+	start := max(p.offset, p.position)
+	end := min(p.offset+int64(len(p.data)), p.position+int64(len(b)))
+	if start < end {
+		b = bytes.Clone(b)
+		copy(b[start-p.position:end-p.position], p.data[start-p.offset:end-p.offset])
+	}
+	n, err := p.w.Write(b)
+	p.position += int64(n)
+	return n, err
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// 最小的 MKV 载体：EBML 头（DocType）+ Segment（大小已知，恰好在文件末尾结束）
+func mkvCarrierBytes(docType string, children ...[]byte) []byte {
+	header := ebmlElementBytes(EBML_HEADER_ID, ebmlElementBytes(EBML_DOC_TYPE_ID, []byte(docType)))
+	var segment []byte
+	for _, child := range children {
+		segment = append(segment, child...)
+	}
+	return append(header, ebmlElementBytes(MKV_SEGMENT_ID, segment)...)
+}
+
+// 在 MKV 附件模式下合并，返回合并结果
+func mergeMKVAttachment(t *testing.T, video, attach []byte, attachName string) (string, []byte) {
+	t.Helper()
+	videoPath := writeTempFile(t, "v.mkv", video)
+	attachPath := writeTempFile(t, attachName, attach)
+	outputPath := filepath.Join(t.TempDir(), "out.mkv")
+	opts := MergeOptions{EmbedMode: EMBED_MODE_MKV_ATTACHMENT, Progress: noopProgress{}}
+	if err := mergeFiles(context.Background(), videoPath, []string{attachPath}, outputPath, opts); err != nil {
+		t.Fatalf("mergeFiles: %v", err)
+	}
+	merged, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outputPath, merged
+}
+
+func TestMKVAttachmentRoundTrip(t *testing.T) {
+	// 0x1549A966 为 Info 元素
+	video := mkvCarrierBytes("matroska", ebmlElementBytes(0x1549A966, bytes.Repeat([]byte("info"), 1000)))
+	attach := bytes.Repeat([]byte("附件 attachment "), 10000)
+	outputPath, merged := mergeMKVAttachment(t, video, attach, "notes.txt")
+
+	// 合并结果本身是 Segment 覆盖到文件末尾的有效 MKV，附件是标准的 AttachedFile
+	_, segment, err := readMKVSegment(bytes.NewReader(merged), int64(len(merged)))
+	if err != nil || segment.DataEnd != int64(len(merged)) {
+		t.Fatalf("segment = %+v, %v; want it to end at %d", segment, err, len(merged))
+	}
+	found := findMKVAttachment(bytes.NewReader(merged), int64(len(merged)))
+	if found == nil || found.Name != "notes.txt" || found.MimeType == "" {
+		t.Fatalf("findMKVAttachment = %+v", found)
+	}
+	if got := merged[found.Offset : found.Offset+found.Size]; !bytes.Equal(got, attach) {
+		t.Error("FileData differs from the attachment")
+	}
+
+	outputDir := t.TempDir()
+	if err := splitFiles(context.Background(), outputPath, outputDir, SplitOptions{Progress: noopProgress{}}); err != nil {
+		t.Fatalf("splitFiles: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "notes.txt")); err != nil || !bytes.Equal(data, attach) {
+		t.Errorf("split attachment: %d bytes, %v", len(data), err)
+	}
+	// 提取出的视频还原了 Segment 大小字段
+	if data, err := os.ReadFile(filepath.Join(outputDir, "v.mkv")); err != nil || !bytes.Equal(data, video) {
+		t.Errorf("split video: %d bytes, %v", len(data), err)
+	}
+
+	mkvextract, err := exec.LookPath("mkvextract")
+	if err != nil {
+		t.Skip("mkvextract not installed")
+	}
+	extracted := filepath.Join(t.TempDir(), "notes.txt")
+	if out, err := exec.Command(mkvextract, outputPath, "attachments", "1:"+extracted).CombinedOutput(); err != nil {
+		t.Fatalf("mkvextract: %v\n%s", err, out)
+	}
+	if data, err := os.ReadFile(extracted); err != nil || !bytes.Equal(data, attach) {
+		t.Errorf("mkvextract attachment: %d bytes, %v", len(data), err)
+	}
+}
+
+// 重新封装会丢掉 Void 元素中的尾部元数据，split 按附件描述找回附件
+func TestMKVAttachmentRemuxed(t *testing.T) {
+	video := mkvCarrierBytes("matroska", ebmlElementBytes(0x1549A966, []byte("info")))
+	attach := bytes.Repeat([]byte("remuxed "), 1000)
+	_, merged := mergeMKVAttachment(t, video, attach, "a.bin")
+
+	found := findMKVAttachment(bytes.NewReader(merged), int64(len(merged)))
+	if found == nil {
+		t.Fatal("attachment not found")
+	}
+	remuxed := bytes.Clone(merged[:found.Offset+found.Size])
+	_, segment, err := readMKVSegment(bytes.NewReader(merged), int64(len(merged)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, _ := encodeEBMLSize(uint64(int64(len(remuxed))-segment.DataStart), segment.SizeLength)
+	copy(remuxed[segment.SizeOffset:], size)
+
+	outputDir := t.TempDir()
+	if err := splitFiles(context.Background(), writeTempFile(t, "remuxed.mkv", remuxed), outputDir, SplitOptions{Progress: noopProgress{}}); err != nil {
+		t.Fatalf("splitFiles: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "a.bin")); err != nil || !bytes.Equal(data, attach) {
+		t.Errorf("remuxed attachment: %d bytes, %v", len(data), err)
+	}
+}
+
+func TestInspectMKVCarrierRejects(t *testing.T) {
+	withAttachments := mkvCarrierBytes("matroska", mkvAttachmentHeader("x", "", 1, 0))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not mkv", bytes.Repeat([]byte("video "), 100)},
+		{"webm", mkvCarrierBytes("webm")},
+		{"has attachments", withAttachments},
+		{"trailing data", append(mkvCarrierBytes("matroska"), "junk"...)},
+	}
+	for _, tt := range tests {
+		if _, err := inspectMKVCarrier(writeTempFile(t, "v.mkv", tt.data)); err == nil {
+			t.Errorf("%s: inspectMKVCarrier accepted the carrier", tt.name)
+		}
+	}
+	if _, err := inspectMKVCarrier(writeTempFile(t, "v.mkv", mkvCarrierBytes("matroska"))); err != nil {
+		t.Errorf("empty segment: %v", err)
+	}
+}
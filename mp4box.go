@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+)
+
+// MP4 box 嵌入模式：附加数据和尾部元数据写在视频之后新增的顶层 free box 里，
+// box 大小一直覆盖到文件末尾，输出在结构上仍是完整的 MP4，不会被当作末尾的多余数据丢弃。
+// 格式：[视频] + [box头(16字节：大小1 + "free" + 64位大小)] + [附加文件] + [尾部元数据]
+const (
+	// --embed-mode 可选值
+	EMBED_MODE_TRAILER = "trailer"
+	EMBED_MODE_MP4BOX  = "mp4box"
+
+	// 写入的 box 类型；读取时 skip 与 free 含义相同
+	MP4_FREE_BOX_TYPE = "free"
+	MP4_SKIP_BOX_TYPE = "skip"
+)
+
+// 解析 --embed-mode，为空时使用末尾追加模式
+func parseEmbedMode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case "", EMBED_MODE_TRAILER:
+		return EMBED_MODE_TRAILER, nil
+	case EMBED_MODE_MP4BOX:
+		return EMBED_MODE_MP4BOX, nil
+	case EMBED_MODE_MKV_ATTACHMENT:
+		return EMBED_MODE_MKV_ATTACHMENT, nil
+	}
+	return "", exitErrorf(EXIT_USAGE, "mp4box.bad_mode", mode)
+}
+
+// 检查 MP4 box 嵌入模式的限制：box 头在附加数据之前写入，附加数据大小必须事先确定
+func checkMP4BoxMode(opts MergeOptions, attachInfos []*FileInfo) error {
+	switch {
+	case opts.Compress:
+		return exitErrorf(EXIT_USAGE, "mp4box.conflict", "--compress")
+	case opts.StealthKey != "":
+		return exitErrorf(EXIT_USAGE, "mp4box.conflict", "--stealth-key")
+	case opts.ZipCompatible:
+		return exitErrorf(EXIT_USAGE, "mp4box.conflict", "--zip-compatible")
+	case opts.Align > 1:
+		return exitErrorf(EXIT_USAGE, "mp4box.conflict", "--align")
+	case opts.FEC:
+		return exitErrorf(EXIT_USAGE, "mp4box.conflict", "--fec")
+	}
+	for _, attachInfo := range attachInfos {
+		if attachInfo.IsDir || attachInfo.IsStdin {
+			return exitErrorf(EXIT_USAGE, "mp4box.regular_files_only")
+		}
+	}
+	return nil
+}
+
+// 确认载体是 MP4/MOV：以 ftyp 开头，顶层 box 大小都明确且恰好在文件末尾结束，才能在其后追加 box
+func checkMP4Carrier(videoPath string) error {
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !hasFtypBox(file) {
+		return newError("mp4box.not_mp4")
+	}
+	for position := int64(0); position < info.Size(); {
+		size, _, _, err := readMP4BoxHeader(file, position, info.Size())
+		if err != nil {
+			return err
+		}
+		position += size
+	}
+	return nil
+}
+
+// 从文件开头遍历顶层 box，确认 boxStart 处是头长度为 headerLength、延伸到文件末尾的 free box
+func locateEmbedBox(r io.ReaderAt, fileSize, boxStart, headerLength int64) error {
+	if !hasFtypBox(r) {
+		return newError("mp4box.not_mp4")
+	}
+	for position := int64(0); position < boxStart; {
+		size, _, _, err := readMP4BoxHeader(r, position, boxStart)
+		if err != nil {
+			return err
+		}
+		position += size
+	}
+
+	size, length, boxType, err := readMP4BoxHeader(r, boxStart, fileSize)
+	if err != nil {
+		return err
+	}
+	if (boxType != MP4_FREE_BOX_TYPE && boxType != MP4_SKIP_BOX_TYPE) || length != headerLength || size != fileSize-boxStart {
+		return newError("mp4box.not_found", boxStart)
+	}
+	return nil
+}
+
+// 文件是否以 ftyp box 开头
+func hasFtypBox(r io.ReaderAt) bool {
+	header := make([]byte, MP4_BOX_HEADER_LENGTH)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return false
+	}
+	return string(header[4:8]) == "ftyp"
+}
+
+// 读取 position 处的 box 头，返回 box 大小、头长度和类型。
+// 大小为0（延伸到文件末尾）或 box 超出 limit 时返回错误
+func readMP4BoxHeader(r io.ReaderAt, position, limit int64) (size, headerLength int64, boxType string, err error) {
+	header := make([]byte, MP4_LARGE_BOX_HEADER_LENGTH)
+	if position+MP4_BOX_HEADER_LENGTH > limit {
+		return 0, 0, "", newError("mp4box.truncated", position)
+	}
+	if _, err := r.ReadAt(header[:MP4_BOX_HEADER_LENGTH], position); err != nil {
+		return 0, 0, "", err
+	}
+	size = int64(binary.BigEndian.Uint32(header[0:4]))
+	boxType = boxName(header[4:8])
+	headerLength = MP4_BOX_HEADER_LENGTH
+
+	switch size {
+	case 0:
+		return 0, 0, "", newError("mp4box.box_to_eof", boxType)
+	case 1:
+		if position+MP4_LARGE_BOX_HEADER_LENGTH > limit {
+			return 0, 0, "", newError("mp4box.truncated", position)
+		}
+		if _, err := r.ReadAt(header[MP4_BOX_HEADER_LENGTH:], position+MP4_BOX_HEADER_LENGTH); err != nil {
+			return 0, 0, "", err
+		}
+		size = int64(binary.BigEndian.Uint64(header[MP4_BOX_HEADER_LENGTH:]))
+		headerLength = MP4_LARGE_BOX_HEADER_LENGTH
+	}
+	if size < headerLength || size > limit-position {
+		return 0, 0, "", newError("mp4box.bad_box", boxType, position, size)
+	}
+	return size, headerLength, boxType, nil
+}
+
+// free box 头：32位大小固定为1，实际大小写在其后的64位字段，附加数据再大头长度也不变
+func mp4BoxHeader(size int64) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, 1)
+	buf = append(buf, MP4_FREE_BOX_TYPE...)
+	return binary.BigEndian.AppendUint64(buf, uint64(size))
+}
@@ -0,0 +1,26 @@
+package main
+
+import "os"
+
+// 读取已是合并文件的视频输入的尾部元数据，普通视频返回 nil
+func nestedTrailerOf(videoPath string) *TrailerInfo {
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	debugInfo := &DebugInfo{
+		FileSize:      info.Size(),
+		CalculatedPos: make(map[string]int64),
+	}
+	trailer, err := loadTrailer(file, info.Size(), stealthKey, debugInfo)
+	if err != nil {
+		return nil
+	}
+	return trailer
+}
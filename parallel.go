@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// 另一个并行任务已失败，本任务随之中止
+var errSiblingFailed = newError("parallel.aborted")
+
+// sharedProgress 多个并行复制共用一个进度，避免多个进度条在终端上互相覆盖
+type sharedProgress struct {
+	mu       sync.Mutex
+	progress Progress
+	// 任一任务失败后，其余任务在下一次读取时中止
+	failed atomic.Bool
+}
+
+func newSharedProgress(total int64, progress Progress) *sharedProgress {
+	p := &sharedProgress{progress: progress}
+	p.progress.Start(total)
+	return p
+}
+
+// 单个任务的进度，只累加到共用进度，开始和完成由 runParallel 统一上报
+func (p *sharedProgress) task() Progress {
+	return sharedTask{shared: p}
+}
+
+// 包装读取器，另一个任务失败后中止读取
+func (p *sharedProgress) wrap(src io.Reader) io.Reader {
+	return &sharedReader{src: src, progress: p}
+}
+
+func (p *sharedProgress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Add(n)
+}
+
+func (p *sharedProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Done()
+}
+
+// sharedTask 计入共用进度的单个复制
+type sharedTask struct {
+	shared *sharedProgress
+}
+
+func (sharedTask) Start(int64)   {}
+func (t sharedTask) Add(n int64) { t.shared.add(n) }
+func (sharedTask) Done()         {}
+
+// sharedReader 并行任务的读取器
+type sharedReader struct {
+	src      io.Reader
+	progress *sharedProgress
+}
+
+func (r *sharedReader) Read(p []byte) (int, error) {
+	if r.progress.failed.Load() {
+		return 0, errSiblingFailed
+	}
+	return r.src.Read(p)
+}
+
+// 并行执行各任务，各任务的复制通过传入的进度来源计入同一个进度；一个任务失败时其余任务尽快中止。
+// 返回首个出错任务的错误（不返回因此被中止的任务的错误）
+func runParallel(source progressSource, total int64, desc string, tasks ...func(progressSource) error) error {
+	progress := newSharedProgress(total, source.forCopy(desc))
+	taskSource := progressSource{shared: progress}
+
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = task(taskSource); errs[i] != nil {
+				progress.failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errSiblingFailed) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	progress.finish()
+	return nil
+}
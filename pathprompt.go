@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// 交互模式的路径输入：终端下按 Tab 补全路径（有多个候选时再按一次列出），
+// 输入 ls [目录] 列出目录内容，以 * 结尾时按通配符查找，输入 b 打开文件浏览器（browser.go）。
+// 拖入、粘贴路径的用法不变
+const (
+	// 列出目录或候选项时最多显示的条目数
+	PATH_LIST_LIMIT = 100
+	// Ctrl-C 在原始模式下作为普通字节读入
+	KEY_CTRL_C = 0x03
+)
+
+// 读取一个文件路径，处理 ls、通配和文件浏览器后返回输入（仍需 parseDroppedPath 解析）
+func readPathInput(prompt string) string {
+	fmt.Fprintln(messageOutput, msg("browser.hint"))
+	for {
+		input, ok := expandPathInput(readPathLine(prompt))
+		if !ok {
+			continue
+		}
+		if isBrowseCommand(input) {
+			if _, err := os.Stat(parseDroppedPath(input)); err != nil {
+				if path, ok := browseFiles(); ok {
+					colorGreen.Printf(msg("browser.selected"), path)
+					return escapeDroppedPath(path)
+				}
+				continue
+			}
+		}
+		return input
+	}
+}
+
+// 处理 ls 和 * 通配；ok 为 false 时已显示列表或提示，需要重新输入。
+// 输入本身是存在的路径时原样返回（如名为 ls 的文件）
+func expandPathInput(input string) (string, bool) {
+	if input == "" {
+		return input, true
+	}
+	path := parseDroppedPath(input)
+	if _, err := os.Stat(path); err == nil {
+		return input, true
+	}
+
+	if input == "ls" || strings.HasPrefix(input, "ls ") {
+		dir := parseDroppedPath(strings.TrimPrefix(input, "ls"))
+		if dir == "" {
+			dir = "."
+		}
+		listDirectory(dir)
+		return "", false
+	}
+
+	if !strings.HasSuffix(path, "*") {
+		return input, true
+	}
+	matches, err := filepath.Glob(path)
+	switch {
+	case err != nil:
+		colorYellow.Printf(msg("pathprompt.bad_pattern"), path, err)
+	case len(matches) == 0:
+		colorYellow.Printf(msg("pathprompt.no_match"), path)
+	case len(matches) == 1:
+		colorGreen.Printf(msg("pathprompt.matched"), matches[0])
+		return escapeDroppedPath(matches[0]), true
+	default:
+		colorCyan.Printf(msg("pathprompt.many_matches"), len(matches))
+		printPathList(matches)
+	}
+	return "", false
+}
+
+// 列出目录内容，子目录名后加分隔符
+func listDirectory(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		colorRed.Printf(msg("pathprompt.list_failed"), dir, err)
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		names = append(names, name)
+	}
+	colorCyan.Printf(msg("pathprompt.listing"), dir, len(names))
+	printPathList(names)
+}
+
+func printPathList(names []string) {
+	fmt.Fprint(messageOutput, formatPathList(names))
+}
+
+// 每行一个条目，超出 PATH_LIST_LIMIT 时只显示剩余数量
+func formatPathList(names []string) string {
+	var builder strings.Builder
+	for i, name := range names {
+		if i == PATH_LIST_LIMIT {
+			builder.WriteString(msgf("pathprompt.more", len(names)-i))
+			break
+		}
+		fmt.Fprintf(&builder, "   %s\n", name)
+	}
+	return builder.String()
+}
+
+// 转义路径，使 parseDroppedPath 解析后得到原路径：
+// Windows 下含空格等字符时加双引号，其他系统按终端拖入的习惯用反斜杠转义
+func escapeDroppedPath(path string) string {
+	if runtime.GOOS == "windows" {
+		if strings.ContainsAny(path, " &'") {
+			return `"` + path + `"`
+		}
+		return path
+	}
+	var builder strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(" \t'\"\\()[]{}&;|<>*?$!#`", r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// 按 Tab 补全：在已输入路径所在的目录中查找以最后一段开头的条目，
+// 补全到所有候选项的公共前缀；返回补全后的输入和全部候选项（只有一项时为 nil）
+func completePath(line string) (string, []string) {
+	prefix := parseDroppedPath(line)
+	dir, base := filepath.Split(prefix)
+	lookupDir := dir
+	if lookupDir == "" {
+		lookupDir = "."
+	}
+	entries, err := os.ReadDir(lookupDir)
+	if err != nil {
+		return line, nil
+	}
+
+	// 文件名不区分大小写的系统上前缀也不区分大小写
+	foldCase := runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if len(name) < len(base) {
+			continue
+		}
+		if foldCase && !strings.EqualFold(name[:len(base)], base) || !foldCase && !strings.HasPrefix(name, base) {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, name)
+	}
+	if len(matches) == 0 {
+		return line, nil
+	}
+
+	common := matches[0]
+	for _, name := range matches[1:] {
+		common = commonPrefix(common, name, foldCase)
+	}
+	completed := escapeDroppedPath(dir + common)
+	if len(matches) == 1 {
+		return completed, nil
+	}
+	if len(dir+common) <= len(prefix) {
+		// 没有更长的公共前缀，保留原输入
+		completed = line
+	}
+	return completed, matches
+}
+
+// 两个名称的公共前缀，不截断多字节字符
+func commonPrefix(a, b string, foldCase bool) string {
+	i := 0
+	for i < len(a) && i < len(b) {
+		ra, size := utf8.DecodeRuneInString(a[i:])
+		rb, _ := utf8.DecodeRuneInString(b[i:])
+		if ra != rb && !(foldCase && strings.EqualFold(string(ra), string(rb))) {
+			break
+		}
+		i += size
+	}
+	return a[:i]
+}
+
+// 读取一行路径输入；标准输入是终端时（Windows 除外）用支持 Tab 补全的行编辑器，
+// 否则与其他提示相同
+func readPathLine(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	// 之前被打断的读取仍在等待标准输入时不能再直接读取
+	if runtime.GOOS == "windows" || stdinPending || !term.IsTerminal(fd) {
+		return readUserInput(prompt)
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return readUserInput(prompt)
+	}
+	// 记下终端状态，中断退出时恢复
+	savedTerminalState.Store(state)
+	defer func() {
+		term.Restore(fd, state)
+		savedTerminalState.Store(nil)
+	}()
+
+	keys := &interruptKeyReader{reader: os.Stdin}
+	editor := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{keys, promptOutput()}, colorBlue.Sprint(prompt))
+	if width, height, err := term.GetSize(fd); err == nil {
+		editor.SetSize(width, height)
+	}
+	editor.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' || pos != len(line) {
+			return "", 0, false
+		}
+		completed, candidates := completePath(line)
+		if completed == line && len(candidates) > 1 {
+			// 回调时编辑器未加锁，Write 会在列表后重新显示提示和已输入的内容
+			fmt.Fprint(editor, formatPathList(candidates))
+		}
+		return completed, len(completed), completed != line
+	}
+
+	line, err := editor.ReadLine()
+	if keys.interrupted {
+		term.Restore(fd, state)
+		fmt.Fprintln(promptOutput())
+		if menuActionActive.Load() {
+			panic(errPromptInterrupted)
+		}
+		exitInterrupted()
+	}
+	if err != nil {
+		fmt.Fprintln(promptOutput())
+		return ""
+	}
+	return strings.TrimSpace(line)
+}
+
+// interruptKeyReader 记下原始模式下读到的 Ctrl-C（行编辑器把它和 Ctrl-D 一样当作输入结束）
+type interruptKeyReader struct {
+	reader      io.Reader
+	interrupted bool
+}
+
+func (r *interruptKeyReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	for _, b := range p[:n] {
+		if b == KEY_CTRL_C {
+			r.interrupted = true
+		}
+	}
+	return n, err
+}
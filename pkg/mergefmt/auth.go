@@ -0,0 +1,64 @@
+package mergefmt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// 尾部元数据末尾的固定字段长度：[扩展块长度(4字节)] + [视频大小(8字节)] + [附加数据大小(8字节)] + [魔术字节(8字节)]
+const TRAILER_TAIL_LENGTH = UINT32_LENGTH + SIZE_LENGTH*2 + MAGIC_LENGTH
+
+var (
+	// 尾部元数据没有认证记录，或认证记录不是扩展块的最后一条
+	ErrAuthMissing = errors.New("mergefmt: no authentication record")
+	// 认证值与密钥计算结果不符：元数据被改动，或密钥不正确
+	ErrAuthMismatch = errors.New("mergefmt: authentication mismatch")
+)
+
+// ComputeAuth 计算尾部元数据的认证值。metadata 为从 [文件名长度] 到 [魔术字节] 的完整尾部元数据，
+// 认证记录是扩展块的最后一条，计算时其内容视为全零。元数据中含视频和附加数据的 SHA-256，
+// 因此认证值同时覆盖各字段和两段数据
+func ComputeAuth(metadata, key []byte) []byte {
+	end := len(metadata) - TRAILER_TAIL_LENGTH
+	start := end - AUTH_TAG_LENGTH
+	mac := hmac.New(sha256.New, key)
+	mac.Write(metadata[:start])
+	mac.Write(make([]byte, AUTH_TAG_LENGTH))
+	mac.Write(metadata[end:])
+	return mac.Sum(nil)
+}
+
+// VerifyAuth 以 key 校验尾部元数据的认证值
+func VerifyAuth(metadata, key []byte) error {
+	tag, ok := authTagOf(metadata)
+	if !ok {
+		return ErrAuthMissing
+	}
+	if !hmac.Equal(tag, ComputeAuth(metadata, key)) {
+		return ErrAuthMismatch
+	}
+	return nil
+}
+
+// 取出扩展块最后一条记录中的认证值，最后一条不是长度正确的认证记录时返回 false
+func authTagOf(metadata []byte) ([]byte, bool) {
+	if len(metadata) < TRAILER_TAIL_LENGTH {
+		return nil, false
+	}
+	end := len(metadata) - TRAILER_TAIL_LENGTH
+	extLength := int(binary.LittleEndian.Uint32(metadata[end : end+UINT32_LENGTH]))
+	if extLength > end {
+		return nil, false
+	}
+	records, err := ParseExtensionRecords(metadata[end-extLength : end])
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+	last := records[len(records)-1]
+	if last.Tag != EXT_TAG_AUTH || len(last.Value) != AUTH_TAG_LENGTH {
+		return nil, false
+	}
+	return last.Value, true
+}
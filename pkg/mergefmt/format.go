@@ -0,0 +1,379 @@
+// Package mergefmt 读写 v3 合并文件格式：视频数据 + 对齐填充 + 附加数据 + 尾部元数据。
+//
+// 尾部元数据格式：[文件名长度(4字节)] + [文件名] + [扩展块] + [扩展块长度(4字节)] +
+// [视频大小(8字节)] + [附加数据大小(8字节)] + [魔术字节(8字节)]，所有整数均为小端序。
+// 扩展块可选：[MEXT(4字节)] + 若干条 [标签(2字节)] + [长度(4字节)] + [内容]；未知标签会被跳过。
+//
+// 本包只处理格式本身，不做任何终端输出、提示或进度显示，可在其他程序中直接使用。
+package mergefmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 默认魔术字节
+	MAGIC_BYTES = "MERGEDv3"
+	// 魔术字节长度
+	MAGIC_LENGTH = 8
+	// 大小字段长度
+	SIZE_LENGTH = 8
+	// 长度字段长度
+	UINT32_LENGTH = 4
+	// 最小v3文件大小：两个大小字段 + 魔术字节
+	MIN_FILE_SIZE = SIZE_LENGTH*2 + MAGIC_LENGTH
+	// 元数据中文件名最大长度（字节）
+	MAX_FILENAME_LENGTH = 1024
+
+	// 扩展块标记
+	EXT_MAGIC = "MEXT"
+	// 扩展块标记长度
+	EXT_MAGIC_LENGTH = 4
+	// 扩展记录头长度：标签(2字节) + 长度(4字节)
+	EXT_RECORD_HEADER_LENGTH = 6
+	// 扩展块最大长度
+	MAX_EXT_LENGTH = 1024 * 1024
+
+	// 视频数据SHA-256
+	EXT_TAG_VIDEO_SHA256 uint16 = 0x0001
+	// 附加文件数据SHA-256
+	EXT_TAG_ATTACH_SHA256 uint16 = 0x0002
+	// 多附加文件列表：[数量(4字节)] + 每个 [文件名长度(4字节)] + [文件名] + [大小(8字节)]
+	EXT_TAG_ATTACHMENTS uint16 = 0x0003
+	// 目录归档（tar）附加文件序号列表：每个序号4字节
+	EXT_TAG_DIR_ARCHIVES uint16 = 0x0004
+	// 附加文件属性：[数量(4字节)] + 每个 [修改时间(8字节,Unix纳秒)] + [权限位(4字节,0表示未记录)]
+	EXT_TAG_FILE_ATTRS uint16 = 0x0005
+	// 格式标志位（4字节）
+	EXT_TAG_FLAGS uint16 = 0x0006
+	// 加密参数
+	EXT_TAG_ENCRYPTION uint16 = 0x0007
+	// 压缩信息
+	EXT_TAG_COMPRESSION uint16 = 0x0008
+	// 原始视频文件名：[文件名长度(4字节)] + [文件名]
+	EXT_TAG_VIDEO_NAME uint16 = 0x0009
+	// 附加文件数据CRC32（Castagnoli，4字节），用于快速校验
+	EXT_TAG_ATTACH_CRC32 uint16 = 0x000A
+	// 附加文件MIME类型
+	EXT_TAG_MIME_TYPES uint16 = 0x000B
+	// 备注（UTF-8，长度由记录头给出，空备注不写入）
+	EXT_TAG_COMMENT uint16 = 0x000C
+	// 视频与附加数据之间的对齐填充长度（8字节），填充为全零
+	EXT_TAG_PADDING uint16 = 0x000D
+	// 构建信息：[工具版本(32字节,UTF-8,不足补零)] + [创建时间(8字节,Unix秒)]
+	EXT_TAG_BUILD_INFO uint16 = 0x000E
+	// ZIP兼容布局：[附加数据前的ZIP本地文件头长度(8字节)] + [附加数据后的ZIP中央目录和目录结束记录长度(8字节)]
+	EXT_TAG_ZIP_LAYOUT uint16 = 0x000F
+	// MP4 box 嵌入：[附加数据前的 free box 头长度(8字节)]，该 box 从视频之后一直延伸到文件末尾
+	EXT_TAG_MP4_BOX uint16 = 0x0010
+	// MKV 附件嵌入：[附加数据前的 Attachments 元素头长度(8字节)] + [附加数据后的 Void 元素头长度(8字节)] +
+	// [被改写的 Segment 大小字段偏移(8字节)] + [该字段的原始字节(Segment 大小未知、未改写时为空)]
+	EXT_TAG_MKV_ATTACHMENT uint16 = 0x0011
+	// 认证值（HMAC-SHA256，32字节）：必须是扩展块的最后一条记录，见 ComputeAuth
+	EXT_TAG_AUTH uint16 = 0x0012
+	// 纠错校验块：[校验块长度(8字节)]，校验块位于附加数据之后、尾部元数据之前，
+	// 保护尾部元数据和附加数据开头，内部格式由写入的工具定义
+	EXT_TAG_FEC uint16 = 0x0013
+
+	// 备注最大长度
+	MAX_COMMENT_LENGTH = 4096
+	// MP4 box 头最长16字节（32位大小为1时其后另有64位大小）
+	MAX_BOX_HEADER_LENGTH = 16
+	// EBML 大小字段最长8字节，Void 元素头为1字节ID加大小字段
+	MAX_EBML_SIZE_LENGTH   = 8
+	MAX_VOID_HEADER_LENGTH = 1 + MAX_EBML_SIZE_LENGTH
+	// 认证值长度
+	AUTH_TAG_LENGTH = 32
+	// 纠错校验块最大长度 (4MB)
+	MAX_FEC_LENGTH = 4 * 1024 * 1024
+	// 最大对齐单位 (1MB)
+	MAX_ALIGNMENT = 1024 * 1024
+	// 构建信息中工具版本字段长度
+	BUILD_VERSION_LENGTH = 32
+	// 构建信息记录长度
+	BUILD_INFO_LENGTH = BUILD_VERSION_LENGTH + SIZE_LENGTH
+
+	// 标志位：附加文件已加密
+	FLAG_ENCRYPTED uint32 = 1 << 0
+	// 标志位：附加文件已压缩
+	FLAG_COMPRESSED uint32 = 1 << 1
+)
+
+// CRC32 使用 Castagnoli 多项式（多数CPU有硬件加速）
+var CRC32C = crc32.MakeTable(crc32.Castagnoli)
+
+// FormatError 格式错误。Code 为稳定的错误代码，Args 为相关数值，调用方可据此本地化
+type FormatError struct {
+	Code string
+	Args []interface{}
+	text string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf(e.text, e.Args...)
+}
+
+func formatError(code, text string, args ...interface{}) error {
+	return &FormatError{Code: code, Args: args, text: text}
+}
+
+// Record 扩展块中的一条记录
+type Record struct {
+	Tag   uint16
+	Value []byte
+}
+
+// 构建扩展块（含末尾长度字段），没有记录时返回空
+func EncodeExtensionBlock(records []Record) []byte {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var block bytes.Buffer
+	block.WriteString(EXT_MAGIC)
+	header := make([]byte, EXT_RECORD_HEADER_LENGTH)
+	for _, record := range records {
+		binary.LittleEndian.PutUint16(header[0:2], record.Tag)
+		binary.LittleEndian.PutUint32(header[2:6], uint32(len(record.Value)))
+		block.Write(header)
+		block.Write(record.Value)
+	}
+
+	return binary.LittleEndian.AppendUint32(block.Bytes(), uint32(block.Len()))
+}
+
+// 解析扩展块内容（不含末尾长度字段）
+func ParseExtensionRecords(block []byte) ([]Record, error) {
+	if len(block) < EXT_MAGIC_LENGTH || string(block[:EXT_MAGIC_LENGTH]) != EXT_MAGIC {
+		return nil, formatError("marker_mismatch", "extension block marker mismatch")
+	}
+
+	var records []Record
+	pos := EXT_MAGIC_LENGTH
+	for pos < len(block) {
+		if len(block)-pos < EXT_RECORD_HEADER_LENGTH {
+			return nil, formatError("record_header_incomplete", "extension record header is incomplete: offset %d", pos)
+		}
+		tag := binary.LittleEndian.Uint16(block[pos : pos+2])
+		length := binary.LittleEndian.Uint32(block[pos+2 : pos+6])
+		pos += EXT_RECORD_HEADER_LENGTH
+		if uint64(length) > uint64(len(block)-pos) {
+			return nil, formatError("record_bad_length", "invalid extension record length: tag %d, length %d", tag, length)
+		}
+		records = append(records, Record{Tag: tag, Value: block[pos : pos+int(length)]})
+		pos += int(length)
+	}
+
+	return records, nil
+}
+
+// ListEntry 多附加文件列表中的一项
+type ListEntry struct {
+	Name string
+	Size uint64
+}
+
+// 编码多附加文件列表
+func EncodeAttachmentList(entries []ListEntry) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(entries)))
+	for _, entry := range entries {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entry.Name)))
+		buf = append(buf, entry.Name...)
+		buf = binary.LittleEndian.AppendUint64(buf, entry.Size)
+	}
+	return buf
+}
+
+// 解析多附加文件列表
+func DecodeAttachmentList(value []byte) ([]ListEntry, error) {
+	if len(value) < UINT32_LENGTH {
+		return nil, formatError("list_incomplete", "attachment list is incomplete")
+	}
+
+	count := binary.LittleEndian.Uint32(value[:UINT32_LENGTH])
+	pos := UINT32_LENGTH
+	entries := make([]ListEntry, 0, min(count, uint32(len(value)/(UINT32_LENGTH+SIZE_LENGTH))))
+	for i := uint32(0); i < count; i++ {
+		if len(value)-pos < UINT32_LENGTH {
+			return nil, formatError("list_entry_incomplete", "attachment list entry %d is incomplete", i+1)
+		}
+		nameLength := binary.LittleEndian.Uint32(value[pos : pos+UINT32_LENGTH])
+		pos += UINT32_LENGTH
+		if nameLength == 0 || nameLength > MAX_FILENAME_LENGTH || uint64(nameLength)+SIZE_LENGTH > uint64(len(value)-pos) {
+			return nil, formatError("list_entry_bad_name_length", "attachment list entry %d has an invalid filename length: %d", i+1, nameLength)
+		}
+		name := string(value[pos : pos+int(nameLength)])
+		pos += int(nameLength)
+		if !utf8.ValidString(name) {
+			return nil, formatError("list_entry_invalid_utf8", "attachment list entry %d filename contains invalid UTF-8", i+1)
+		}
+		size := binary.LittleEndian.Uint64(value[pos : pos+SIZE_LENGTH])
+		pos += SIZE_LENGTH
+
+		entries = append(entries, ListEntry{Name: name, Size: size})
+	}
+
+	if pos != len(value) {
+		return nil, formatError("list_trailing_data", "attachment list has trailing data")
+	}
+
+	return entries, nil
+}
+
+// 编码原始视频文件名
+func EncodeVideoName(name string) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(name)))
+	return append(buf, name...)
+}
+
+// 解析原始视频文件名
+func DecodeVideoName(value []byte) (string, error) {
+	if len(value) < UINT32_LENGTH {
+		return "", formatError("video_name_incomplete", "video filename record is incomplete")
+	}
+
+	nameLength := binary.LittleEndian.Uint32(value[:UINT32_LENGTH])
+	if nameLength == 0 || nameLength > MAX_FILENAME_LENGTH || int(nameLength) != len(value)-UINT32_LENGTH {
+		return "", formatError("video_name_bad_length", "invalid video filename length: %d", nameLength)
+	}
+
+	name := string(value[UINT32_LENGTH:])
+	if !utf8.ValidString(name) {
+		return "", formatError("video_name_invalid_utf8", "video filename contains invalid UTF-8")
+	}
+
+	return name, nil
+}
+
+// 编码构建信息（固定长度，版本过长时截断）
+func EncodeBuildInfo(version string, createdAt time.Time) []byte {
+	buf := make([]byte, BUILD_VERSION_LENGTH, BUILD_INFO_LENGTH)
+	copy(buf, version)
+	return binary.LittleEndian.AppendUint64(buf, uint64(createdAt.Unix()))
+}
+
+// 解析构建信息，返回工具版本和创建时间
+func DecodeBuildInfo(value []byte) (string, time.Time, error) {
+	if len(value) != BUILD_INFO_LENGTH {
+		return "", time.Time{}, formatError("build_bad_length", "invalid build info length: %d", len(value))
+	}
+
+	version := strings.TrimRight(string(value[:BUILD_VERSION_LENGTH]), "\x00")
+	if !utf8.ValidString(version) {
+		version = strings.ToValidUTF8(version, "?")
+	}
+	createdAt := time.Unix(int64(binary.LittleEndian.Uint64(value[BUILD_VERSION_LENGTH:])), 0)
+	return version, createdAt, nil
+}
+
+// 从扩展记录读取对齐填充长度，没有记录时为0
+func PaddingOf(records []Record) (uint64, error) {
+	for _, record := range records {
+		if record.Tag != EXT_TAG_PADDING {
+			continue
+		}
+		if len(record.Value) != SIZE_LENGTH {
+			return 0, formatError("padding_record_bad_length", "invalid padding record length: %d", len(record.Value))
+		}
+		padding := binary.LittleEndian.Uint64(record.Value)
+		if padding >= MAX_ALIGNMENT {
+			return 0, formatError("padding_bad", "invalid padding length: %d", padding)
+		}
+		return padding, nil
+	}
+	return 0, nil
+}
+
+// 从扩展记录读取ZIP兼容布局中附加数据前后ZIP结构的长度，没有记录时均为0
+func ZipLayoutOf(records []Record) (header, directory uint64, err error) {
+	for _, record := range records {
+		if record.Tag != EXT_TAG_ZIP_LAYOUT {
+			continue
+		}
+		if len(record.Value) != SIZE_LENGTH*2 {
+			return 0, 0, formatError("zip_layout_bad_length", "invalid ZIP layout record length: %d", len(record.Value))
+		}
+		header = binary.LittleEndian.Uint64(record.Value[:SIZE_LENGTH])
+		directory = binary.LittleEndian.Uint64(record.Value[SIZE_LENGTH:])
+		if header == 0 || directory == 0 || header > MAX_EXT_LENGTH || directory > MAX_EXT_LENGTH {
+			return 0, 0, formatError("zip_layout_bad", "invalid ZIP layout: header %d, directory %d", header, directory)
+		}
+		return header, directory, nil
+	}
+	return 0, 0, nil
+}
+
+// 从扩展记录读取 MP4 box 嵌入时附加数据前的 box 头长度，没有记录时为0
+func BoxHeaderOf(records []Record) (uint64, error) {
+	for _, record := range records {
+		if record.Tag != EXT_TAG_MP4_BOX {
+			continue
+		}
+		if len(record.Value) != SIZE_LENGTH {
+			return 0, formatError("mp4_box_bad_length", "invalid MP4 box record length: %d", len(record.Value))
+		}
+		header := binary.LittleEndian.Uint64(record.Value)
+		if header == 0 || header > MAX_BOX_HEADER_LENGTH {
+			return 0, formatError("mp4_box_bad", "invalid MP4 box header length: %d", header)
+		}
+		return header, nil
+	}
+	return 0, nil
+}
+
+// 从扩展记录读取纠错校验块长度，没有记录时为0
+func FECLengthOf(records []Record) (uint64, error) {
+	for _, record := range records {
+		if record.Tag != EXT_TAG_FEC {
+			continue
+		}
+		if len(record.Value) != SIZE_LENGTH {
+			return 0, formatError("fec_bad_length", "invalid FEC record length: %d", len(record.Value))
+		}
+		length := binary.LittleEndian.Uint64(record.Value)
+		if length == 0 || length > MAX_FEC_LENGTH {
+			return 0, formatError("fec_bad", "invalid FEC block length: %d", length)
+		}
+		return length, nil
+	}
+	return 0, nil
+}
+
+// 从扩展记录读取 MKV 附件嵌入布局，没有记录时为 nil
+func MKVLayoutOf(records []Record) (*MKVLayout, error) {
+	for _, record := range records {
+		if record.Tag != EXT_TAG_MKV_ATTACHMENT {
+			continue
+		}
+		if len(record.Value) < SIZE_LENGTH*3 || len(record.Value) > SIZE_LENGTH*3+MAX_EBML_SIZE_LENGTH {
+			return nil, formatError("mkv_layout_bad_length", "invalid MKV layout record length: %d", len(record.Value))
+		}
+		layout := &MKVLayout{
+			Header:     binary.LittleEndian.Uint64(record.Value[:SIZE_LENGTH]),
+			Void:       binary.LittleEndian.Uint64(record.Value[SIZE_LENGTH : SIZE_LENGTH*2]),
+			SizeOffset: int64(binary.LittleEndian.Uint64(record.Value[SIZE_LENGTH*2 : SIZE_LENGTH*3])),
+		}
+		if size := record.Value[SIZE_LENGTH*3:]; len(size) > 0 {
+			layout.OriginalSize = bytes.Clone(size)
+		}
+		if layout.Header == 0 || layout.Header > MAX_EXT_LENGTH || layout.Void < 2 || layout.Void > MAX_VOID_HEADER_LENGTH || layout.SizeOffset < 0 {
+			return nil, formatError("mkv_layout_bad", "invalid MKV layout: header %d, void %d, size field at %d", layout.Header, layout.Void, layout.SizeOffset)
+		}
+		return layout, nil
+	}
+	return nil, nil
+}
+
+// 计算对齐到 alignment 的倍数所需的填充长度
+func AlignPadding(size, alignment int64) int64 {
+	if alignment <= 1 {
+		return 0
+	}
+	return (alignment - size%alignment) % alignment
+}
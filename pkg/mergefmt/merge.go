@@ -0,0 +1,96 @@
+package mergefmt
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Metadata 合并时写入尾部的描述信息
+type Metadata struct {
+	// 附加文件名（必填），拆分时用作输出文件名
+	AttachName string
+	// 原始视频文件名，空时不记录
+	VideoName string
+	// 备注，空时不记录
+	Comment string
+	// 工具版本，CreatedAt 非空时随构建信息写入
+	ToolVersion string
+	CreatedAt   *time.Time
+	// 附加数据起始位置对齐单位，0或1为不对齐
+	Align int64
+	// 魔术字节，为空时使用 MAGIC_BYTES
+	Magic string
+}
+
+func (m *Metadata) validate() error {
+	if m.AttachName == "" || len(m.AttachName) > MAX_FILENAME_LENGTH || !utf8.ValidString(m.AttachName) {
+		return formatError("bad_name", "invalid attachment name: %q", m.AttachName)
+	}
+	if len(m.VideoName) > MAX_FILENAME_LENGTH || !utf8.ValidString(m.VideoName) {
+		return formatError("bad_video_name", "invalid video name: %q", m.VideoName)
+	}
+	if len(m.Comment) > MAX_COMMENT_LENGTH || !utf8.ValidString(m.Comment) {
+		return formatError("comment_bad", "invalid comment: length %d", len(m.Comment))
+	}
+	if m.Align < 0 || m.Align > MAX_ALIGNMENT {
+		return formatError("bad_align", "invalid alignment: %d", m.Align)
+	}
+	if m.Magic != "" && len(m.Magic) != MAGIC_LENGTH {
+		return formatError("bad_magic", "magic bytes must be %d bytes: %q", MAGIC_LENGTH, m.Magic)
+	}
+	return nil
+}
+
+// 把视频和附加数据依次写入 out 并追加尾部元数据。
+// 两路数据各只读一遍，SHA-256 和 CRC32 在写入的同时计算；视频不能为空
+func Merge(video io.Reader, attach io.Reader, meta Metadata, out io.Writer) error {
+	if err := meta.validate(); err != nil {
+		return err
+	}
+
+	videoHash := sha256.New()
+	videoSize, err := io.Copy(io.MultiWriter(out, videoHash), video)
+	if err != nil {
+		return err
+	}
+	if videoSize == 0 {
+		return formatError("empty_video", "video data is empty")
+	}
+
+	padding := AlignPadding(videoSize, meta.Align)
+	if padding > 0 {
+		if _, err := out.Write(make([]byte, padding)); err != nil {
+			return err
+		}
+	}
+
+	attachHash := sha256.New()
+	attachCRC := crc32.New(CRC32C)
+	attachSize, err := io.Copy(io.MultiWriter(out, attachHash, attachCRC), attach)
+	if err != nil {
+		return err
+	}
+
+	crc := attachCRC.Sum32()
+	trailer, err := EncodeTrailer(&Trailer{
+		Magic:        meta.Magic,
+		VideoSize:    uint64(videoSize),
+		Padding:      uint64(padding),
+		Attachments:  []Attachment{{Name: meta.AttachName, Size: uint64(attachSize)}},
+		VideoSHA256:  videoHash.Sum(nil),
+		AttachSHA256: attachHash.Sum(nil),
+		AttachCRC32:  &crc,
+		VideoName:    meta.VideoName,
+		Comment:      meta.Comment,
+		ToolVersion:  meta.ToolVersion,
+		CreatedAt:    meta.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(trailer)
+	return err
+}
@@ -0,0 +1,69 @@
+package mergefmt
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// MKVLayout MKV 附件嵌入布局：附加数据是 Segment 内 Attachments 元素中的文件数据，
+// 尾部元数据包在其后的 Void 元素里。合并时 Segment 的大小字段被改写为覆盖到文件末尾，
+// 原始字节记录在此，还原视频时改回
+type MKVLayout struct {
+	// 附加数据之前的 Attachments/AttachedFile/FileData 元素头长度
+	Header uint64 `json:"header"`
+	// 附加数据之后的 Void 元素头长度
+	Void uint64 `json:"void"`
+	// Segment 大小字段在视频中的偏移和原始字节，未改写时 OriginalSize 为空
+	SizeOffset   int64  `json:"segment_size_offset,omitempty"`
+	OriginalSize []byte `json:"segment_size_original,omitempty"`
+}
+
+// 附加数据前的元素头长度，普通文件为0
+func (l *MKVLayout) HeaderLength() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.Header
+}
+
+// 附加数据后的 Void 元素头长度，普通文件为0
+func (l *MKVLayout) VoidLength() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.Void
+}
+
+// 编码为 EXT_TAG_MKV_ATTACHMENT 记录内容
+func (l *MKVLayout) Encode() []byte {
+	buf := binary.LittleEndian.AppendUint64(nil, l.Header)
+	buf = binary.LittleEndian.AppendUint64(buf, l.Void)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(l.SizeOffset))
+	return append(buf, l.OriginalSize...)
+}
+
+// 按原始内容读取视频区域：Segment 大小字段读出的是合并前的字节。
+// 普通文件（l 为 nil）或大小字段未改写时直接返回 r
+func (l *MKVLayout) OriginalVideo(r io.ReaderAt) io.ReaderAt {
+	if l == nil || len(l.OriginalSize) == 0 {
+		return r
+	}
+	return &PatchedReader{R: r, Offset: l.SizeOffset, Data: l.OriginalSize}
+}
+
+// PatchedReader 读取 R，但 [Offset, Offset+len(Data)) 范围内的字节以 Data 代替
+type PatchedReader struct {
+	R      io.ReaderAt
+	Offset int64
+	Data   []byte
+}
+
+func (p *PatchedReader) ReadAt(b []byte, off int64) (int, error) {
+	n, err := p.R.ReadAt(b, off)
+	start := max(p.Offset, off)
+	end := min(p.Offset+int64(len(p.Data)), off+int64(n))
+	if start < end {
+		copy(b[start-off:end-off], p.Data[start-p.Offset:end-p.Offset])
+	}
+	return n, err
+}
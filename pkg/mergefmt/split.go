@@ -0,0 +1,103 @@
+package mergefmt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// 附加数据已加密或压缩，本包无法直接提取原始内容
+var ErrUnsupported = fmt.Errorf("mergefmt: encrypted or compressed attachments: %w", errors.ErrUnsupported)
+
+// Archive 已解析的合并文件，按区域读取原始数据
+type Archive struct {
+	r       io.ReaderAt
+	Trailer *Trailer
+}
+
+// 解析合并文件尾部，不读取视频和附加数据
+func Open(r io.ReaderAt, size int64, opts ...Option) (*Archive, error) {
+	trailer, err := DecodeTrailer(r, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{r: r, Trailer: trailer}, nil
+}
+
+// 视频数据（MKV 附件嵌入时按合并前的原始内容读取）
+func (a *Archive) Video() *io.SectionReader {
+	return io.NewSectionReader(a.Trailer.MKV.OriginalVideo(a.r), 0, int64(a.Trailer.VideoSize))
+}
+
+// 全部附加数据（多个附加文件首尾相连）
+func (a *Archive) Attachments() *io.SectionReader {
+	return io.NewSectionReader(a.r, a.Trailer.AttachStart(), int64(a.Trailer.AttachSize))
+}
+
+// 第 i 个附加文件的存储数据，已加密或压缩时为处理后的数据
+func (a *Archive) Attachment(i int) *io.SectionReader {
+	attachment := a.Trailer.Attachments[i]
+	return io.NewSectionReader(a.r, attachment.Offset, int64(attachment.Size))
+}
+
+// 附加数据是否需要先解密或解压
+func (a *Archive) Encoded() bool {
+	return a.Trailer.Flags&(FLAG_ENCRYPTED|FLAG_COMPRESSED) != 0
+}
+
+// 把视频和全部附加数据分别写入 video 和 attach（为 nil 时跳过），同时按尾部记录校验。
+// 附加数据已加密或压缩时返回 ErrUnsupported，不写入任何数据
+func Split(r io.ReaderAt, size int64, video io.Writer, attach io.Writer, opts ...Option) (*Trailer, error) {
+	archive, err := Open(r, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if attach != nil && archive.Encoded() {
+		return archive.Trailer, ErrUnsupported
+	}
+
+	trailer := archive.Trailer
+	if video != nil {
+		videoHash := sha256.New()
+		if err := copyVerified(video, archive.Video(), videoHash); err != nil {
+			return trailer, err
+		}
+		if trailer.VideoSHA256 != nil && !bytes.Equal(videoHash.Sum(nil), trailer.VideoSHA256) {
+			return trailer, fmt.Errorf("video: %w", ErrChecksumMismatch)
+		}
+	}
+	if attach != nil {
+		attachHash := sha256.New()
+		attachCRC := crc32.New(CRC32C)
+		if err := copyVerified(attach, archive.Attachments(), attachHash, attachCRC); err != nil {
+			return trailer, err
+		}
+		if trailer.AttachSHA256 != nil && !bytes.Equal(attachHash.Sum(nil), trailer.AttachSHA256) {
+			return trailer, fmt.Errorf("attachment: %w", ErrChecksumMismatch)
+		}
+		if trailer.AttachCRC32 != nil && attachCRC.Sum32() != *trailer.AttachCRC32 {
+			return trailer, fmt.Errorf("attachment: %w", ErrChecksumMismatch)
+		}
+	}
+	return trailer, nil
+}
+
+// 复制一个区域并同时计算校验值
+func copyVerified(dst io.Writer, src *io.SectionReader, hashes ...hash.Hash) error {
+	writers := []io.Writer{dst}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), src)
+	if err != nil {
+		return err
+	}
+	if n != src.Size() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
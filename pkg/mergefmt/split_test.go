@@ -0,0 +1,132 @@
+package mergefmt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// 在内存中合并，返回合并结果
+func mergeBytes(t *testing.T, video, attach []byte, meta Metadata) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Merge(bytes.NewReader(video), bytes.NewReader(attach), meta, &out); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestMergeSplitRoundTrip(t *testing.T) {
+	video := bytes.Repeat([]byte("video frame "), 1000)
+	attach := bytes.Repeat([]byte("attachment "), 333)
+	for _, align := range []int64{0, 1, 4096} {
+		merged := mergeBytes(t, video, attach, Metadata{AttachName: "附件.txt", VideoName: "v.mkv", Comment: "c", Align: align})
+		if !bytes.HasPrefix(merged, video) {
+			t.Fatalf("align %d: merged file does not start with the video", align)
+		}
+
+		var videoOut, attachOut bytes.Buffer
+		trailer, err := Split(bytes.NewReader(merged), int64(len(merged)), &videoOut, &attachOut)
+		if err != nil {
+			t.Fatalf("align %d: Split: %v", align, err)
+		}
+		if !bytes.Equal(videoOut.Bytes(), video) || !bytes.Equal(attachOut.Bytes(), attach) {
+			t.Errorf("align %d: split data differs from the input", align)
+		}
+		if trailer.Name != "附件.txt" || trailer.VideoName != "v.mkv" || trailer.Comment != "c" {
+			t.Errorf("align %d: trailer = %q/%q/%q", align, trailer.Name, trailer.VideoName, trailer.Comment)
+		}
+		if align > 1 && trailer.AttachStart()%align != 0 {
+			t.Errorf("align %d: attachment starts at %d", align, trailer.AttachStart())
+		}
+	}
+}
+
+func TestSplitChecksumMismatch(t *testing.T) {
+	video := []byte("some video bytes")
+	attach := []byte("some attachment bytes")
+	merged := mergeBytes(t, video, attach, Metadata{AttachName: "a.bin"})
+
+	for _, offset := range []int{0, len(video)} {
+		corrupt := bytes.Clone(merged)
+		corrupt[offset] ^= 0xff
+		var videoOut, attachOut bytes.Buffer
+		if _, err := Split(bytes.NewReader(corrupt), int64(len(corrupt)), &videoOut, &attachOut); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("byte %d flipped: error = %v, want ErrChecksumMismatch", offset, err)
+		}
+	}
+}
+
+func TestSplitEncodedUnsupported(t *testing.T) {
+	file := buildFile(t, []byte("video"), [][]byte{[]byte("encrypted")}, &Trailer{
+		VideoSize:   5,
+		Attachments: []Attachment{{Name: "a.bin", Size: 9}},
+		Flags:       FLAG_ENCRYPTED,
+	})
+	var attachOut bytes.Buffer
+	if _, err := Split(bytes.NewReader(file), int64(len(file)), nil, &attachOut); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("error = %v, want ErrUnsupported", err)
+	}
+	if attachOut.Len() != 0 {
+		t.Errorf("wrote %d bytes for an encrypted attachment", attachOut.Len())
+	}
+	// 只提取视频时不受影响
+	var videoOut bytes.Buffer
+	if _, err := Split(bytes.NewReader(file), int64(len(file)), &videoOut, nil); err != nil || videoOut.String() != "video" {
+		t.Errorf("video only: %q, %v", videoOut.String(), err)
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	file := buildFile(t, []byte("video"), [][]byte{[]byte("one"), []byte("two!")}, &Trailer{
+		VideoSize:   5,
+		Attachments: []Attachment{{Name: "1.txt", Size: 3}, {Name: "2.txt", Size: 4}},
+	})
+	archive, err := Open(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if archive.Encoded() {
+		t.Error("Encoded() = true for a plain archive")
+	}
+	read := func(section *io.SectionReader) string {
+		data, err := io.ReadAll(section)
+		if err != nil {
+			t.Fatalf("read section: %v", err)
+		}
+		return string(data)
+	}
+	if got := read(archive.Video()); got != "video" {
+		t.Errorf("Video() = %q", got)
+	}
+	if got := read(archive.Attachments()); got != "onetwo!" {
+		t.Errorf("Attachments() = %q", got)
+	}
+	if got := read(archive.Attachment(1)); got != "two!" {
+		t.Errorf("Attachment(1) = %q", got)
+	}
+}
+
+func TestMergeRejectsBadMetadata(t *testing.T) {
+	tests := []struct {
+		meta Metadata
+		code string
+	}{
+		{Metadata{}, "bad_name"},
+		{Metadata{AttachName: "a", Align: -1}, "bad_align"},
+		{Metadata{AttachName: "a", Magic: "short"}, "bad_magic"},
+	}
+	for _, tt := range tests {
+		err := Merge(bytes.NewReader([]byte("v")), bytes.NewReader(nil), tt.meta, &bytes.Buffer{})
+		var formatErr *FormatError
+		if !errors.As(err, &formatErr) || formatErr.Code != tt.code {
+			t.Errorf("Merge(%+v) error = %v, want code %q", tt.meta, err, tt.code)
+		}
+	}
+	err := Merge(bytes.NewReader(nil), bytes.NewReader([]byte("a")), Metadata{AttachName: "a"}, &bytes.Buffer{})
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) || formatErr.Code != "empty_video" {
+		t.Errorf("empty video: error = %v, want code empty_video", err)
+	}
+}
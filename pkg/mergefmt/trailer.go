@@ -0,0 +1,381 @@
+package mergefmt
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// 末尾没有魔术字节或文件过小，不是合并文件
+	ErrNotMerged = errors.New("mergefmt: not a merged file")
+	// 提取的数据与记录的校验值不符
+	ErrChecksumMismatch = errors.New("mergefmt: checksum mismatch")
+)
+
+// Attachment 单个附加文件在合并文件中的位置，Size 为存储的数据大小
+type Attachment struct {
+	Name   string
+	Size   uint64
+	Offset int64
+}
+
+// Trailer 尾部元数据，编码和解析共用
+type Trailer struct {
+	// 魔术字节，为空时使用 MAGIC_BYTES
+	Magic       string
+	VideoSize   uint64
+	Padding     uint64
+	AttachSize  uint64
+	Attachments []Attachment
+
+	// ZIP兼容布局中附加数据之前的本地文件头、之后的中央目录和目录结束记录长度，普通文件为0
+	ZipHeader    uint64
+	ZipDirectory uint64
+
+	// MP4 box 嵌入时附加数据之前的 free box 头长度，普通文件为0
+	BoxHeader uint64
+
+	// MKV 附件嵌入布局，普通文件为 nil
+	MKV *MKVLayout
+
+	// 附加数据与尾部元数据之间的纠错校验块长度，没有时为0
+	FEC uint64
+
+	// 校验值，nil 表示未记录（旧版文件）
+	VideoSHA256  []byte
+	AttachSHA256 []byte
+	AttachCRC32  *uint32
+
+	// 编码时非空则以此密钥在扩展块末尾写入认证值；解析得到的认证值放在 Auth，没有时为 nil
+	AuthKey []byte
+	Auth    []byte
+
+	VideoName   string
+	Comment     string
+	ToolVersion string
+	CreatedAt   *time.Time
+	Flags       uint32
+
+	// 本包不解析的扩展记录（目录归档、文件属性、MIME类型、加密、压缩等），
+	// 编码时按原顺序写在已知记录之后、标志位之前
+	Records []Record
+
+	// 解析得到的扩展块长度（不含末尾长度字段），没有扩展块时为0
+	ExtLength uint32
+	// 解析得到的基础字段文件名，编码时忽略（写入第一个附加文件名）
+	Name string
+}
+
+// 附加数据区起始位置（视频之后，跳过对齐填充、ZIP本地文件头、MP4 box 头和 MKV 元素头）
+func (t *Trailer) AttachStart() int64 {
+	return int64(t.VideoSize + t.Padding + t.ZipHeader + t.BoxHeader + t.MKV.HeaderLength())
+}
+
+// 尾部元数据起始位置（附加数据之后，跳过ZIP中央目录、MKV Void 元素头和纠错校验块）
+func (t *Trailer) MetadataStart() int64 {
+	return t.AttachStart() + int64(t.AttachSize+t.ZipDirectory+t.MKV.VoidLength()+t.FEC)
+}
+
+func (t *Trailer) magic() string {
+	if t.Magic == "" {
+		return MAGIC_BYTES
+	}
+	return t.Magic
+}
+
+// Option 解析选项
+type Option func(*options)
+
+type options struct {
+	magic string
+}
+
+// 使用自定义魔术字节（必须为 MAGIC_LENGTH 字节）
+func WithMagic(magic string) Option {
+	return func(o *options) { o.magic = magic }
+}
+
+func applyOptions(opts []Option) options {
+	o := options{magic: MAGIC_BYTES}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// 生成完整的尾部元数据。Attachments 至少一项，第一项的文件名写入基础字段，多于一项时写入列表记录
+func EncodeTrailer(t *Trailer) ([]byte, error) {
+	if len(t.Attachments) == 0 {
+		return nil, formatError("no_attachments", "at least one attachment is required")
+	}
+	if len(t.magic()) != MAGIC_LENGTH {
+		return nil, formatError("bad_magic", "magic bytes must be %d bytes: %q", MAGIC_LENGTH, t.magic())
+	}
+	for _, attachment := range t.Attachments {
+		if attachment.Name == "" || len(attachment.Name) > MAX_FILENAME_LENGTH || !utf8.ValidString(attachment.Name) {
+			return nil, formatError("bad_name", "invalid attachment name: %q", attachment.Name)
+		}
+	}
+
+	attachName := t.Attachments[0].Name
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(attachName)))
+	buf = append(buf, attachName...)
+
+	var records []Record
+	if t.VideoSHA256 != nil {
+		records = append(records, Record{Tag: EXT_TAG_VIDEO_SHA256, Value: t.VideoSHA256})
+	}
+	if t.AttachSHA256 != nil {
+		records = append(records, Record{Tag: EXT_TAG_ATTACH_SHA256, Value: t.AttachSHA256})
+	}
+	if t.AttachCRC32 != nil {
+		records = append(records, Record{Tag: EXT_TAG_ATTACH_CRC32, Value: binary.LittleEndian.AppendUint32(nil, *t.AttachCRC32)})
+	}
+	if t.VideoName != "" {
+		records = append(records, Record{Tag: EXT_TAG_VIDEO_NAME, Value: EncodeVideoName(t.VideoName)})
+	}
+	if t.Comment != "" {
+		records = append(records, Record{Tag: EXT_TAG_COMMENT, Value: []byte(t.Comment)})
+	}
+	if t.CreatedAt != nil {
+		records = append(records, Record{Tag: EXT_TAG_BUILD_INFO, Value: EncodeBuildInfo(t.ToolVersion, *t.CreatedAt)})
+	}
+	if t.Padding > 0 {
+		records = append(records, Record{Tag: EXT_TAG_PADDING, Value: binary.LittleEndian.AppendUint64(nil, t.Padding)})
+	}
+	if t.ZipHeader > 0 {
+		value := binary.LittleEndian.AppendUint64(nil, t.ZipHeader)
+		records = append(records, Record{Tag: EXT_TAG_ZIP_LAYOUT, Value: binary.LittleEndian.AppendUint64(value, t.ZipDirectory)})
+	}
+	if t.BoxHeader > 0 {
+		records = append(records, Record{Tag: EXT_TAG_MP4_BOX, Value: binary.LittleEndian.AppendUint64(nil, t.BoxHeader)})
+	}
+	if t.MKV != nil {
+		records = append(records, Record{Tag: EXT_TAG_MKV_ATTACHMENT, Value: t.MKV.Encode()})
+	}
+	if t.FEC > 0 {
+		records = append(records, Record{Tag: EXT_TAG_FEC, Value: binary.LittleEndian.AppendUint64(nil, t.FEC)})
+	}
+	if len(t.Attachments) > 1 {
+		list := make([]ListEntry, len(t.Attachments))
+		for i, attachment := range t.Attachments {
+			list[i] = ListEntry{Name: attachment.Name, Size: attachment.Size}
+		}
+		records = append(records, Record{Tag: EXT_TAG_ATTACHMENTS, Value: EncodeAttachmentList(list)})
+	}
+	records = append(records, t.Records...)
+	if t.Flags != 0 {
+		records = append(records, Record{Tag: EXT_TAG_FLAGS, Value: binary.LittleEndian.AppendUint32(nil, t.Flags)})
+	}
+	// 认证记录先以全零占位，整段元数据生成后再填入
+	if t.AuthKey != nil {
+		records = append(records, Record{Tag: EXT_TAG_AUTH, Value: make([]byte, AUTH_TAG_LENGTH)})
+	}
+	buf = append(buf, EncodeExtensionBlock(records)...)
+
+	var attachSize uint64
+	for _, attachment := range t.Attachments {
+		attachSize += attachment.Size
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, t.VideoSize)
+	buf = binary.LittleEndian.AppendUint64(buf, attachSize)
+	buf = append(buf, t.magic()...)
+	if t.AuthKey != nil {
+		copy(buf[len(buf)-TRAILER_TAIL_LENGTH-AUTH_TAG_LENGTH:], ComputeAuth(buf, t.AuthKey))
+	}
+	return buf, nil
+}
+
+// 从指定偏移读满 buf，数据不足时返回 io.ErrUnexpectedEOF
+func readAt(r io.ReaderAt, buf []byte, offset int64) error {
+	_, err := io.ReadFull(io.NewSectionReader(r, offset, int64(len(buf))), buf)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// 解析尾部元数据（固定位置读取，不读取视频和附加数据）
+func DecodeTrailer(r io.ReaderAt, size int64, opts ...Option) (*Trailer, error) {
+	o := applyOptions(opts)
+	if size < MIN_FILE_SIZE {
+		return nil, ErrNotMerged
+	}
+
+	// 魔术字节和两个大小字段
+	fixed := make([]byte, SIZE_LENGTH*2+MAGIC_LENGTH)
+	if err := readAt(r, fixed, size-int64(len(fixed))); err != nil {
+		return nil, err
+	}
+	if string(fixed[SIZE_LENGTH*2:]) != o.magic {
+		return nil, ErrNotMerged
+	}
+	t := &Trailer{
+		Magic:      o.magic,
+		VideoSize:  binary.LittleEndian.Uint64(fixed[:SIZE_LENGTH]),
+		AttachSize: binary.LittleEndian.Uint64(fixed[SIZE_LENGTH : SIZE_LENGTH*2]),
+	}
+	if t.VideoSize == 0 || t.VideoSize >= uint64(size) {
+		return nil, formatError("bad_video_size", "invalid video size: %d", t.VideoSize)
+	}
+	if t.AttachSize >= uint64(size) {
+		return nil, formatError("bad_attach_size", "invalid attachment size: %d", t.AttachSize)
+	}
+
+	// 可选扩展块
+	records, extLength := readExtensionBlock(r, size)
+	padding, err := PaddingOf(records)
+	if err != nil {
+		return nil, err
+	}
+	t.Padding = padding
+	if t.ZipHeader, t.ZipDirectory, err = ZipLayoutOf(records); err != nil {
+		return nil, err
+	}
+	if t.BoxHeader, err = BoxHeaderOf(records); err != nil {
+		return nil, err
+	}
+	if t.MKV, err = MKVLayoutOf(records); err != nil {
+		return nil, err
+	}
+	if t.FEC, err = FECLengthOf(records); err != nil {
+		return nil, err
+	}
+
+	// 文件名
+	metadataStart := t.MetadataStart()
+	nameLengthBytes := make([]byte, UINT32_LENGTH)
+	if err := readAt(r, nameLengthBytes, metadataStart); err != nil {
+		return nil, err
+	}
+	nameLength := binary.LittleEndian.Uint32(nameLengthBytes)
+	if nameLength == 0 || nameLength > MAX_FILENAME_LENGTH {
+		return nil, formatError("bad_name_length", "invalid filename length: %d", nameLength)
+	}
+	name := make([]byte, nameLength)
+	if err := readAt(r, name, metadataStart+UINT32_LENGTH); err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(name) {
+		return nil, formatError("invalid_name", "filename contains invalid UTF-8")
+	}
+
+	// 总体结构
+	expected := uint64(t.AttachStart()) + t.AttachSize + t.ZipDirectory + t.MKV.VoidLength() + t.FEC + UINT32_LENGTH + uint64(nameLength) + SIZE_LENGTH*2 + MAGIC_LENGTH
+	if records != nil {
+		// 文件名末尾字节恰好形似扩展块时，以无扩展块的原结构为准
+		if t.Padding == 0 && t.ZipHeader == 0 && t.BoxHeader == 0 && t.MKV == nil && t.FEC == 0 && expected == uint64(size) {
+			records, extLength = nil, 0
+		} else {
+			expected += uint64(extLength) + UINT32_LENGTH
+		}
+	}
+	if expected != uint64(size) {
+		return nil, formatError("structure_mismatch", "structure mismatch: expected %d bytes, file has %d", int64(expected), size)
+	}
+	t.ExtLength = extLength
+	t.Name = string(name)
+
+	if err := t.applyRecords(records); err != nil {
+		return nil, err
+	}
+	if t.Attachments == nil {
+		t.Attachments = []Attachment{{Name: t.Name, Size: t.AttachSize}}
+	}
+	offset := t.AttachStart()
+	var total uint64
+	for i := range t.Attachments {
+		t.Attachments[i].Offset = offset
+		offset += int64(t.Attachments[i].Size)
+		total += t.Attachments[i].Size
+	}
+	if total != t.AttachSize {
+		return nil, formatError("list_size_mismatch", "attachment sizes add up to %d, expected %d", total, t.AttachSize)
+	}
+	return t, nil
+}
+
+// 尝试读取可选扩展块，不存在或无法解析时返回 nil
+func readExtensionBlock(r io.ReaderAt, size int64) ([]Record, uint32) {
+	extLengthPos := size - int64(MAGIC_LENGTH+SIZE_LENGTH*2+UINT32_LENGTH)
+	if extLengthPos < 0 {
+		return nil, 0
+	}
+	extLengthBytes := make([]byte, UINT32_LENGTH)
+	if err := readAt(r, extLengthBytes, extLengthPos); err != nil {
+		return nil, 0
+	}
+	extLength := binary.LittleEndian.Uint32(extLengthBytes)
+	if extLength < EXT_MAGIC_LENGTH || extLength > MAX_EXT_LENGTH || int64(extLength) > extLengthPos {
+		return nil, 0
+	}
+	block := make([]byte, extLength)
+	if err := readAt(r, block, extLengthPos-int64(extLength)); err != nil {
+		return nil, 0
+	}
+	records, err := ParseExtensionRecords(block)
+	if err != nil {
+		return nil, 0
+	}
+	return records, extLength
+}
+
+// 将扩展记录应用到解析结果，本包不解析的记录保留在 Records 中
+func (t *Trailer) applyRecords(records []Record) error {
+	for _, record := range records {
+		switch record.Tag {
+		case EXT_TAG_VIDEO_SHA256:
+			t.VideoSHA256 = record.Value
+		case EXT_TAG_ATTACH_SHA256:
+			t.AttachSHA256 = record.Value
+		case EXT_TAG_ATTACH_CRC32:
+			if len(record.Value) != UINT32_LENGTH {
+				return formatError("crc_bad_length", "invalid CRC32 record length: %d", len(record.Value))
+			}
+			crc := binary.LittleEndian.Uint32(record.Value)
+			t.AttachCRC32 = &crc
+		case EXT_TAG_ATTACHMENTS:
+			list, err := DecodeAttachmentList(record.Value)
+			if err != nil {
+				return err
+			}
+			t.Attachments = make([]Attachment, len(list))
+			for i, entry := range list {
+				t.Attachments[i] = Attachment{Name: entry.Name, Size: entry.Size}
+			}
+		case EXT_TAG_COMMENT:
+			if len(record.Value) > MAX_COMMENT_LENGTH || !utf8.Valid(record.Value) {
+				return formatError("comment_bad", "invalid comment: length %d", len(record.Value))
+			}
+			t.Comment = string(record.Value)
+		case EXT_TAG_FLAGS:
+			if len(record.Value) != UINT32_LENGTH {
+				return formatError("flags_bad_length", "invalid flags length: %d", len(record.Value))
+			}
+			t.Flags = binary.LittleEndian.Uint32(record.Value)
+		case EXT_TAG_AUTH:
+			t.Auth = record.Value
+		case EXT_TAG_VIDEO_NAME:
+			name, err := DecodeVideoName(record.Value)
+			if err != nil {
+				return err
+			}
+			t.VideoName = name
+		case EXT_TAG_BUILD_INFO:
+			version, createdAt, err := DecodeBuildInfo(record.Value)
+			if err != nil {
+				return err
+			}
+			t.ToolVersion = version
+			t.CreatedAt = &createdAt
+		case EXT_TAG_PADDING, EXT_TAG_ZIP_LAYOUT, EXT_TAG_MP4_BOX, EXT_TAG_MKV_ATTACHMENT, EXT_TAG_FEC:
+			// 填充、ZIP结构、box 头和 MKV 元素头长度决定元数据位置，已在验证文件结构时读取
+		default:
+			t.Records = append(t.Records, record)
+		}
+	}
+	return nil
+}
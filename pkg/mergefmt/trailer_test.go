@@ -0,0 +1,168 @@
+package mergefmt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 拼出 [视频][填充][附加数据][尾部] 的完整文件
+func buildFile(t *testing.T, video []byte, attachments [][]byte, trailer *Trailer) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(video)
+	buf.Write(make([]byte, trailer.Padding))
+	for _, data := range attachments {
+		buf.Write(data)
+	}
+	metadata, err := EncodeTrailer(trailer)
+	if err != nil {
+		t.Fatalf("EncodeTrailer: %v", err)
+	}
+	buf.Write(metadata)
+	return buf.Bytes()
+}
+
+func TestEncodeDecodeTrailer(t *testing.T) {
+	video := bytes.Repeat([]byte("V"), 100)
+	attachments := [][]byte{[]byte("first"), []byte("second attachment")}
+	crc := uint32(0x12345678)
+	createdAt := time.Unix(1700000000, 0)
+	original := &Trailer{
+		VideoSize:    uint64(len(video)),
+		Padding:      uint64(AlignPadding(int64(len(video)), 64)),
+		Attachments:  []Attachment{{Name: "a.txt", Size: 5}, {Name: "文档.pdf", Size: 17}},
+		VideoSHA256:  bytes.Repeat([]byte{1}, 32),
+		AttachSHA256: bytes.Repeat([]byte{2}, 32),
+		AttachCRC32:  &crc,
+		VideoName:    "video.mkv",
+		Comment:      "备注",
+		ToolVersion:  "v1.2.3",
+		CreatedAt:    &createdAt,
+		Flags:        FLAG_COMPRESSED,
+		Records:      []Record{{Tag: EXT_TAG_MIME_TYPES, Value: []byte("opaque")}},
+	}
+	file := buildFile(t, video, attachments, original)
+
+	decoded, err := DecodeTrailer(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("DecodeTrailer: %v", err)
+	}
+	if decoded.VideoSize != 100 || decoded.Padding != 28 || decoded.AttachSize != 22 {
+		t.Errorf("sizes = %d/%d/%d, want 100/28/22", decoded.VideoSize, decoded.Padding, decoded.AttachSize)
+	}
+	if decoded.Name != "a.txt" || decoded.VideoName != "video.mkv" || decoded.Comment != "备注" || decoded.ToolVersion != "v1.2.3" {
+		t.Errorf("names = %q/%q/%q/%q", decoded.Name, decoded.VideoName, decoded.Comment, decoded.ToolVersion)
+	}
+	if decoded.CreatedAt == nil || !decoded.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt = %v, want %v", decoded.CreatedAt, createdAt)
+	}
+	if !bytes.Equal(decoded.VideoSHA256, original.VideoSHA256) || !bytes.Equal(decoded.AttachSHA256, original.AttachSHA256) {
+		t.Error("SHA-256 values not preserved")
+	}
+	if decoded.AttachCRC32 == nil || *decoded.AttachCRC32 != crc {
+		t.Errorf("AttachCRC32 = %v, want %#x", decoded.AttachCRC32, crc)
+	}
+	if decoded.Flags != FLAG_COMPRESSED {
+		t.Errorf("Flags = %#x, want %#x", decoded.Flags, FLAG_COMPRESSED)
+	}
+	if len(decoded.Records) != 1 || decoded.Records[0].Tag != EXT_TAG_MIME_TYPES || string(decoded.Records[0].Value) != "opaque" {
+		t.Errorf("Records = %+v", decoded.Records)
+	}
+	if decoded.MetadataStart() != int64(len(video)+28+22) {
+		t.Errorf("MetadataStart = %d, want %d", decoded.MetadataStart(), len(video)+28+22)
+	}
+
+	want := []Attachment{{Name: "a.txt", Size: 5, Offset: 128}, {Name: "文档.pdf", Size: 17, Offset: 133}}
+	if len(decoded.Attachments) != len(want) {
+		t.Fatalf("Attachments = %+v, want %+v", decoded.Attachments, want)
+	}
+	for i, attachment := range decoded.Attachments {
+		if attachment != want[i] {
+			t.Errorf("Attachments[%d] = %+v, want %+v", i, attachment, want[i])
+		}
+		if got := file[attachment.Offset : attachment.Offset+int64(attachment.Size)]; !bytes.Equal(got, attachments[i]) {
+			t.Errorf("Attachments[%d] data = %q, want %q", i, got, attachments[i])
+		}
+	}
+}
+
+func TestDecodeTrailerNotMerged(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("short"), bytes.Repeat([]byte("plain video "), 20)} {
+		if _, err := DecodeTrailer(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNotMerged) {
+			t.Errorf("DecodeTrailer(%d bytes) error = %v, want ErrNotMerged", len(data), err)
+		}
+	}
+}
+
+func TestDecodeTrailerCustomMagic(t *testing.T) {
+	file := buildFile(t, []byte("video"), [][]byte{[]byte("data")}, &Trailer{
+		Magic:       "SECRETv1",
+		VideoSize:   5,
+		Attachments: []Attachment{{Name: "d.bin", Size: 4}},
+	})
+	if _, err := DecodeTrailer(bytes.NewReader(file), int64(len(file))); !errors.Is(err, ErrNotMerged) {
+		t.Errorf("default magic: error = %v, want ErrNotMerged", err)
+	}
+	decoded, err := DecodeTrailer(bytes.NewReader(file), int64(len(file)), WithMagic("SECRETv1"))
+	if err != nil {
+		t.Fatalf("WithMagic: %v", err)
+	}
+	if decoded.Magic != "SECRETv1" || decoded.Name != "d.bin" {
+		t.Errorf("decoded = %q/%q", decoded.Magic, decoded.Name)
+	}
+}
+
+func TestDecodeTrailerCorrupt(t *testing.T) {
+	// 附加数据第4字节起形似长度为2的文件名，附加数据大小改为4时能解析到文件名但总体结构不符
+	file := buildFile(t, []byte("video data"), [][]byte{[]byte("abcd\x02\x00\x00\x00xy")}, &Trailer{
+		VideoSize:   10,
+		Attachments: []Attachment{{Name: "a.bin", Size: 10}},
+	})
+	tests := []struct {
+		name   string
+		offset int
+		value  []byte
+		code   string
+	}{
+		{"video size", len(file) - 24, []byte{0xff, 0xff, 0xff, 0xff}, "bad_video_size"},
+		{"attach size", len(file) - 16, []byte{0xff, 0xff, 0xff, 0xff}, "bad_attach_size"},
+		{"name length", 20, []byte{0, 0, 0, 0}, "bad_name_length"},
+		{"invalid name", 24, []byte{0xff}, "invalid_name"},
+		{"structure", len(file) - 16, []byte{4}, "structure_mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupt := bytes.Clone(file)
+			copy(corrupt[tt.offset:], tt.value)
+			_, err := DecodeTrailer(bytes.NewReader(corrupt), int64(len(corrupt)))
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) || formatErr.Code != tt.code {
+				t.Errorf("error = %v, want code %q", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestEncodeTrailerAuth(t *testing.T) {
+	key := []byte("secret")
+	metadata, err := EncodeTrailer(&Trailer{
+		VideoSize:   1,
+		Attachments: []Attachment{{Name: "a", Size: 1}},
+		AuthKey:     key,
+	})
+	if err != nil {
+		t.Fatalf("EncodeTrailer: %v", err)
+	}
+	if err := VerifyAuth(metadata, key); err != nil {
+		t.Errorf("VerifyAuth: %v", err)
+	}
+	if err := VerifyAuth(metadata, []byte("wrong")); !errors.Is(err, ErrAuthMismatch) {
+		t.Errorf("wrong key: error = %v, want ErrAuthMismatch", err)
+	}
+	metadata[4] ^= 1
+	if err := VerifyAuth(metadata, key); !errors.Is(err, ErrAuthMismatch) {
+		t.Errorf("tampered: error = %v, want ErrAuthMismatch", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MP4 box 头长度（32位大小+类型），大小为1时其后另有64位大小
+	MP4_BOX_HEADER_LENGTH       = 8
+	MP4_LARGE_BOX_HEADER_LENGTH = 16
+	// 最多检查的顶层 box 数，避免异常文件导致长时间循环
+	MP4_MAX_TOP_LEVEL_BOXES = 4096
+
+	// EBML 头和 MKV Segment 元素ID
+	EBML_HEADER_ID = 0x1A45DFA3
+	MKV_SEGMENT_ID = 0x18538067
+	// EBML 变长整数最长8字节
+	EBML_MAX_VINT_LENGTH = 8
+)
+
+// 合并完成后检查输出中视频数据区的容器结构，只显示提示，不影响合并结果
+func checkPlayable(outputPath string, videoSize int64) {
+	fmt.Fprint(messageOutput, msg("playable.title"))
+
+	// 分卷输出按拼接后的逻辑偏移读取
+	var reader mergedReader
+	volumes, err := openVolumes(outputPath)
+	if err == nil && volumes != nil {
+		reader = volumes
+	} else if err == nil {
+		reader, err = os.Open(outputPath)
+	}
+	if err != nil {
+		logWarnf("playable.read_failed", err)
+		return
+	}
+	defer reader.Close()
+
+	header := make([]byte, MP4_BOX_HEADER_LENGTH)
+	if videoSize < int64(len(header)) {
+		fmt.Fprint(messageOutput, msg("playable.unknown"))
+		return
+	}
+	if _, err := reader.ReadAt(header, 0); err != nil {
+		logWarnf("playable.read_failed", err)
+		return
+	}
+
+	switch {
+	case string(header[4:8]) == "ftyp":
+		err = checkMP4Boxes(reader, videoSize)
+	case binary.BigEndian.Uint32(header) == EBML_HEADER_ID:
+		err = checkMKVSegment(reader, videoSize)
+	default:
+		fmt.Fprint(messageOutput, msg("playable.unknown"))
+	}
+	if err != nil {
+		logWarnf("playable.read_failed", err)
+	}
+}
+
+// 遍历顶层 box，确认各 box 的声明大小都在视频数据区内结束
+func checkMP4Boxes(r io.ReaderAt, videoSize int64) error {
+	var types []string
+	warnings := 0
+	header := make([]byte, MP4_LARGE_BOX_HEADER_LENGTH)
+	position := int64(0)
+	for position < videoSize && len(types) < MP4_MAX_TOP_LEVEL_BOXES {
+		if position+MP4_BOX_HEADER_LENGTH > videoSize {
+			logWarnf("playable.bad_box", position, videoSize-position)
+			warnings++
+			break
+		}
+		if _, err := r.ReadAt(header[:MP4_BOX_HEADER_LENGTH], position); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := boxName(header[4:8])
+		headerLength := int64(MP4_BOX_HEADER_LENGTH)
+
+		switch size {
+		case 0:
+			// 大小为0表示延伸到文件末尾，追加的数据会被当作该 box 的内容
+			logWarnf("playable.box_to_eof", boxType)
+			warnings++
+			size = videoSize - position
+		case 1:
+			if position+MP4_LARGE_BOX_HEADER_LENGTH > videoSize {
+				logWarnf("playable.bad_box", position, videoSize-position)
+				warnings++
+				size = -1
+				break
+			}
+			if _, err := r.ReadAt(header[MP4_BOX_HEADER_LENGTH:], position+MP4_BOX_HEADER_LENGTH); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[MP4_BOX_HEADER_LENGTH:]))
+			headerLength = MP4_LARGE_BOX_HEADER_LENGTH
+		}
+		if size < 0 {
+			break
+		}
+		if size < headerLength {
+			logWarnf("playable.bad_box", position, size)
+			warnings++
+			break
+		}
+		if size > videoSize-position {
+			logWarnf("playable.box_overruns", boxType, position, size, videoSize)
+			warnings++
+			types = append(types, boxType)
+			break
+		}
+		types = append(types, boxType)
+		position += size
+	}
+	fmt.Fprintf(messageOutput, msg("playable.mp4_boxes"), strings.Join(types, " "))
+
+	// 布局检查：缺少必需的 box、moov 在末尾、依赖文件末尾定位的 mfra
+	indexOf := func(boxType string) int {
+		for i, t := range types {
+			if t == boxType {
+				return i
+			}
+		}
+		return -1
+	}
+	if indexOf("moov") < 0 && indexOf("moof") < 0 {
+		logWarnf("playable.missing_box", "moov")
+		warnings++
+	}
+	if moov, mdat := indexOf("moov"), indexOf("mdat"); moov >= 0 && mdat >= 0 && moov > mdat {
+		logWarnf("playable.trailing_moov")
+		warnings++
+	}
+	if indexOf("mfra") >= 0 {
+		logWarnf("playable.mfra")
+		warnings++
+	}
+	if warnings == 0 {
+		fmt.Fprint(messageOutput, msg("playable.ok"))
+	}
+	return nil
+}
+
+// 检查 EBML 头和 Segment 的声明大小，不逐个检查 Cluster
+func checkMKVSegment(r io.ReaderAt, videoSize int64) error {
+	position := int64(0)
+	for _, expectedID := range []uint64{EBML_HEADER_ID, MKV_SEGMENT_ID} {
+		id, idLength, _, err := readEBMLVint(r, position, videoSize, true)
+		if err != nil {
+			return err
+		}
+		if idLength == 0 || id != expectedID {
+			logWarnf("playable.mkv_bad_element", position)
+			return nil
+		}
+		size, sizeLength, unknown, err := readEBMLVint(r, position+int64(idLength), videoSize, false)
+		if err != nil {
+			return err
+		}
+		if sizeLength == 0 {
+			logWarnf("playable.mkv_bad_element", position)
+			return nil
+		}
+		dataStart := position + int64(idLength) + int64(sizeLength)
+		if id == MKV_SEGMENT_ID {
+			if unknown {
+				// 大小未知时 Segment 延伸到文件末尾
+				logWarnf("playable.mkv_unknown_size")
+			} else if size > uint64(videoSize-dataStart) {
+				logWarnf("playable.mkv_overruns", uint64(dataStart)+size, videoSize)
+			} else {
+				fmt.Fprint(messageOutput, msg("playable.mkv_ok"))
+			}
+			fmt.Fprint(messageOutput, msg("playable.mkv_partial"))
+			return nil
+		}
+		if unknown || size > uint64(videoSize-dataStart) {
+			logWarnf("playable.mkv_bad_element", position)
+			return nil
+		}
+		position = dataStart + int64(size)
+	}
+	return nil
+}
+
+// 读取 EBML 变长整数；keepMarker 为 true 时按元素ID保留长度标记位。
+// 长度为0表示数据无效，unknown 表示数值位全为1（大小未知）
+func readEBMLVint(r io.ReaderAt, position, limit int64, keepMarker bool) (value uint64, length int, unknown bool, err error) {
+	if position >= limit {
+		return 0, 0, false, nil
+	}
+	first := make([]byte, 1)
+	if _, err := r.ReadAt(first, position); err != nil {
+		return 0, 0, false, err
+	}
+	for length = 1; length <= EBML_MAX_VINT_LENGTH; length++ {
+		if first[0]&(0x80>>(length-1)) != 0 {
+			break
+		}
+	}
+	if length > EBML_MAX_VINT_LENGTH || position+int64(length) > limit {
+		return 0, 0, false, nil
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, position); err != nil {
+		return 0, 0, false, err
+	}
+	if !keepMarker {
+		data[0] &^= 0x80 >> (length - 1)
+	}
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	unknown = !keepMarker && value == 1<<(7*length)-1
+	return value, length, unknown, nil
+}
+
+// box 类型按文字显示，含不可打印字符时加引号转义
+func boxName(boxType []byte) string {
+	name := string(boxType)
+	for _, c := range boxType {
+		if c < 0x20 || c > 0x7e {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
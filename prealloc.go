@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// 不预分配输出文件 (--no-preallocate)
+var noPreallocate = false
+
+// 按预计的最终大小为输出文件预分配空间，减少大文件逐步增长造成的碎片。
+// 返回 true 时写完后须用 trimPreallocated 截断到实际大小（压缩后的大小可能小于预计）；
+// 文件系统不支持预分配时照常写入
+func preallocateOutput(file *os.File, size int64) bool {
+	if noPreallocate || size <= 0 {
+		return false
+	}
+	if err := preallocate(file, size); err != nil {
+		logDebugf("devlog.prealloc_failed", file.Name(), err)
+		return false
+	}
+	logDebugf("devlog.preallocated", file.Name(), size)
+	return true
+}
+
+// 把预分配的输出文件截断到已写入的大小（输出均为顺序写入，当前偏移即写入量），
+// 释放多余的预分配空间；操作失败时也据此避免留下预分配大小的文件
+func trimPreallocated(file *os.File) error {
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		err = file.Truncate(offset)
+	}
+	if err != nil {
+		return newError("error.trim_output_failed", file.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// 只分配磁盘块、不改变文件大小，失败中途退出时文件大小仍是已写入的大小
+const FALLOC_FL_KEEP_SIZE = 0x1
+
+// 用 fallocate 为文件预留 size 字节的连续空间
+func preallocate(file *os.File, size int64) error {
+	return syscall.Fallocate(int(file.Fd()), FALLOC_FL_KEEP_SIZE, 0, size)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// 把文件扩展到 size 字节（Windows 上即 SetEndOfFile，NTFS 会一次分配所需的簇），
+// 之后从头顺序写入
+func preallocate(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+const (
+	// 速度平滑的时间常数：约5秒前的样本权重衰减到 1/e，
+	// 短暂的卡顿或突发不会让剩余时间大幅跳动
+	RATE_WINDOW = 5 * time.Second
+)
+
+// rateMeter 按指数加权移动平均估算传输速度（字节/秒）
+type rateMeter struct {
+	rate      float64
+	lastAt    time.Time
+	lastBytes int64
+	started   bool
+}
+
+// 记录当前累计字节数，按距上次采样的时间加权更新速度
+func (m *rateMeter) sample(total int64, now time.Time) {
+	if !m.started {
+		m.lastAt, m.lastBytes, m.started = now, total, true
+		return
+	}
+	dt := now.Sub(m.lastAt)
+	if dt <= 0 {
+		return
+	}
+	instant := float64(total-m.lastBytes) / dt.Seconds()
+	if m.rate == 0 {
+		m.rate = instant
+	} else {
+		// 采样间隔不固定，按间隔长短换算权重
+		alpha := 1 - math.Exp(-float64(dt)/float64(RATE_WINDOW))
+		m.rate += alpha * (instant - m.rate)
+	}
+	m.lastAt, m.lastBytes = now, total
+}
+
+// 按当前速度估算剩余 remaining 字节所需时间，尚无速度时返回 false
+func (m *rateMeter) eta(remaining int64) (time.Duration, bool) {
+	if m.rate <= 0 || remaining < 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / m.rate * float64(time.Second)), true
+}
+
+// progressDisplay 限制进度条刷新频率，并在描述后附上平滑后的速度和剩余时间
+type progressDisplay struct {
+	bar         *progressbar.ProgressBar
+	desc        string
+	size        int64
+	shownCopied int64
+	shownAt     time.Time
+	meter       rateMeter
+}
+
+func newProgressDisplay(bar *progressbar.ProgressBar, desc string, size int64) *progressDisplay {
+	d := &progressDisplay{bar: bar, desc: desc, size: size, shownAt: time.Now()}
+	d.meter.sample(0, d.shownAt)
+	return d
+}
+
+// 复制量变化时调用；高速磁盘上每个缓冲区都重绘会明显拖慢复制
+func (d *progressDisplay) update(copied int64) {
+	if copied-d.shownCopied < PROGRESS_UPDATE_BYTES && time.Since(d.shownAt) < PROGRESS_UPDATE_INTERVAL {
+		return
+	}
+	now := time.Now()
+	d.meter.sample(copied, now)
+	d.bar.Describe(d.describe(copied))
+	d.bar.Set64(copied)
+	d.shownCopied, d.shownAt = copied, now
+}
+
+func (d *progressDisplay) describe(copied int64) string {
+	if d.meter.rate <= 0 {
+		return d.desc
+	}
+	eta := "--:--"
+	if remaining, ok := d.meter.eta(d.size - copied); ok && d.size > 0 {
+		eta = formatDuration(remaining)
+	}
+	return msgf("progress.rate_eta", d.desc, formatFileSize(int64(d.meter.rate)), eta)
+}
+
+func (d *progressDisplay) finish(copied int64) {
+	d.bar.Describe(d.desc)
+	d.bar.Set64(copied)
+	d.bar.Finish()
+}
+
+// Progress 接收复制进度。每次复制先调用 Start（总量未知时为 -1），
+// 读取过程中用 Add 上报新增字节数，复制成功完成后调用 Done；出错或中断时不调用 Done
+type Progress interface {
+	Start(total int64)
+	Add(n int64)
+	Done()
+}
+
+// progressSource 一次合并或拆分中各步复制的进度来源，随调用逐层传递，
+// 同时进行的多个操作互不影响。零值表示各自显示终端进度条
+type progressSource struct {
+	// 调用方提供的实现（MergeOptions/SplitOptions.Progress），所有复制都向它上报
+	progress Progress
+	// 并行提取时共用的进度
+	shared *sharedProgress
+}
+
+// 为一次复制选择进度实现：并行提取时计入共用进度，调用方提供了实现时使用它，
+// 否则显示终端进度条
+func (s progressSource) forCopy(desc string) Progress {
+	if s.shared != nil {
+		return s.shared.task()
+	}
+	if s.progress != nil {
+		return s.progress
+	}
+	return newProgress(desc)
+}
+
+// 显示终端进度条（--quiet 或 --no-progress 时不显示）
+func newProgress(desc string) Progress {
+	if quietMode || noProgress || logJSON {
+		return noopProgress{}
+	}
+	return newTerminalProgress(desc)
+}
+
+// noopProgress 不显示任何进度
+type noopProgress struct{}
+
+func (noopProgress) Start(int64) {}
+func (noopProgress) Add(int64)   {}
+func (noopProgress) Done()       {}
+
+// terminalProgress 在标准错误上显示进度条，每次 Start 开始一个新进度条
+type terminalProgress struct {
+	desc    string
+	display *progressDisplay
+	copied  int64
+}
+
+func newTerminalProgress(desc string) *terminalProgress {
+	return &terminalProgress{desc: desc}
+}
+
+func (p *terminalProgress) Start(total int64) {
+	// 空数据不显示进度条
+	p.display = newProgressDisplay(newProgressBar(total, p.desc, total != 0), p.desc, total)
+	p.copied = 0
+}
+
+func (p *terminalProgress) Add(n int64) {
+	p.copied += n
+	p.display.update(p.copied)
+}
+
+func (p *terminalProgress) Done() {
+	p.display.finish(p.copied)
+}
+
+// 格式化时长为 m:ss 或 h:mm:ss
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds < 3600 {
+		return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// 平均速度（字节/秒），用时过短时按1毫秒计，避免除零
+func averageRate(bytes int64, elapsed time.Duration) int64 {
+	if elapsed < time.Millisecond {
+		elapsed = time.Millisecond
+	}
+	return int64(float64(bytes) / elapsed.Seconds())
+}
+
+// 打印本次传输的用时和平均速度，并写入 --json 结果
+func printTransferStats(bytes int64, elapsed time.Duration, result *OperationResult) {
+	rate := averageRate(bytes, elapsed)
+	if result != nil {
+		result.TransferMs = elapsed.Milliseconds()
+		result.BytesPerSec = rate
+		result.RateLimit = rateLimit
+	}
+	if !quietMode {
+		logInfof(nil, "common.transfer_stats", formatDuration(elapsed), formatFileSize(rate))
+		if rateLimit > 0 {
+			logInfof(nil, "common.rate_limit", formatFileSize(rateLimit))
+		}
+	}
+}
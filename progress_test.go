@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// 记录上报总量的进度实现；并行提取时由共用进度加锁调用
+type recordingProgress struct {
+	mu    sync.Mutex
+	added int64
+	done  int
+}
+
+func (p *recordingProgress) Start(int64) {}
+
+func (p *recordingProgress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.added += n
+}
+
+func (p *recordingProgress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+}
+
+// 同时进行的合并、拆分各自向自己的 Progress 上报，互不串扰
+func TestConcurrentOperationProgress(t *testing.T) {
+	type operation struct {
+		run      func(Progress) error
+		expected int64
+	}
+	var operations []operation
+	for i, size := range []int{3000, 50000} {
+		video := bytes.Repeat([]byte{byte('a' + i)}, size)
+		attach := bytes.Repeat([]byte{byte('A' + i)}, size/3)
+		path := writeTempFile(t, "merged.mp4", mergedBytes(t, video, attach, "a.bin"))
+		outputDir := t.TempDir()
+		operations = append(operations, operation{
+			run: func(progress Progress) error {
+				return splitFiles(context.Background(), path, outputDir, SplitOptions{Progress: progress})
+			},
+			expected: int64(len(video) + len(attach)),
+		})
+	}
+	videoPath := writeTempFile(t, "video.mp4", bytes.Repeat([]byte("v"), 7000))
+	attachPath := writeTempFile(t, "attach.txt", bytes.Repeat([]byte("t"), 1234))
+	outputPath := filepath.Join(t.TempDir(), "out.mp4")
+	operations = append(operations, operation{
+		run: func(progress Progress) error {
+			return mergeFiles(context.Background(), videoPath, []string{attachPath}, outputPath, MergeOptions{Progress: progress})
+		},
+		expected: 7000 + 1234,
+	})
+
+	progresses := make([]*recordingProgress, len(operations))
+	errs := make([]error, len(operations))
+	var wg sync.WaitGroup
+	for i, op := range operations {
+		progresses[i] = &recordingProgress{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = op.run(progresses[i])
+		}()
+	}
+	wg.Wait()
+
+	for i, op := range operations {
+		if errs[i] != nil {
+			t.Fatalf("operation %d: %v", i, errs[i])
+		}
+		if progresses[i].added != op.expected || progresses[i].done == 0 {
+			t.Errorf("operation %d: progress added %d (done %d), want %d", i, progresses[i].added, progresses[i].done, op.expected)
+		}
+	}
+}
+
+// 进度上报本身的开销：每个缓冲区都重绘进度条、限制刷新频率、不显示进度条。
+// 只上报不复制，以 1GB 数据、每次 BUFFER_SIZE 计
+func BenchmarkProgressUpdates(b *testing.B) {
+	const size = 1024 * 1024 * 1024
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+	stderr := os.Stderr
+	os.Stderr = devNull
+	defer func() { os.Stderr = stderr }()
+
+	for _, bc := range []struct {
+		name   string
+		report func() func(copied int64)
+	}{
+		{"every_buffer", func() func(int64) {
+			bar := newProgressBar(size, "bench", true)
+			return func(copied int64) { bar.Set64(copied) }
+		}},
+		{"throttled", func() func(int64) {
+			display := newProgressDisplay(newProgressBar(size, "bench", true), "bench", size)
+			return display.update
+		}},
+		// --no-progress 时 newProgress 返回的实现
+		{"disabled", func() func(int64) { return noopProgress{}.Add }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				report := bc.report()
+				for copied := int64(BUFFER_SIZE); copied <= size; copied += BUFFER_SIZE {
+					report(copied)
+				}
+			}
+		})
+	}
+}
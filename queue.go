@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 队列模式：交互式配置的合并、拆分操作先加入内存中的队列，
+// 检查、调整完后一次依次执行，执行中不再需要输入
+
+// queuedJob 队列中的一个操作，路径在加入时已解析为绝对路径
+type queuedJob struct {
+	// "merge" 或 "split"
+	Operation string
+	// 合并时为视频和附加文件，拆分时为合并文件
+	Inputs []string
+	// 合并输出文件或拆分输出目录
+	Output     string
+	StealthKey string
+	// 加入队列时已确认覆盖（或写入非空的输出目录），执行时不再询问
+	Overwrite bool
+}
+
+var (
+	// 正在配置要加入队列的操作（此时合并、拆分不立即执行）
+	queueMode = false
+	jobQueue  []queuedJob
+)
+
+// 合并文件，队列模式下加入队列
+func runInteractiveMerge(videoPath, attachPath, outputPath string) error {
+	if queueMode {
+		return enqueueJob(queuedJob{Operation: "merge", Inputs: []string{videoPath, attachPath}, Output: outputPath})
+	}
+	return mergeFiles(operationContext(), videoPath, []string{attachPath}, outputPath, MergeOptions{})
+}
+
+// 拆分文件，队列模式下加入队列
+func runInteractiveSplit(mergedPath, outputDir string) error {
+	if queueMode {
+		return enqueueJob(queuedJob{Operation: "split", Inputs: []string{mergedPath}, Output: outputDir, StealthKey: stealthKey})
+	}
+	return splitFiles(operationContext(), mergedPath, outputDir, SplitOptions{StealthKey: stealthKey})
+}
+
+// 开始执行前的确认，队列模式下改为确认加入队列
+func confirmStart(id string) bool {
+	if queueMode {
+		return confirmAction(msg("queue.confirm_add"))
+	}
+	return confirmAction(msg(id))
+}
+
+// 加入队列：解析绝对路径，输出已存在时现在就确认覆盖，执行时不再询问
+func enqueueJob(job queuedJob) error {
+	for i, input := range job.Inputs {
+		job.Inputs[i] = queueAbsPath(input)
+	}
+	if job.Operation == "split" {
+		// 使用默认输出目录时交互流程已确认过非空目录
+		if job.Output != defaultSplitOutputDir(job.Inputs[0]) {
+			if err := confirmSplitOutputDir(job.Output); err != nil {
+				return err
+			}
+		}
+		entries, err := os.ReadDir(job.Output)
+		job.Overwrite = err == nil && len(entries) > 0
+	} else if _, err := os.Stat(job.Output); err == nil {
+		colorYellow.Printf(msg("common.file_exists"), job.Output)
+		if err := confirmOverwrite(job.Output, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+		job.Overwrite = true
+	}
+	job.Output = queueAbsPath(job.Output)
+
+	for i, queued := range jobQueue {
+		if samePath(queued.Output, job.Output) {
+			return newError("queue.duplicate_output", job.Output, i+1)
+		}
+	}
+	jobQueue = append(jobQueue, job)
+	colorGreen.Printf(msg("queue.added"), len(jobQueue), job.describe())
+	return nil
+}
+
+func queueAbsPath(path string) string {
+	if isRemotePath(path) {
+		return path
+	}
+	if absPath, err := filepath.Abs(path); err == nil {
+		return absPath
+	}
+	return path
+}
+
+// 一行说明：操作、输入文件名和输出路径
+func (job *queuedJob) describe() string {
+	if job.Operation == "merge" {
+		return msgf("queue.describe_merge", filepath.Base(job.Inputs[0]), filepath.Base(job.Inputs[1]), job.Output)
+	}
+	return msgf("queue.describe_split", filepath.Base(job.Inputs[0]), job.Output)
+}
+
+// 执行一个任务；加入时已确认覆盖的输出直接覆盖
+func (job *queuedJob) run() error {
+	if job.Overwrite {
+		saved := forceOverwrite
+		forceOverwrite = true
+		defer func() { forceOverwrite = saved }()
+	}
+	if job.Operation == "merge" {
+		return mergeFiles(operationContext(), job.Inputs[0], job.Inputs[1:], job.Output, MergeOptions{})
+	}
+	return splitFiles(operationContext(), job.Inputs[0], job.Output, SplitOptions{StealthKey: job.StealthKey})
+}
+
+// 队列模式菜单；离开时队列保留，可再次进入继续添加或执行
+func queueMenu() {
+	for {
+		fmt.Fprintln(messageOutput)
+		colorMagenta.Printf(msg("queue.title"), len(jobQueue))
+		fmt.Fprintln(messageOutput, msg("queue.menu_merge"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_split"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_smart"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_list"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_remove"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_move"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_run"))
+		fmt.Fprintln(messageOutput, msg("queue.menu_back"))
+
+		switch choice := readUserInput(msg("queue.choose")); choice {
+		case "1":
+			addQueueJobs(interactiveMerge)
+		case "2":
+			addQueueJobs(interactiveSplit)
+		case "3":
+			addQueueJobs(smartFileHandler)
+		case "4":
+			printJobQueue()
+		case "5":
+			removeQueuedJob()
+		case "6":
+			moveQueuedJob()
+		case "7":
+			runJobQueue()
+		case "8", "q", "quit", "exit":
+			return
+		default:
+			colorYellow.Printf(msg("menu.invalid"), choice)
+		}
+	}
+}
+
+// 按原有的交互流程配置操作，结尾改为加入队列
+func addQueueJobs(configure func() error) {
+	queueMode = true
+	defer func() { queueMode = false }()
+	if err := runMenuAction(configure); err != nil && !interruptedToMenu(err) && !cancelledToMenu(err) {
+		colorRed.Printf(msg("queue.add_failed"), err)
+	}
+}
+
+func printJobQueue() {
+	if len(jobQueue) == 0 {
+		colorYellow.Println(msg("queue.empty"))
+		return
+	}
+	fmt.Fprintln(messageOutput)
+	for i := range jobQueue {
+		fmt.Fprintf(messageOutput, "   %d. %s\n", i+1, jobQueue[i].describe())
+	}
+}
+
+// 读取任务序号（从1开始），无效时返回 -1
+func readQueueIndex(prompt string) int {
+	input := readUserInput(prompt)
+	index, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || index < 1 || index > len(jobQueue) {
+		colorYellow.Printf(msg("queue.bad_index"), input, len(jobQueue))
+		return -1
+	}
+	return index - 1
+}
+
+func removeQueuedJob() {
+	printJobQueue()
+	if len(jobQueue) == 0 {
+		return
+	}
+	index := readQueueIndex(msg("queue.remove_prompt"))
+	if index < 0 {
+		return
+	}
+	removed := jobQueue[index]
+	jobQueue = append(jobQueue[:index], jobQueue[index+1:]...)
+	colorGreen.Printf(msg("queue.removed"), removed.describe())
+}
+
+// 把一个任务移到新位置，其余任务顺序不变
+func moveQueuedJob() {
+	printJobQueue()
+	if len(jobQueue) < 2 {
+		return
+	}
+	from := readQueueIndex(msg("queue.move_from_prompt"))
+	if from < 0 {
+		return
+	}
+	to := readQueueIndex(msg("queue.move_to_prompt"))
+	if to < 0 {
+		return
+	}
+	job := jobQueue[from]
+	jobQueue = append(jobQueue[:from], jobQueue[from+1:]...)
+	jobQueue = append(jobQueue[:to], append([]queuedJob{job}, jobQueue[to:]...)...)
+	printJobQueue()
+}
+
+// 依次执行全部任务，失败的任务不影响之后的任务；中断时停止，剩余任务保留。
+// 执行完后显示每个任务的结果，成功的任务移出队列
+func runJobQueue() {
+	if len(jobQueue) == 0 {
+		colorYellow.Println(msg("queue.empty"))
+		return
+	}
+	printJobQueue()
+	if !confirmAction(msg("queue.confirm_run")) {
+		return
+	}
+
+	total := len(jobQueue)
+	results := make([]error, total)
+	ran := 0
+	for i := range jobQueue {
+		fmt.Fprintln(messageOutput)
+		colorMagenta.Printf(msg("queue.running"), i+1, total, jobQueue[i].describe())
+		results[i] = runMenuAction(jobQueue[i].run)
+		ran++
+		if interruptedToMenu(results[i]) {
+			break
+		}
+	}
+
+	fmt.Fprintln(messageOutput)
+	colorMagenta.Println(msg("queue.report_title"))
+	var remaining []queuedJob
+	succeeded := 0
+	for i := range jobQueue {
+		switch {
+		case i >= ran:
+			fmt.Fprintf(messageOutput, msg("queue.report_skipped"), i+1, jobQueue[i].describe())
+		case results[i] != nil:
+			colorRed.Printf(msg("queue.report_failed"), i+1, jobQueue[i].describe(), results[i])
+		default:
+			colorGreen.Printf(msg("queue.report_ok"), i+1, jobQueue[i].describe())
+			succeeded++
+			continue
+		}
+		remaining = append(remaining, jobQueue[i])
+	}
+	fmt.Fprintf(messageOutput, msg("queue.report_summary"), succeeded, total, len(remaining))
+	jobQueue = remaining
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// --limit-rate 下限 (16KB/s)，再低时每次读取都要等待很久，无法及时响应
+	MIN_RATE_LIMIT = 16 * 1024
+)
+
+var (
+	// --limit-rate 指定的限速
+	limitRateFlag = ""
+	// 复制限速（字节/秒），0 为不限速
+	rateLimit int64
+	// 所有复制共用的限速器，并行提取时合计不超过限速
+	copyLimiter *rateLimiter
+)
+
+// 按 --limit-rate 设置复制限速，如 50M 或 50M/s
+func configureRateLimit() error {
+	rateLimit, copyLimiter = 0, nil
+	if limitRateFlag == "" {
+		return nil
+	}
+	limit, ok := parseByteSize(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(limitRateFlag)), "/S"))
+	if !ok {
+		return exitErrorf(EXIT_USAGE, "ratelimit.invalid", limitRateFlag)
+	}
+	if limit < MIN_RATE_LIMIT {
+		return exitErrorf(EXIT_USAGE, "ratelimit.too_low", limitRateFlag, formatFileSize(MIN_RATE_LIMIT))
+	}
+	rateLimit = limit
+	copyLimiter = newRateLimiter(limit)
+	logDebugf("devlog.rate_limit", formatFileSize(limit))
+	return nil
+}
+
+// rateLimiter 令牌桶：每秒补充 rate 个令牌，桶容量为一个缓冲区。
+// 读取后扣除令牌，不足时欠账并等待补足，所以开始时最多只超出一个缓冲区
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	// 桶初始为空，开始时不会突发
+	return &rateLimiter{rate: float64(rate), last: time.Now()}
+}
+
+// 扣除 n 个令牌，必要时等待；等待中上下文被取消时返回 ctx.Err()
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	// 空闲时积累的令牌不超过一个缓冲区
+	if capacity := float64(bufferSize); l.tokens > capacity {
+		l.tokens = capacity
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedReader 每次读取后按限速等待
+type limitedReader struct {
+	src     io.Reader
+	ctx     context.Context
+	limiter *rateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
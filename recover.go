@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 尽力恢复：合并文件被其他工具改写（如重新封装时在末尾追加了自己的数据）后魔术字节不在文件末尾，
+// 从末尾向前查找签名，在每个命中处按“合并文件在此结束”解析尾部元数据，
+// 各字段与偏移一致的候选可按普通拆分提取。签名之后的数据被忽略，视频部分也可能已被改写，结果需自行核对
+const (
+	// 默认从文件末尾向前查找的范围
+	RECOVER_DEFAULT_WINDOW = 64 * 1024 * 1024
+	// 查找时每次读取的块大小
+	RECOVER_CHUNK_SIZE = 1024 * 1024
+)
+
+// RecoverOptions 恢复选项
+type RecoverOptions struct {
+	// 查找整个文件，不限于末尾的查找范围
+	Full bool
+	// 从文件末尾向前查找的字节数
+	Window int64
+	// 只列出候选，不提取
+	ListOnly bool
+	// 直接提取第几个候选（从1开始），0 时逐个询问
+	Candidate int
+	// 提取时的拆分选项（密码、密钥文件等）
+	Split SplitOptions
+}
+
+// recoverCandidate 在某处签名解析出的尾部元数据
+type recoverCandidate struct {
+	// 合并数据结束位置（签名之后）
+	End     int64
+	Trailer *TrailerInfo
+}
+
+// recoveredInput 文件开头到候选结束位置的部分，按独立的合并文件拆分
+type recoveredInput struct {
+	*io.SectionReader
+	info *FileInfo
+}
+
+// 文件由 recover 命令打开和关闭
+func (*recoveredInput) Close() error {
+	return nil
+}
+
+// 从 fileSize 向前查找 window 字节范围内的签名，返回可解析的候选（靠后的在前）和签名总数
+func findTrailerCandidates(ctx context.Context, file io.ReaderAt, fileSize, window int64) ([]recoverCandidate, int, error) {
+	magic := []byte(magicBytes)
+	limit := max(0, fileSize-window)
+	progress := newProgress(msg("progress.recover"))
+	progress.Start(fileSize - limit)
+
+	var candidates []recoverCandidate
+	hits := 0
+	buf := make([]byte, RECOVER_CHUNK_SIZE+len(magic)-1)
+	for end := fileSize; end > limit; {
+		if err := ctx.Err(); err != nil {
+			return nil, hits, err
+		}
+		start := max(limit, end-RECOVER_CHUNK_SIZE)
+		// 与后一块重叠签名长度减一个字节，跨块的签名也能找到；起点在后一块的签名已在后一块中处理
+		chunk := buf[:min(end+int64(len(magic))-1, fileSize)-start]
+		if _, err := file.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, hits, newError("recover.read_failed", start, err)
+		}
+		for i := len(chunk); ; {
+			index := bytes.LastIndex(chunk[:i], magic)
+			if index < 0 {
+				break
+			}
+			i = index
+			if int64(index) >= end-start {
+				continue
+			}
+			hits++
+			candidateEnd := start + int64(index+len(magic))
+			debugInfo := &DebugInfo{FileSize: candidateEnd, CalculatedPos: make(map[string]int64)}
+			trailer, err := loadTrailer(io.NewSectionReader(file, 0, candidateEnd), candidateEnd, "", debugInfo)
+			if err != nil {
+				logDebugf("devlog.recover_reject", start+int64(index), err)
+				continue
+			}
+			logDebugf("devlog.recover_found", start+int64(index), trailer.VideoSize, trailer.AttachSize)
+			candidates = append(candidates, recoverCandidate{End: candidateEnd, Trailer: trailer})
+		}
+		progress.Add(end - start)
+		end = start
+	}
+	progress.Done()
+	return candidates, hits, nil
+}
+
+// 显示候选列表
+func printRecoverCandidates(candidates []recoverCandidate, fileSize int64) {
+	colorGreen.Printf(msg("recover.found"), len(candidates))
+	for i, candidate := range candidates {
+		trailer := candidate.Trailer
+		fmt.Fprintf(messageOutput, msg("recover.candidate"), i+1, candidate.End, formatFileSize(fileSize-candidate.End))
+		fmt.Fprintf(messageOutput, msg("recover.candidate_detail"), formatFileSize(int64(trailer.VideoSize)), trailer.AttachName, len(trailer.Attachments), formatFileSize(int64(trailer.AttachSize)))
+		if trailer.Encrypted {
+			fmt.Fprint(messageOutput, msg("recover.candidate_encrypted"))
+		}
+	}
+}
+
+// 在文件中查找签名并提取找到的合并数据
+func recoverMergedFile(ctx context.Context, path, outputDir string, opts RecoverOptions) (err error) {
+	defer func() { err = canceledOperation(ctx, err, PHASE_PREPARE) }()
+
+	info, err := validateFile(path)
+	if err != nil {
+		return newError("error.merged_invalid_w", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return exitErrorf(EXIT_IO, "error.open_merged_failed", err)
+	}
+	defer file.Close()
+
+	window := opts.Window
+	if opts.Full || window <= 0 || window > info.Size {
+		window = info.Size
+	}
+	colorBlue.Printf(msg("recover.start"), info.Name, magicBytes)
+	if window < info.Size {
+		fmt.Fprintf(messageOutput, msg("recover.window"), formatFileSize(window))
+	} else {
+		fmt.Fprint(messageOutput, msg("recover.full"))
+	}
+
+	candidates, hits, err := findTrailerCandidates(ctx, file, info.Size, window)
+	if err != nil {
+		return withExitCode(EXIT_IO, err)
+	}
+	if len(candidates) == 0 {
+		if hits == 0 {
+			return exitErrorf(EXIT_NOT_MERGED, "recover.no_signature", magicBytes)
+		}
+		if window < info.Size {
+			return exitErrorf(EXIT_NOT_MERGED, "recover.none_window", hits)
+		}
+		return exitErrorf(EXIT_NOT_MERGED, "recover.none", hits)
+	}
+	printRecoverCandidates(candidates, info.Size)
+	colorYellow.Print(msg("recover.best_effort"))
+	if opts.ListOnly {
+		return nil
+	}
+
+	// 指定候选时直接提取，否则从最靠后的候选开始逐个询问
+	chosen := -1
+	if opts.Candidate > 0 {
+		if opts.Candidate > len(candidates) {
+			return exitErrorf(EXIT_USAGE, "recover.bad_candidate", opts.Candidate, len(candidates))
+		}
+		chosen = opts.Candidate - 1
+	} else {
+		for i := range candidates {
+			if confirmAction(msgf("recover.confirm", i+1)) {
+				chosen = i
+				break
+			}
+		}
+	}
+	if chosen < 0 {
+		return newError("recover.skipped")
+	}
+
+	candidate := candidates[chosen]
+	candidateInfo := *info
+	candidateInfo.Size = candidate.End
+	if outputDir == "" {
+		outputDir = withDefaultOutputDir("recovered_" + strings.TrimSuffix(info.Name, filepath.Ext(info.Name)))
+	}
+	colorCyan.Printf(msg("recover.extracting"), chosen+1, outputDir)
+	splitOpts := opts.Split
+	splitOpts.recovered = &recoveredInput{SectionReader: io.NewSectionReader(file, 0, candidate.End), info: &candidateInfo}
+	return splitFiles(ctx, path, outputDir, splitOpts)
+}
+
+var (
+	recoverOpts      RecoverOptions
+	recoverWindow    string
+	recoverOutputDir string
+)
+
+// 恢复命令
+var recoverCmd = &cobra.Command{
+	Use:   "recover <file>",
+	Short: helpText("help.recover.short"),
+	Long:  helpText("help.recover.long"),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := recoverOpts
+		opts.Window = RECOVER_DEFAULT_WINDOW
+		if recoverWindow != "" {
+			window, ok := parseByteSize(recoverWindow)
+			if !ok {
+				return exitErrorf(EXIT_USAGE, "recover.bad_window", recoverWindow)
+			}
+			opts.Window = window
+		}
+		return recoverMergedFile(operationContext(), args[0], recoverOutputDir, opts)
+	},
+}
@@ -0,0 +1,182 @@
+package main
+
+// Reed–Solomon 纠删码：GF(2^8)（本原多项式 0x11d），k 个等长数据分片生成 m 个校验分片，
+// 任意 k 个完好分片即可还原全部数据。编码矩阵由 (k+m)×k 范德蒙矩阵乘以其上方方阵的逆得到，
+// 上方 k 行为单位矩阵（系统码），任意 k 行仍线性无关。分片损坏的位置由调用方（如 CRC）确定
+const (
+	// 数据分片与校验分片总数上限
+	RS_MAX_SHARDS = 255
+	// GF(2^8) 本原多项式
+	GF_POLYNOMIAL = 0x11d
+)
+
+// 指数表（长度翻倍，乘法时免取模）和对数表
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= GF_POLYNOMIAL
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// a 不能为0
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+func gfPow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])*n%255]
+}
+
+func gfMulMatrix(a, b [][]byte) [][]byte {
+	product := make([][]byte, len(a))
+	for i := range a {
+		product[i] = make([]byte, len(b[0]))
+		for j := range product[i] {
+			var sum byte
+			for k := range b {
+				sum ^= gfMul(a[i][k], b[k][j])
+			}
+			product[i][j] = sum
+		}
+	}
+	return product
+}
+
+// 高斯-约当消元求逆矩阵，不可逆时返回 false
+func gfInvertMatrix(matrix [][]byte) ([][]byte, bool) {
+	n := len(matrix)
+	work := make([][]byte, n)
+	for i := range matrix {
+		work[i] = make([]byte, n*2)
+		copy(work[i], matrix[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for j := range work[col] {
+			work[col][j] = gfMul(work[col][j], scale)
+		}
+		for row := 0; row < n; row++ {
+			if factor := work[row][col]; row != col && factor != 0 {
+				for j := range work[row] {
+					work[row][j] ^= gfMul(factor, work[col][j])
+				}
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse, true
+}
+
+// 按矩阵的一行组合分片：out = Σ row[i]·shards[i]
+func gfCombine(row []byte, shards [][]byte, out []byte) {
+	clear(out)
+	for i, coef := range row {
+		if coef == 0 {
+			continue
+		}
+		for j, b := range shards[i] {
+			out[j] ^= gfMul(coef, b)
+		}
+	}
+}
+
+// rsCode k 个数据分片、m 个校验分片的编码器，k+m 不超过 RS_MAX_SHARDS
+type rsCode struct {
+	dataShards   int
+	parityShards int
+	// (k+m)×k 编码矩阵，第 i 行对应第 i 个分片
+	matrix [][]byte
+}
+
+func newRSCode(dataShards, parityShards int) *rsCode {
+	vandermonde := make([][]byte, dataShards+parityShards)
+	for r := range vandermonde {
+		vandermonde[r] = make([]byte, dataShards)
+		for c := range vandermonde[r] {
+			vandermonde[r][c] = gfPow(byte(r), c)
+		}
+	}
+	// 范德蒙矩阵的方阵部分总是可逆的
+	top, _ := gfInvertMatrix(vandermonde[:dataShards])
+	return &rsCode{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		matrix:       gfMulMatrix(vandermonde, top),
+	}
+}
+
+// 由数据分片计算校验分片
+func (c *rsCode) encode(data [][]byte) [][]byte {
+	parity := make([][]byte, c.parityShards)
+	for i := range parity {
+		parity[i] = make([]byte, len(data[0]))
+		gfCombine(c.matrix[c.dataShards+i], data, parity[i])
+	}
+	return parity
+}
+
+// 就地还原损坏的数据分片：shards 为全部 k+m 个分片，good 标记完好的分片；
+// 完好分片不足 k 个时返回 false
+func (c *rsCode) reconstruct(shards [][]byte, good []bool) bool {
+	rows := make([][]byte, 0, c.dataShards)
+	inputs := make([][]byte, 0, c.dataShards)
+	for i := range shards {
+		if good[i] && len(rows) < c.dataShards {
+			rows = append(rows, c.matrix[i])
+			inputs = append(inputs, shards[i])
+		}
+	}
+	if len(rows) < c.dataShards {
+		return false
+	}
+
+	decode, ok := gfInvertMatrix(rows)
+	if !ok {
+		return false
+	}
+	for i := 0; i < c.dataShards; i++ {
+		if !good[i] {
+			gfCombine(decode[i], inputs, shards[i])
+		}
+	}
+	return true
+}
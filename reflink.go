@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// 尝试以 reflink 把 src 开头 length 字节克隆到新建的 dst 开头（btrfs、XFS 等，数据块共享、不复制）。
+// 返回克隆的字节数：不支持、跨文件系统或 src 不是普通文件时为 0，此时照常复制；
+// 未到源文件末尾时只克隆按块对齐的部分。克隆后 dst 的写入位置移到克隆数据之后
+func cloneVideoData(dst *os.File, src io.ReaderAt, length int64) int64 {
+	file, ok := src.(*os.File)
+	if !ok || length <= 0 {
+		return 0
+	}
+	cloned, err := cloneFileRange(dst, file, length)
+	if err != nil {
+		logDebugf("devlog.clone_failed", dst.Name(), err)
+		return 0
+	}
+	if cloned == 0 {
+		return 0
+	}
+	// 写入位置移不过去时从头写入全部数据，结果仍然正确
+	if _, err := dst.Seek(cloned, io.SeekStart); err != nil {
+		return 0
+	}
+	logDebugf("devlog.cloned", dst.Name(), cloned)
+	return cloned
+}
+
+// skipWriter 跳过开头已克隆的 skip 字节，之后的数据照常写入
+type skipWriter struct {
+	w    io.Writer
+	skip int64
+}
+
+func (s *skipWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if s.skip > 0 {
+		skipped := min(s.skip, int64(len(p)))
+		s.skip -= skipped
+		p = p[skipped:]
+	}
+	if len(p) > 0 {
+		if _, err := s.w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl FICLONERANGE：把源文件的一段克隆到目标文件
+const FICLONERANGE = 0x4020940d
+
+// struct file_clone_range
+type fileCloneRange struct {
+	srcFd      int64
+	srcOffset  uint64
+	srcLength  uint64
+	destOffset uint64
+}
+
+// 把 src 开头 length 字节克隆到 dst 开头，返回克隆的字节数
+func cloneFileRange(dst, src *os.File, length int64) (int64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// 克隆范围未到源文件末尾时，长度必须按文件系统块大小对齐，余下部分照常复制
+	if length < info.Size() {
+		var stat syscall.Statfs_t
+		if err := syscall.Fstatfs(int(src.Fd()), &stat); err != nil {
+			return 0, err
+		}
+		length -= length % int64(stat.Bsize)
+		if length == 0 {
+			return 0, nil
+		}
+	}
+
+	arg := fileCloneRange{srcFd: int64(src.Fd()), srcLength: uint64(length)}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), FICLONERANGE, uintptr(unsafe.Pointer(&arg))); errno != 0 {
+		return 0, errno
+	}
+	return length, nil
+}
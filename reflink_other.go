@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// 其他系统暂不支持按范围克隆（macOS 的 clonefile 只能克隆整个文件到新路径），照常复制
+func cloneFileRange(dst, src *os.File, length int64) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
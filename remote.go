@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 远程合并文件：info、detect 和 split 可直接读取 http(s) 地址，
+// 先用 HEAD 取得文件大小，再用 Range 请求读取末尾的元数据和所需的数据（--attach-only 时不下载视频）
+const (
+	// 打开时一次读取的末尾长度，通常已包含完整的尾部元数据和扩展块
+	REMOTE_TAIL_LENGTH = 64 * 1024
+	// 末尾缓存之前的数据每次最少请求的长度：随机读取时较短，接着上次读完的位置继续读时较长
+	REMOTE_READ_MIN   = 64 * 1024
+	REMOTE_READ_AHEAD = 4 * 1024 * 1024
+	// 下载中连接中断时从中断位置重新请求的最多次数
+	REMOTE_MAX_RETRIES = 3
+	// 等待响应头的超时时间（读取响应体不限时）
+	REMOTE_RESPONSE_TIMEOUT = 30 * time.Second
+	// 地址中取不到可用文件名时使用的名称
+	REMOTE_DEFAULT_NAME = "remote"
+)
+
+// 是否为 http(s) 地址
+func isRemotePath(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// 显示用的地址：隐去密码
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// remoteFile 按需用 Range 请求读取的远程文件，末尾数据打开时缓存，
+// 之前的数据按顺序读取时复用同一个响应
+type remoteFile struct {
+	client *http.Client
+	url    *url.URL
+	size   int64
+
+	mu        sync.Mutex
+	tail      []byte
+	tailStart int64
+	body      io.ReadCloser
+	bodyPos   int64
+	bodyEnd   int64
+}
+
+// 打开远程文件：取得大小并缓存末尾数据；服务器不支持 Range 时返回错误
+func openRemoteFile(rawURL string) (*remoteFile, *FileInfo, error) {
+	r, info, err := statRemoteFile(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.tailStart = r.size - REMOTE_TAIL_LENGTH
+	if r.tailStart < 0 {
+		r.tailStart = 0
+	}
+	if r.tailStart < r.size {
+		body, err := r.openRange(r.tailStart, r.size)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.tail, err = io.ReadAll(io.LimitReader(body, r.size-r.tailStart))
+		body.Close()
+		if err == nil && int64(len(r.tail)) != r.size-r.tailStart {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, exitErrorf(EXIT_IO, "remote.read_failed", err)
+		}
+	}
+
+	return r, info, nil
+}
+
+// 用 HEAD 请求取得远程文件的大小和修改时间，不读取数据
+func statRemoteFile(rawURL string) (*remoteFile, *FileInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, nil, exitErrorf(EXIT_USAGE, "remote.bad_url", redactURL(rawURL))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = REMOTE_RESPONSE_TIMEOUT
+	r := &remoteFile{client: &http.Client{Transport: transport}, url: u}
+
+	logDebugf("remote.opening", u.Redacted())
+	resp, err := r.do(http.MethodHead, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, exitErrorf(EXIT_IO, "remote.bad_status", u.Redacted(), resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, nil, exitErrorf(EXIT_IO, "remote.no_size", u.Redacted())
+	}
+	r.size = resp.ContentLength
+	// 之后的请求直接发往重定向后的地址，同一主机时保留地址中的账号密码
+	if final := resp.Request.URL; final.String() != u.String() {
+		redirected := *final
+		if redirected.User == nil && redirected.Host == u.Host {
+			redirected.User = u.User
+		}
+		r.url = &redirected
+		logDebugf("remote.redirected", redirected.Redacted())
+	}
+
+	info := &FileInfo{Name: remoteBaseName(rawURL), Size: r.size, Path: u.Redacted()}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return r, info, nil
+}
+
+// 发送请求；地址中的账号密码显式放进请求头，同一主机的重定向会继续携带
+func (r *remoteFile) do(method, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(operationContext(), method, r.url.String(), nil)
+	if err != nil {
+		return nil, exitErrorf(EXIT_USAGE, "remote.bad_url", r.url.Redacted())
+	}
+	if r.url.User != nil {
+		password, _ := r.url.User.Password()
+		req.SetBasicAuth(r.url.User.Username(), password)
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, exitErrorf(EXIT_IO, "remote.request_failed", r.url.Redacted(), err)
+	}
+	return resp, nil
+}
+
+// 请求 [start, end) 范围的数据，服务器必须返回 206 和对应的 Content-Range
+func (r *remoteFile) openRange(start, end int64) (io.ReadCloser, error) {
+	resp, err := r.do(http.MethodGet, fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		resp.Body.Close()
+		return nil, exitErrorf(EXIT_IO, "remote.no_range", r.url.Redacted())
+	case resp.StatusCode != http.StatusPartialContent:
+		resp.Body.Close()
+		return nil, exitErrorf(EXIT_IO, "remote.bad_status", r.url.Redacted(), resp.Status)
+	}
+	if got := contentRangeStart(resp.Header.Get("Content-Range")); got != start {
+		resp.Body.Close()
+		return nil, exitErrorf(EXIT_IO, "remote.bad_range", start, resp.Header.Get("Content-Range"))
+	}
+	logDebugf("remote.range", start, end)
+	return resp.Body, nil
+}
+
+// Content-Range 的起始位置（"bytes 100-199/1000"），无法解析时返回 -1
+func contentRangeStart(value string) int64 {
+	value, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+func (r *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, retries := 0, 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		if pos >= r.tailStart {
+			n += copy(p[n:], r.tail[pos-r.tailStart:])
+			continue
+		}
+		// 末尾缓存之前的数据：从当前位置向后请求一段（不超过缓存开始处），连续读取时复用同一个响应
+		if r.body == nil || r.bodyPos != pos || r.bodyPos >= r.bodyEnd {
+			readAhead := int64(REMOTE_READ_MIN)
+			if r.body != nil && r.bodyPos == pos {
+				readAhead = REMOTE_READ_AHEAD
+			}
+			r.closeBody()
+			end := pos + max(int64(len(p)-n), readAhead)
+			if end > r.tailStart {
+				end = r.tailStart
+			}
+			body, err := r.openRange(pos, end)
+			if err != nil {
+				return n, err
+			}
+			r.body, r.bodyPos, r.bodyEnd = body, pos, end
+		}
+		want := len(p) - n
+		if remaining := r.bodyEnd - pos; int64(want) > remaining {
+			want = int(remaining)
+		}
+		read, err := io.ReadFull(r.body, p[n:n+want])
+		n += read
+		r.bodyPos += int64(read)
+		if err != nil {
+			r.closeBody()
+			// 连接中断时从已读到的位置重新请求，不必从头下载；读到了数据时重新计数
+			if read > 0 {
+				retries = 0
+			}
+			if retries < REMOTE_MAX_RETRIES && operationContext().Err() == nil {
+				retries++
+				logWarnf("remote.retry", formatFileSize(r.bodyPos), err, retries, REMOTE_MAX_RETRIES)
+				continue
+			}
+			return n, newError("remote.read_failed", err)
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *remoteFile) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+}
+
+func (r *remoteFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeBody()
+	return nil
+}
+
+// 远程文件名取自地址路径的最后一段（路径为空时用主机名）。u.Path 已经解码过，不再解码第二次；
+// 地址来自用户或链接、不可信，清理后仍不是当前目录下的普通文件名时使用默认名称
+func remoteBaseName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return REMOTE_DEFAULT_NAME
+	}
+	name := path.Base(u.Path)
+	if name == "" || name == "/" || name == "." {
+		name = u.Hostname()
+	}
+	cleaned, err := validateAndCleanFilename(name)
+	if err != nil || !filepath.IsLocal(cleaned) {
+		return REMOTE_DEFAULT_NAME
+	}
+	return cleaned
+}
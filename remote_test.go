@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 远程地址中的文件名只解码一次，清理后不能离开输出目录
+func TestRemoteBaseName(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/dir/video.mp4", "video.mp4"},
+		{"https://example.com/My%20Movie.mp4?token=1", "My Movie.mp4"},
+		{"https://example.com/", "example.com"},
+		{"https://example.com", "example.com"},
+		// 双重编码的 ../ 只解码一层，结果是普通文件名
+		{"https://example.com/%252e%252e%252f%252e%252e%252fevil.mp4", "%2e%2e%2f%2e%2e%2fevil.mp4"},
+		{"https://example.com/a%2f..%2f..%2fevil.mp4", "evil.mp4"},
+		{"https://example.com/..%5c..%5cevil.mp4", "_.._evil.mp4"},
+		{"https://example.com/%2e%2e", REMOTE_DEFAULT_NAME},
+		{"https://example.com/.hidden", "hidden"},
+		{"https://[::1/", REMOTE_DEFAULT_NAME},
+	}
+	for _, tt := range tests {
+		if got := remoteBaseName(tt.url); got != tt.want {
+			t.Errorf("remoteBaseName(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultSplitOutputDirRemote(t *testing.T) {
+	for _, rawURL := range []string{
+		"https://example.com/%252e%252e%252f%252e%252e%252fevil.mp4",
+		"https://example.com/%2e%2e%2f%2e%2e%2fevil.mp4",
+		"https://example.com/%2e%2e",
+	} {
+		if dir := defaultSplitOutputDir(rawURL); !filepath.IsLocal(dir) {
+			t.Errorf("defaultSplitOutputDir(%q) = %q, outside the current directory", rawURL, dir)
+		}
+	}
+}
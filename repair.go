@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 尾部元数据损坏但数据完好时，按用户给出的视频大小重建尾部：
+// 视频为 [0, 视频大小)，附加文件为其后直到 --attach-size 或文件末尾，其余部分（损坏的旧尾部）截断后写入新尾部。
+// 原有的加密、压缩、对齐填充和多个附加文件的划分无法还原，附加区域整体作为一个原始附加文件记录
+const REPAIR_DEFAULT_ATTACH_NAME = "recovered_attachment.bin"
+
+// RepairOptions 修复选项
+type RepairOptions struct {
+	// 视频数据的大小（附加数据起始偏移）
+	VideoSize int64
+	// 附加数据大小，0 表示直到文件末尾
+	AttachSize int64
+	// 记录的附加文件名
+	AttachName string
+	// 只显示重建后的布局，不修改文件
+	DryRun bool
+}
+
+// 嗅探附加区域开头内容的MIME类型
+func sniffRegionMimeType(file io.ReaderAt, offset, size int64) string {
+	buffer := make([]byte, min(MIME_SNIFF_LENGTH, size))
+	n, err := file.ReadAt(buffer, offset)
+	if err != nil && err != io.EOF {
+		return MIME_UNKNOWN
+	}
+	return http.DetectContentType(buffer[:n])
+}
+
+// 文件是否仍以魔术字节结尾
+func endsWithMagic(file io.ReaderAt, size int64) bool {
+	tail := make([]byte, len(magicBytes))
+	if size < int64(len(tail)) {
+		return false
+	}
+	_, err := file.ReadAt(tail, size-int64(len(tail)))
+	return err == nil && string(tail) == magicBytes
+}
+
+// 按给出的视频大小为尾部损坏的合并文件重建尾部元数据
+func repairTrailer(mergedPath string, opts RepairOptions) error {
+	colorBlue.Println(msg("repair.start"))
+
+	// 存在未完成的追加操作时文件尾部可能不完整
+	backupPath := appendBackupPath(mergedPath)
+	if _, err := os.Stat(backupPath); err == nil {
+		return newError("repair.pending_append", backupPath)
+	}
+
+	mergedInfo, err := validateFile(mergedPath)
+	if err != nil {
+		return newError("error.merged_invalid", err)
+	}
+
+	flag := os.O_RDWR
+	if opts.DryRun {
+		flag = os.O_RDONLY
+	}
+	mergedFile, err := os.OpenFile(mergedPath, flag, 0)
+	if err != nil {
+		return newError("error.open_merged_failed", err)
+	}
+	defer mergedFile.Close()
+
+	// 尾部完好的文件不需要修复，避免误把元数据当作附加数据
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
+	}
+	if _, err := loadTrailer(mergedFile, mergedInfo.Size, stealthKey, debugInfo); err == nil {
+		return exitErrorf(EXIT_USAGE, "repair.trailer_valid")
+	}
+
+	// 附加区域必须非空且在文件内
+	videoSize := opts.VideoSize
+	if videoSize <= 0 || videoSize >= mergedInfo.Size {
+		return exitErrorf(EXIT_USAGE, "repair.bad_video_size", videoSize, mergedInfo.Size)
+	}
+	attachSize := mergedInfo.Size - videoSize
+	if opts.AttachSize > 0 {
+		if opts.AttachSize > attachSize {
+			return exitErrorf(EXIT_USAGE, "repair.bad_attach_size", opts.AttachSize, attachSize)
+		}
+		attachSize = opts.AttachSize
+	}
+	attachEnd := videoSize + attachSize
+
+	attachName := opts.AttachName
+	if attachName == "" {
+		attachName = REPAIR_DEFAULT_ATTACH_NAME
+	}
+	if attachName, err = validateAndCleanFilename(attachName); err != nil {
+		return exitErrorf(EXIT_USAGE, "repair.bad_attach_name", err)
+	}
+
+	entries := []AttachmentEntry{{
+		Name:         attachName,
+		Size:         uint64(attachSize),
+		Offset:       videoSize,
+		MimeType:     sniffRegionMimeType(mergedFile, videoSize, attachSize),
+		OriginalSize: uint64(attachSize),
+	}}
+	createdAt := time.Now()
+	spec := &trailerSpec{
+		VideoSize:   videoSize,
+		VideoName:   mergedInfo.Name,
+		ToolVersion: toolVersion,
+		CreatedAt:   &createdAt,
+		Attachments: entries,
+	}
+
+	// 校验值长度固定，先用占位值得到新尾部长度
+	spec.VideoSHA256 = make([]byte, sha256.Size)
+	spec.AttachSHA256 = make([]byte, sha256.Size)
+	metadata, err := buildTrailer(spec)
+	if err != nil {
+		return err
+	}
+	newSize := attachEnd + int64(metadata.Len())
+
+	fmt.Fprintf(messageOutput, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+	fmt.Fprint(messageOutput, msg("repair.layout"))
+	fmt.Fprintf(messageOutput, msg("repair.layout_video"), int64(0), videoSize, formatFileSize(videoSize))
+	fmt.Fprintf(messageOutput, msg("repair.layout_attach"), videoSize, attachEnd, formatFileSize(attachSize), attachName, formatMimeType(entries[0].MimeType))
+	if attachEnd < mergedInfo.Size {
+		fmt.Fprintf(messageOutput, msg("repair.layout_truncate"), attachEnd, mergedInfo.Size, formatFileSize(mergedInfo.Size-attachEnd))
+	} else if endsWithMagic(mergedFile, mergedInfo.Size) {
+		// 不截断时损坏的旧尾部会被算进附加文件
+		logWarnf("repair.old_trailer_kept")
+	}
+	fmt.Fprintf(messageOutput, msg("repair.layout_trailer"), attachEnd, newSize, metadata.Len())
+	fmt.Fprintf(messageOutput, msg("common.total_size_change"), formatFileSize(mergedInfo.Size), formatFileSize(newSize))
+	colorYellow.Println(msg("repair.best_effort"))
+
+	if opts.DryRun {
+		colorGreen.Println(msg("repair.dry_run_done"))
+		return nil
+	}
+
+	fmt.Fprintln(messageOutput)
+	if err := confirmOverwrite(mergedPath, msg("repair.confirm")); err != nil {
+		return err
+	}
+
+	// 按现有数据计算校验值，修复后 split 和 verify 照常校验
+	ctx := operationContext()
+	videoHash := sha256.New()
+	if _, err := copyWithProgress(ctx, nil, io.NewSectionReader(mergedFile, 0, videoSize), videoSize, newProgress(msg("progress.verify_video")), videoHash); err != nil {
+		return newError("repair.read_failed", err)
+	}
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	if _, err := copyWithProgress(ctx, nil, io.NewSectionReader(mergedFile, videoSize, attachSize), attachSize, newProgress(msg("progress.attach_data")), attachHash, attachCRC); err != nil {
+		return newError("repair.read_failed", err)
+	}
+	spec.VideoSHA256 = videoHash.Sum(nil)
+	spec.AttachSHA256 = attachHash.Sum(nil)
+	spec.AttachCRC32 = attachCRC.Sum32()
+	if metadata, err = buildTrailer(spec); err != nil {
+		return err
+	}
+
+	colorCyan.Println(msg("common.writing_metadata"))
+	if err := mergedFile.Truncate(attachEnd); err != nil {
+		return newError("append.truncate_failed", err)
+	}
+	if _, err := mergedFile.Seek(attachEnd, io.SeekStart); err != nil {
+		return newError("repair.locate_failed", err)
+	}
+	if _, err := writeTrailer(mergedFile, metadata, ""); err != nil {
+		return err
+	}
+	if err := mergedFile.Sync(); err != nil {
+		return newError("append.sync_merged_failed", err)
+	}
+
+	// 重新解析写入的尾部，确认修复后的文件能被识别
+	debugInfo = &DebugInfo{
+		FileSize:      newSize,
+		CalculatedPos: make(map[string]int64),
+	}
+	defer printDebugInfo(debugInfo)
+	if _, err := loadTrailer(mergedFile, newSize, "", debugInfo); err != nil {
+		return newError("repair.check_failed", err)
+	}
+
+	absPath, err := filepath.Abs(mergedPath)
+	if err != nil {
+		absPath = mergedPath
+	}
+
+	colorGreen.Print(msg("repair.done"))
+	fmt.Fprintf(messageOutput, msg("common.total_size_change"), formatFileSize(mergedInfo.Size), formatFileSize(newSize))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(mergedPath)
+
+	return nil
+}
+
+var (
+	repairOpts       RepairOptions
+	repairVideoSize  string
+	repairAttachSize string
+)
+
+// 修复命令
+var repairCmd = &cobra.Command{
+	Use:   "repair <file> --video-size N",
+	Short: helpText("help.repair.short"),
+	Long:  helpText("help.repair.long"),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := repairOpts
+		size, ok := parseByteSize(repairVideoSize)
+		if !ok {
+			return exitErrorf(EXIT_USAGE, "repair.bad_size", "--video-size", repairVideoSize)
+		}
+		opts.VideoSize = size
+		if repairAttachSize != "" {
+			if opts.AttachSize, ok = parseByteSize(repairAttachSize); !ok {
+				return exitErrorf(EXIT_USAGE, "repair.bad_size", "--attach-size", repairAttachSize)
+			}
+		}
+		return repairTrailer(args[0], opts)
+	},
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 去掉附加数据和元数据，还原原始视频
+func restoreVideo(mergedPath string, opts RestoreOptions) error {
+	colorBlue.Println(msg("restore.start"))
+
+	// 存在未完成的追加操作时文件尾部可能不完整
+	backupPath := appendBackupPath(mergedPath)
+	if _, err := os.Stat(backupPath); err == nil {
+		return newError("restore.pending_append", backupPath)
+	}
+
+	mergedInfo, err := validateFile(mergedPath)
+	if err != nil {
+		return newError("error.merged_invalid", err)
+	}
+
+	mergedFile, err := os.Open(mergedPath)
+	if err != nil {
+		return newError("error.open_merged_failed", err)
+	}
+	defer mergedFile.Close()
+
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
+	}
+	defer printDebugInfo(debugInfo)
+
+	// 结构校验失败时拒绝任何修改
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, opts.StealthKey, debugInfo)
+	if err != nil {
+		return newError("restore.structure_failed", err)
+	}
+	videoSize := int64(trailer.VideoSize)
+
+	fmt.Fprintf(messageOutput, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+	fmt.Fprintf(messageOutput, msg("restore.video"), formatFileSize(videoSize))
+	fmt.Fprintf(messageOutput, msg("restore.removing"), len(trailer.Attachments), formatFileSize(mergedInfo.Size-videoSize))
+
+	if opts.Copy != "" {
+		if err := checkOutputsNotInputs([]string{opts.Copy}, []string{mergedPath}); err != nil {
+			return err
+		}
+		return copyVideo(operationContext(), videoSourceOf(mergedFile, trailer), videoSize, opts.Copy)
+	}
+
+	fmt.Fprintln(messageOutput)
+	colorYellow.Println(msg("restore.in_place_warning"))
+	if err := confirmOverwrite(mergedPath, msg("restore.confirm")); err != nil {
+		return err
+	}
+	mergedFile.Close()
+
+	if err := os.Truncate(mergedPath, videoSize); err != nil {
+		return newError("append.truncate_failed", err)
+	}
+	// MKV 附件嵌入：Segment 大小字段改回合并前的内容
+	if err := restoreMKVSegmentSize(mergedPath, trailer.MKV); err != nil {
+		return newError("mkvattach.restore_failed", err)
+	}
+
+	absPath, err := filepath.Abs(mergedPath)
+	if err != nil {
+		absPath = mergedPath
+	}
+
+	colorGreen.Print(msg("restore.done"))
+	fmt.Fprintf(messageOutput, msg("common.total_size_change"), formatFileSize(mergedInfo.Size), formatFileSize(videoSize))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(mergedPath)
+
+	return nil
+}
+
+// 将视频数据区复制到新文件
+func copyVideo(ctx context.Context, mergedFile io.ReaderAt, videoSize int64, outputPath string) error {
+	// 检查输出文件是否存在
+	if _, err := os.Stat(outputPath); err == nil {
+		colorYellow.Printf(msg("merge.output_exists"), outputPath)
+		if err := confirmOverwrite(outputPath, msg("prompt.overwrite")); err != nil {
+			return err
+		}
+	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return newError("error.create_output_failed", err)
+	}
+	defer outputFile.Close()
+	trackPartialOutput(outputPath)
+
+	fmt.Fprintln(messageOutput)
+	colorCyan.Println(msg("restore.copying_video"))
+	videoReader := io.NewSectionReader(mergedFile, 0, videoSize)
+	if _, err := copyWithProgress(ctx, outputFile, videoReader, videoSize, newProgress(msg("progress.video_data"))); err != nil {
+		return newError("restore.copy_video_failed", err)
+	}
+	if err := syncAndClose(outputFile); err != nil {
+		return err
+	}
+	finishPartialOutput(outputPath)
+
+	absPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		absPath = outputPath
+	}
+
+	colorGreen.Print(msg("restore.done"))
+	fmt.Fprintf(messageOutput, msg("split.stats_video"), filepath.Base(outputPath), formatFileSize(videoSize))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(outputPath)
+
+	return nil
+}
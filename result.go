@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// OperationResult 合并、拆分结果（--json 输出，字段保持稳定）
+type OperationResult struct {
+	Operation    string   `json:"operation"`
+	Success      bool     `json:"success"`
+	Inputs       []string `json:"inputs"`
+	Outputs      []string `json:"outputs"`
+	VideoSize    int64    `json:"video_size"`
+	AttachSize   int64    `json:"attach_size"`
+	MetadataSize int64    `json:"metadata_size"`
+	TotalSize    int64    `json:"total_size"`
+	DurationMs   int64    `json:"duration_ms"`
+	TransferMs   int64    `json:"transfer_ms"`
+	BytesPerSec  int64    `json:"bytes_per_sec"`
+	RateLimit    int64    `json:"rate_limit,omitempty"`
+	Verified     bool     `json:"verified,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	ErrorCode    string   `json:"error_code,omitempty"` // 消息ID，不随 --lang 变化
+	ExitCode     int      `json:"exit_code,omitempty"`
+}
+
+// 标准输出只留给结果（输出路径、JSON、报告、附加文件数据），横幅、提示、进度条等普通输出写到标准错误
+func reserveStdoutForResults() {
+	resultIsTerminal = term.IsTerminal(int(os.Stdout.Fd()))
+	// 安静模式已屏蔽普通输出
+	if quietMode {
+		return
+	}
+	// JSON日志模式下标准错误只输出日志记录，不夹杂文字摘要
+	if logJSON {
+		messageOutput = io.Discard
+		color.Output = io.Discard
+		return
+	}
+	messageOutput = os.Stderr
+	color.Output = color.Error
+}
+
+// 绝对路径列表，用于结果输出（远程地址隐去密码）
+func absPaths(paths ...string) []string {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if path == STDIN_PATH {
+			result = append(result, path)
+			continue
+		}
+		if isRemotePath(path) {
+			result = append(result, redactURL(path))
+			continue
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+		result = append(result, absPath)
+	}
+	return result
+}
+
+// 执行操作并在 --json 模式下输出结果
+func runWithResult(result *OperationResult, run func() error) error {
+	if !jsonOutput {
+		return run()
+	}
+
+	start := time.Now()
+	err := run()
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorCode = errorCode(err)
+		result.ExitCode = exitCodeOf(err)
+	}
+	if result.Outputs == nil {
+		result.Outputs = []string{}
+	}
+
+	output, jsonErr := json.Marshal(result)
+	if jsonErr != nil {
+		return newError("error.json_failed", jsonErr)
+	}
+	fmt.Fprintln(resultOutput, string(output))
+	return err
+}
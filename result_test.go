@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// --json 输出的字段名是对外约定，增删字段需同步更新这里
+var JSON_RESULT_FIELDS = []string{
+	"attach_size", "bytes_per_sec", "duration_ms", "inputs", "metadata_size",
+	"operation", "outputs", "success", "total_size", "transfer_ms", "video_size",
+}
+
+func decodeJSONResult(t *testing.T, stdout string) (OperationResult, map[string]json.RawMessage) {
+	t.Helper()
+	var result OperationResult
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+	if err := json.Unmarshal([]byte(stdout), &fields); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+	return result, fields
+}
+
+func TestJSONResult(t *testing.T) {
+	video := bytes.Repeat([]byte("video "), 1000)
+	attach := bytes.Repeat([]byte("attachment "), 100)
+	videoPath := writeTempFile(t, "v.mp4", video)
+	attachPath := writeTempFile(t, "a.txt", attach)
+	outputPath := filepath.Join(t.TempDir(), "merged.mp4")
+
+	code, stdout, _ := runMain(t, "merge", "--json", videoPath, attachPath, outputPath)
+	if code != EXIT_OK {
+		t.Fatalf("merge exit code %d", code)
+	}
+	if strings.Count(stdout, "\n") != 1 {
+		t.Errorf("stdout is not a single JSON line: %q", stdout)
+	}
+	result, fields := decodeJSONResult(t, stdout)
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != strings.Join(JSON_RESULT_FIELDS, ",") {
+		t.Errorf("merge fields = %v, want %v", names, JSON_RESULT_FIELDS)
+	}
+	if result.Operation != "merge" || !result.Success || len(result.Inputs) != 2 || len(result.Outputs) != 1 {
+		t.Errorf("merge result = %+v", result)
+	}
+	if result.VideoSize != int64(len(video)) || result.AttachSize <= 0 || result.MetadataSize <= 0 {
+		t.Errorf("merge sizes = %d/%d/%d", result.VideoSize, result.AttachSize, result.MetadataSize)
+	}
+	if result.TotalSize < result.VideoSize+result.AttachSize+result.MetadataSize {
+		t.Errorf("total_size %d is smaller than its parts", result.TotalSize)
+	}
+
+	code, stdout, _ = runMain(t, "split", "--json", outputPath, "-o", t.TempDir())
+	if code != EXIT_OK {
+		t.Fatalf("split exit code %d", code)
+	}
+	result, _ = decodeJSONResult(t, stdout)
+	if result.Operation != "split" || !result.Success || len(result.Outputs) != 2 || result.VideoSize != int64(len(video)) {
+		t.Errorf("split result = %+v", result)
+	}
+}
+
+// 失败时仍输出一个 JSON 对象，带错误信息，outputs 为空数组而不是 null
+func TestJSONResultError(t *testing.T) {
+	code, stdout, _ := runMain(t, "split", "--json", filepath.Join(t.TempDir(), "missing.mp4"))
+	if code == EXIT_OK {
+		t.Fatal("split of a missing file succeeded")
+	}
+	result, fields := decodeJSONResult(t, stdout)
+	if result.Success || result.Error == "" || result.ErrorCode == "" || result.ExitCode != code {
+		t.Errorf("error result = %+v", result)
+	}
+	if string(fields["outputs"]) != "[]" {
+		t.Errorf("outputs = %s, want []", fields["outputs"])
+	}
+}
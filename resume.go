@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// 断点文件后缀，与输出文件放在同一目录
+	RESUME_SUFFIX = ".resume"
+	// 断点文件格式版本
+	RESUME_VERSION = 1
+	// 每复制此字节数 (1GB) 记录一次断点
+	RESUME_CHECKPOINT_INTERVAL = 1024 * 1024 * 1024
+)
+
+// resumeCheckpoint 断点记录：输出文件前 Offset 字节已写入磁盘，与输入文件同一位置的数据相同。
+// 只记录视频数据的复制（合并输出和拆分出的视频都从偏移0开始与输入一一对应），
+// 对齐填充、附加文件和元数据续传时重新写入
+type resumeCheckpoint struct {
+	Version int `json:"version"`
+	// 输入文件的绝对路径、大小和修改时间，任一变化都视为过期
+	Source        string `json:"source"`
+	SourceSize    int64  `json:"source_size"`
+	SourceModTime int64  `json:"source_mod_time"`
+	// 需要复制的视频数据总长度
+	Length int64 `json:"length"`
+	// 已写入磁盘的字节数
+	Offset int64 `json:"offset"`
+	// 最后一段（ChunkOffset 到 Offset）输出数据的CRC32C，续传前重新计算比对
+	ChunkOffset int64  `json:"chunk_offset"`
+	ChunkCRC32  string `json:"chunk_crc32"`
+	// 前 Offset 字节视频数据的SHA-256中间状态，续传时不必重新读取
+	HashState []byte `json:"hash_state"`
+}
+
+// 输出文件对应的断点文件路径
+func resumePath(outputPath string) string {
+	return outputPath + RESUME_SUFFIX
+}
+
+// 以输入文件当前的路径、大小和修改时间创建断点记录
+func newResumeCheckpoint(source string, length int64) (*resumeCheckpoint, error) {
+	// 远程输入按地址（隐去密码）、大小和 Last-Modified 判断是否变化
+	if isRemotePath(source) {
+		_, info, err := statRemoteFile(source)
+		if err != nil {
+			return nil, err
+		}
+		return &resumeCheckpoint{
+			Version:       RESUME_VERSION,
+			Source:        info.Path,
+			SourceSize:    info.Size,
+			SourceModTime: info.ModTime.UnixNano(),
+			Length:        length,
+		}, nil
+	}
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absSource)
+	if err != nil {
+		return nil, err
+	}
+	return &resumeCheckpoint{
+		Version:       RESUME_VERSION,
+		Source:        absSource,
+		SourceSize:    info.Size(),
+		SourceModTime: info.ModTime().UnixNano(),
+		Length:        length,
+	}, nil
+}
+
+// 读取并校验断点。断点不存在或与输入、输出不符时说明原因并返回 nil，
+// 调用方从头开始，不会在旧数据后面续写出损坏的文件
+func loadResumeCheckpoint(outputPath, source string, length int64) *resumeCheckpoint {
+	data, err := os.ReadFile(resumePath(outputPath))
+	if err != nil {
+		logWarnf("resume.not_found", resumePath(outputPath))
+		return nil
+	}
+	var checkpoint resumeCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil || checkpoint.Version != RESUME_VERSION {
+		logWarnf("resume.stale", msg("resume.reason_bad_file"))
+		return nil
+	}
+	if reason := checkResumeCheckpoint(&checkpoint, outputPath, source, length); reason != "" {
+		logWarnf("resume.stale", msg(reason))
+		return nil
+	}
+	logInfof(colorGreen, "resume.continuing", formatFileSize(checkpoint.Offset), formatFileSize(length))
+	return &checkpoint
+}
+
+// 校验断点，不符时返回原因的消息ID
+func checkResumeCheckpoint(checkpoint *resumeCheckpoint, outputPath, source string, length int64) string {
+	current, err := newResumeCheckpoint(source, length)
+	if err != nil || current.Source != checkpoint.Source || current.SourceSize != checkpoint.SourceSize ||
+		current.SourceModTime != checkpoint.SourceModTime || current.Length != checkpoint.Length {
+		return "resume.reason_source_changed"
+	}
+	if checkpoint.Offset <= 0 || checkpoint.Offset > length || checkpoint.ChunkOffset < 0 || checkpoint.ChunkOffset > checkpoint.Offset {
+		return "resume.reason_bad_file"
+	}
+
+	// 输出文件必须还在、不短于断点，且最后一段数据与记录一致
+	output, err := os.Open(outputPath)
+	if err != nil {
+		return "resume.reason_output_missing"
+	}
+	defer output.Close()
+	if info, err := output.Stat(); err != nil || info.Size() < checkpoint.Offset {
+		return "resume.reason_output_short"
+	}
+	chunkCRC := crc32.New(crc32cTable)
+	if _, err := io.Copy(chunkCRC, io.NewSectionReader(output, checkpoint.ChunkOffset, checkpoint.Offset-checkpoint.ChunkOffset)); err != nil {
+		return "resume.reason_output_short"
+	}
+	if formatCRC32(chunkCRC.Sum32()) != checkpoint.ChunkCRC32 {
+		return "resume.reason_chunk_mismatch"
+	}
+
+	if err := sha256.New().(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint.HashState); err != nil {
+		return "resume.reason_bad_file"
+	}
+	logDebugf("devlog.resume_checked", checkpoint.ChunkOffset, checkpoint.Offset, checkpoint.ChunkCRC32)
+	return ""
+}
+
+// 视频数据的SHA-256：续传时从断点记录的中间状态继续计算
+func resumedVideoHash(checkpoint *resumeCheckpoint) hash.Hash {
+	videoHash := sha256.New()
+	if checkpoint != nil {
+		// 状态已在 checkResumeCheckpoint 中校验过
+		videoHash.(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint.HashState)
+	}
+	return videoHash
+}
+
+// 打开部分输出文件以便续写：截掉断点之后未确认的数据，定位到断点
+func openResumedOutput(outputPath string, checkpoint *resumeCheckpoint) (*os.File, error) {
+	file, err := os.OpenFile(outputPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(checkpoint.Offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// checkpointWriter 接收复制的视频数据，每 RESUME_CHECKPOINT_INTERVAL 字节把缓冲写入磁盘并记录断点。
+// 须排在 io.MultiWriter 中输出之后；视频校验值由 copyWithProgress 先于目标更新
+type checkpointWriter struct {
+	checkpoint resumeCheckpoint
+	outputPath string
+	file       *os.File
+	// 输出的写缓冲，记录断点前先写入文件
+	flush     func() error
+	videoHash hash.Hash
+	chunkCRC  hash.Hash32
+	pending   int64
+	// 断点文件写入失败后不再尝试
+	failed bool
+}
+
+// 视频较大或正在续传时返回记录断点的写入端，否则返回 nil。
+// resume 为已校验的断点，从头开始时为 nil；source 为视频数据所在的输入文件
+func startCheckpoints(resume *resumeCheckpoint, source string, length int64, outputPath string, file *os.File, flush func() error, videoHash hash.Hash) io.Writer {
+	checkpoint := resume
+	if checkpoint == nil {
+		if length <= RESUME_CHECKPOINT_INTERVAL {
+			return nil
+		}
+		var err error
+		if checkpoint, err = newResumeCheckpoint(source, length); err != nil {
+			logDebugf("devlog.resume_save_failed", err)
+			return nil
+		}
+	}
+	logInfof(nil, "resume.enabled")
+	return &checkpointWriter{
+		checkpoint: *checkpoint,
+		outputPath: outputPath,
+		file:       file,
+		flush:      flush,
+		videoHash:  videoHash,
+		chunkCRC:   crc32.New(crc32cTable),
+	}
+}
+
+func (w *checkpointWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		return len(p), nil
+	}
+	w.chunkCRC.Write(p)
+	w.pending += int64(len(p))
+	if w.pending >= RESUME_CHECKPOINT_INTERVAL {
+		if err := w.save(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// 把已复制的数据写入磁盘后记录断点；只有断点文件本身写不进去时继续复制，不影响本次操作
+func (w *checkpointWriter) save() error {
+	if err := w.flush(); err != nil {
+		return newError("error.flush_output_failed", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return newError("error.sync_output_failed", w.file.Name(), err)
+	}
+	state, err := w.videoHash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	w.checkpoint.ChunkOffset = w.checkpoint.Offset
+	w.checkpoint.Offset += w.pending
+	w.checkpoint.ChunkCRC32 = formatCRC32(w.chunkCRC.Sum32())
+	w.checkpoint.HashState = state
+	w.chunkCRC.Reset()
+	w.pending = 0
+
+	if err := writeResumeFile(resumePath(w.outputPath), &w.checkpoint); err != nil {
+		w.failed = true
+		logDebugf("devlog.resume_save_failed", err)
+		return nil
+	}
+	// 已有断点的部分输出中断时保留，供 --resume 续传
+	finishPartialOutput(w.outputPath)
+	logDebugf("devlog.resume_saved", w.checkpoint.Offset)
+	return nil
+}
+
+// 先写临时文件再改名，断点文件不会只写一半
+func writeResumeFile(path string, checkpoint *resumeCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := syncAndClose(file); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// 操作完成或从头开始时删除断点文件
+func removeResumeFile(outputPath string) {
+	if err := os.Remove(resumePath(outputPath)); err == nil {
+		logDebugf("devlog.resume_removed", resumePath(outputPath))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScanOptions 扫描选项
+type ScanOptions struct {
+	// 只扫描这些扩展名（不区分大小写，可省略点），为空时扫描全部文件
+	Extensions []string
+	// 最小文件大小（字节）
+	MinSize int64
+	// 隐蔽模式口令
+	StealthKey string
+	// 结果输出格式（json、yaml、csv），为空时只显示文字摘要
+	Format string
+}
+
+// ScanResult 合并文件的元数据摘要（scan 的结果，也用于 info 的 YAML/CSV 输出）
+type ScanResult struct {
+	Path          string `json:"path"`
+	FormatVersion int    `json:"format_version"`
+	FileSize      int64  `json:"file_size"`
+	VideoSize     uint64 `json:"video_size"`
+	AttachName    string `json:"filename"`
+	AttachCount   int    `json:"attach_count"`
+	AttachSize    uint64 `json:"attach_size"`
+	Encrypted     bool   `json:"encrypted"`
+	Stealth       bool   `json:"stealth,omitempty"`
+	VideoSHA256   string `json:"video_sha256,omitempty"`
+	AttachSHA256  string `json:"attach_sha256,omitempty"`
+	AttachCRC32   string `json:"attach_crc32,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+// 由尾部元数据生成摘要
+func scanResultOf(path string, size int64, trailer *TrailerInfo) ScanResult {
+	return ScanResult{
+		Path:          path,
+		FormatVersion: trailer.FormatVersion,
+		FileSize:      size,
+		VideoSize:     trailer.VideoSize,
+		AttachName:    trailer.AttachName,
+		AttachCount:   len(trailer.Attachments),
+		AttachSize:    trailer.AttachSize,
+		Encrypted:     trailer.Encrypted,
+		Stealth:       trailer.Stealth,
+		VideoSHA256:   trailer.VideoSHA256,
+		AttachSHA256:  trailer.AttachSHA256,
+		AttachCRC32:   trailer.AttachCRC32,
+		Comment:       trailer.Comment,
+	}
+}
+
+// 扫描扩展名过滤集合
+func scanExtensionSet(extensions []string) map[string]bool {
+	if len(extensions) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
+
+// 只读方式检测单个文件，是合并文件时返回元数据摘要
+func scanFile(path string, size int64, stealthKey string) (*ScanResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	debugInfo := &DebugInfo{
+		FileSize:      size,
+		CalculatedPos: make(map[string]int64),
+	}
+	trailer, err := loadTrailer(file, size, stealthKey, debugInfo)
+	if err != nil {
+		return nil, nil
+	}
+
+	result := scanResultOf(path, size, trailer)
+	return &result, nil
+}
+
+// 递归扫描目录中的合并文件（只读，不修改任何文件）
+func scanMergedFiles(root string, opts ScanOptions) error {
+	colorBlue.Printf(msg("scan.start"), root)
+
+	extensions := scanExtensionSet(opts.Extensions)
+	minSize := opts.MinSize
+	if minSize < MIN_V3_FILE_SIZE {
+		minSize = MIN_V3_FILE_SIZE
+	}
+
+	var results []ScanResult
+	var scanned, unreadable int
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// 无权限等错误只提示，继续扫描其余部分
+			colorYellow.Printf(msg("scan.inaccessible"), path, err)
+			unreadable++
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if extensions != nil && !extensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			colorYellow.Printf(msg("scan.inaccessible"), path, err)
+			unreadable++
+			return nil
+		}
+		if info.Size() < minSize {
+			return nil
+		}
+
+		scanned++
+		result, err := scanFile(path, info.Size(), opts.StealthKey)
+		if err != nil {
+			colorYellow.Printf(msg("scan.unreadable"), path, err)
+			unreadable++
+			return nil
+		}
+		if result != nil {
+			results = append(results, *result)
+			colorGreen.Printf("✅ %s\n", path)
+			if opts.Format == FORMAT_TEXT {
+				printResultPath(path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return newError("scan.failed", err)
+	}
+
+	if opts.Format != FORMAT_TEXT {
+		if err := writeScanResults(resultOutput, opts.Format, results, false); err != nil {
+			return err
+		}
+	}
+
+	colorBlue.Printf(msg("scan.summary"), scanned, len(results))
+	if unreadable > 0 {
+		colorYellow.Printf(msg("scan.summary_inaccessible"), unreadable)
+	}
+	fmt.Fprintln(messageOutput)
+	for _, result := range results {
+		fmt.Fprintf(messageOutput, "   📦 %s\n", result.Path)
+		fmt.Fprintf(messageOutput, "      📎 %s", result.AttachName)
+		if result.AttachCount > 1 {
+			fmt.Fprintf(messageOutput, msg("scan.more"), result.AttachCount)
+		}
+		fmt.Fprintf(messageOutput, " (%s)", formatFileSize(int64(result.AttachSize)))
+		if result.Encrypted {
+			fmt.Fprintf(messageOutput, " 🔒")
+		}
+		if result.Stealth {
+			fmt.Fprintf(messageOutput, " 🕶️")
+		}
+		fmt.Fprintln(messageOutput)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 表示从标准输入读取附加数据的路径参数
+const STDIN_PATH = "-"
+
+// 标准输入附加文件信息：大小未知，写入完成后才能确定
+func stdinAttachInfo(attachName string) (*FileInfo, error) {
+	if attachName == "" {
+		return nil, exitErrorf(EXIT_USAGE, "stdin.needs_name")
+	}
+	return &FileInfo{
+		Name:    attachName,
+		Size:    -1,
+		Path:    STDIN_PATH,
+		IsStdin: true,
+		ModTime: time.Now(),
+		Mode:    0644,
+	}, nil
+}
+
+// 是否包含标准输入附加路径
+func hasStdinPath(paths []string) bool {
+	for _, path := range paths {
+		if path == STDIN_PATH {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffReader 在读取时保留开头的数据，用于事后识别MIME类型
+type sniffReader struct {
+	r    io.Reader
+	head []byte
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if remaining := MIME_SNIFF_LENGTH - len(s.head); remaining > 0 && n > 0 {
+		if remaining > n {
+			remaining = n
+		}
+		s.head = append(s.head, p[:remaining]...)
+	}
+	return n, err
+}
+
+// 从标准输入流式复制附加数据，返回识别出的MIME类型
+func copyStdinAttachment(ctx context.Context, progress progressSource, dst io.Writer) (string, error) {
+	reader := &sniffReader{r: os.Stdin}
+	if _, err := copyWithProgress(ctx, dst, reader, -1, progress.forCopy(msg("progress.stdin"))); err != nil {
+		return "", newError("stdin.read_failed", err)
+	}
+	return http.DetectContentType(reader.head), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os/signal"
+	"syscall"
+)
+
+// 下游程序提前关闭管道
+var errBrokenPipe = newError("stdout.broken_pipe")
+
+// pipeWriter 把 EPIPE 转为明确的错误，而不是让进程被 SIGPIPE 终止
+type pipeWriter struct {
+	w io.Writer
+}
+
+func (p *pipeWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	if errors.Is(err, syscall.EPIPE) {
+		return n, errBrokenPipe
+	}
+	return n, err
+}
+
+// 把唯一的附加文件流式写到标准输出，不提取视频；目录附加文件输出其 tar 归档
+func streamAttachmentToStdout(ctx context.Context, progress progressSource, mergedFile io.ReaderAt, trailer *TrailerInfo, aead cipher.AEAD, skipCRC bool, debugInfo *DebugInfo) error {
+	if len(trailer.Attachments) != 1 {
+		return exitErrorf(EXIT_USAGE, "stdout.multiple_attachments", len(trailer.Attachments))
+	}
+	entry := trailer.Attachments[0]
+
+	// 写入已关闭的管道时返回错误而不是直接退出
+	signal.Ignore(syscall.SIGPIPE)
+
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	reader, err := openAttachmentReader(mergedFile, trailer, 0, io.MultiWriter(attachHash, attachCRC), aead)
+	if err != nil {
+		return newError("split.read_attach_failed", entry.Name, err)
+	}
+
+	colorCyan.Printf(msg("stdout.start"), entry.Name)
+	if entry.IsDir {
+		fmt.Fprintln(messageOutput, msg("stdout.dir_as_tar"))
+	}
+	if _, err := copyWithProgress(ctx, &pipeWriter{w: resultOutput}, reader, int64(entry.OriginalSize), progress.forCopy(msg("progress.attachment"))); err != nil {
+		return exitErrorf(EXIT_IO, "stdout.write_failed", err)
+	}
+
+	debugInfo.ActualAttachSHA256 = hex.EncodeToString(attachHash.Sum(nil))
+	debugInfo.ActualAttachCRC32 = formatCRC32(attachCRC.Sum32())
+	if trailer.AttachCRC32 != "" && !skipCRC && trailer.AttachCRC32 != debugInfo.ActualAttachCRC32 {
+		debugInfo.ValidationError = msg("split.attach_crc_mismatch")
+		return exitErrorf(EXIT_INVALID_FORMAT, "stdout.crc_failed", trailer.AttachCRC32, debugInfo.ActualAttachCRC32)
+	}
+	if trailer.AttachSHA256 != "" && trailer.AttachSHA256 != debugInfo.ActualAttachSHA256 {
+		debugInfo.ValidationError = msg("split.attach_sha_mismatch")
+		return exitErrorf(EXIT_INVALID_FORMAT, "stdout.sha_failed", trailer.AttachSHA256, debugInfo.ActualAttachSHA256)
+	}
+
+	colorGreen.Printf(msg("stdout.done"), entry.Name, formatFileSize(int64(entry.OriginalSize)))
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+)
+
+// 隐蔽模式：整个尾部元数据（含 MERGEDv3 魔术字节）用口令派生的密钥以 AES-256-GCM 加密，
+// 文件末尾只剩随机盐和由密钥计算的标记，没有口令时与普通视频文件无法区分。
+// 格式：[视频] + [附加文件] + [加密的尾部元数据] + [掩码后的长度(4字节)] + [标记(8字节)] + [盐(16字节)]
+const (
+	// 隐蔽模式盐长度
+	STEALTH_SALT_LENGTH = 16
+	// 隐蔽标记长度（替代魔术字节）
+	STEALTH_MARKER_LENGTH = 8
+	// 隐蔽模式末尾固定部分长度
+	STEALTH_FOOTER_LENGTH = UINT32_LENGTH + STEALTH_MARKER_LENGTH + STEALTH_SALT_LENGTH
+	// 加密尾部元数据的最大长度
+	MAX_STEALTH_TRAILER_LENGTH = MAX_EXT_LENGTH + MAX_FILENAME_LENGTH + 64
+)
+
+// 隐蔽模式派生的密钥材料
+type stealthKeys struct {
+	key    []byte
+	marker []byte
+	mask   uint32
+}
+
+// 由口令和盐派生加密密钥、标记和长度掩码
+func deriveStealthKeys(passphrase string, salt []byte) (*stealthKeys, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, PBKDF2_ITERATIONS, KEY_LENGTH)
+	if err != nil {
+		return nil, newError("crypto.kdf_failed", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("stealth-marker"))
+	sum := mac.Sum(nil)
+
+	return &stealthKeys{
+		key:    key,
+		marker: sum[:STEALTH_MARKER_LENGTH],
+		mask:   binary.LittleEndian.Uint32(sum[STEALTH_MARKER_LENGTH : STEALTH_MARKER_LENGTH+UINT32_LENGTH]),
+	}, nil
+}
+
+// 创建隐蔽模式的 AEAD（每个文件的盐随机，密钥只使用一次，nonce 固定为零）
+func newStealthAEAD(keys *stealthKeys) (cipher.AEAD, []byte, error) {
+	block, err := aes.NewCipher(keys.key)
+	if err != nil {
+		return nil, nil, newError("crypto.init_failed", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, newError("crypto.init_failed", err)
+	}
+	return aead, make([]byte, aead.NonceSize()), nil
+}
+
+// 加密尾部元数据，返回写入文件末尾的完整隐蔽尾部
+func sealStealthTrailer(trailer []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, STEALTH_SALT_LENGTH)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, newError("crypto.salt_failed", err)
+	}
+
+	keys, err := deriveStealthKeys(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, nonce, err := newStealthAEAD(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nil, nonce, trailer, nil)
+	sealed = binary.LittleEndian.AppendUint32(sealed, uint32(len(sealed))^keys.mask)
+	sealed = append(sealed, keys.marker...)
+	return append(sealed, salt...), nil
+}
+
+// 尝试用口令解密隐蔽尾部，返回附加数据结束位置和明文尾部元数据
+func openStealthTrailer(r io.ReaderAt, fileSize int64, passphrase string) (int64, []byte, error) {
+	if fileSize < STEALTH_FOOTER_LENGTH {
+		return 0, nil, newError("stealth.too_small")
+	}
+
+	footer := make([]byte, STEALTH_FOOTER_LENGTH)
+	if err := readTrailerAt(r, footer, fileSize-STEALTH_FOOTER_LENGTH, nil); err != nil {
+		return 0, nil, newError("stealth.read_failed", err)
+	}
+
+	keys, err := deriveStealthKeys(passphrase, footer[UINT32_LENGTH+STEALTH_MARKER_LENGTH:])
+	if err != nil {
+		return 0, nil, err
+	}
+	if !hmac.Equal(footer[UINT32_LENGTH:UINT32_LENGTH+STEALTH_MARKER_LENGTH], keys.marker) {
+		return 0, nil, newError("stealth.marker_mismatch")
+	}
+
+	sealedLength := int64(binary.LittleEndian.Uint32(footer[:UINT32_LENGTH]) ^ keys.mask)
+	if sealedLength > MAX_STEALTH_TRAILER_LENGTH || sealedLength > fileSize-STEALTH_FOOTER_LENGTH {
+		return 0, nil, newError("stealth.bad_length", sealedLength)
+	}
+
+	dataEnd := fileSize - STEALTH_FOOTER_LENGTH - sealedLength
+	sealed := make([]byte, sealedLength)
+	if err := readTrailerAt(r, sealed, dataEnd, nil); err != nil {
+		return 0, nil, newError("stealth.read_failed", err)
+	}
+
+	aead, nonce, err := newStealthAEAD(keys)
+	if err != nil {
+		return 0, nil, err
+	}
+	trailer, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return 0, nil, newError("stealth.decrypt_failed", err)
+	}
+
+	return dataEnd, trailer, nil
+}
+
+// 拼接真实数据区和解密后的尾部元数据，按普通v3文件解析
+type stealthSource struct {
+	file    io.ReaderAt
+	dataEnd int64
+	trailer []byte
+}
+
+func (s *stealthSource) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	if off < s.dataEnd {
+		limit := len(p)
+		if int64(limit) > s.dataEnd-off {
+			limit = int(s.dataEnd - off)
+		}
+		read, err := s.file.ReadAt(p[:limit], off)
+		n += read
+		if err != nil {
+			return n, err
+		}
+		off += int64(read)
+	}
+
+	if n < len(p) {
+		pos := off - s.dataEnd
+		if pos >= int64(len(s.trailer)) {
+			return n, io.EOF
+		}
+		copied := copy(p[n:], s.trailer[pos:])
+		n += copied
+		if n < len(p) {
+			return n, io.EOF
+		}
+	}
+
+	return n, nil
+}
+
+// 读取合并文件元数据：指定隐蔽密钥时先尝试隐蔽解析，失败后按普通格式解析
+func loadTrailer(mergedFile io.ReaderAt, fileSize int64, stealthKey string, debugInfo *DebugInfo) (*TrailerInfo, error) {
+	// 元数据都在文件末尾：一次读入末尾一个缓冲区，各字段从内存解析，
+	// 只有超出这一范围的部分（如很长的附加文件列表）才再读文件
+	reader := newTailReader(mergedFile, fileSize)
+	tail := traceReads(reader)
+	if stealthKey == "" {
+		return parseTrailer(tail, fileSize, debugInfo)
+	}
+
+	debugInfo.StealthAttempted = true
+	dataEnd, trailer, err := openStealthTrailer(tail, fileSize, stealthKey)
+	if err != nil {
+		debugInfo.StealthError = err.Error()
+		return parseTrailer(tail, fileSize, debugInfo)
+	}
+
+	logDebugf("devlog.stealth_opened", dataEnd, len(trailer))
+	source := &stealthSource{file: reader, dataEnd: dataEnd, trailer: trailer}
+	info, err := parseTrailer(traceReads(source), dataEnd+int64(len(trailer)), debugInfo)
+	if err != nil {
+		debugInfo.StealthError = msgf("stealth.bad_metadata", err)
+		return nil, err
+	}
+
+	info.Stealth = true
+	info.FileSize = fileSize
+	debugInfo.StealthDetected = true
+	return info, nil
+}
+
+// 将尾部元数据按需加密后写入
+func writeTrailer(dst io.Writer, trailer *bytes.Buffer, stealthKey string) (int, error) {
+	data := trailer.Bytes()
+	if stealthKey != "" {
+		sealed, err := sealStealthTrailer(data, stealthKey)
+		if err != nil {
+			return 0, err
+		}
+		data = sealed
+	}
+
+	if _, err := dst.Write(data); err != nil {
+		return 0, newError("error.write_metadata_failed", err)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// 合并和读取使用同一个 --stealth-key：合并结果没有魔术字节，只有给出密钥才能识别和拆分
+func TestMergeStealthKey(t *testing.T) {
+	videoPath := writeTempFile(t, "v.mp4", bytes.Repeat([]byte("video "), 1000))
+	attachPath := writeTempFile(t, "a.txt", []byte("attachment"))
+	outputPath := filepath.Join(t.TempDir(), "out.mp4")
+
+	if code, _, stderr := runMain(t, "merge", "--stealth-key", "k", videoPath, attachPath, outputPath); code != EXIT_OK {
+		t.Fatalf("merge exit code %d: %s", code, stderr)
+	}
+	if code, _, _ := runMain(t, "detect", outputPath); code != DETECT_EXIT_PLAIN {
+		t.Errorf("detect without key: exit code %d, want %d", code, DETECT_EXIT_PLAIN)
+	}
+	if code, _, _ := runMain(t, "detect", "--stealth-key", "k", outputPath); code != DETECT_EXIT_MERGED {
+		t.Errorf("detect with key: exit code %d, want %d", code, DETECT_EXIT_MERGED)
+	}
+	if code, _, stderr := runMain(t, "split", "--stealth-key", "k", outputPath, "-o", t.TempDir()); code != EXIT_OK {
+		t.Errorf("split with key: exit code %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cancundeyingzi/Video-File-Merge-Split-Tool/pkg/mergefmt"
+)
+
+// 扩展块格式见 mergefmt 包说明；旧版v3文件没有扩展块，解析时自动按原结构处理
+const (
+	EXT_MAGIC                = mergefmt.EXT_MAGIC
+	EXT_MAGIC_LENGTH         = mergefmt.EXT_MAGIC_LENGTH
+	EXT_RECORD_HEADER_LENGTH = mergefmt.EXT_RECORD_HEADER_LENGTH
+	MAX_EXT_LENGTH           = mergefmt.MAX_EXT_LENGTH
+
+	EXT_TAG_VIDEO_SHA256  = mergefmt.EXT_TAG_VIDEO_SHA256
+	EXT_TAG_ATTACH_SHA256 = mergefmt.EXT_TAG_ATTACH_SHA256
+	EXT_TAG_ATTACHMENTS   = mergefmt.EXT_TAG_ATTACHMENTS
+	EXT_TAG_DIR_ARCHIVES  = mergefmt.EXT_TAG_DIR_ARCHIVES
+	EXT_TAG_FILE_ATTRS    = mergefmt.EXT_TAG_FILE_ATTRS
+	EXT_TAG_FLAGS         = mergefmt.EXT_TAG_FLAGS
+	// 加密参数：见 EncryptionParams
+	EXT_TAG_ENCRYPTION = mergefmt.EXT_TAG_ENCRYPTION
+	// 压缩信息：见 encodeCompression
+	EXT_TAG_COMPRESSION  = mergefmt.EXT_TAG_COMPRESSION
+	EXT_TAG_VIDEO_NAME   = mergefmt.EXT_TAG_VIDEO_NAME
+	EXT_TAG_ATTACH_CRC32 = mergefmt.EXT_TAG_ATTACH_CRC32
+	// 附加文件MIME类型（内容嗅探）：见 encodeMimeTypes
+	EXT_TAG_MIME_TYPES = mergefmt.EXT_TAG_MIME_TYPES
+	EXT_TAG_COMMENT    = mergefmt.EXT_TAG_COMMENT
+	EXT_TAG_PADDING    = mergefmt.EXT_TAG_PADDING
+	EXT_TAG_BUILD_INFO = mergefmt.EXT_TAG_BUILD_INFO
+	EXT_TAG_ZIP_LAYOUT = mergefmt.EXT_TAG_ZIP_LAYOUT
+	EXT_TAG_MP4_BOX    = mergefmt.EXT_TAG_MP4_BOX
+
+	EXT_TAG_MKV_ATTACHMENT = mergefmt.EXT_TAG_MKV_ATTACHMENT
+	EXT_TAG_AUTH           = mergefmt.EXT_TAG_AUTH
+	EXT_TAG_FEC            = mergefmt.EXT_TAG_FEC
+
+	MAX_COMMENT_LENGTH   = mergefmt.MAX_COMMENT_LENGTH
+	MAX_ALIGNMENT        = mergefmt.MAX_ALIGNMENT
+	MAX_FEC_LENGTH       = mergefmt.MAX_FEC_LENGTH
+	BUILD_VERSION_LENGTH = mergefmt.BUILD_VERSION_LENGTH
+	BUILD_INFO_LENGTH    = mergefmt.BUILD_INFO_LENGTH
+
+	// 单条文件属性长度
+	FILE_ATTR_LENGTH = SIZE_LENGTH + UINT32_LENGTH
+
+	FLAG_ENCRYPTED  = mergefmt.FLAG_ENCRYPTED
+	FLAG_COMPRESSED = mergefmt.FLAG_COMPRESSED
+)
+
+// 由魔术字节判断格式版本，无法识别时返回 0
+func formatVersionOf(magic string) int {
+	if magic == magicBytes {
+		return CURRENT_FORMAT_VERSION
+	}
+	return 0
+}
+
+// extRecord 扩展块中的一条记录
+type extRecord = mergefmt.Record
+
+// MKV 附件嵌入布局，格式见 mergefmt.MKVLayout
+type mkvLayout = mergefmt.MKVLayout
+
+// AttachmentEntry 单个附加文件在合并文件中的位置
+type AttachmentEntry struct {
+	Name   string `json:"name"`
+	Size   uint64 `json:"size"`
+	Offset int64  `json:"offset"`
+	IsDir  bool   `json:"is_dir,omitempty"`
+
+	// 原始修改时间和权限位（旧版文件没有记录）
+	ModTime *time.Time `json:"mod_time,omitempty"`
+	Mode    uint32     `json:"mode,omitempty"`
+
+	// 合并时嗅探的MIME类型（旧版文件没有记录）
+	MimeType string `json:"mime_type,omitempty"`
+
+	// 压缩、加密等处理前的原始大小，未处理时与 Size 相同
+	OriginalSize uint64 `json:"original_size"`
+}
+
+// TrailerInfo v3格式尾部元数据解析结果
+type TrailerInfo struct {
+	FormatVersion int               `json:"format_version"`
+	Stealth       bool              `json:"stealth,omitempty"`
+	FileSize      int64             `json:"file_size"`
+	VideoSize     uint64            `json:"video_size"`
+	AttachSize    uint64            `json:"attach_size"`
+	Padding       uint64            `json:"padding,omitempty"`
+	ZipHeader     uint64            `json:"zip_header,omitempty"`
+	ZipDirectory  uint64            `json:"zip_directory,omitempty"`
+	BoxHeader     uint64            `json:"mp4_box_header,omitempty"`
+	MKV           *mkvLayout        `json:"mkv_attachment,omitempty"`
+	FEC           uint64            `json:"fec_length,omitempty"`
+	NameLength    uint32            `json:"filename_length"`
+	AttachName    string            `json:"filename"`
+	VideoName     string            `json:"video_name,omitempty"`
+	Comment       string            `json:"comment,omitempty"`
+	ToolVersion   string            `json:"tool_version,omitempty"`
+	CreatedAt     *time.Time        `json:"created_at,omitempty"`
+	ExtLength     uint32            `json:"extension_length"`
+	VideoSHA256   string            `json:"video_sha256,omitempty"`
+	AttachSHA256  string            `json:"attach_sha256,omitempty"`
+	AttachCRC32   string            `json:"attach_crc32,omitempty"`
+	Flags         uint32            `json:"flags"`
+	Encrypted     bool              `json:"encrypted"`
+	KeyFile       bool              `json:"key_file,omitempty"`
+	Authenticated bool              `json:"authenticated,omitempty"`
+	Compressed    bool              `json:"compressed"`
+	Compression   string            `json:"compression,omitempty"`
+	Attachments   []AttachmentEntry `json:"attachments"`
+	Offsets       map[string]int64  `json:"offsets"`
+
+	// 加密参数（仅加密文件）
+	Encryption *EncryptionParams `json:"-"`
+
+	// 目录归档附加文件的序号、文件属性和压缩前大小，附加文件列表确定后再应用
+	dirIndexes    []uint32
+	fileAttrs     []byte
+	mimeTypes     []byte
+	originalSizes []uint64
+
+	// 带认证记录时保留的整段尾部元数据（解密后），供校验认证值
+	metadata []byte
+}
+
+// trailerSpec 生成尾部元数据所需的信息
+type trailerSpec struct {
+	VideoSize    int64
+	Padding      int64
+	ZipHeader    int64
+	ZipDirectory int64
+	BoxHeader    int64
+	MKV          *mkvLayout
+	VideoName    string
+	VideoSHA256  []byte
+	AttachSHA256 []byte
+	AttachCRC32  uint32
+	Comment      string
+	ToolVersion  string
+	CreatedAt    *time.Time
+	Attachments  []AttachmentEntry
+	Encryption   *EncryptionParams
+	Compressed   bool
+	// 非空时写入认证值
+	AuthKey string
+	// 附加数据之后的纠错校验块长度，0 表示没有
+	FEC uint64
+}
+
+// 生成完整的尾部元数据，格式见 mergefmt.EncodeTrailer
+// 目录归档、文件属性、MIME类型、加密和压缩记录由命令行工具附加在包内已知记录之后
+func buildTrailer(spec *trailerSpec) (*bytes.Buffer, error) {
+	attachments := make([]mergefmt.Attachment, len(spec.Attachments))
+	for i, entry := range spec.Attachments {
+		attachments[i] = mergefmt.Attachment{Name: entry.Name, Size: entry.Size, Offset: entry.Offset}
+	}
+	attachCRC32 := spec.AttachCRC32
+	trailer := &mergefmt.Trailer{
+		Magic:        magicBytes,
+		VideoSize:    uint64(spec.VideoSize),
+		Padding:      uint64(spec.Padding),
+		ZipHeader:    uint64(spec.ZipHeader),
+		ZipDirectory: uint64(spec.ZipDirectory),
+		BoxHeader:    uint64(spec.BoxHeader),
+		MKV:          spec.MKV,
+		FEC:          spec.FEC,
+		Attachments:  attachments,
+		VideoSHA256:  spec.VideoSHA256,
+		AttachSHA256: spec.AttachSHA256,
+		AttachCRC32:  &attachCRC32,
+		Comment:      spec.Comment,
+		ToolVersion:  spec.ToolVersion,
+		CreatedAt:    spec.CreatedAt,
+	}
+	if videoName, err := validateAndCleanFilename(spec.VideoName); err == nil {
+		trailer.VideoName = videoName
+	}
+
+	if dirIndexes := encodeDirIndexes(spec.Attachments); len(dirIndexes) > 0 {
+		trailer.Records = append(trailer.Records, extRecord{Tag: EXT_TAG_DIR_ARCHIVES, Value: dirIndexes})
+	}
+	trailer.Records = append(trailer.Records,
+		extRecord{Tag: EXT_TAG_FILE_ATTRS, Value: encodeFileAttrs(spec.Attachments)},
+		extRecord{Tag: EXT_TAG_MIME_TYPES, Value: encodeMimeTypes(spec.Attachments)},
+	)
+	if spec.Encryption != nil {
+		trailer.Flags |= FLAG_ENCRYPTED
+		trailer.Records = append(trailer.Records, extRecord{Tag: EXT_TAG_ENCRYPTION, Value: encodeEncryptionParams(spec.Encryption)})
+	}
+	if spec.Compressed {
+		trailer.Flags |= FLAG_COMPRESSED
+		trailer.Records = append(trailer.Records, extRecord{Tag: EXT_TAG_COMPRESSION, Value: encodeCompression(COMPRESS_GZIP, spec.Attachments)})
+	}
+	if spec.AuthKey != "" {
+		trailer.AuthKey = []byte(spec.AuthKey)
+	}
+
+	metadata, err := mergefmt.EncodeTrailer(trailer)
+	if err != nil {
+		return nil, localizeFormatError(err)
+	}
+	return bytes.NewBuffer(metadata), nil
+}
+
+// 把 mergefmt 的格式错误换成当前语言的 ext.* 消息，没有对应消息时原样返回
+func localizeFormatError(err error) error {
+	var formatErr *mergefmt.FormatError
+	if !errors.As(err, &formatErr) {
+		return err
+	}
+	if _, ok := messages["ext."+formatErr.Code]; !ok {
+		return err
+	}
+	return newError("ext."+formatErr.Code, formatErr.Args...)
+}
+
+// CRC32 使用 Castagnoli 多项式（多数CPU有硬件加速）
+var crc32cTable = mergefmt.CRC32C
+
+// CRC32 显示格式
+func formatCRC32(sum uint32) string {
+	return fmt.Sprintf("%08x", sum)
+}
+
+// 编码目录归档附加文件序号列表
+func encodeDirIndexes(entries []AttachmentEntry) []byte {
+	var buf []byte
+	for i, entry := range entries {
+		if entry.IsDir {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(i))
+		}
+	}
+	return buf
+}
+
+// 编码附加文件属性
+func encodeFileAttrs(entries []AttachmentEntry) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(entries)))
+	for _, entry := range entries {
+		var modTime int64
+		if entry.ModTime != nil {
+			modTime = entry.ModTime.UnixNano()
+		}
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(modTime))
+		buf = binary.LittleEndian.AppendUint32(buf, entry.Mode)
+	}
+	return buf
+}
+
+// 解析附加文件属性并应用到附加文件列表
+func applyFileAttrs(entries []AttachmentEntry, value []byte) error {
+	if len(value) < UINT32_LENGTH {
+		return newError("ext.attrs_incomplete")
+	}
+
+	count := binary.LittleEndian.Uint32(value[:UINT32_LENGTH])
+	if int(count) != len(entries) || len(value) != UINT32_LENGTH+int(count)*FILE_ATTR_LENGTH {
+		return newError("ext.attrs_count_mismatch", count, len(entries))
+	}
+
+	pos := UINT32_LENGTH
+	for i := range entries {
+		modTime := int64(binary.LittleEndian.Uint64(value[pos : pos+SIZE_LENGTH]))
+		if modTime != 0 {
+			t := time.Unix(0, modTime)
+			entries[i].ModTime = &t
+		}
+		entries[i].Mode = binary.LittleEndian.Uint32(value[pos+SIZE_LENGTH : pos+FILE_ATTR_LENGTH])
+		pos += FILE_ATTR_LENGTH
+	}
+
+	return nil
+}
+
+// 将本包自行解析的扩展记录（mergefmt.Trailer.Records）应用到解析结果
+func applyExtensionRecords(info *TrailerInfo, records []extRecord) error {
+	for _, record := range records {
+		switch record.Tag {
+		case EXT_TAG_DIR_ARCHIVES:
+			if len(record.Value)%UINT32_LENGTH != 0 {
+				return newError("ext.dirs_bad_length", len(record.Value))
+			}
+			for pos := 0; pos < len(record.Value); pos += UINT32_LENGTH {
+				info.dirIndexes = append(info.dirIndexes, binary.LittleEndian.Uint32(record.Value[pos:pos+UINT32_LENGTH]))
+			}
+		case EXT_TAG_FILE_ATTRS:
+			info.fileAttrs = record.Value
+		case EXT_TAG_MIME_TYPES:
+			info.mimeTypes = record.Value
+		case EXT_TAG_ENCRYPTION:
+			params, err := decodeEncryptionParams(record.Value)
+			if err != nil {
+				return err
+			}
+			info.Encryption = params
+			info.KeyFile = params.KDF == KDF_KEY_FILE
+		case EXT_TAG_COMPRESSION:
+			algorithm, sizes, err := decodeCompression(record.Value)
+			if err != nil {
+				return err
+			}
+			info.Compression = compressionName(algorithm)
+			info.originalSizes = sizes
+		}
+	}
+
+	return nil
+}
+
+// 格式化工具版本，未记录时显示 unknown
+func formatToolVersion(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
+
+// 附加数据区起始位置（视频之后，跳过对齐填充、ZIP本地文件头、MP4 box 头和 MKV 元素头）
+func attachStartOf(info *TrailerInfo) int64 {
+	return int64(info.VideoSize + info.Padding + info.ZipHeader + info.BoxHeader + info.MKV.HeaderLength())
+}
+
+// 按原始内容读取视频区域，MKV 附件嵌入时 Segment 大小字段读出的是合并前的字节
+func videoSourceOf(r io.ReaderAt, info *TrailerInfo) io.ReaderAt {
+	return info.MKV.OriginalVideo(r)
+}
+
+// 计算对齐到 alignment 的倍数所需的填充长度
+func alignPadding(size, alignment int64) int64 {
+	return mergefmt.AlignPadding(size, alignment)
+}
+
+// 读取尾部元数据时遇到意外的文件结尾
+var errTrailerEOF = newError("trailer.unexpected_eof")
+
+// 从指定偏移读满 buf；数据不足时返回 errTrailerEOF，并在调试信息中记录偏移
+func readTrailerAt(r io.ReaderAt, buf []byte, offset int64, debugInfo *DebugInfo) error {
+	_, err := io.ReadFull(io.NewSectionReader(r, offset, int64(len(buf))), buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if debugInfo != nil {
+			debugInfo.UnexpectedEOF = true
+			debugInfo.UnexpectedEOFOffset = offset
+			debugInfo.UnexpectedEOFLength = len(buf)
+		}
+		logDebugf("debug.unexpected_eof", offset, len(buf))
+		return errTrailerEOF
+	}
+	return err
+}
+
+// eofRecorder 解析尾部时读到文件结尾之外（大小字段与实际文件不符）时在调试信息中记录偏移
+type eofRecorder struct {
+	r         io.ReaderAt
+	debugInfo *DebugInfo
+}
+
+func (e eofRecorder) ReadAt(p []byte, off int64) (int, error) {
+	n, err := e.r.ReadAt(p, off)
+	if err == io.EOF && n < len(p) {
+		e.debugInfo.UnexpectedEOF = true
+		e.debugInfo.UnexpectedEOFOffset = off
+		e.debugInfo.UnexpectedEOFLength = len(p)
+		logDebugf("debug.unexpected_eof", off, len(p))
+	}
+	return n, err
+}
+
+// 扩展记录解析错误按错误代码前缀对应的消息，其余扩展记录错误归入 trailer.bad_ext
+var trailerRecordErrors = []struct{ prefix, id string }{
+	{"padding", "trailer.bad_padding"},
+	{"zip_layout", "trailer.bad_zip_layout"},
+	{"mp4_box", "trailer.bad_mp4_box"},
+	{"mkv_layout", "trailer.bad_mkv_attachment"},
+	{"fec", "trailer.bad_fec"},
+}
+
+// 把 mergefmt.DecodeTrailer 的错误换成带退出码的本地化错误，并记录调试信息
+func trailerDecodeError(r io.ReaderAt, fileSize int64, err error, debugInfo *DebugInfo) error {
+	if errors.Is(err, mergefmt.ErrNotMerged) {
+		return notMergedTrailerError(r, fileSize, debugInfo)
+	}
+	recordTrailerSizes(r, fileSize, debugInfo)
+
+	var formatErr *mergefmt.FormatError
+	if !errors.As(err, &formatErr) {
+		// 大小字段指向文件之外时读到意外的文件结尾，其余为读取失败
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			debugInfo.ValidationError = msgf("trailer.read_failed", errTrailerEOF)
+			return exitErrorf(EXIT_INVALID_FORMAT, "trailer.read_failed", errTrailerEOF)
+		}
+		debugInfo.ValidationError = msgf("trailer.read_failed", err)
+		return exitErrorf(EXIT_IO, "trailer.read_failed", err)
+	}
+
+	switch formatErr.Code {
+	case "bad_video_size", "bad_attach_size", "bad_name_length", "list_size_mismatch":
+		debugInfo.ValidationError = msgf("trailer."+formatErr.Code, formatErr.Args...)
+		return exitErrorf(EXIT_INVALID_FORMAT, "trailer."+formatErr.Code+"_fmt", formatErr.Args...)
+	case "invalid_name":
+		debugInfo.ValidationError = msg("filename.invalid_utf8")
+		return exitErrorf(EXIT_INVALID_FORMAT, "filename.invalid_utf8")
+	case "structure_mismatch":
+		expected, actual := formatErr.Args[0].(int64), formatErr.Args[1].(int64)
+		debugInfo.ValidationError = msgf("trailer.structure_mismatch", expected, actual)
+		return withExitCode(EXIT_INVALID_FORMAT, &ErrStructureInvalid{Expected: expected, Actual: actual})
+	}
+
+	id := "trailer.bad_ext"
+	for _, record := range trailerRecordErrors {
+		if strings.HasPrefix(formatErr.Code, record.prefix) {
+			id = record.id
+			break
+		}
+	}
+	err = localizeFormatError(err)
+	debugInfo.ValidationError = msgf(id, err)
+	return exitErrorf(EXIT_INVALID_FORMAT, id+"_fmt", err)
+}
+
+// 末尾不是当前魔术字节：记录实际的魔术字节
+func notMergedTrailerError(r io.ReaderAt, fileSize int64, debugInfo *DebugInfo) error {
+	if fileSize < MIN_V3_FILE_SIZE {
+		debugInfo.ValidationError = msgf("trailer.too_small", fileSize, MIN_V3_FILE_SIZE)
+		return notMergedErrorf("trailer.too_small_invalid")
+	}
+
+	magicBuffer := make([]byte, MAGIC_LENGTH)
+	if err := readTrailerAt(r, magicBuffer, fileSize-int64(MAGIC_LENGTH), debugInfo); err != nil {
+		debugInfo.ValidationError = msgf("trailer.read_magic_failed", err)
+		return exitErrorf(EXIT_IO, "trailer.read_magic_failed", err)
+	}
+	debugInfo.MagicBytes = string(magicBuffer)
+	debugInfo.ValidationError = msgf("trailer.magic_mismatch", magicBytes, string(magicBuffer))
+	return withExitCode(EXIT_NOT_MERGED, ErrNotMergedFile)
+}
+
+// 解析失败时把末尾的大小字段记入调试信息，读取失败时忽略
+func recordTrailerSizes(r io.ReaderAt, fileSize int64, debugInfo *DebugInfo) {
+	fixed := make([]byte, SIZE_LENGTH*2+MAGIC_LENGTH)
+	if _, err := r.ReadAt(fixed, fileSize-int64(len(fixed))); err != nil {
+		return
+	}
+	debugInfo.MagicBytes = magicBytes
+	debugInfo.FormatVersion = CURRENT_FORMAT_VERSION
+	debugInfo.VideoSize = binary.LittleEndian.Uint64(fixed[:SIZE_LENGTH])
+	debugInfo.AttachSize = binary.LittleEndian.Uint64(fixed[SIZE_LENGTH : SIZE_LENGTH*2])
+}
+
+// 解析格式尾部元数据（固定位置读取），不创建任何输出。
+// 格式本身由 mergefmt.DecodeTrailer 解析，这里补充调试信息、嵌入结构检查和命令行工具自己的扩展记录
+func parseTrailer(mergedFile io.ReaderAt, fileSize int64, debugInfo *DebugInfo) (*TrailerInfo, error) {
+	// 固定字段位于文件末尾（魔术字节、附加文件大小、视频大小）
+	magicPos := fileSize - int64(MAGIC_LENGTH)
+	debugInfo.CalculatedPos["magic_bytes"] = magicPos
+	debugInfo.CalculatedPos["attach_size"] = fileSize - int64(MAGIC_LENGTH+SIZE_LENGTH)
+	debugInfo.CalculatedPos["video_size"] = fileSize - int64(MAGIC_LENGTH+SIZE_LENGTH*2)
+
+	trailer, err := mergefmt.DecodeTrailer(eofRecorder{r: mergedFile, debugInfo: debugInfo}, fileSize, mergefmt.WithMagic(magicBytes))
+	if err != nil {
+		return nil, trailerDecodeError(mergedFile, fileSize, err, debugInfo)
+	}
+
+	videoSize, attachSize := trailer.VideoSize, trailer.AttachSize
+	debugInfo.MagicBytes = magicBytes
+	debugInfo.FormatVersion = CURRENT_FORMAT_VERSION
+	debugInfo.VideoSize = videoSize
+	debugInfo.AttachSize = attachSize
+	debugInfo.FilenameLength = uint32(len(trailer.Name))
+	debugInfo.Filename = trailer.Name
+	debugInfo.Padding = trailer.Padding
+	logDebugf("devlog.magic_ok", magicPos, CURRENT_FORMAT_VERSION)
+	logDebugf("devlog.sizes_ok", videoSize, attachSize)
+
+	// 各区域位置：对齐填充、ZIP结构、box 头、MKV 元素头和纠错校验块
+	padding := trailer.Padding
+	attachStart := uint64(trailer.AttachStart())
+	if padding > 0 {
+		debugInfo.CalculatedPos["padding_start"] = int64(videoSize)
+		debugInfo.CalculatedPos["attach_start"] = int64(attachStart)
+	}
+	if trailer.ZipHeader > 0 {
+		debugInfo.CalculatedPos["zip_header_start"] = int64(videoSize + padding)
+		debugInfo.CalculatedPos["attach_start"] = int64(attachStart)
+		debugInfo.CalculatedPos["zip_directory_start"] = int64(attachStart + attachSize)
+	}
+	if trailer.BoxHeader > 0 {
+		debugInfo.CalculatedPos["mp4_box_start"] = int64(videoSize + padding)
+		debugInfo.CalculatedPos["attach_start"] = int64(attachStart)
+	}
+	if trailer.MKV != nil {
+		debugInfo.CalculatedPos["mkv_attachments_start"] = int64(videoSize + padding)
+		debugInfo.CalculatedPos["attach_start"] = int64(attachStart)
+		debugInfo.CalculatedPos["mkv_void_start"] = int64(attachStart + attachSize)
+	}
+	if trailer.FEC > 0 {
+		debugInfo.CalculatedPos["fec_start"] = int64(attachStart + attachSize)
+	}
+	if trailer.ExtLength > 0 {
+		// 扩展块长度字段紧挨视频大小字段之前
+		extLengthPos := fileSize - int64(MAGIC_LENGTH+SIZE_LENGTH*2+UINT32_LENGTH)
+		debugInfo.CalculatedPos["extension_start"] = extLengthPos - int64(trailer.ExtLength)
+		debugInfo.CalculatedPos["extension_length"] = extLengthPos
+	}
+	metadataStart := trailer.MetadataStart()
+	debugInfo.CalculatedPos["metadata_start"] = metadataStart
+	logDebugf("devlog.metadata_start", metadataStart, padding, trailer.ExtLength)
+	logDebugf("devlog.structure_ok", fileSize)
+
+	// MP4 box 嵌入：从文件开头遍历顶层 box，确认附加数据所在的 free box 恰好延伸到文件末尾
+	if trailer.BoxHeader > 0 {
+		if err := locateEmbedBox(mergedFile, fileSize, int64(videoSize+padding), int64(trailer.BoxHeader)); err != nil {
+			debugInfo.ValidationError = msgf("trailer.bad_mp4_box", err)
+			return nil, exitErrorf(EXIT_INVALID_FORMAT, "trailer.bad_mp4_box_fmt", err)
+		}
+		logDebugf("devlog.mp4_box_ok", videoSize+padding)
+	}
+
+	// MKV 附件嵌入：确认视频之后是本工具写入的 Attachments 元素，且 Segment 覆盖到文件末尾
+	if trailer.MKV != nil {
+		if err := locateMKVAttachment(mergedFile, fileSize, int64(videoSize+padding), int64(attachStart+attachSize), trailer.MKV); err != nil {
+			debugInfo.ValidationError = msgf("trailer.bad_mkv_attachment", err)
+			return nil, exitErrorf(EXIT_INVALID_FORMAT, "trailer.bad_mkv_attachment_fmt", err)
+		}
+		logDebugf("devlog.mkv_ok", videoSize+padding)
+	}
+
+	// 记录各区域偏移，供 info 等命令展示
+	offsets := make(map[string]int64, len(debugInfo.CalculatedPos)+3)
+	for key, pos := range debugInfo.CalculatedPos {
+		offsets[key] = pos
+	}
+	offsets["video_start"] = 0
+	offsets["attach_start"] = int64(attachStart)
+	offsets["filename"] = metadataStart + int64(UINT32_LENGTH)
+
+	info := &TrailerInfo{
+		FormatVersion: CURRENT_FORMAT_VERSION,
+		FileSize:      fileSize,
+		VideoSize:     videoSize,
+		AttachSize:    attachSize,
+		Padding:       padding,
+		ZipHeader:     trailer.ZipHeader,
+		ZipDirectory:  trailer.ZipDirectory,
+		BoxHeader:     trailer.BoxHeader,
+		MKV:           trailer.MKV,
+		FEC:           trailer.FEC,
+		NameLength:    uint32(len(trailer.Name)),
+		AttachName:    trailer.Name,
+		VideoName:     trailer.VideoName,
+		Comment:       trailer.Comment,
+		ToolVersion:   trailer.ToolVersion,
+		CreatedAt:     trailer.CreatedAt,
+		ExtLength:     trailer.ExtLength,
+		Flags:         trailer.Flags,
+		Authenticated: trailer.Auth != nil,
+		Offsets:       offsets,
+	}
+	if trailer.VideoSHA256 != nil {
+		info.VideoSHA256 = hex.EncodeToString(trailer.VideoSHA256)
+	}
+	if trailer.AttachSHA256 != nil {
+		info.AttachSHA256 = hex.EncodeToString(trailer.AttachSHA256)
+	}
+	if trailer.AttachCRC32 != nil {
+		info.AttachCRC32 = formatCRC32(*trailer.AttachCRC32)
+	}
+	info.Attachments = make([]AttachmentEntry, len(trailer.Attachments))
+	for i, attachment := range trailer.Attachments {
+		info.Attachments[i] = AttachmentEntry{Name: attachment.Name, Size: attachment.Size, Offset: attachment.Offset}
+	}
+	if err := applyExtensionRecords(info, trailer.Records); err != nil {
+		debugInfo.ValidationError = msgf("trailer.bad_ext", err)
+		return nil, exitErrorf(EXIT_INVALID_FORMAT, "trailer.bad_ext_fmt", err)
+	}
+
+	// 标记目录归档附加文件
+	for _, index := range info.dirIndexes {
+		if index >= uint32(len(info.Attachments)) {
+			debugInfo.ValidationError = msgf("trailer.dir_index_out_of_range", index)
+			return nil, newError("trailer.dir_index_out_of_range_fmt", index)
+		}
+		info.Attachments[index].IsDir = true
+	}
+
+	// 应用附加文件属性（旧版文件没有该记录）
+	if info.fileAttrs != nil {
+		if err := applyFileAttrs(info.Attachments, info.fileAttrs); err != nil {
+			debugInfo.ValidationError = msgf("trailer.bad_attrs", err)
+			return nil, newError("trailer.bad_attrs_fmt", err)
+		}
+	}
+
+	// 应用MIME类型（旧版文件没有该记录）
+	if info.mimeTypes != nil {
+		if err := applyMimeTypes(info.Attachments, info.mimeTypes); err != nil {
+			debugInfo.ValidationError = msgf("trailer.bad_mime", err)
+			return nil, newError("trailer.bad_mime_fmt", err)
+		}
+	}
+
+	// 加密标志与加密参数必须同时存在
+	info.Encrypted = info.Flags&FLAG_ENCRYPTED != 0
+	if info.Encrypted != (info.Encryption != nil) {
+		debugInfo.ValidationError = msg("trailer.encryption_mismatch")
+		return nil, newError("trailer.encryption_mismatch_fmt")
+	}
+
+	// 压缩标志与压缩记录必须同时存在，且记录数量与附加文件一致
+	info.Compressed = info.Flags&FLAG_COMPRESSED != 0
+	if info.Compressed != (info.originalSizes != nil) {
+		debugInfo.ValidationError = msg("trailer.compression_mismatch")
+		return nil, newError("trailer.compression_mismatch_fmt")
+	}
+	if info.Compressed && len(info.originalSizes) != len(info.Attachments) {
+		debugInfo.ValidationError = msgf("trailer.compression_count_mismatch", len(info.originalSizes), len(info.Attachments))
+		return nil, newError("trailer.compression_count_mismatch_fmt", len(info.originalSizes), len(info.Attachments))
+	}
+
+	// 计算原始大小（压缩时以记录为准，否则由加密后大小推算）
+	for i := range info.Attachments {
+		info.Attachments[i].OriginalSize = info.Attachments[i].Size
+		if info.Compressed {
+			info.Attachments[i].OriginalSize = info.originalSizes[i]
+		} else if info.Encrypted {
+			info.Attachments[i].OriginalSize = plainSizeOf(info.Attachments[i].Size, info.Encryption.ChunkSize)
+		}
+	}
+
+	debugInfo.Attachments = info.Attachments
+	debugInfo.VideoName = info.VideoName
+	debugInfo.Comment = info.Comment
+	debugInfo.ToolVersion = formatToolVersion(info.ToolVersion)
+	debugInfo.CreatedAt = formatModTime(info.CreatedAt)
+
+	// 带认证记录时保留整段尾部元数据，指定 --auth-key 时据此校验
+	if info.Authenticated {
+		info.metadata = make([]byte, fileSize-metadataStart)
+		if err := readTrailerAt(mergedFile, info.metadata, metadataStart, debugInfo); err != nil {
+			return nil, exitErrorf(EXIT_INVALID_FORMAT, "auth.read_failed", err)
+		}
+	}
+	logDebugf("devlog.trailer_ok", len(info.Attachments), info.Encrypted, info.Compressed)
+
+	return info, nil
+}
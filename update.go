@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 用新的附加文件替换合并文件中的全部附加文件，视频数据保持不变
+func updateFiles(mergedPath string, attachPaths []string, opts UpdateOptions) error {
+	colorBlue.Println(msg("update.start"))
+
+	if len(attachPaths) == 0 {
+		return newError("error.no_attachments")
+	}
+
+	// 存在未完成的追加操作时文件尾部可能不完整
+	backupPath := appendBackupPath(mergedPath)
+	if _, err := os.Stat(backupPath); err == nil {
+		return newError("update.pending_append", backupPath)
+	}
+
+	mergedInfo, err := validateFile(mergedPath)
+	if err != nil {
+		return newError("error.merged_invalid", err)
+	}
+
+	mergedFile, err := os.OpenFile(mergedPath, os.O_RDWR, 0)
+	if err != nil {
+		return newError("error.open_merged_failed", err)
+	}
+	defer mergedFile.Close()
+
+	debugInfo := &DebugInfo{
+		FileSize:      mergedInfo.Size,
+		CalculatedPos: make(map[string]int64),
+	}
+	defer printDebugInfo(debugInfo)
+
+	// 结构校验失败时拒绝任何修改
+	trailer, err := loadTrailer(mergedFile, mergedInfo.Size, opts.StealthKey, debugInfo)
+	if err != nil {
+		return newError("update.structure_failed", err)
+	}
+	if trailer.ZipHeader > 0 {
+		return exitErrorf(EXIT_USAGE, "zip.update_unsupported")
+	}
+	if trailer.BoxHeader > 0 {
+		return exitErrorf(EXIT_USAGE, "mp4box.update_unsupported")
+	}
+	if trailer.MKV != nil {
+		return exitErrorf(EXIT_USAGE, "mkvattach.update_unsupported")
+	}
+	if err := checkRewriteAuth(trailer, opts.AuthKey, debugInfo); err != nil {
+		return err
+	}
+	// 改写后的尾部元数据不带纠错校验块
+	if trailer.FEC > 0 {
+		logWarnf("fec.dropped")
+	}
+
+	attachInfos, attachEntries, err := prepareAttachments(attachPaths, "", make(map[string]bool), false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(messageOutput, msg("common.merged_file_line"), mergedInfo.Name, formatFileSize(mergedInfo.Size))
+	fmt.Fprintf(messageOutput, msg("update.old_attachments"), len(trailer.Attachments), formatFileSize(int64(trailer.AttachSize)))
+	for i, attachInfo := range attachInfos {
+		fmt.Fprintf(messageOutput, "%s: %s → %s (%s)\n", attachLabel(attachEntries[i]), attachInfo.Name, attachEntries[i].Name, formatFileSize(attachInfo.Size))
+	}
+
+	// 加密文件需要原密码；新数据使用新的盐和nonce，避免与旧数据重复使用nonce
+	var aead cipher.AEAD
+	var encParams *EncryptionParams
+	if trailer.Encrypted {
+		password := opts.Password
+		if password == "" && opts.KeyFile == "" && trailer.Encryption.KDF == KDF_PBKDF2_SHA256 {
+			password, err = readPassword(msg("prompt.old_password"))
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := openEncryptedAttachments(mergedFile, trailer, password, opts.KeyFile); err != nil {
+			return err
+		}
+		encParams, aead, err = newEncryption(password, opts.KeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 截断到附加数据起始位置后写入新附加文件（保留原对齐填充）
+	videoSize := int64(trailer.VideoSize)
+	attachStart := attachStartOf(trailer)
+	if err := mergedFile.Truncate(attachStart); err != nil {
+		return newError("append.truncate_failed", err)
+	}
+	if _, err := mergedFile.Seek(attachStart, io.SeekStart); err != nil {
+		return newError("update.locate_failed", err)
+	}
+
+	attachHash := sha256.New()
+	attachCRC := crc32.New(crc32cTable)
+	writer := attachmentWriter{
+		ctx:       operationContext(),
+		dst:       io.MultiWriter(mergedFile, attachHash, attachCRC),
+		aead:      aead,
+		encParams: encParams,
+		compress:  trailer.Compressed,
+	}
+	offset := attachStart
+	for i, attachInfo := range attachInfos {
+		attachEntries[i].Offset = offset
+		colorCyan.Printf("\n%s (%d/%d): %s\n", attachCopyLabel(attachInfo), i+1, len(attachInfos), attachEntries[i].Name)
+		if err := writer.write(attachInfo, &attachEntries[i], i); err != nil {
+			return err
+		}
+		offset += int64(attachEntries[i].Size)
+	}
+
+	// 写入新尾部，保留视频校验值、视频名称和备注
+	colorCyan.Println(msg("common.writing_metadata"))
+	var videoSHA256 []byte
+	if trailer.VideoSHA256 != "" {
+		videoSHA256, _ = hex.DecodeString(trailer.VideoSHA256)
+	}
+	metadata, err := buildTrailer(&trailerSpec{
+		VideoSize:    videoSize,
+		Padding:      int64(trailer.Padding),
+		VideoName:    trailer.VideoName,
+		VideoSHA256:  videoSHA256,
+		AttachSHA256: attachHash.Sum(nil),
+		AttachCRC32:  attachCRC.Sum32(),
+		Comment:      trailer.Comment,
+		ToolVersion:  trailer.ToolVersion,
+		CreatedAt:    trailer.CreatedAt,
+		Attachments:  attachEntries,
+		Encryption:   encParams,
+		Compressed:   trailer.Compressed,
+		AuthKey:      opts.AuthKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	stealth := ""
+	if trailer.Stealth {
+		stealth = opts.StealthKey
+	}
+	if _, err := writeTrailer(mergedFile, metadata, stealth); err != nil {
+		return err
+	}
+	if err := mergedFile.Sync(); err != nil {
+		return newError("append.sync_merged_failed", err)
+	}
+
+	newSize, err := mergedFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return newError("append.position_failed", err)
+	}
+
+	absPath, err := filepath.Abs(mergedPath)
+	if err != nil {
+		absPath = mergedPath
+	}
+
+	colorGreen.Print(msg("update.done"))
+	fmt.Fprint(messageOutput, msg("update.stats"))
+	fmt.Fprintf(messageOutput, msg("update.stats_video"), formatFileSize(videoSize))
+	fmt.Fprintf(messageOutput, msg("update.stats_attach"), len(trailer.Attachments), formatFileSize(int64(trailer.AttachSize)), len(attachEntries), formatFileSize(offset-attachStart))
+	fmt.Fprintf(messageOutput, msg("common.total_size_change"), formatFileSize(mergedInfo.Size), formatFileSize(newSize))
+	colorCyan.Printf(msg("common.full_path"), absPath)
+	printResultPath(mergedPath)
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 写入后校验（--verify）：操作完成后重新读取输出，与写入时计算的校验值比较，
+// 发现写入过程中（如不稳定的 USB 设备）损坏的数据。校验失败时输出保留，退出码为 4
+
+// 合并后校验：重新打开输出并解析尾部元数据，按元数据中的位置重新读取视频和附加数据区，
+// 与写入时计算的 SHA-256 比较；分卷输出按拼接后的整体读取
+func verifyMergedOutput(ctx context.Context, progress progressSource, outputPath, stealthKey, videoSHA256, attachSHA256 string) error {
+	fmt.Fprintln(messageOutput)
+	logInfof(colorCyan, "verify_output.merge_start")
+
+	file, info, err := openMergedInput(outputPath)
+	if err != nil {
+		return exitErrorf(EXIT_IO, "verify_output.open_failed", outputPath, err)
+	}
+	defer file.Close()
+
+	debugInfo := &DebugInfo{FileSize: info.Size, CalculatedPos: make(map[string]int64)}
+	trailer, err := loadTrailer(file, info.Size, stealthKey, debugInfo)
+	if err != nil {
+		return exitErrorf(EXIT_INVALID_FORMAT, "verify_output.trailer_failed", err)
+	}
+	// 元数据中记录的校验值本身也可能写坏
+	if trailer.VideoSHA256 != videoSHA256 || trailer.AttachSHA256 != attachSHA256 {
+		return exitErrorf(EXIT_INVALID_FORMAT, "verify_output.trailer_mismatch")
+	}
+
+	// MKV 附件嵌入时视频区域按改写 Segment 大小字段前的原始内容计算
+	regions := []struct {
+		label    string
+		source   io.ReaderAt
+		start    int64
+		size     int64
+		expected string
+	}{
+		{msg("progress.verify_video"), videoSourceOf(file, trailer), 0, int64(trailer.VideoSize), videoSHA256},
+		{msg("progress.verify_attach"), file, attachStartOf(trailer), int64(trailer.AttachSize), attachSHA256},
+	}
+	for _, region := range regions {
+		actual, err := hashReader(ctx, progress, io.NewSectionReader(region.source, region.start, region.size), region.size, region.label)
+		if err != nil {
+			return exitErrorf(EXIT_IO, "verify_output.read_failed", outputPath, err)
+		}
+		if actual != region.expected {
+			return exitErrorf(EXIT_INVALID_FORMAT, "verify_output.data_mismatch", region.label, region.expected, actual)
+		}
+	}
+
+	logInfof(colorGreen, "verify_output.ok")
+	return nil
+}
+
+// 拆分后校验：重新读取提取出的文件。视频与元数据中的 SHA-256 比较（旧版文件没有时与提取时从合并文件计算的值比较）；
+// 元数据只记录整个附加数据区的校验值，附加文件改为与从合并文件重新解密、解压得到的数据比较。
+// 解包为目录的附加文件不校验
+func verifySplitOutputs(ctx context.Context, progress progressSource, mergedFile io.ReaderAt, trailer *TrailerInfo, aead cipher.AEAD, videoOutputPath, videoSHA256 string, attachOutputPaths []string, volumeSize int64) error {
+	fmt.Fprintln(messageOutput)
+	logInfof(colorCyan, "verify_output.split_start")
+
+	if videoOutputPath != "" {
+		expected := trailer.VideoSHA256
+		if expected == "" {
+			expected = videoSHA256
+		}
+		actual, err := hashFile(ctx, progress, videoOutputPath, msg("progress.verify_video"))
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return exitErrorf(EXIT_INVALID_FORMAT, "verify_output.file_mismatch", videoOutputPath, expected, actual)
+		}
+	}
+
+	for i, path := range attachOutputPaths {
+		entry := trailer.Attachments[i]
+		if entry.IsDir {
+			logWarnf("verify_output.dir_skipped", path)
+			continue
+		}
+		reader, err := openAttachmentReader(mergedFile, trailer, i, io.Discard, aead)
+		if err != nil {
+			return newError("split.read_attach_failed", entry.Name, err)
+		}
+		expected, err := hashReader(ctx, progress, reader, int64(entry.OriginalSize), msg("progress.verify_source"))
+		if err != nil {
+			return exitErrorf(EXIT_IO, "verify_output.source_failed", entry.Name, err)
+		}
+		// 写成分卷时按顺序拼接各分卷计算
+		var actual string
+		if volumeSize > 0 {
+			actual, err = hashFiles(ctx, progress, attachVolumePaths(path, int64(entry.OriginalSize), volumeSize), msg("progress.verify_attach"))
+		} else {
+			actual, err = hashFile(ctx, progress, path, msg("progress.verify_attach"))
+		}
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return exitErrorf(EXIT_INVALID_FORMAT, "verify_output.file_mismatch", path, expected, actual)
+		}
+	}
+
+	logInfof(colorGreen, "verify_output.ok")
+	return nil
+}
+
+// 计算文件的 SHA-256，显示校验进度
+func hashFile(ctx context.Context, progress progressSource, path, label string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", exitErrorf(EXIT_IO, "verify_output.open_failed", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", exitErrorf(EXIT_IO, "verify_output.open_failed", path, err)
+	}
+	sum, err := hashReader(ctx, progress, file, info.Size(), label)
+	if err != nil {
+		return "", exitErrorf(EXIT_IO, "verify_output.read_failed", path, err)
+	}
+	return sum, nil
+}
+
+func hashReader(ctx context.Context, progress progressSource, reader io.Reader, size int64, label string) (string, error) {
+	hash := sha256.New()
+	if _, err := copyWithProgress(ctx, nil, reader, size, progress.forCopy(label), hash); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// 构建信息，发布时通过 -ldflags 注入：
+//
+//	go build -ldflags "-X main.toolVersion=1.2.0 -X main.gitCommit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 源码直接构建时保留默认值，提交和时间会尽量从 Go 自带的 VCS 信息中补全
+var (
+	toolVersion = "dev"
+	gitCommit   = ""
+	buildDate   = ""
+)
+
+// 当前可读写的格式版本
+const CURRENT_FORMAT_VERSION = 3
+
+// 读取 Go 构建时记录的 VCS 信息
+func vcsBuildSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// 构建所用的 git 提交
+func buildCommit() string {
+	if gitCommit != "" {
+		return gitCommit
+	}
+	if revision := vcsBuildSetting("vcs.revision"); revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		if vcsBuildSetting("vcs.modified") == "true" {
+			revision += "-dirty"
+		}
+		return revision
+	}
+	return "unknown"
+}
+
+// 构建时间
+func buildTime() string {
+	if buildDate != "" {
+		return buildDate
+	}
+	if vcsTime := vcsBuildSetting("vcs.time"); vcsTime != "" {
+		return vcsTime
+	}
+	return "unknown"
+}
+
+// 一行版本摘要，用于 --version 和调试输出
+func versionSummary() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", toolVersion, buildCommit(), buildTime())
+}
+
+// 可识别的格式版本说明
+func supportedFormats() []string {
+	return []string{msgf("version.format_rw", CURRENT_FORMAT_VERSION)}
+}
+
+// 打印完整版本信息
+func printVersion() {
+	fmt.Fprintf(resultOutput, "🎬 video-merger-v3 %s\n", toolVersion)
+	fmt.Fprintf(resultOutput, msg("version.commit"), buildCommit())
+	fmt.Fprintf(resultOutput, msg("version.build_time"), buildTime())
+	fmt.Fprintf(resultOutput, "   🐹 Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprint(resultOutput, msg("version.formats"))
+	for _, format := range supportedFormats() {
+		fmt.Fprintf(resultOutput, "      - %s\n", format)
+	}
+}
+
+// 版本命令
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: helpText("help.version.short"),
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printVersion()
+	},
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// 分卷头魔术字节
+	VOLUME_MAGIC = "MVOLUME1"
+	// 分卷头长度：魔术字节(8) + 分卷序号(4) + 分卷数(4) + 数据起始偏移(8) + 数据总大小(8)
+	VOLUME_HEADER_LENGTH = 32
+	// 分卷大小下限 (1MB)
+	MIN_VOLUME_SIZE = 1024 * 1024
+)
+
+// 分卷文件名的编号后缀，如 .001
+var volumeSuffix = regexp.MustCompile(`\.\d{3}$`)
+
+// volumePart 一个分卷及其数据在整体中的位置
+type volumePart struct {
+	path   string
+	file   *os.File
+	offset int64
+	size   int64
+}
+
+// 第 index 个分卷（从0开始）的路径：output.mp4.001、output.mp4.002…
+func volumePartPath(basePath string, index int) string {
+	return fmt.Sprintf("%s.%03d", basePath, index+1)
+}
+
+// 解析 --volume-size，如 3900M、4G
+func parseVolumeSize(value string) (int64, error) {
+	size, ok := parseByteSize(value)
+	if !ok || size < MIN_VOLUME_SIZE {
+		return 0, exitErrorf(EXIT_USAGE, "volume.invalid_size", value, formatFileSize(MIN_VOLUME_SIZE))
+	}
+	return size, nil
+}
+
+// 显示各分卷对应的数据范围；非开发模式只显示分卷列表
+func printVolumeLayout(parts []volumePart) {
+	for i, part := range parts {
+		if devMode {
+			colorMagenta.Printf(msg("volume.layout_dev"), i+1, filepath.Base(part.path), part.offset, part.offset+part.size, formatFileSize(part.size))
+		} else {
+			fmt.Fprintf(messageOutput, msg("volume.layout"), i+1, filepath.Base(part.path), formatFileSize(part.size))
+		}
+	}
+}
+
+// volumeWriter 把合并结果按固定大小写成编号分卷，每个分卷以分卷头开始
+type volumeWriter struct {
+	basePath string
+	// 每个分卷可容纳的数据量（不含分卷头）
+	partData int64
+	parts    []volumePart
+	current  *os.File
+	total    int64
+}
+
+// 创建第一个分卷
+func createVolumes(basePath string, volumeSize int64) (*volumeWriter, error) {
+	writer := &volumeWriter{basePath: basePath, partData: volumeSize - VOLUME_HEADER_LENGTH}
+	return writer, writer.nextPart()
+}
+
+// 写完当前分卷并创建下一个；分卷数和总大小要等全部写完后回填
+func (w *volumeWriter) nextPart() error {
+	if w.current != nil {
+		if err := syncAndClose(w.current); err != nil {
+			return err
+		}
+		w.current = nil
+	}
+
+	path := volumePartPath(w.basePath, len(w.parts))
+	file, err := os.Create(path)
+	if err != nil {
+		return newError("volume.create_failed", path, err)
+	}
+	trackPartialOutput(path)
+
+	header := make([]byte, VOLUME_HEADER_LENGTH)
+	copy(header, VOLUME_MAGIC)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(w.parts)))
+	binary.LittleEndian.PutUint64(header[16:], uint64(w.total))
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return newError("volume.write_failed", path, err)
+	}
+
+	w.current = file
+	w.parts = append(w.parts, volumePart{path: path, offset: w.total})
+	return nil
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		part := &w.parts[len(w.parts)-1]
+		if part.size == w.partData {
+			if err := w.nextPart(); err != nil {
+				return written, err
+			}
+			continue
+		}
+		n, err := w.current.Write(p[:min(int64(len(p)), w.partData-part.size)])
+		part.size += int64(n)
+		w.total += int64(n)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// 写完最后一个分卷，回填每个分卷头中的分卷数和数据总大小
+func (w *volumeWriter) finish() error {
+	if err := syncAndClose(w.current); err != nil {
+		return err
+	}
+	w.current = nil
+
+	fields := make([]byte, 20)
+	binary.LittleEndian.PutUint32(fields, uint32(len(w.parts)))
+	binary.LittleEndian.PutUint64(fields[12:], uint64(w.total))
+	for _, part := range w.parts {
+		binary.LittleEndian.PutUint64(fields[4:], uint64(part.offset))
+		file, err := os.OpenFile(part.path, os.O_WRONLY, 0)
+		if err != nil {
+			return newError("volume.write_failed", part.path, err)
+		}
+		if _, err := file.WriteAt(fields, 12); err != nil {
+			file.Close()
+			return newError("volume.write_failed", part.path, err)
+		}
+		if err := syncAndClose(file); err != nil {
+			return err
+		}
+		finishPartialOutput(part.path)
+	}
+	return nil
+}
+
+// 出错时关闭当前分卷
+func (w *volumeWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.current.Close()
+}
+
+// 各分卷路径
+func (w *volumeWriter) paths() []string {
+	paths := make([]string, len(w.parts))
+	for i, part := range w.parts {
+		paths[i] = part.path
+	}
+	return paths
+}
+
+// volumeSet 拆分时把全部分卷拼接成一个整体读取
+type volumeSet struct {
+	basePath string
+	parts    []volumePart
+	total    int64
+}
+
+// 读取分卷头，返回序号、分卷数、数据起始偏移和总大小；不是分卷时 ok 为 false
+func readVolumeHeader(file *os.File) (index, count uint32, offset, total int64, ok bool) {
+	header := make([]byte, VOLUME_HEADER_LENGTH)
+	if _, err := io.ReadFull(io.NewSectionReader(file, 0, VOLUME_HEADER_LENGTH), header); err != nil {
+		return 0, 0, 0, 0, false
+	}
+	if string(header[:len(VOLUME_MAGIC)]) != VOLUME_MAGIC {
+		return 0, 0, 0, 0, false
+	}
+	return binary.LittleEndian.Uint32(header[8:]), binary.LittleEndian.Uint32(header[12:]),
+		int64(binary.LittleEndian.Uint64(header[16:])), int64(binary.LittleEndian.Uint64(header[24:])), true
+}
+
+// 按路径查找分卷：路径本身是分卷（.001、.002…），或路径不存在而 路径.001 存在。
+// 不是分卷时返回 nil；分卷缺失或分卷头不一致时返回错误
+func openVolumes(path string) (*volumeSet, error) {
+	basePath := path
+	if volumeSuffix.MatchString(path) {
+		basePath = strings.TrimSuffix(path, filepath.Ext(path))
+	} else if _, err := os.Stat(path); err == nil {
+		return nil, nil
+	}
+
+	first, err := os.Open(volumePartPath(basePath, 0))
+	if err != nil {
+		return nil, nil
+	}
+	_, count, _, total, ok := readVolumeHeader(first)
+	first.Close()
+	if !ok {
+		return nil, nil
+	}
+
+	set := &volumeSet{basePath: basePath, total: total}
+	var offset int64
+	for i := 0; i < int(count); i++ {
+		partPath := volumePartPath(basePath, i)
+		file, err := os.Open(partPath)
+		if err != nil {
+			set.Close()
+			return nil, exitErrorf(EXIT_IO, "volume.missing_part", partPath, count)
+		}
+		set.parts = append(set.parts, volumePart{path: partPath, file: file, offset: offset})
+
+		info, statErr := file.Stat()
+		index, partCount, partOffset, partTotal, ok := readVolumeHeader(file)
+		if statErr != nil || !ok || index != uint32(i) || partCount != count || partOffset != offset || partTotal != total || info.Size() < VOLUME_HEADER_LENGTH {
+			set.Close()
+			return nil, exitErrorf(EXIT_INVALID_FORMAT, "volume.bad_part", partPath)
+		}
+		set.parts[i].size = info.Size() - VOLUME_HEADER_LENGTH
+		offset += set.parts[i].size
+	}
+	if count == 0 || offset != total {
+		set.Close()
+		return nil, exitErrorf(EXIT_INVALID_FORMAT, "volume.size_mismatch", offset, total)
+	}
+	return set, nil
+}
+
+func (s *volumeSet) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for _, part := range s.parts {
+		if len(p) == 0 {
+			break
+		}
+		if off >= part.offset+part.size || off < part.offset {
+			continue
+		}
+		n, err := part.file.ReadAt(p[:min(int64(len(p)), part.offset+part.size-off)], VOLUME_HEADER_LENGTH+off-part.offset)
+		read += n
+		off += int64(n)
+		p = p[n:]
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+	}
+	if len(p) > 0 {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (s *volumeSet) Close() error {
+	for _, part := range s.parts {
+		part.file.Close()
+	}
+	return nil
+}
+
+// 拼接后的合并文件信息，名称为去掉编号后缀的基础文件名
+func (s *volumeSet) info() *FileInfo {
+	return &FileInfo{Name: filepath.Base(s.basePath), Size: s.total, Path: s.basePath}
+}
+
+// mergedReader 拆分时读取的合并文件：普通文件或拼接后的分卷
+type mergedReader interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// 打开待拆分的合并文件；路径指向分卷（或其基础名）时拼接全部分卷，http(s) 地址按需远程读取
+func openMergedInput(mergedPath string) (mergedReader, *FileInfo, error) {
+	if isRemotePath(mergedPath) {
+		return openRemoteFile(mergedPath)
+	}
+	volumes, err := openVolumes(mergedPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if volumes != nil {
+		colorCyan.Printf(msg("volume.reassembling"), len(volumes.parts))
+		printVolumeLayout(volumes.parts)
+		return volumes, volumes.info(), nil
+	}
+
+	mergedInfo, err := validateFile(mergedPath)
+	if err != nil {
+		return nil, nil, newError("error.merged_invalid_w", err)
+	}
+	mergedFile, err := os.Open(mergedPath)
+	if err != nil {
+		return nil, nil, exitErrorf(EXIT_IO, "error.open_merged_failed", err)
+	}
+	return mergedFile, mergedInfo, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// ZIP兼容模式：附加文件以不压缩（stored）的ZIP条目写在视频之后，
+// 中央目录和目录结束记录紧随其后，格式尾部元数据作为ZIP注释写在最后。
+// ZIP工具从文件末尾查找目录结束记录，因此输出同时是可播放的视频和有效的ZIP文件
+const (
+	ZIP_LOCAL_HEADER_SIGNATURE   = 0x04034b50
+	ZIP_CENTRAL_HEADER_SIGNATURE = 0x02014b50
+	ZIP_END_SIGNATURE            = 0x06054b50
+
+	// 各结构的固定部分长度（不含文件名和注释）
+	ZIP_LOCAL_HEADER_LENGTH   = 30
+	ZIP_CENTRAL_HEADER_LENGTH = 46
+	ZIP_END_LENGTH            = 22
+
+	// 解压所需版本 2.0，文件名为 UTF-8（通用标志位 11）
+	ZIP_VERSION   = 20
+	ZIP_FLAG_UTF8 = 0x0800
+
+	// 不使用 ZIP64 时大小和偏移的上限
+	ZIP_MAX_OFFSET = 0xFFFFFFFF
+	// ZIP注释（即格式尾部元数据）最大长度
+	ZIP_MAX_COMMENT_LENGTH = 0xFFFF
+)
+
+// zipEntry ZIP兼容模式下唯一的附加文件条目
+type zipEntry struct {
+	Name    string
+	Size    int64
+	CRC32   uint32
+	ModTime time.Time
+	// 本地文件头在输出中的位置
+	Offset int64
+}
+
+// ZIP结构长度：附加数据之前的本地文件头，之后的中央目录和目录结束记录（不含注释）
+func zipStructureLengths(name string) (header, directory int64) {
+	return int64(ZIP_LOCAL_HEADER_LENGTH + len(name)), int64(ZIP_CENTRAL_HEADER_LENGTH + len(name) + ZIP_END_LENGTH)
+}
+
+// 检查ZIP兼容模式的限制：只支持单个普通文件，不加密、不压缩、不分卷
+func checkZipCompatible(opts MergeOptions, videoInfo *FileInfo, attachInfos []*FileInfo, attachEntries []AttachmentEntry) error {
+	switch {
+	case opts.Password != "":
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--password")
+	case opts.KeyFile != "":
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--key-file")
+	case opts.Compress:
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--compress")
+	case opts.StealthKey != "":
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--stealth-key")
+	case opts.VolumeSize > 0:
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--volume-size")
+	case opts.Resume:
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--resume")
+	case opts.Align > 1:
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--align")
+	case opts.FEC:
+		return exitErrorf(EXIT_USAGE, "zip.conflict", "--fec")
+	}
+	if len(attachInfos) != 1 || attachInfos[0].IsDir || attachInfos[0].IsStdin {
+		return exitErrorf(EXIT_USAGE, "zip.single_file_only")
+	}
+	header, directory := zipStructureLengths(attachEntries[0].Name)
+	if videoInfo.Size+header+attachInfos[0].Size+directory > ZIP_MAX_OFFSET {
+		return exitErrorf(EXIT_USAGE, "zip.too_large", formatFileSize(ZIP_MAX_OFFSET))
+	}
+	return nil
+}
+
+// ZIP本地文件头需要在数据之前写入CRC-32（与格式校验用的 CRC32C 不同），先读一遍附加文件计算
+func zipChecksum(ctx context.Context, progress progressSource, attachInfo *FileInfo) (uint32, error) {
+	file, err := os.Open(attachInfo.Path)
+	if err != nil {
+		return 0, newError("merge.open_attach_failed", err)
+	}
+	defer file.Close()
+
+	sum := crc32.NewIEEE()
+	if _, err := copyWithProgress(ctx, io.Discard, file, attachInfo.Size, progress.forCopy(msg("progress.zip_checksum")), sum); err != nil {
+		return 0, newError("zip.checksum_failed", err)
+	}
+	return sum.Sum32(), nil
+}
+
+// 本地文件头（其后紧跟附加数据）
+func (e *zipEntry) localHeader() []byte {
+	date, clock := dosDateTime(e.ModTime)
+	buf := binary.LittleEndian.AppendUint32(nil, ZIP_LOCAL_HEADER_SIGNATURE)
+	buf = binary.LittleEndian.AppendUint16(buf, ZIP_VERSION)
+	buf = binary.LittleEndian.AppendUint16(buf, ZIP_FLAG_UTF8)
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // stored，不压缩
+	buf = binary.LittleEndian.AppendUint16(buf, clock)
+	buf = binary.LittleEndian.AppendUint16(buf, date)
+	buf = binary.LittleEndian.AppendUint32(buf, e.CRC32)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Size))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Size))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(e.Name)))
+	buf = binary.LittleEndian.AppendUint16(buf, 0)
+	return append(buf, e.Name...)
+}
+
+// 中央目录和目录结束记录；注释长度为随后写入的尾部元数据长度
+func (e *zipEntry) directory(commentLength int) ([]byte, error) {
+	if commentLength > ZIP_MAX_COMMENT_LENGTH {
+		return nil, newError("zip.metadata_too_long", commentLength, ZIP_MAX_COMMENT_LENGTH)
+	}
+	date, clock := dosDateTime(e.ModTime)
+	buf := binary.LittleEndian.AppendUint32(nil, ZIP_CENTRAL_HEADER_SIGNATURE)
+	buf = binary.LittleEndian.AppendUint16(buf, ZIP_VERSION)
+	buf = binary.LittleEndian.AppendUint16(buf, ZIP_VERSION)
+	buf = binary.LittleEndian.AppendUint16(buf, ZIP_FLAG_UTF8)
+	buf = binary.LittleEndian.AppendUint16(buf, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, clock)
+	buf = binary.LittleEndian.AppendUint16(buf, date)
+	buf = binary.LittleEndian.AppendUint32(buf, e.CRC32)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Size))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Size))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(e.Name)))
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 扩展字段长度
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 文件注释长度
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 起始磁盘号
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 内部属性
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // 外部属性
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Offset))
+	buf = append(buf, e.Name...)
+
+	directoryOffset := e.Offset + int64(ZIP_LOCAL_HEADER_LENGTH+len(e.Name)) + e.Size
+	directorySize := len(buf)
+	buf = binary.LittleEndian.AppendUint32(buf, ZIP_END_SIGNATURE)
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 本磁盘号
+	buf = binary.LittleEndian.AppendUint16(buf, 0) // 中央目录起始磁盘号
+	buf = binary.LittleEndian.AppendUint16(buf, 1)
+	buf = binary.LittleEndian.AppendUint16(buf, 1)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(directorySize))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(directoryOffset))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(commentLength))
+	return buf, nil
+}
+
+// 转换为 MS-DOS 日期和时间（本地时间，精度2秒，早于1980年时按1980年记录）
+func dosDateTime(t time.Time) (date, clock uint16) {
+	t = t.Local()
+	if t.Year() < 1980 {
+		return 1<<5 | 1, 0
+	}
+	date = uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+	clock = uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2)
+	return date, clock
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// ZIP兼容模式的输出用 ZIP 读取器和 split 取出的附加文件完全相同
+func TestZipCompatibleRoundTrip(t *testing.T) {
+	video := bytes.Repeat([]byte("video frame "), 10000)
+	attach := bytes.Repeat([]byte("附件内容 attachment "), 5000)
+	videoPath := writeTempFile(t, "v.mp4", video)
+	attachPath := writeTempFile(t, "文档.txt", attach)
+	outputPath := filepath.Join(t.TempDir(), "out.mp4")
+
+	if err := mergeFiles(context.Background(), videoPath, []string{attachPath}, outputPath, MergeOptions{ZipCompatible: true, Progress: noopProgress{}}); err != nil {
+		t.Fatalf("mergeFiles: %v", err)
+	}
+	merged, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(merged, video) {
+		t.Fatal("output does not start with the video")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(merged), int64(len(merged)))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "文档.txt" || archive.File[0].Method != zip.Store {
+		t.Fatalf("zip entries = %+v", archive.File)
+	}
+	entry, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 读到结尾时校验 CRC32
+	fromZip, err := io.ReadAll(entry)
+	entry.Close()
+	if err != nil || !bytes.Equal(fromZip, attach) {
+		t.Errorf("zip entry: %d bytes, %v", len(fromZip), err)
+	}
+
+	outputDir := t.TempDir()
+	if err := splitFiles(context.Background(), outputPath, outputDir, SplitOptions{Progress: noopProgress{}}); err != nil {
+		t.Fatalf("splitFiles: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "文档.txt")); err != nil || !bytes.Equal(data, attach) {
+		t.Errorf("split attachment: %d bytes, %v", len(data), err)
+	}
+	if data, err := os.ReadFile(filepath.Join(outputDir, "v.mp4")); err != nil || !bytes.Equal(data, video) {
+		t.Errorf("split video: %d bytes, %v", len(data), err)
+	}
+
+	unzip, err := exec.LookPath("unzip")
+	if err != nil {
+		t.Skip("unzip not installed")
+	}
+	fromUnzip, err := exec.Command(unzip, "-p", outputPath).Output()
+	if err != nil || !bytes.Equal(fromUnzip, attach) {
+		t.Errorf("unzip -p: %d bytes, %v", len(fromUnzip), err)
+	}
+}